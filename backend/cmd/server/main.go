@@ -4,15 +4,18 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"timesync/backend/internal/config"
 	"timesync/backend/internal/httpapi"
 	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/scheduler"
 	"timesync/backend/internal/store"
 )
 
@@ -58,6 +61,9 @@ func run(ctx context.Context, logger *slog.Logger) error {
 
 	api := httpapi.New(st, mailerSvc, settings, logger)
 
+	stopJobs := startScheduler(ctx, cfg, st, mailerSvc, api, logger)
+	defer stopJobs()
+
 	addr := fmt.Sprintf(":%d", cfg.Port)
 	srv := newServer(addr, api.Handler())
 
@@ -88,6 +94,51 @@ func run(ctx context.Context, logger *slog.Logger) error {
 	return nil
 }
 
+// startScheduler runs background jobs until ctx is cancelled. The returned
+// func stops them and waits, so jobs never outlive the store.
+func startScheduler(ctx context.Context, cfg config.Config, st *store.Store, mailerSvc mailer.Mailer, api *httpapi.API, logger *slog.Logger) func() {
+	if !cfg.SchedulerEnabled {
+		return func() {}
+	}
+
+	jobs := scheduler.CleanupJobs(
+		st.Querier(),
+		time.Duration(cfg.CleanupIntervalMinutes)*time.Minute,
+		time.Duration(cfg.CleanupRetentionHours)*time.Hour,
+		logger,
+	)
+	if cfg.LinkSigningKey != "" {
+		jobs = append(jobs, scheduler.ReminderJob(
+			st.Querier(),
+			mailerSvc,
+			api.ResumeSharingURL,
+			time.Duration(cfg.ReminderPollMinutes)*time.Minute,
+			logger,
+		))
+	} else {
+		logger.Warn("LINK_SIGNING_KEY is not set; sharing reminders are disabled")
+	}
+	jobs = append(jobs, scheduler.DomainVerificationJob(
+		st.Querier(),
+		net.DefaultResolver,
+		time.Duration(cfg.DomainRecheckMinutes)*time.Minute,
+		logger,
+	))
+	leader := scheduler.NewPostgresLeader(st.Pool, scheduler.LeaderLockKey)
+	sched := scheduler.New(leader, time.Duration(cfg.SchedulerPollSeconds)*time.Second, logger, jobs...)
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sched.Run(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
 var newSMTP = mailer.NewSMTP
 
 func newMailer(cfg config.Config) (mailer.Mailer, error) {
@@ -119,8 +170,25 @@ func buildSettings(cfg config.Config) httpapi.Settings {
 		VerifyCodeLock:         time.Duration(cfg.VerifyCodeLockMinutes) * time.Minute,
 		VerifyCodeIPLimit:      cfg.VerifyCodeIPLimit,
 		VerifyCodeIPWindow:     time.Duration(cfg.VerifyCodeIPWindow) * time.Minute,
+		VerifyLinkIPLimit:      cfg.VerifyLinkIPLimit,
+		VerifyLinkIPWindow:     time.Duration(cfg.VerifyLinkIPWindow) * time.Minute,
+		PairingRedeemIPLimit:   cfg.PairingRedeemIPLimit,
+		PairingRedeemIPWindow:  time.Duration(cfg.PairingRedeemIPWindow) * time.Minute,
+		SSOStartIPLimit:        cfg.SSOStartIPLimit,
+		SSOStartIPWindow:       time.Duration(cfg.SSOStartIPWindow) * time.Minute,
+		SSOCompleteIPLimit:     cfg.SSOCompleteIPLimit,
+		SSOCompleteIPWindow:    time.Duration(cfg.SSOCompleteIPWindow) * time.Minute,
 		RefreshDeviceLimit:     cfg.RefreshDeviceLimit,
 		RefreshDeviceWindow:    time.Duration(cfg.RefreshDeviceWindow) * time.Minute,
+		DomainCheckUserLimit:   cfg.DomainCheckUserLimit,
+		DomainCheckUserWindow:  time.Duration(cfg.DomainCheckUserWindow) * time.Minute,
+		InviteTTL:              time.Duration(cfg.InviteTTLHours) * time.Hour,
+		PublicBaseURL:          cfg.PublicBaseURL,
+		LinkSigningKey:         cfg.LinkSigningKey,
+		ResumeLinkTTL:          time.Duration(cfg.ResumeLinkTTLHours) * time.Hour,
+		RateLimitBackend:       cfg.RateLimitBackend,
+		FreeMailDomains:        cfg.FreeMailDomains,
+		FreeMailAllowedDomains: cfg.FreeMailAllowedDomains,
 	}
 }
 
@@ -84,8 +84,25 @@ func TestBuildSettings(t *testing.T) {
 		VerifyCodeLockMinutes:  13,
 		VerifyCodeIPLimit:      4,
 		VerifyCodeIPWindow:     14,
+		VerifyLinkIPLimit:      15,
+		VerifyLinkIPWindow:     16,
+		PairingRedeemIPLimit:   17,
+		PairingRedeemIPWindow:  18,
+		SSOStartIPLimit:        19,
+		SSOStartIPWindow:       20,
+		SSOCompleteIPLimit:     21,
+		SSOCompleteIPWindow:    22,
 		RefreshDeviceLimit:     5,
 		RefreshDeviceWindow:    6,
+		DomainCheckUserLimit:   23,
+		DomainCheckUserWindow:  24,
+		InviteTTLHours:         48,
+		PublicBaseURL:          "https://api.example.com",
+		LinkSigningKey:         "secret",
+		ResumeLinkTTLHours:     24,
+		RateLimitBackend:       "postgres",
+		FreeMailDomains:        []string{"mail.example"},
+		FreeMailAllowedDomains: []string{"gmx.net"},
 	}
 
 	settings := buildSettings(cfg)
@@ -107,12 +124,42 @@ func TestBuildSettings(t *testing.T) {
 	if settings.RequestCodeIPWindow != 8*time.Minute {
 		t.Fatalf("unexpected request code ip window: %v", settings.RequestCodeIPWindow)
 	}
+	if settings.VerifyLinkIPLimit != 15 || settings.VerifyLinkIPWindow != 16*time.Minute {
+		t.Fatalf("unexpected verify link ip limit: %d/%v", settings.VerifyLinkIPLimit, settings.VerifyLinkIPWindow)
+	}
+	if settings.PairingRedeemIPLimit != 17 || settings.PairingRedeemIPWindow != 18*time.Minute {
+		t.Fatalf("unexpected pairing redeem ip limit: %d/%v", settings.PairingRedeemIPLimit, settings.PairingRedeemIPWindow)
+	}
+	if settings.SSOStartIPLimit != 19 || settings.SSOStartIPWindow != 20*time.Minute {
+		t.Fatalf("unexpected sso start ip limit: %d/%v", settings.SSOStartIPLimit, settings.SSOStartIPWindow)
+	}
+	if settings.SSOCompleteIPLimit != 21 || settings.SSOCompleteIPWindow != 22*time.Minute {
+		t.Fatalf("unexpected sso complete ip limit: %d/%v", settings.SSOCompleteIPLimit, settings.SSOCompleteIPWindow)
+	}
 	if settings.VerifyCodeLock != 13*time.Minute {
 		t.Fatalf("unexpected verify code lock: %v", settings.VerifyCodeLock)
 	}
 	if settings.RefreshDeviceWindow != 6*time.Minute {
 		t.Fatalf("unexpected refresh device window: %v", settings.RefreshDeviceWindow)
 	}
+	if settings.DomainCheckUserLimit != 23 || settings.DomainCheckUserWindow != 24*time.Minute {
+		t.Fatalf("unexpected domain check user limit: %d/%v", settings.DomainCheckUserLimit, settings.DomainCheckUserWindow)
+	}
+	if settings.InviteTTL != 48*time.Hour {
+		t.Fatalf("unexpected invite ttl: %v", settings.InviteTTL)
+	}
+	if settings.PublicBaseURL != "https://api.example.com" || settings.LinkSigningKey != "secret" {
+		t.Fatalf("unexpected link settings: %q/%q", settings.PublicBaseURL, settings.LinkSigningKey)
+	}
+	if settings.ResumeLinkTTL != 24*time.Hour {
+		t.Fatalf("unexpected resume link ttl: %v", settings.ResumeLinkTTL)
+	}
+	if settings.RateLimitBackend != "postgres" {
+		t.Fatalf("unexpected rate limit backend: %q", settings.RateLimitBackend)
+	}
+	if len(settings.FreeMailDomains) != 1 || len(settings.FreeMailAllowedDomains) != 1 {
+		t.Fatalf("unexpected free mail settings: %v/%v", settings.FreeMailDomains, settings.FreeMailAllowedDomains)
+	}
 }
 
 func TestNewMailerUsesLogMailer(t *testing.T) {
@@ -248,6 +295,11 @@ func TestRunShutdownError(t *testing.T) {
 	}
 }
 
+func TestStartSchedulerDisabled(t *testing.T) {
+	stop := startScheduler(context.Background(), config.Config{}, &store.Store{}, &mailer.LogMailer{}, nil, slog.Default())
+	stop()
+}
+
 func TestBuildServer(t *testing.T) {
 	srv := buildServer(":8080", http.NewServeMux())
 	if srv.Addr != ":8080" {
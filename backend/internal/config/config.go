@@ -8,29 +8,52 @@ import (
 )
 
 type Config struct {
-	DatabaseURL            string `env:"DATABASE_URL,required"`
-	Port                   int    `env:"PORT" envDefault:"8080"`
-	SMTPHost               string `env:"SMTP_HOST"`
-	SMTPPort               int    `env:"SMTP_PORT" envDefault:"587"`
-	SMTPUser               string `env:"SMTP_USER"`
-	SMTPPass               string `env:"SMTP_PASS"`
-	SMTPFrom               string `env:"SMTP_FROM" envDefault:"no-reply@timesync"`
-	AccessTTLMinutes       int    `env:"ACCESS_TTL_MINUTES" envDefault:"30"`
-	RefreshTTLHours        int    `env:"REFRESH_TTL_HOURS" envDefault:"720"`
-	CodeTTLMinutes         int    `env:"CODE_TTL_MINUTES" envDefault:"10"`
-	RefreshGraceSeconds    int    `env:"REFRESH_GRACE_SECONDS" envDefault:"30"`
-	TeamSizeLimit          int    `env:"TEAM_SIZE_LIMIT" envDefault:"30"`
-	RequestCodeEmailLimit  int    `env:"REQUEST_CODE_EMAIL_LIMIT" envDefault:"3"`
-	RequestCodeEmailWindow int    `env:"REQUEST_CODE_EMAIL_WINDOW_MINUTES" envDefault:"15"`
-	RequestCodeIPLimit     int    `env:"REQUEST_CODE_IP_LIMIT" envDefault:"10"`
-	RequestCodeIPWindow    int    `env:"REQUEST_CODE_IP_WINDOW_MINUTES" envDefault:"60"`
-	VerifyCodeEmailLimit   int    `env:"VERIFY_CODE_EMAIL_LIMIT" envDefault:"5"`
-	VerifyCodeEmailWindow  int    `env:"VERIFY_CODE_EMAIL_WINDOW_MINUTES" envDefault:"15"`
-	VerifyCodeLockMinutes  int    `env:"VERIFY_CODE_LOCK_MINUTES" envDefault:"15"`
-	VerifyCodeIPLimit      int    `env:"VERIFY_CODE_IP_LIMIT" envDefault:"20"`
-	VerifyCodeIPWindow     int    `env:"VERIFY_CODE_IP_WINDOW_MINUTES" envDefault:"60"`
-	RefreshDeviceLimit     int    `env:"REFRESH_DEVICE_LIMIT" envDefault:"10"`
-	RefreshDeviceWindow    int    `env:"REFRESH_DEVICE_WINDOW_MINUTES" envDefault:"1"`
+	DatabaseURL            string   `env:"DATABASE_URL,required"`
+	Port                   int      `env:"PORT" envDefault:"8080"`
+	SMTPHost               string   `env:"SMTP_HOST"`
+	SMTPPort               int      `env:"SMTP_PORT" envDefault:"587"`
+	SMTPUser               string   `env:"SMTP_USER"`
+	SMTPPass               string   `env:"SMTP_PASS"`
+	SMTPFrom               string   `env:"SMTP_FROM" envDefault:"no-reply@timesync"`
+	AccessTTLMinutes       int      `env:"ACCESS_TTL_MINUTES" envDefault:"30"`
+	RefreshTTLHours        int      `env:"REFRESH_TTL_HOURS" envDefault:"720"`
+	CodeTTLMinutes         int      `env:"CODE_TTL_MINUTES" envDefault:"10"`
+	RefreshGraceSeconds    int      `env:"REFRESH_GRACE_SECONDS" envDefault:"30"`
+	TeamSizeLimit          int      `env:"TEAM_SIZE_LIMIT" envDefault:"30"`
+	RequestCodeEmailLimit  int      `env:"REQUEST_CODE_EMAIL_LIMIT" envDefault:"3"`
+	RequestCodeEmailWindow int      `env:"REQUEST_CODE_EMAIL_WINDOW_MINUTES" envDefault:"15"`
+	RequestCodeIPLimit     int      `env:"REQUEST_CODE_IP_LIMIT" envDefault:"10"`
+	RequestCodeIPWindow    int      `env:"REQUEST_CODE_IP_WINDOW_MINUTES" envDefault:"60"`
+	VerifyCodeEmailLimit   int      `env:"VERIFY_CODE_EMAIL_LIMIT" envDefault:"5"`
+	VerifyCodeEmailWindow  int      `env:"VERIFY_CODE_EMAIL_WINDOW_MINUTES" envDefault:"15"`
+	VerifyCodeLockMinutes  int      `env:"VERIFY_CODE_LOCK_MINUTES" envDefault:"15"`
+	VerifyCodeIPLimit      int      `env:"VERIFY_CODE_IP_LIMIT" envDefault:"20"`
+	VerifyCodeIPWindow     int      `env:"VERIFY_CODE_IP_WINDOW_MINUTES" envDefault:"60"`
+	VerifyLinkIPLimit      int      `env:"VERIFY_LINK_IP_LIMIT" envDefault:"20"`
+	VerifyLinkIPWindow     int      `env:"VERIFY_LINK_IP_WINDOW_MINUTES" envDefault:"60"`
+	PairingRedeemIPLimit   int      `env:"PAIRING_REDEEM_IP_LIMIT" envDefault:"20"`
+	PairingRedeemIPWindow  int      `env:"PAIRING_REDEEM_IP_WINDOW_MINUTES" envDefault:"60"`
+	SSOStartIPLimit        int      `env:"SSO_START_IP_LIMIT" envDefault:"10"`
+	SSOStartIPWindow       int      `env:"SSO_START_IP_WINDOW_MINUTES" envDefault:"60"`
+	SSOCompleteIPLimit     int      `env:"SSO_COMPLETE_IP_LIMIT" envDefault:"20"`
+	SSOCompleteIPWindow    int      `env:"SSO_COMPLETE_IP_WINDOW_MINUTES" envDefault:"60"`
+	RefreshDeviceLimit     int      `env:"REFRESH_DEVICE_LIMIT" envDefault:"10"`
+	RefreshDeviceWindow    int      `env:"REFRESH_DEVICE_WINDOW_MINUTES" envDefault:"1"`
+	DomainCheckUserLimit   int      `env:"DOMAIN_CHECK_USER_LIMIT" envDefault:"20"`
+	DomainCheckUserWindow  int      `env:"DOMAIN_CHECK_USER_WINDOW_MINUTES" envDefault:"60"`
+	InviteTTLHours         int      `env:"INVITE_TTL_HOURS" envDefault:"72"`
+	SchedulerEnabled       bool     `env:"SCHEDULER_ENABLED" envDefault:"true"`
+	SchedulerPollSeconds   int      `env:"SCHEDULER_POLL_SECONDS" envDefault:"30"`
+	CleanupIntervalMinutes int      `env:"CLEANUP_INTERVAL_MINUTES" envDefault:"60"`
+	CleanupRetentionHours  int      `env:"CLEANUP_RETENTION_HOURS" envDefault:"168"`
+	ReminderPollMinutes    int      `env:"REMINDER_POLL_MINUTES" envDefault:"60"`
+	DomainRecheckMinutes   int      `env:"DOMAIN_RECHECK_POLL_MINUTES" envDefault:"60"`
+	PublicBaseURL          string   `env:"PUBLIC_BASE_URL" envDefault:"http://localhost:8080"`
+	LinkSigningKey         string   `env:"LINK_SIGNING_KEY"`
+	ResumeLinkTTLHours     int      `env:"RESUME_LINK_TTL_HOURS" envDefault:"72"`
+	RateLimitBackend       string   `env:"RATE_LIMIT_BACKEND" envDefault:"memory"`
+	FreeMailDomains        []string `env:"FREE_MAIL_DOMAINS" envSeparator:","`
+	FreeMailAllowedDomains []string `env:"FREE_MAIL_ALLOWED_DOMAINS" envSeparator:","`
 }
 
 func Load() (Config, error) {
@@ -42,5 +65,8 @@ func Load() (Config, error) {
 	if cfg.DatabaseURL == "" {
 		return Config{}, errors.New("DATABASE_URL is required")
 	}
+	if cfg.RateLimitBackend != "memory" && cfg.RateLimitBackend != "postgres" {
+		return Config{}, errors.New("RATE_LIMIT_BACKEND must be memory or postgres")
+	}
 	return cfg, nil
 }
@@ -21,6 +21,39 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.TeamSizeLimit != 30 {
 		t.Fatalf("expected default team size limit 30, got %d", cfg.TeamSizeLimit)
 	}
+	if cfg.InviteTTLHours != 72 {
+		t.Fatalf("expected default invite ttl hours 72, got %d", cfg.InviteTTLHours)
+	}
+	if cfg.ReminderPollMinutes != 60 || cfg.ResumeLinkTTLHours != 72 {
+		t.Fatalf("unexpected reminder defaults: %d/%d", cfg.ReminderPollMinutes, cfg.ResumeLinkTTLHours)
+	}
+	if cfg.DomainRecheckMinutes != 60 {
+		t.Fatalf("expected default domain recheck poll 60, got %d", cfg.DomainRecheckMinutes)
+	}
+	if cfg.VerifyLinkIPLimit != 20 || cfg.VerifyLinkIPWindow != 60 {
+		t.Fatalf("unexpected verify link ip defaults: %d/%d", cfg.VerifyLinkIPLimit, cfg.VerifyLinkIPWindow)
+	}
+	if cfg.PairingRedeemIPLimit != 20 || cfg.PairingRedeemIPWindow != 60 {
+		t.Fatalf("unexpected pairing redeem ip defaults: %d/%d", cfg.PairingRedeemIPLimit, cfg.PairingRedeemIPWindow)
+	}
+	if cfg.DomainCheckUserLimit != 20 || cfg.DomainCheckUserWindow != 60 {
+		t.Fatalf("unexpected domain check user defaults: %d/%d", cfg.DomainCheckUserLimit, cfg.DomainCheckUserWindow)
+	}
+	if cfg.SSOStartIPLimit != 10 || cfg.SSOCompleteIPLimit != 20 {
+		t.Fatalf("unexpected sso ip defaults: %d/%d", cfg.SSOStartIPLimit, cfg.SSOCompleteIPLimit)
+	}
+	if cfg.PublicBaseURL != "http://localhost:8080" {
+		t.Fatalf("unexpected public base url: %q", cfg.PublicBaseURL)
+	}
+	if !cfg.SchedulerEnabled {
+		t.Fatal("expected scheduler to be enabled by default")
+	}
+	if cfg.RateLimitBackend != "memory" {
+		t.Fatalf("expected memory rate limit backend, got %q", cfg.RateLimitBackend)
+	}
+	if cfg.SchedulerPollSeconds != 30 || cfg.CleanupIntervalMinutes != 60 || cfg.CleanupRetentionHours != 168 {
+		t.Fatalf("unexpected scheduler defaults: %d/%d/%d", cfg.SchedulerPollSeconds, cfg.CleanupIntervalMinutes, cfg.CleanupRetentionHours)
+	}
 }
 
 func TestLoadOverrides(t *testing.T) {
@@ -29,6 +62,10 @@ func TestLoadOverrides(t *testing.T) {
 	t.Setenv("SMTP_HOST", "smtp.example")
 	t.Setenv("SMTP_PORT", "2525")
 	t.Setenv("ACCESS_TTL_MINUTES", "15")
+	t.Setenv("SCHEDULER_ENABLED", "false")
+	t.Setenv("RATE_LIMIT_BACKEND", "postgres")
+	t.Setenv("FREE_MAIL_DOMAINS", "mail.example,post.example")
+	t.Setenv("FREE_MAIL_ALLOWED_DOMAINS", "gmx.net")
 
 	cfg, err := Load()
 	if err != nil {
@@ -46,6 +83,18 @@ func TestLoadOverrides(t *testing.T) {
 	if cfg.AccessTTLMinutes != 15 {
 		t.Fatalf("expected access ttl minutes 15, got %d", cfg.AccessTTLMinutes)
 	}
+	if cfg.SchedulerEnabled {
+		t.Fatal("expected scheduler to be disabled")
+	}
+	if cfg.RateLimitBackend != "postgres" {
+		t.Fatalf("expected postgres rate limit backend, got %q", cfg.RateLimitBackend)
+	}
+	if len(cfg.FreeMailDomains) != 2 || cfg.FreeMailDomains[1] != "post.example" {
+		t.Fatalf("unexpected free mail domains: %v", cfg.FreeMailDomains)
+	}
+	if len(cfg.FreeMailAllowedDomains) != 1 || cfg.FreeMailAllowedDomains[0] != "gmx.net" {
+		t.Fatalf("unexpected allowed free mail domains: %v", cfg.FreeMailAllowedDomains)
+	}
 }
 
 func TestLoadRequiresDatabaseURL(t *testing.T) {
@@ -64,3 +113,12 @@ func TestLoadRejectsInvalidNumbers(t *testing.T) {
 		t.Fatal("expected Load to fail with invalid PORT")
 	}
 }
+
+func TestLoadRejectsUnknownRateLimitBackend(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://example")
+	t.Setenv("RATE_LIMIT_BACKEND", "redis")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected Load to fail with unknown RATE_LIMIT_BACKEND")
+	}
+}
@@ -0,0 +1,70 @@
+// Package domainverify proves control of an email domain through a DNS TXT
+// record carrying a generated token.
+package domainverify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"net"
+	"strings"
+)
+
+const (
+	// RecordLabel is prepended to the domain so the token lives in its own
+	// name and doesn't crowd the apex TXT records used for SPF and the like.
+	RecordLabel = "_timesync"
+	// ValuePrefix marks our record among others at the same name.
+	ValuePrefix = "timesync-domain-verification="
+
+	tokenBytes = 20
+)
+
+// Resolver looks up TXT records. *net.Resolver satisfies it; tests pass a
+// fake.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+var _ Resolver = net.DefaultResolver
+
+// NewToken returns a random token that is safe to publish in DNS.
+func NewToken() (string, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf)), nil
+}
+
+// RecordName is where the TXT record for domain must be published.
+func RecordName(domain string) string {
+	return RecordLabel + "." + domain
+}
+
+// RecordValue is the TXT record contents proving control with token.
+func RecordValue(token string) string {
+	return ValuePrefix + token
+}
+
+// Check reports whether the record for token is published at domain. A name
+// that doesn't exist is a definite no; any other lookup failure is returned
+// so callers can retry instead of treating a DNS outage as lost control.
+func Check(ctx context.Context, r Resolver, domain, token string) (bool, error) {
+	records, err := r.LookupTXT(ctx, RecordName(domain))
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	want := RecordValue(token)
+	for _, record := range records {
+		if strings.TrimSpace(record) == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
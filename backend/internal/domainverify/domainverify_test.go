@@ -0,0 +1,78 @@
+package domainverify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+type fakeResolver map[string][]string
+
+func (f fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := f[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+type failingResolver struct{}
+
+func (failingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+}
+
+func TestNewToken(t *testing.T) {
+	first, err := NewToken()
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	second, err := NewToken()
+	if err != nil {
+		t.Fatalf("token error: %v", err)
+	}
+	if first == second {
+		t.Fatalf("expected distinct tokens, got %q twice", first)
+	}
+	if len(first) != 32 || strings.ToLower(first) != first {
+		t.Fatalf("unexpected token shape: %q", first)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	resolver := fakeResolver{
+		"_timesync.acme.com":  {"v=spf1 -all", " timesync-domain-verification=abc "},
+		"_timesync.other.com": {"timesync-domain-verification=zzz"},
+	}
+
+	tests := []struct {
+		name   string
+		domain string
+		want   bool
+	}{
+		{name: "published", domain: "acme.com", want: true},
+		{name: "other token", domain: "other.com", want: false},
+		{name: "no record", domain: "missing.com", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Check(context.Background(), resolver, tt.domain, "abc")
+			if err != nil {
+				t.Fatalf("check error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckReturnsLookupFailures(t *testing.T) {
+	ok, err := Check(context.Background(), failingResolver{}, "acme.com", "abc")
+	var dnsErr *net.DNSError
+	if ok || !errors.As(err, &dnsErr) {
+		t.Fatalf("expected dns error, got %v, %v", ok, err)
+	}
+}
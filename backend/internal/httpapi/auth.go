@@ -22,22 +22,25 @@ type requestCodeRequest struct {
 }
 
 type verifyCodeRequest struct {
-	Email string `json:"email"`
-	Code  string `json:"code"`
+	Email      string `json:"email"`
+	Code       string `json:"code"`
+	InviteCode string `json:"invite_code,omitempty"`
+	DeviceName string `json:"device_name,omitempty"`
+	Platform   string `json:"platform,omitempty"`
+	AppVersion string `json:"app_version,omitempty"`
 }
 
 type refreshRequest struct {
 	RefreshToken string `json:"refresh_token"`
+	AppVersion   string `json:"app_version,omitempty"`
 }
 
 type authResponse struct {
-	AccessToken      string        `json:"access_token"`
-	AccessExpiresAt  time.Time     `json:"access_expires_at"`
-	RefreshToken     string        `json:"refresh_token"`
-	RefreshExpiresAt time.Time     `json:"refresh_expires_at"`
-	User             *userResponse `json:"user,omitempty"`
-	Team             *teamResponse `json:"team,omitempty"`
-	Role             string        `json:"role,omitempty"`
+	AccessToken      string    `json:"access_token"`
+	AccessExpiresAt  time.Time `json:"access_expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	bootstrapResponse
 }
 
 type userResponse struct {
@@ -46,9 +49,12 @@ type userResponse struct {
 }
 
 type teamResponse struct {
-	ID     string `json:"id"`
-	Domain string `json:"domain"`
-	Name   string `json:"name"`
+	ID                string `json:"id"`
+	Domain            string `json:"domain"`
+	Name              string `json:"name"`
+	IncludeSubdomains bool   `json:"include_subdomains"`
+	JoinPolicy        string `json:"join_policy"`
+	DomainVerified    bool   `json:"domain_verified"`
 }
 
 func (a *API) handleRequestCode(w http.ResponseWriter, r *http.Request) {
@@ -64,32 +70,24 @@ func (a *API) handleRequestCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	now := a.clock()
-	if !a.emailLimit.Allow(email, a.settings.RequestCodeEmailLimit, a.settings.RequestCodeEmailWindow, now) {
-		writeError(w, http.StatusTooManyRequests, "too many requests")
+	ctx := r.Context()
+	if err := checkSSORequired(ctx, a.store.Querier(), email); err != nil {
+		a.writeEmailCodeError(w, err, "failed to send verification code")
 		return
 	}
-
-	code, err := generateCode()
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to generate code")
-		return
+	codeRow, err := a.issueEmailCode(ctx, email, a.clock())
+	if err == nil {
+		// Apps that send their device id also get a magic link, bound to
+		// that device.
+		deviceID := strings.TrimSpace(r.Header.Get("X-Device-Id"))
+		if link, ok := a.signInURL(codeRow, deviceID); ok {
+			err = a.mailer.SendSignInLink(ctx, email, codeRow.Code, link)
+		} else {
+			err = a.mailer.SendVerificationCode(ctx, email, codeRow.Code)
+		}
 	}
-
-	expiresAt := now.Add(a.settings.CodeTTL)
-	_, err = a.store.Querier().CreateEmailVerificationCode(r.Context(), sqlc.CreateEmailVerificationCodeParams{
-		Email:     email,
-		Code:      code,
-		ExpiresAt: toTimestamptz(expiresAt),
-	})
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to create verification code")
-		return
-	}
-
-	if err := a.mailer.SendVerificationCode(r.Context(), email, code); err != nil {
-		a.logger.Error("failed to send verification code", slog.String("email", email), slog.Any("err", err))
-		writeError(w, http.StatusInternalServerError, "failed to send verification code")
+		a.writeEmailCodeError(w, err, "failed to send verification code")
 		return
 	}
 
@@ -113,6 +111,11 @@ func (a *API) handleVerifyCode(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "invalid code format")
 		return
 	}
+	inviteCode := normalizeCode(req.InviteCode)
+	if inviteCode != "" && !isValidCode(inviteCode) {
+		writeError(w, http.StatusBadRequest, "invalid invite code format")
+		return
+	}
 
 	deviceID := strings.TrimSpace(r.Header.Get("X-Device-Id"))
 	if deviceID == "" {
@@ -120,13 +123,13 @@ func (a *API) handleVerifyCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx := r.Context()
 	now := a.clock()
-	if a.failLimit.IsLocked(email, now) {
-		writeError(w, http.StatusTooManyRequests, "too many attempts")
+	if err := a.checkCodeLockout(ctx, email, now); err != nil {
+		a.writeEmailCodeError(w, err, "failed to check rate limit")
 		return
 	}
 
-	ctx := r.Context()
 	tx, err := a.store.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to start transaction")
@@ -135,38 +138,51 @@ func (a *API) handleVerifyCode(w http.ResponseWriter, r *http.Request) {
 	defer tx.Rollback(ctx)
 
 	q := a.store.WithTx(tx)
-	codeRow, err := q.GetEmailVerificationCode(ctx, sqlc.GetEmailVerificationCodeParams{
-		Email:     email,
-		Code:      code,
-		ExpiresAt: toTimestamptz(now),
-	})
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			locked := a.failLimit.RegisterFailure(email, a.settings.VerifyCodeEmailLimit, a.settings.VerifyCodeEmailWindow, a.settings.VerifyCodeLock, now)
-			if locked {
-				writeError(w, http.StatusTooManyRequests, "too many attempts")
-				return
-			}
-			writeError(w, http.StatusUnauthorized, "invalid code")
-			return
-		}
-		writeError(w, http.StatusInternalServerError, "failed to verify code")
+	if err := a.redeemEmailCode(ctx, q, email, code, now); err != nil {
+		a.writeEmailCodeError(w, err, "failed to verify code")
 		return
 	}
 
-	if err := q.MarkEmailVerificationCodeUsed(ctx, sqlc.MarkEmailVerificationCodeUsedParams{
-		ID:     codeRow.ID,
-		UsedAt: toTimestamptz(now),
-	}); err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to verify code")
-		return
-	}
+	a.completeSignIn(ctx, w, tx, q, email, inviteCode, signInByEmail, signInDevice{
+		ID:         deviceID,
+		Name:       req.DeviceName,
+		Platform:   req.Platform,
+		AppVersion: req.AppVersion,
+	}, now)
+}
+
+// How a sign-in proved the address. Email codes and links stop working for
+// addresses whose team requires single sign-on, including codes mailed before
+// the requirement was turned on.
+const (
+	signInByEmail   = "email"
+	signInByPairing = "pairing"
+	signInBySSO     = "sso"
+)
 
+// signInDevice is the client-supplied device a new session is issued to.
+type signInDevice struct {
+	ID         string
+	Name       string
+	Platform   string
+	AppVersion string
+}
+
+// completeSignIn finishes a sign-in once email is proven by method: it
+// resolves the user's team, issues a session for device and commits tx.
+func (a *API) completeSignIn(ctx context.Context, w http.ResponseWriter, tx pgx.Tx, q sqlc.Querier, email, inviteCode, method string, device signInDevice, now time.Time) {
 	domain, ok := emailDomain(email)
 	if !ok {
 		writeError(w, http.StatusBadRequest, "invalid email")
 		return
 	}
+	// Free mail domains never belong to a team, so they have no provider.
+	if method == signInByEmail && !a.freeMail.contains(domain) {
+		if err := checkSSORequired(ctx, q, email); err != nil {
+			a.writeEmailCodeError(w, err, "failed to verify code")
+			return
+		}
+	}
 
 	user, isNewUser, err := getOrCreateUser(ctx, q, email, domain, now)
 	if err != nil {
@@ -174,19 +190,29 @@ func (a *API) handleVerifyCode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	team, createdTeam, err := getOrCreateTeam(ctx, q, domain)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to resolve team")
-		return
+	var (
+		team        sqlc.Team
+		role        string
+		status      = joinStatusMember
+		joinRequest *sqlc.TeamJoinRequest
+	)
+	if inviteCode != "" {
+		team, role, err = redeemInvite(ctx, q, user, inviteCode, now, a.settings.TeamSizeLimit)
+	} else {
+		team, role, err = existingTeam(ctx, q, user)
+		if errors.Is(err, pgx.ErrNoRows) {
+			team, role, joinRequest, err = a.joinDomainTeam(ctx, q, user, domain, isNewUser, now)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Nobody has claimed the domain yet; the user decides between
+			// creating its team and redeeming an invite.
+			status, err = joinStatusChooseTeam, nil
+		} else if joinRequest != nil {
+			status = joinRequest.Status
+		}
 	}
-
-	role, err := ensureMembership(ctx, q, user, team, isNewUser, createdTeam, now, a.settings.TeamSizeLimit)
 	if err != nil {
-		if errors.Is(err, errTeamFull) {
-			writeError(w, http.StatusConflict, "team is full")
-			return
-		}
-		writeError(w, http.StatusInternalServerError, "failed to create membership")
+		a.writeJoinError(w, err)
 		return
 	}
 
@@ -205,39 +231,40 @@ func (a *API) handleVerifyCode(w http.ResponseWriter, r *http.Request) {
 	refreshExpires := now.Add(a.settings.RefreshTTL)
 	_, err = q.CreateAuthSession(ctx, sqlc.CreateAuthSessionParams{
 		UserID:           user.ID,
-		DeviceIDHash:     hashString(deviceID),
+		DeviceIDHash:     hashString(device.ID),
 		AccessTokenHash:  accessHash,
 		AccessExpiresAt:  toTimestamptz(accessExpires),
 		RefreshTokenHash: refreshHash,
 		RefreshExpiresAt: toTimestamptz(refreshExpires),
+		DeviceName:       deviceField(device.Name, maxDeviceNameLength),
+		Platform:         deviceField(device.Platform, maxDeviceFieldLength),
+		AppVersion:       deviceField(device.AppVersion, maxDeviceFieldLength),
 	})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to issue token")
 		return
 	}
 
+	bootstrap, err := loadJoinOutcome(ctx, q, user, team, role, status, joinRequest, now)
+	if err != nil {
+		a.logger.Error("failed to load bootstrap state", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to load account")
+		return
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to save session")
 		return
 	}
 
-	a.failLimit.Reset(email)
+	a.resetCodeLockout(ctx, email)
 
 	writeJSON(w, http.StatusOK, authResponse{
-		AccessToken:      accessToken,
-		AccessExpiresAt:  accessExpires,
-		RefreshToken:     refreshToken,
-		RefreshExpiresAt: refreshExpires,
-		User: &userResponse{
-			ID:    uuidString(user.ID),
-			Email: user.Email,
-		},
-		Team: &teamResponse{
-			ID:     uuidString(team.ID),
-			Domain: team.Domain,
-			Name:   team.Name,
-		},
-		Role: role,
+		AccessToken:       accessToken,
+		AccessExpiresAt:   accessExpires,
+		RefreshToken:      refreshToken,
+		RefreshExpiresAt:  refreshExpires,
+		bootstrapResponse: bootstrap,
 	})
 }
 
@@ -260,9 +287,19 @@ func (a *API) handleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The session row stays locked until commit, so concurrent refreshes with
+	// the same token run one after another and only the first rotates it.
+	ctx := r.Context()
+	tx, err := a.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to refresh session")
+		return
+	}
+	defer tx.Rollback(ctx)
+
 	now := a.clock()
-	q := a.store.Querier()
-	session, err := q.GetAuthSessionByRefreshHash(r.Context(), sqlc.GetAuthSessionByRefreshHashParams{
+	q := a.store.WithTx(tx)
+	session, err := q.GetAuthSessionByRefreshHashForUpdate(ctx, sqlc.GetAuthSessionByRefreshHashForUpdateParams{
 		RefreshTokenHash: hashString(refreshToken),
 		RefreshExpiresAt: toTimestamptz(now),
 	})
@@ -278,18 +315,42 @@ func (a *API) handleRefresh(w http.ResponseWriter, r *http.Request) {
 
 	if session.RotatedAt.Valid {
 		if now.Sub(session.RotatedAt.Time) > a.settings.RefreshGrace {
+			a.revokeReusedFamily(ctx, q, session, now)
+			if err := tx.Commit(ctx); err != nil {
+				a.logger.Error("failed to commit session family revoke", slog.Any("err", err))
+			}
 			writeError(w, http.StatusUnauthorized, "refresh token expired")
 			return
 		}
+		// Inside the grace window a repeat caller, whether a concurrent
+		// duplicate or a retry after a lost response, gets a sibling session
+		// in the same family. The first sibling to be refreshed revokes the
+		// others, so the family collapses back to a single chain.
 	} else {
-		if err := q.RotateAuthSession(r.Context(), sqlc.RotateAuthSessionParams{
+		rotated, err := q.RotateAuthSession(ctx, sqlc.RotateAuthSessionParams{
 			ID:        session.ID,
 			RotatedAt: toTimestamptz(now),
-		}); err != nil {
+		})
+		if err != nil {
 			a.logger.Error("failed to rotate session", slog.Any("err", err))
 			writeError(w, http.StatusInternalServerError, "failed to refresh session")
 			return
 		}
+		if rotated == 0 {
+			writeError(w, http.StatusConflict, "refresh already in progress")
+			return
+		}
+		if session.ParentID.Valid {
+			if err := q.RevokeSiblingAuthSessions(ctx, sqlc.RevokeSiblingAuthSessionsParams{
+				ParentID:  session.ParentID,
+				ID:        session.ID,
+				RevokedAt: toTimestamptz(now),
+			}); err != nil {
+				a.logger.Error("failed to revoke sibling sessions", slog.Any("err", err))
+				writeError(w, http.StatusInternalServerError, "failed to refresh session")
+				return
+			}
+		}
 	}
 
 	accessToken, accessHash, err := generateToken()
@@ -303,26 +364,46 @@ func (a *API) handleRefresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Apps update between refreshes, so a newer version replaces the one
+	// recorded at sign-in.
+	appVersion := session.AppVersion
+	if v := deviceField(req.AppVersion, maxDeviceFieldLength); v != "" {
+		appVersion = v
+	}
+
 	accessExpires := now.Add(a.settings.AccessTTL)
 	refreshExpires := now.Add(a.settings.RefreshTTL)
-	_, err = q.CreateAuthSession(r.Context(), sqlc.CreateAuthSessionParams{
+	_, err = q.CreateAuthSession(ctx, sqlc.CreateAuthSessionParams{
 		UserID:           session.UserID,
 		DeviceIDHash:     session.DeviceIDHash,
 		AccessTokenHash:  accessHash,
 		AccessExpiresAt:  toTimestamptz(accessExpires),
 		RefreshTokenHash: refreshHash,
 		RefreshExpiresAt: toTimestamptz(refreshExpires),
+		DeviceName:       session.DeviceName,
+		Platform:         session.Platform,
+		AppVersion:       appVersion,
+		FamilyID:         session.FamilyID,
+		ParentID:         session.ID,
 	})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to issue token")
 		return
 	}
 
-	if err := q.MarkAuthSessionUsed(r.Context(), sqlc.MarkAuthSessionUsedParams{
+	if err := q.MarkAuthSessionUsed(ctx, sqlc.MarkAuthSessionUsedParams{
 		ID:         session.ID,
 		LastUsedAt: toTimestamptz(now),
 	}); err != nil {
 		a.logger.Error("failed to mark session used", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to refresh session")
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		a.logger.Error("failed to commit refresh", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to refresh session")
+		return
 	}
 
 	writeJSON(w, http.StatusOK, authResponse{
@@ -333,6 +414,29 @@ func (a *API) handleRefresh(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// revokeReusedFamily handles a rotated refresh token presented after the
+// grace window. Only one side of the chain should still hold that token, so
+// either the caller or the current session holder stole it; every session in
+// the family is revoked and both have to sign in again.
+func (a *API) revokeReusedFamily(ctx context.Context, q sqlc.Querier, session sqlc.AuthSession, now time.Time) {
+	revoked, err := q.RevokeAuthSessionFamily(ctx, sqlc.RevokeAuthSessionFamilyParams{
+		FamilyID:  session.FamilyID,
+		RevokedAt: toTimestamptz(now),
+	})
+	if err != nil {
+		a.logger.Error("failed to revoke session family after refresh token reuse",
+			slog.String("user_id", uuidString(session.UserID)),
+			slog.String("family_id", uuidString(session.FamilyID)),
+			slog.Any("err", err))
+		return
+	}
+	a.logger.Warn("security: refresh token reuse detected, session family revoked",
+		slog.String("user_id", uuidString(session.UserID)),
+		slog.String("family_id", uuidString(session.FamilyID)),
+		slog.String("session_id", uuidString(session.ID)),
+		slog.Int64("revoked_sessions", revoked))
+}
+
 func (a *API) handleLogout(w http.ResponseWriter, r *http.Request) {
 	var req refreshRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -392,7 +496,16 @@ func normalizeEmail(value string) (string, bool) {
 	if addr.Address == "" {
 		return "", false
 	}
-	return strings.ToLower(addr.Address), true
+	address := strings.ToLower(addr.Address)
+	at := strings.LastIndex(address, "@")
+	if at <= 0 {
+		return "", false
+	}
+	host, ok := canonicalHost(address[at+1:])
+	if !ok {
+		return "", false
+	}
+	return address[:at+1] + host, true
 }
 
 func emailDomain(email string) (string, bool) {
@@ -414,6 +527,14 @@ func uuidString(id pgtype.UUID) string {
 	return uuid.UUID(id.Bytes).String()
 }
 
+func parseUUID(value string) (pgtype.UUID, bool) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return pgtype.UUID{}, false
+	}
+	return pgtype.UUID{Bytes: id, Valid: true}, true
+}
+
 func hashEqual(a, b []byte) bool {
 	if len(a) != len(b) {
 		return false
@@ -455,25 +576,55 @@ func getOrCreateUser(ctx context.Context, q sqlc.Querier, email, domain string,
 	return user, true, nil
 }
 
-func getOrCreateTeam(ctx context.Context, q sqlc.Querier, domain string) (sqlc.Team, bool, error) {
-	team, err := q.GetTeamByDomain(ctx, domain)
-	if err == nil {
-		return team, false, nil
+// loadJoinOutcome builds the bootstrap for a user whose team was just
+// resolved: the full state for members, otherwise the status along with any
+// join request.
+func loadJoinOutcome(ctx context.Context, q sqlc.Querier, user sqlc.User, team sqlc.Team, role, status string, joinRequest *sqlc.TeamJoinRequest, now time.Time) (bootstrapResponse, error) {
+	if status == joinStatusMember {
+		return loadBootstrap(ctx, q, user, team, role, now)
 	}
-	if !errors.Is(err, pgx.ErrNoRows) {
-		return sqlc.Team{}, false, err
+	bootstrap := bootstrapResponse{
+		Status:     status,
+		User:       &userResponse{ID: uuidString(user.ID), Email: user.Email},
+		ServerTime: &now,
 	}
-	team, err = q.CreateTeam(ctx, sqlc.CreateTeamParams{
-		Domain: domain,
-		Name:   domain,
-	})
-	if err != nil {
-		return sqlc.Team{}, false, err
+	if joinRequest != nil {
+		resp := newJoinRequestResponse(*joinRequest, team)
+		bootstrap.JoinRequest = &resp
+	}
+	return bootstrap, nil
+}
+
+// findDomainTeam resolves the team for an email host. Subdomains share the
+// registrable domain's team unless that team keeps subdomains separate, in
+// which case each subdomain has a team of its own. When no team exists it
+// returns pgx.ErrNoRows along with the domain a new team should claim.
+func findDomainTeam(ctx context.Context, q sqlc.Querier, host string) (sqlc.Team, string, error) {
+	registrable := registrableDomain(host)
+	if host != registrable {
+		team, err := q.GetTeamByDomain(ctx, host)
+		if err == nil {
+			return team, host, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return sqlc.Team{}, "", err
+		}
+	}
+
+	team, err := q.GetTeamByDomain(ctx, registrable)
+	if err == nil {
+		if team.IncludeSubdomains || host == registrable {
+			return team, registrable, nil
+		}
+		return sqlc.Team{}, host, pgx.ErrNoRows
 	}
-	return team, true, nil
+	return sqlc.Team{}, registrable, err
 }
 
-func ensureMembership(ctx context.Context, q sqlc.Querier, user sqlc.User, team sqlc.Team, isNewUser, createdTeam bool, now time.Time, teamSizeLimit int) (string, error) {
+// ensureMembership adds user to team unless they already belong to it. The
+// first new user of an empty team becomes its admin, except on a verified
+// domain: there admin rights only come from proving control of the domain.
+func ensureMembership(ctx context.Context, q sqlc.Querier, user sqlc.User, team sqlc.Team, isNewUser bool, now time.Time, teamSizeLimit int) (string, error) {
 	membership, err := q.GetTeamMembership(ctx, sqlc.GetTeamMembershipParams{
 		TeamID: team.ID,
 		UserID: user.ID,
@@ -494,7 +645,7 @@ func ensureMembership(ctx context.Context, q sqlc.Querier, user sqlc.User, team
 	}
 
 	role := "member"
-	if createdTeam || (isNewUser && count == 0) {
+	if isNewUser && count == 0 && !team.DomainVerifiedAt.Valid {
 		role = "admin"
 	}
 
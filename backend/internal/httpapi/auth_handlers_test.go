@@ -7,6 +7,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 
@@ -38,11 +39,29 @@ func (s *stubStore) WithTx(tx pgx.Tx) sqlc.Querier {
 	return s.querier
 }
 
+// newTxStore returns a stubStore whose transactions always succeed.
+func newTxStore(q sqlc.Querier) *stubStore {
+	return &stubStore{
+		querier: q,
+		beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+			return &testTx{}, nil
+		},
+	}
+}
+
 type stubMailer struct {
-	calls     int
-	lastEmail string
-	lastCode  string
-	err       error
+	calls         int
+	inviteCalls   int
+	reminderCalls int
+	changedCalls  int
+	linkCalls     int
+	lastEmail     string
+	lastCode      string
+	lastTeam      string
+	lastURL       string
+	lastNewEmail  string
+	lastLink      string
+	err           error
 }
 
 func (m *stubMailer) SendVerificationCode(_ context.Context, email, code string) error {
@@ -52,6 +71,36 @@ func (m *stubMailer) SendVerificationCode(_ context.Context, email, code string)
 	return m.err
 }
 
+func (m *stubMailer) SendSignInLink(_ context.Context, email, code, link string) error {
+	m.linkCalls++
+	m.lastEmail = email
+	m.lastCode = code
+	m.lastLink = link
+	return m.err
+}
+
+func (m *stubMailer) SendInviteCode(_ context.Context, email, teamName, code string) error {
+	m.inviteCalls++
+	m.lastEmail = email
+	m.lastTeam = teamName
+	m.lastCode = code
+	return m.err
+}
+
+func (m *stubMailer) SendSharingReminder(_ context.Context, email, resumeURL string) error {
+	m.reminderCalls++
+	m.lastEmail = email
+	m.lastURL = resumeURL
+	return m.err
+}
+
+func (m *stubMailer) SendEmailChanged(_ context.Context, oldEmail, newEmail string) error {
+	m.changedCalls++
+	m.lastEmail = oldEmail
+	m.lastNewEmail = newEmail
+	return m.err
+}
+
 // stubQuerier builder for cleaner test setup
 type querierBuilder struct {
 	fns map[string]interface{}
@@ -61,26 +110,111 @@ func newQuerierBuilder() *querierBuilder {
 	return &querierBuilder{fns: make(map[string]interface{})}
 }
 
+func (b *querierBuilder) onAdvisoryUnlock(fn func(context.Context, int64) error) *querierBuilder {
+	b.fns["advisoryUnlock"] = fn
+	return b
+}
+
+func (b *querierBuilder) onApproveDevicePairing(fn func(context.Context, sqlc.ApproveDevicePairingParams) (sqlc.DevicePairing, error)) *querierBuilder {
+	b.fns["approveDevicePairing"] = fn
+	return b
+}
+
+func (b *querierBuilder) onAuthenticateSsoLogin(fn func(context.Context, sqlc.AuthenticateSsoLoginParams) error) *querierBuilder {
+	b.fns["authenticateSsoLogin"] = fn
+	return b
+}
+
+func (b *querierBuilder) onClaimDevicePairing(fn func(context.Context, sqlc.ClaimDevicePairingParams) (sqlc.DevicePairing, error)) *querierBuilder {
+	b.fns["claimDevicePairing"] = fn
+	return b
+}
+
+func (b *querierBuilder) onClaimDueHiddenReminders(fn func(context.Context, sqlc.ClaimDueHiddenRemindersParams) ([]sqlc.ClaimDueHiddenRemindersRow, error)) *querierBuilder {
+	b.fns["claimDueHiddenReminders"] = fn
+	return b
+}
+
+func (b *querierBuilder) onCompleteDevicePairing(fn func(context.Context, sqlc.CompleteDevicePairingParams) error) *querierBuilder {
+	b.fns["completeDevicePairing"] = fn
+	return b
+}
+
+func (b *querierBuilder) onCompleteSsoLogin(fn func(context.Context, sqlc.CompleteSsoLoginParams) error) *querierBuilder {
+	b.fns["completeSsoLogin"] = fn
+	return b
+}
+
+func (b *querierBuilder) onCountActivePairingSessions(fn func(context.Context, sqlc.CountActivePairingSessionsParams) (int64, error)) *querierBuilder {
+	b.fns["countActivePairingSessions"] = fn
+	return b
+}
+
+func (b *querierBuilder) onCountClaimedDevicePairings(fn func(context.Context, sqlc.CountClaimedDevicePairingsParams) (int64, error)) *querierBuilder {
+	b.fns["countClaimedDevicePairings"] = fn
+	return b
+}
+
+func (b *querierBuilder) onCountTeamAdmins(fn func(context.Context, pgtype.UUID) (int64, error)) *querierBuilder {
+	b.fns["countTeamAdmins"] = fn
+	return b
+}
+
 func (b *querierBuilder) onCountTeamMembers(fn func(context.Context, pgtype.UUID) (int64, error)) *querierBuilder {
 	b.fns["countTeamMembers"] = fn
 	return b
 }
 
+func (b *querierBuilder) onCountTeamMembersAtDomain(fn func(context.Context, sqlc.CountTeamMembersAtDomainParams) (int64, error)) *querierBuilder {
+	b.fns["countTeamMembersAtDomain"] = fn
+	return b
+}
+
 func (b *querierBuilder) onCreateAuthSession(fn func(context.Context, sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error)) *querierBuilder {
 	b.fns["createAuthSession"] = fn
 	return b
 }
 
+func (b *querierBuilder) onCreateDevicePairing(fn func(context.Context, sqlc.CreateDevicePairingParams) (sqlc.DevicePairing, error)) *querierBuilder {
+	b.fns["createDevicePairing"] = fn
+	return b
+}
+
 func (b *querierBuilder) onCreateEmailVerificationCode(fn func(context.Context, sqlc.CreateEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error)) *querierBuilder {
 	b.fns["createEmailVerificationCode"] = fn
 	return b
 }
 
+func (b *querierBuilder) onCreateInviteCode(fn func(context.Context, sqlc.CreateInviteCodeParams) (sqlc.InviteCode, error)) *querierBuilder {
+	b.fns["createInviteCode"] = fn
+	return b
+}
+
+func (b *querierBuilder) onCreateSsoLogin(fn func(context.Context, sqlc.CreateSsoLoginParams) (sqlc.SsoLogin, error)) *querierBuilder {
+	b.fns["createSsoLogin"] = fn
+	return b
+}
+
 func (b *querierBuilder) onCreateTeam(fn func(context.Context, sqlc.CreateTeamParams) (sqlc.Team, error)) *querierBuilder {
 	b.fns["createTeam"] = fn
 	return b
 }
 
+func (b *querierBuilder) onCreateTeamDomain(fn func(context.Context, sqlc.CreateTeamDomainParams) (sqlc.TeamDomain, error)) *querierBuilder {
+	b.fns["createTeamDomain"] = fn
+	return b
+}
+
+func (b *querierBuilder) onCreateTeamDomainMigration(fn func(context.Context, sqlc.CreateTeamDomainMigrationParams) (sqlc.TeamDomainMigration, error)) *querierBuilder {
+	b.fns["createTeamDomainMigration"] = fn
+	return b
+}
+
+func (b *querierBuilder) onCreateTeamJoinRequest(fn func(context.Context, sqlc.CreateTeamJoinRequestParams) (sqlc.TeamJoinRequest, error)) *querierBuilder {
+	b.fns["createTeamJoinRequest"] = fn
+	return b
+}
+
 func (b *querierBuilder) onCreateTeamMembership(fn func(context.Context, sqlc.CreateTeamMembershipParams) error) *querierBuilder {
 	b.fns["createTeamMembership"] = fn
 	return b
@@ -91,182 +225,1229 @@ func (b *querierBuilder) onCreateUser(fn func(context.Context, sqlc.CreateUserPa
 	return b
 }
 
+func (b *querierBuilder) onDecideTeamJoinRequest(fn func(context.Context, sqlc.DecideTeamJoinRequestParams) (sqlc.TeamJoinRequest, error)) *querierBuilder {
+	b.fns["decideTeamJoinRequest"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteDevicePairing(fn func(context.Context, sqlc.DeleteDevicePairingParams) (int64, error)) *querierBuilder {
+	b.fns["deleteDevicePairing"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteExpiredAttemptLimits(fn func(context.Context, pgtype.Timestamptz) (int64, error)) *querierBuilder {
+	b.fns["deleteExpiredAttemptLimits"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteExpiredDevicePairings(fn func(context.Context, pgtype.Timestamptz) (int64, error)) *querierBuilder {
+	b.fns["deleteExpiredDevicePairings"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteExpiredEmailVerificationCodes(fn func(context.Context, pgtype.Timestamptz) (int64, error)) *querierBuilder {
+	b.fns["deleteExpiredEmailVerificationCodes"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteExpiredRateLimitCounters(fn func(context.Context, pgtype.Timestamptz) (int64, error)) *querierBuilder {
+	b.fns["deleteExpiredRateLimitCounters"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteExpiredSsoLogins(fn func(context.Context, pgtype.Timestamptz) (int64, error)) *querierBuilder {
+	b.fns["deleteExpiredSsoLogins"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteInviteCode(fn func(context.Context, sqlc.DeleteInviteCodeParams) (int64, error)) *querierBuilder {
+	b.fns["deleteInviteCode"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteStaleAuthSessions(fn func(context.Context, sqlc.DeleteStaleAuthSessionsParams) (int64, error)) *querierBuilder {
+	b.fns["deleteStaleAuthSessions"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteStaleInviteCodes(fn func(context.Context, pgtype.Timestamptz) (int64, error)) *querierBuilder {
+	b.fns["deleteStaleInviteCodes"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteTeam(fn func(context.Context, pgtype.UUID) error) *querierBuilder {
+	b.fns["deleteTeam"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteTeamDomain(fn func(context.Context, sqlc.DeleteTeamDomainParams) (int64, error)) *querierBuilder {
+	b.fns["deleteTeamDomain"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteTeamDomainVerifications(fn func(context.Context, sqlc.DeleteTeamDomainVerificationsParams) (int64, error)) *querierBuilder {
+	b.fns["deleteTeamDomainVerifications"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteTeamMembership(fn func(context.Context, sqlc.DeleteTeamMembershipParams) (int64, error)) *querierBuilder {
+	b.fns["deleteTeamMembership"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDeleteTeamSsoProvider(fn func(context.Context, pgtype.UUID) (int64, error)) *querierBuilder {
+	b.fns["deleteTeamSsoProvider"] = fn
+	return b
+}
+
+func (b *querierBuilder) onDemoteOtherTeamAdmins(fn func(context.Context, sqlc.DemoteOtherTeamAdminsParams) (int64, error)) *querierBuilder {
+	b.fns["demoteOtherTeamAdmins"] = fn
+	return b
+}
+
+func (b *querierBuilder) onFinishTeamDomainMigration(fn func(context.Context, sqlc.FinishTeamDomainMigrationParams) (sqlc.TeamDomainMigration, error)) *querierBuilder {
+	b.fns["finishTeamDomainMigration"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetActiveTeamDomainMigration(fn func(context.Context, pgtype.UUID) (sqlc.TeamDomainMigration, error)) *querierBuilder {
+	b.fns["getActiveTeamDomainMigration"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetAttemptLockUntil(fn func(context.Context, string) (pgtype.Timestamptz, error)) *querierBuilder {
+	b.fns["getAttemptLockUntil"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetAuthSessionByAccessHash(fn func(context.Context, sqlc.GetAuthSessionByAccessHashParams) (sqlc.AuthSession, error)) *querierBuilder {
+	b.fns["getAuthSessionByAccessHash"] = fn
+	return b
+}
+
 func (b *querierBuilder) onGetAuthSessionByRefreshHash(fn func(context.Context, sqlc.GetAuthSessionByRefreshHashParams) (sqlc.AuthSession, error)) *querierBuilder {
 	b.fns["getAuthSessionByRefreshHash"] = fn
 	return b
 }
 
+func (b *querierBuilder) onGetAuthSessionByRefreshHashForUpdate(fn func(context.Context, sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error)) *querierBuilder {
+	b.fns["getAuthSessionByRefreshHashForUpdate"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetDevicePairing(fn func(context.Context, sqlc.GetDevicePairingParams) (sqlc.DevicePairing, error)) *querierBuilder {
+	b.fns["getDevicePairing"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetDevicePairingByClaimTokenForUpdate(fn func(context.Context, sqlc.GetDevicePairingByClaimTokenForUpdateParams) (sqlc.DevicePairing, error)) *querierBuilder {
+	b.fns["getDevicePairingByClaimTokenForUpdate"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetDomainVerification(fn func(context.Context, sqlc.GetDomainVerificationParams) (sqlc.DomainVerification, error)) *querierBuilder {
+	b.fns["getDomainVerification"] = fn
+	return b
+}
+
 func (b *querierBuilder) onGetEmailVerificationCode(fn func(context.Context, sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error)) *querierBuilder {
 	b.fns["getEmailVerificationCode"] = fn
 	return b
 }
 
+func (b *querierBuilder) onGetEmailVerificationCodeByIDForUpdate(fn func(context.Context, sqlc.GetEmailVerificationCodeByIDForUpdateParams) (sqlc.EmailVerificationCode, error)) *querierBuilder {
+	b.fns["getEmailVerificationCodeByIDForUpdate"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetLatestTeamJoinRequestByUserID(fn func(context.Context, pgtype.UUID) (sqlc.TeamJoinRequest, error)) *querierBuilder {
+	b.fns["getLatestTeamJoinRequestByUserID"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetRateLimitCounts(fn func(context.Context, sqlc.GetRateLimitCountsParams) (sqlc.GetRateLimitCountsRow, error)) *querierBuilder {
+	b.fns["getRateLimitCounts"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetSsoLoginByHandoffForUpdate(fn func(context.Context, sqlc.GetSsoLoginByHandoffForUpdateParams) (sqlc.SsoLogin, error)) *querierBuilder {
+	b.fns["getSsoLoginByHandoffForUpdate"] = fn
+	return b
+}
+
+func (b *querierBuilder) onClaimSsoLogin(fn func(context.Context, sqlc.ClaimSsoLoginParams) (sqlc.SsoLogin, error)) *querierBuilder {
+	b.fns["getSsoLoginByStateForUpdate"] = fn
+	return b
+}
+
 func (b *querierBuilder) onGetTeamByDomain(fn func(context.Context, string) (sqlc.Team, error)) *querierBuilder {
 	b.fns["getTeamByDomain"] = fn
 	return b
 }
 
+func (b *querierBuilder) onGetTeamByID(fn func(context.Context, pgtype.UUID) (sqlc.Team, error)) *querierBuilder {
+	b.fns["getTeamByID"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetTeamByIDForUpdate(fn func(context.Context, pgtype.UUID) (sqlc.Team, error)) *querierBuilder {
+	b.fns["getTeamByIDForUpdate"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetTeamDomain(fn func(context.Context, sqlc.GetTeamDomainParams) (sqlc.TeamDomain, error)) *querierBuilder {
+	b.fns["getTeamDomain"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetTeamJoinRequestForUpdate(fn func(context.Context, sqlc.GetTeamJoinRequestForUpdateParams) (sqlc.TeamJoinRequest, error)) *querierBuilder {
+	b.fns["getTeamJoinRequestForUpdate"] = fn
+	return b
+}
+
 func (b *querierBuilder) onGetTeamMembership(fn func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error)) *querierBuilder {
 	b.fns["getTeamMembership"] = fn
 	return b
 }
 
+func (b *querierBuilder) onGetTeamMembershipByUserID(fn func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error)) *querierBuilder {
+	b.fns["getTeamMembershipByUserID"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetTeamSsoProvider(fn func(context.Context, pgtype.UUID) (sqlc.TeamSsoProvider, error)) *querierBuilder {
+	b.fns["getTeamSsoProvider"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetTimezoneState(fn func(context.Context, pgtype.UUID) (sqlc.TimezoneState, error)) *querierBuilder {
+	b.fns["getTimezoneState"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetTimezoneVisibility(fn func(context.Context, pgtype.UUID) (sqlc.TimezoneVisibility, error)) *querierBuilder {
+	b.fns["getTimezoneVisibility"] = fn
+	return b
+}
+
 func (b *querierBuilder) onGetUserByEmail(fn func(context.Context, string) (sqlc.User, error)) *querierBuilder {
 	b.fns["getUserByEmail"] = fn
 	return b
 }
 
+func (b *querierBuilder) onGetUserByID(fn func(context.Context, pgtype.UUID) (sqlc.User, error)) *querierBuilder {
+	b.fns["getUserByID"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetVerifiedDomainVerification(fn func(context.Context, sqlc.GetVerifiedDomainVerificationParams) (sqlc.DomainVerification, error)) *querierBuilder {
+	b.fns["getVerifiedDomainVerification"] = fn
+	return b
+}
+
+func (b *querierBuilder) onGetWorkingHours(fn func(context.Context, pgtype.UUID) (sqlc.WorkingHour, error)) *querierBuilder {
+	b.fns["getWorkingHours"] = fn
+	return b
+}
+
+func (b *querierBuilder) onIncrementAttemptLimit(fn func(context.Context, sqlc.IncrementAttemptLimitParams) (int32, error)) *querierBuilder {
+	b.fns["incrementAttemptLimit"] = fn
+	return b
+}
+
+func (b *querierBuilder) onIncrementRateLimitCounter(fn func(context.Context, sqlc.IncrementRateLimitCounterParams) error) *querierBuilder {
+	b.fns["incrementRateLimitCounter"] = fn
+	return b
+}
+
+func (b *querierBuilder) onListActiveAuthSessions(fn func(context.Context, sqlc.ListActiveAuthSessionsParams) ([]sqlc.ListActiveAuthSessionsRow, error)) *querierBuilder {
+	b.fns["listActiveAuthSessions"] = fn
+	return b
+}
+
+func (b *querierBuilder) onListDueDomainVerifications(fn func(context.Context, sqlc.ListDueDomainVerificationsParams) ([]sqlc.DomainVerification, error)) *querierBuilder {
+	b.fns["listDueDomainVerifications"] = fn
+	return b
+}
+
+func (b *querierBuilder) onListDueTeamDomains(fn func(context.Context, sqlc.ListDueTeamDomainsParams) ([]sqlc.TeamDomain, error)) *querierBuilder {
+	b.fns["listDueTeamDomains"] = fn
+	return b
+}
+
+func (b *querierBuilder) onListInviteCodes(fn func(context.Context, pgtype.UUID) ([]sqlc.InviteCode, error)) *querierBuilder {
+	b.fns["listInviteCodes"] = fn
+	return b
+}
+
+func (b *querierBuilder) onListPendingTeamJoinRequests(fn func(context.Context, pgtype.UUID) ([]sqlc.ListPendingTeamJoinRequestsRow, error)) *querierBuilder {
+	b.fns["listPendingTeamJoinRequests"] = fn
+	return b
+}
+
+func (b *querierBuilder) onListTeamDomains(fn func(context.Context, pgtype.UUID) ([]sqlc.TeamDomain, error)) *querierBuilder {
+	b.fns["listTeamDomains"] = fn
+	return b
+}
+
+func (b *querierBuilder) onListTeamRoster(fn func(context.Context, sqlc.ListTeamRosterParams) ([]sqlc.ListTeamRosterRow, error)) *querierBuilder {
+	b.fns["listTeamRoster"] = fn
+	return b
+}
+
 func (b *querierBuilder) onMarkAuthSessionUsed(fn func(context.Context, sqlc.MarkAuthSessionUsedParams) error) *querierBuilder {
 	b.fns["markAuthSessionUsed"] = fn
 	return b
 }
 
+func (b *querierBuilder) onMarkDomainVerified(fn func(context.Context, sqlc.MarkDomainVerifiedParams) (sqlc.DomainVerification, error)) *querierBuilder {
+	b.fns["markDomainVerified"] = fn
+	return b
+}
+
 func (b *querierBuilder) onMarkEmailVerificationCodeUsed(fn func(context.Context, sqlc.MarkEmailVerificationCodeUsedParams) error) *querierBuilder {
 	b.fns["markEmailVerificationCodeUsed"] = fn
 	return b
 }
 
+func (b *querierBuilder) onRedeemInviteCode(fn func(context.Context, sqlc.RedeemInviteCodeParams) (sqlc.InviteCode, error)) *querierBuilder {
+	b.fns["redeemInviteCode"] = fn
+	return b
+}
+
+func (b *querierBuilder) onRefreshInviteCode(fn func(context.Context, sqlc.RefreshInviteCodeParams) (sqlc.InviteCode, error)) *querierBuilder {
+	b.fns["refreshInviteCode"] = fn
+	return b
+}
+
+func (b *querierBuilder) onRegisterAttemptFailure(fn func(context.Context, sqlc.RegisterAttemptFailureParams) (pgtype.Timestamptz, error)) *querierBuilder {
+	b.fns["registerAttemptFailure"] = fn
+	return b
+}
+
+func (b *querierBuilder) onResetAttemptLimit(fn func(context.Context, string) error) *querierBuilder {
+	b.fns["resetAttemptLimit"] = fn
+	return b
+}
+
+func (b *querierBuilder) onRestoreHiddenReminder(fn func(context.Context, sqlc.RestoreHiddenReminderParams) error) *querierBuilder {
+	b.fns["restoreHiddenReminder"] = fn
+	return b
+}
+
+func (b *querierBuilder) onResumeSharingFromReminder(fn func(context.Context, sqlc.ResumeSharingFromReminderParams) (int64, error)) *querierBuilder {
+	b.fns["resumeSharingFromReminder"] = fn
+	return b
+}
+
 func (b *querierBuilder) onRevokeAuthSession(fn func(context.Context, sqlc.RevokeAuthSessionParams) error) *querierBuilder {
 	b.fns["revokeAuthSession"] = fn
 	return b
 }
 
-func (b *querierBuilder) onRotateAuthSession(fn func(context.Context, sqlc.RotateAuthSessionParams) error) *querierBuilder {
-	b.fns["rotateAuthSession"] = fn
+func (b *querierBuilder) onRevokeAuthSessionFamily(fn func(context.Context, sqlc.RevokeAuthSessionFamilyParams) (int64, error)) *querierBuilder {
+	b.fns["revokeAuthSessionFamily"] = fn
 	return b
 }
 
-func (b *querierBuilder) onUpdateUserVerifiedAt(fn func(context.Context, sqlc.UpdateUserVerifiedAtParams) (sqlc.User, error)) *querierBuilder {
-	b.fns["updateUserVerifiedAt"] = fn
-	return b
+func (b *querierBuilder) onRevokeDomainVerification(fn func(context.Context, sqlc.RevokeDomainVerificationParams) error) *querierBuilder {
+	b.fns["revokeDomainVerification"] = fn
+	return b
+}
+
+func (b *querierBuilder) onRevokeOtherUserAuthSessions(fn func(context.Context, sqlc.RevokeOtherUserAuthSessionsParams) (int64, error)) *querierBuilder {
+	b.fns["revokeOtherUserAuthSessions"] = fn
+	return b
+}
+
+func (b *querierBuilder) onRevokeSiblingAuthSessions(fn func(context.Context, sqlc.RevokeSiblingAuthSessionsParams) error) *querierBuilder {
+	b.fns["revokeSiblingAuthSessions"] = fn
+	return b
+}
+
+func (b *querierBuilder) onRevokeTeamDomain(fn func(context.Context, sqlc.RevokeTeamDomainParams) error) *querierBuilder {
+	b.fns["revokeTeamDomain"] = fn
+	return b
+}
+
+func (b *querierBuilder) onRevokeUserAuthSessionFamily(fn func(context.Context, sqlc.RevokeUserAuthSessionFamilyParams) (int64, error)) *querierBuilder {
+	b.fns["revokeUserAuthSessionFamily"] = fn
+	return b
+}
+
+func (b *querierBuilder) onRevokeUserAuthSessions(fn func(context.Context, sqlc.RevokeUserAuthSessionsParams) error) *querierBuilder {
+	b.fns["revokeUserAuthSessions"] = fn
+	return b
+}
+
+func (b *querierBuilder) onRotateAuthSession(fn func(context.Context, sqlc.RotateAuthSessionParams) (int64, error)) *querierBuilder {
+	b.fns["rotateAuthSession"] = fn
+	return b
+}
+
+func (b *querierBuilder) onSetTeamDomainVerification(fn func(context.Context, sqlc.SetTeamDomainVerificationParams) error) *querierBuilder {
+	b.fns["setTeamDomainVerification"] = fn
+	return b
+}
+
+func (b *querierBuilder) onSetTeamDomainVerified(fn func(context.Context, sqlc.SetTeamDomainVerifiedParams) error) *querierBuilder {
+	b.fns["setTeamDomainVerified"] = fn
+	return b
+}
+
+func (b *querierBuilder) onSetTeamPrimaryDomain(fn func(context.Context, sqlc.SetTeamPrimaryDomainParams) (sqlc.Team, error)) *querierBuilder {
+	b.fns["setTeamPrimaryDomain"] = fn
+	return b
+}
+
+func (b *querierBuilder) onTouchDomainVerification(fn func(context.Context, sqlc.TouchDomainVerificationParams) error) *querierBuilder {
+	b.fns["touchDomainVerification"] = fn
+	return b
+}
+
+func (b *querierBuilder) onTouchTeamDomain(fn func(context.Context, sqlc.TouchTeamDomainParams) error) *querierBuilder {
+	b.fns["touchTeamDomain"] = fn
+	return b
+}
+
+func (b *querierBuilder) onTryAdvisoryLock(fn func(context.Context, int64) (bool, error)) *querierBuilder {
+	b.fns["tryAdvisoryLock"] = fn
+	return b
+}
+
+func (b *querierBuilder) onUpdateTeamMembershipRole(fn func(context.Context, sqlc.UpdateTeamMembershipRoleParams) (sqlc.TeamMembership, error)) *querierBuilder {
+	b.fns["updateTeamMembershipRole"] = fn
+	return b
+}
+
+func (b *querierBuilder) onUpdateTeamSettings(fn func(context.Context, sqlc.UpdateTeamSettingsParams) (sqlc.Team, error)) *querierBuilder {
+	b.fns["updateTeamSettings"] = fn
+	return b
+}
+
+func (b *querierBuilder) onUpdateUserEmail(fn func(context.Context, sqlc.UpdateUserEmailParams) (sqlc.User, error)) *querierBuilder {
+	b.fns["updateUserEmail"] = fn
+	return b
+}
+
+func (b *querierBuilder) onUpdateUserVerifiedAt(fn func(context.Context, sqlc.UpdateUserVerifiedAtParams) (sqlc.User, error)) *querierBuilder {
+	b.fns["updateUserVerifiedAt"] = fn
+	return b
+}
+
+func (b *querierBuilder) onUpsertDomainVerification(fn func(context.Context, sqlc.UpsertDomainVerificationParams) (sqlc.DomainVerification, error)) *querierBuilder {
+	b.fns["upsertDomainVerification"] = fn
+	return b
+}
+
+func (b *querierBuilder) onUpsertTeamSsoProvider(fn func(context.Context, sqlc.UpsertTeamSsoProviderParams) (sqlc.TeamSsoProvider, error)) *querierBuilder {
+	b.fns["upsertTeamSsoProvider"] = fn
+	return b
+}
+
+func (b *querierBuilder) onUpsertTimezoneState(fn func(context.Context, sqlc.UpsertTimezoneStateParams) (sqlc.TimezoneState, error)) *querierBuilder {
+	b.fns["upsertTimezoneState"] = fn
+	return b
+}
+
+func (b *querierBuilder) onUpsertTimezoneVisibility(fn func(context.Context, sqlc.UpsertTimezoneVisibilityParams) (sqlc.TimezoneVisibility, error)) *querierBuilder {
+	b.fns["upsertTimezoneVisibility"] = fn
+	return b
+}
+
+func (b *querierBuilder) onUpsertWorkingHours(fn func(context.Context, sqlc.UpsertWorkingHoursParams) (sqlc.WorkingHour, error)) *querierBuilder {
+	b.fns["upsertWorkingHours"] = fn
+	return b
+}
+
+func (b *querierBuilder) onVerifyTeamDomain(fn func(context.Context, sqlc.VerifyTeamDomainParams) (sqlc.TeamDomain, error)) *querierBuilder {
+	b.fns["verifyTeamDomain"] = fn
+	return b
+}
+
+func (b *querierBuilder) build() sqlc.Querier {
+	return &builtQuerier{fns: b.fns}
+}
+
+type builtQuerier struct {
+	fns map[string]interface{}
+}
+
+func (q *builtQuerier) AdvisoryUnlock(ctx context.Context, lockKey int64) error {
+	if fn, ok := q.fns["advisoryUnlock"]; ok {
+		return fn.(func(context.Context, int64) error)(ctx, lockKey)
+	}
+	return nil
+}
+
+func (q *builtQuerier) ApproveDevicePairing(ctx context.Context, arg sqlc.ApproveDevicePairingParams) (sqlc.DevicePairing, error) {
+	if fn, ok := q.fns["approveDevicePairing"]; ok {
+		return fn.(func(context.Context, sqlc.ApproveDevicePairingParams) (sqlc.DevicePairing, error))(ctx, arg)
+	}
+	return sqlc.DevicePairing{}, nil
+}
+
+func (q *builtQuerier) AuthenticateSsoLogin(ctx context.Context, arg sqlc.AuthenticateSsoLoginParams) error {
+	if fn, ok := q.fns["authenticateSsoLogin"]; ok {
+		return fn.(func(context.Context, sqlc.AuthenticateSsoLoginParams) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) ClaimDevicePairing(ctx context.Context, arg sqlc.ClaimDevicePairingParams) (sqlc.DevicePairing, error) {
+	if fn, ok := q.fns["claimDevicePairing"]; ok {
+		return fn.(func(context.Context, sqlc.ClaimDevicePairingParams) (sqlc.DevicePairing, error))(ctx, arg)
+	}
+	return sqlc.DevicePairing{}, nil
+}
+
+func (q *builtQuerier) ClaimDueHiddenReminders(ctx context.Context, arg sqlc.ClaimDueHiddenRemindersParams) ([]sqlc.ClaimDueHiddenRemindersRow, error) {
+	if fn, ok := q.fns["claimDueHiddenReminders"]; ok {
+		return fn.(func(context.Context, sqlc.ClaimDueHiddenRemindersParams) ([]sqlc.ClaimDueHiddenRemindersRow, error))(ctx, arg)
+	}
+	return nil, nil
+}
+
+func (q *builtQuerier) CompleteDevicePairing(ctx context.Context, arg sqlc.CompleteDevicePairingParams) error {
+	if fn, ok := q.fns["completeDevicePairing"]; ok {
+		return fn.(func(context.Context, sqlc.CompleteDevicePairingParams) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) CompleteSsoLogin(ctx context.Context, arg sqlc.CompleteSsoLoginParams) error {
+	if fn, ok := q.fns["completeSsoLogin"]; ok {
+		return fn.(func(context.Context, sqlc.CompleteSsoLoginParams) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) CountActivePairingSessions(ctx context.Context, arg sqlc.CountActivePairingSessionsParams) (int64, error) {
+	if fn, ok := q.fns["countActivePairingSessions"]; ok {
+		return fn.(func(context.Context, sqlc.CountActivePairingSessionsParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) CountClaimedDevicePairings(ctx context.Context, arg sqlc.CountClaimedDevicePairingsParams) (int64, error) {
+	if fn, ok := q.fns["countClaimedDevicePairings"]; ok {
+		return fn.(func(context.Context, sqlc.CountClaimedDevicePairingsParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) CountTeamAdmins(ctx context.Context, teamID pgtype.UUID) (int64, error) {
+	if fn, ok := q.fns["countTeamAdmins"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (int64, error))(ctx, teamID)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) CountTeamMembers(ctx context.Context, teamID pgtype.UUID) (int64, error) {
+	if fn, ok := q.fns["countTeamMembers"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (int64, error))(ctx, teamID)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) CountTeamMembersAtDomain(ctx context.Context, arg sqlc.CountTeamMembersAtDomainParams) (int64, error) {
+	if fn, ok := q.fns["countTeamMembersAtDomain"]; ok {
+		return fn.(func(context.Context, sqlc.CountTeamMembersAtDomainParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) CreateAuthSession(ctx context.Context, arg sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
+	if fn, ok := q.fns["createAuthSession"]; ok {
+		return fn.(func(context.Context, sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error))(ctx, arg)
+	}
+	return sqlc.AuthSession{}, nil
+}
+
+func (q *builtQuerier) CreateDevicePairing(ctx context.Context, arg sqlc.CreateDevicePairingParams) (sqlc.DevicePairing, error) {
+	if fn, ok := q.fns["createDevicePairing"]; ok {
+		return fn.(func(context.Context, sqlc.CreateDevicePairingParams) (sqlc.DevicePairing, error))(ctx, arg)
+	}
+	return sqlc.DevicePairing{}, nil
+}
+
+func (q *builtQuerier) CreateEmailVerificationCode(ctx context.Context, arg sqlc.CreateEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+	if fn, ok := q.fns["createEmailVerificationCode"]; ok {
+		return fn.(func(context.Context, sqlc.CreateEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error))(ctx, arg)
+	}
+	return sqlc.EmailVerificationCode{}, nil
+}
+
+func (q *builtQuerier) CreateInviteCode(ctx context.Context, arg sqlc.CreateInviteCodeParams) (sqlc.InviteCode, error) {
+	if fn, ok := q.fns["createInviteCode"]; ok {
+		return fn.(func(context.Context, sqlc.CreateInviteCodeParams) (sqlc.InviteCode, error))(ctx, arg)
+	}
+	return sqlc.InviteCode{}, nil
+}
+
+func (q *builtQuerier) CreateSsoLogin(ctx context.Context, arg sqlc.CreateSsoLoginParams) (sqlc.SsoLogin, error) {
+	if fn, ok := q.fns["createSsoLogin"]; ok {
+		return fn.(func(context.Context, sqlc.CreateSsoLoginParams) (sqlc.SsoLogin, error))(ctx, arg)
+	}
+	return sqlc.SsoLogin{}, nil
+}
+
+func (q *builtQuerier) CreateTeam(ctx context.Context, arg sqlc.CreateTeamParams) (sqlc.Team, error) {
+	if fn, ok := q.fns["createTeam"]; ok {
+		return fn.(func(context.Context, sqlc.CreateTeamParams) (sqlc.Team, error))(ctx, arg)
+	}
+	return sqlc.Team{}, nil
+}
+
+func (q *builtQuerier) CreateTeamDomain(ctx context.Context, arg sqlc.CreateTeamDomainParams) (sqlc.TeamDomain, error) {
+	if fn, ok := q.fns["createTeamDomain"]; ok {
+		return fn.(func(context.Context, sqlc.CreateTeamDomainParams) (sqlc.TeamDomain, error))(ctx, arg)
+	}
+	return sqlc.TeamDomain{}, nil
+}
+
+func (q *builtQuerier) CreateTeamDomainMigration(ctx context.Context, arg sqlc.CreateTeamDomainMigrationParams) (sqlc.TeamDomainMigration, error) {
+	if fn, ok := q.fns["createTeamDomainMigration"]; ok {
+		return fn.(func(context.Context, sqlc.CreateTeamDomainMigrationParams) (sqlc.TeamDomainMigration, error))(ctx, arg)
+	}
+	return sqlc.TeamDomainMigration{}, nil
+}
+
+func (q *builtQuerier) CreateTeamJoinRequest(ctx context.Context, arg sqlc.CreateTeamJoinRequestParams) (sqlc.TeamJoinRequest, error) {
+	if fn, ok := q.fns["createTeamJoinRequest"]; ok {
+		return fn.(func(context.Context, sqlc.CreateTeamJoinRequestParams) (sqlc.TeamJoinRequest, error))(ctx, arg)
+	}
+	return sqlc.TeamJoinRequest{}, nil
+}
+
+func (q *builtQuerier) CreateTeamMembership(ctx context.Context, arg sqlc.CreateTeamMembershipParams) error {
+	if fn, ok := q.fns["createTeamMembership"]; ok {
+		return fn.(func(context.Context, sqlc.CreateTeamMembershipParams) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) CreateUser(ctx context.Context, arg sqlc.CreateUserParams) (sqlc.User, error) {
+	if fn, ok := q.fns["createUser"]; ok {
+		return fn.(func(context.Context, sqlc.CreateUserParams) (sqlc.User, error))(ctx, arg)
+	}
+	return sqlc.User{}, nil
+}
+
+func (q *builtQuerier) DecideTeamJoinRequest(ctx context.Context, arg sqlc.DecideTeamJoinRequestParams) (sqlc.TeamJoinRequest, error) {
+	if fn, ok := q.fns["decideTeamJoinRequest"]; ok {
+		return fn.(func(context.Context, sqlc.DecideTeamJoinRequestParams) (sqlc.TeamJoinRequest, error))(ctx, arg)
+	}
+	return sqlc.TeamJoinRequest{}, nil
+}
+
+func (q *builtQuerier) DeleteDevicePairing(ctx context.Context, arg sqlc.DeleteDevicePairingParams) (int64, error) {
+	if fn, ok := q.fns["deleteDevicePairing"]; ok {
+		return fn.(func(context.Context, sqlc.DeleteDevicePairingParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteExpiredAttemptLimits(ctx context.Context, now pgtype.Timestamptz) (int64, error) {
+	if fn, ok := q.fns["deleteExpiredAttemptLimits"]; ok {
+		return fn.(func(context.Context, pgtype.Timestamptz) (int64, error))(ctx, now)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteExpiredDevicePairings(ctx context.Context, expiresAt pgtype.Timestamptz) (int64, error) {
+	if fn, ok := q.fns["deleteExpiredDevicePairings"]; ok {
+		return fn.(func(context.Context, pgtype.Timestamptz) (int64, error))(ctx, expiresAt)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteExpiredEmailVerificationCodes(ctx context.Context, expiresAt pgtype.Timestamptz) (int64, error) {
+	if fn, ok := q.fns["deleteExpiredEmailVerificationCodes"]; ok {
+		return fn.(func(context.Context, pgtype.Timestamptz) (int64, error))(ctx, expiresAt)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteExpiredRateLimitCounters(ctx context.Context, windowStart pgtype.Timestamptz) (int64, error) {
+	if fn, ok := q.fns["deleteExpiredRateLimitCounters"]; ok {
+		return fn.(func(context.Context, pgtype.Timestamptz) (int64, error))(ctx, windowStart)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteExpiredSsoLogins(ctx context.Context, expiresAt pgtype.Timestamptz) (int64, error) {
+	if fn, ok := q.fns["deleteExpiredSsoLogins"]; ok {
+		return fn.(func(context.Context, pgtype.Timestamptz) (int64, error))(ctx, expiresAt)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteInviteCode(ctx context.Context, arg sqlc.DeleteInviteCodeParams) (int64, error) {
+	if fn, ok := q.fns["deleteInviteCode"]; ok {
+		return fn.(func(context.Context, sqlc.DeleteInviteCodeParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteStaleAuthSessions(ctx context.Context, arg sqlc.DeleteStaleAuthSessionsParams) (int64, error) {
+	if fn, ok := q.fns["deleteStaleAuthSessions"]; ok {
+		return fn.(func(context.Context, sqlc.DeleteStaleAuthSessionsParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteStaleInviteCodes(ctx context.Context, expiresAt pgtype.Timestamptz) (int64, error) {
+	if fn, ok := q.fns["deleteStaleInviteCodes"]; ok {
+		return fn.(func(context.Context, pgtype.Timestamptz) (int64, error))(ctx, expiresAt)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteTeam(ctx context.Context, arg pgtype.UUID) error {
+	if fn, ok := q.fns["deleteTeam"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) DeleteTeamDomain(ctx context.Context, arg sqlc.DeleteTeamDomainParams) (int64, error) {
+	if fn, ok := q.fns["deleteTeamDomain"]; ok {
+		return fn.(func(context.Context, sqlc.DeleteTeamDomainParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteTeamDomainVerifications(ctx context.Context, arg sqlc.DeleteTeamDomainVerificationsParams) (int64, error) {
+	if fn, ok := q.fns["deleteTeamDomainVerifications"]; ok {
+		return fn.(func(context.Context, sqlc.DeleteTeamDomainVerificationsParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteTeamMembership(ctx context.Context, arg sqlc.DeleteTeamMembershipParams) (int64, error) {
+	if fn, ok := q.fns["deleteTeamMembership"]; ok {
+		return fn.(func(context.Context, sqlc.DeleteTeamMembershipParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DeleteTeamSsoProvider(ctx context.Context, teamID pgtype.UUID) (int64, error) {
+	if fn, ok := q.fns["deleteTeamSsoProvider"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (int64, error))(ctx, teamID)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) DemoteOtherTeamAdmins(ctx context.Context, arg sqlc.DemoteOtherTeamAdminsParams) (int64, error) {
+	if fn, ok := q.fns["demoteOtherTeamAdmins"]; ok {
+		return fn.(func(context.Context, sqlc.DemoteOtherTeamAdminsParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) FinishTeamDomainMigration(ctx context.Context, arg sqlc.FinishTeamDomainMigrationParams) (sqlc.TeamDomainMigration, error) {
+	if fn, ok := q.fns["finishTeamDomainMigration"]; ok {
+		return fn.(func(context.Context, sqlc.FinishTeamDomainMigrationParams) (sqlc.TeamDomainMigration, error))(ctx, arg)
+	}
+	return sqlc.TeamDomainMigration{}, nil
+}
+
+func (q *builtQuerier) GetActiveTeamDomainMigration(ctx context.Context, teamID pgtype.UUID) (sqlc.TeamDomainMigration, error) {
+	if fn, ok := q.fns["getActiveTeamDomainMigration"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (sqlc.TeamDomainMigration, error))(ctx, teamID)
+	}
+	return sqlc.TeamDomainMigration{}, nil
+}
+
+func (q *builtQuerier) GetAttemptLockUntil(ctx context.Context, key string) (pgtype.Timestamptz, error) {
+	if fn, ok := q.fns["getAttemptLockUntil"]; ok {
+		return fn.(func(context.Context, string) (pgtype.Timestamptz, error))(ctx, key)
+	}
+	return pgtype.Timestamptz{}, nil
+}
+
+func (q *builtQuerier) GetAuthSessionByAccessHash(ctx context.Context, arg sqlc.GetAuthSessionByAccessHashParams) (sqlc.AuthSession, error) {
+	if fn, ok := q.fns["getAuthSessionByAccessHash"]; ok {
+		return fn.(func(context.Context, sqlc.GetAuthSessionByAccessHashParams) (sqlc.AuthSession, error))(ctx, arg)
+	}
+	return sqlc.AuthSession{}, nil
+}
+
+func (q *builtQuerier) GetAuthSessionByRefreshHash(ctx context.Context, arg sqlc.GetAuthSessionByRefreshHashParams) (sqlc.AuthSession, error) {
+	if fn, ok := q.fns["getAuthSessionByRefreshHash"]; ok {
+		return fn.(func(context.Context, sqlc.GetAuthSessionByRefreshHashParams) (sqlc.AuthSession, error))(ctx, arg)
+	}
+	return sqlc.AuthSession{}, nil
+}
+
+func (q *builtQuerier) GetAuthSessionByRefreshHashForUpdate(ctx context.Context, arg sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error) {
+	if fn, ok := q.fns["getAuthSessionByRefreshHashForUpdate"]; ok {
+		return fn.(func(context.Context, sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error))(ctx, arg)
+	}
+	return sqlc.AuthSession{}, nil
+}
+
+func (q *builtQuerier) GetDevicePairing(ctx context.Context, arg sqlc.GetDevicePairingParams) (sqlc.DevicePairing, error) {
+	if fn, ok := q.fns["getDevicePairing"]; ok {
+		return fn.(func(context.Context, sqlc.GetDevicePairingParams) (sqlc.DevicePairing, error))(ctx, arg)
+	}
+	return sqlc.DevicePairing{}, nil
+}
+
+func (q *builtQuerier) GetDevicePairingByClaimTokenForUpdate(ctx context.Context, arg sqlc.GetDevicePairingByClaimTokenForUpdateParams) (sqlc.DevicePairing, error) {
+	if fn, ok := q.fns["getDevicePairingByClaimTokenForUpdate"]; ok {
+		return fn.(func(context.Context, sqlc.GetDevicePairingByClaimTokenForUpdateParams) (sqlc.DevicePairing, error))(ctx, arg)
+	}
+	return sqlc.DevicePairing{}, nil
+}
+
+func (q *builtQuerier) GetDomainVerification(ctx context.Context, arg sqlc.GetDomainVerificationParams) (sqlc.DomainVerification, error) {
+	if fn, ok := q.fns["getDomainVerification"]; ok {
+		return fn.(func(context.Context, sqlc.GetDomainVerificationParams) (sqlc.DomainVerification, error))(ctx, arg)
+	}
+	return sqlc.DomainVerification{}, nil
+}
+
+func (q *builtQuerier) GetEmailVerificationCode(ctx context.Context, arg sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+	if fn, ok := q.fns["getEmailVerificationCode"]; ok {
+		return fn.(func(context.Context, sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error))(ctx, arg)
+	}
+	return sqlc.EmailVerificationCode{}, nil
+}
+
+func (q *builtQuerier) GetEmailVerificationCodeByIDForUpdate(ctx context.Context, arg sqlc.GetEmailVerificationCodeByIDForUpdateParams) (sqlc.EmailVerificationCode, error) {
+	if fn, ok := q.fns["getEmailVerificationCodeByIDForUpdate"]; ok {
+		return fn.(func(context.Context, sqlc.GetEmailVerificationCodeByIDForUpdateParams) (sqlc.EmailVerificationCode, error))(ctx, arg)
+	}
+	return sqlc.EmailVerificationCode{}, nil
+}
+
+func (q *builtQuerier) GetLatestTeamJoinRequestByUserID(ctx context.Context, userID pgtype.UUID) (sqlc.TeamJoinRequest, error) {
+	if fn, ok := q.fns["getLatestTeamJoinRequestByUserID"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (sqlc.TeamJoinRequest, error))(ctx, userID)
+	}
+	return sqlc.TeamJoinRequest{}, nil
+}
+
+func (q *builtQuerier) GetRateLimitCounts(ctx context.Context, arg sqlc.GetRateLimitCountsParams) (sqlc.GetRateLimitCountsRow, error) {
+	if fn, ok := q.fns["getRateLimitCounts"]; ok {
+		return fn.(func(context.Context, sqlc.GetRateLimitCountsParams) (sqlc.GetRateLimitCountsRow, error))(ctx, arg)
+	}
+	return sqlc.GetRateLimitCountsRow{}, nil
+}
+
+func (q *builtQuerier) GetSsoLoginByHandoffForUpdate(ctx context.Context, arg sqlc.GetSsoLoginByHandoffForUpdateParams) (sqlc.SsoLogin, error) {
+	if fn, ok := q.fns["getSsoLoginByHandoffForUpdate"]; ok {
+		return fn.(func(context.Context, sqlc.GetSsoLoginByHandoffForUpdateParams) (sqlc.SsoLogin, error))(ctx, arg)
+	}
+	return sqlc.SsoLogin{}, nil
+}
+
+func (q *builtQuerier) ClaimSsoLogin(ctx context.Context, arg sqlc.ClaimSsoLoginParams) (sqlc.SsoLogin, error) {
+	if fn, ok := q.fns["getSsoLoginByStateForUpdate"]; ok {
+		return fn.(func(context.Context, sqlc.ClaimSsoLoginParams) (sqlc.SsoLogin, error))(ctx, arg)
+	}
+	return sqlc.SsoLogin{}, nil
+}
+
+func (q *builtQuerier) GetTeamByDomain(ctx context.Context, domain string) (sqlc.Team, error) {
+	if fn, ok := q.fns["getTeamByDomain"]; ok {
+		return fn.(func(context.Context, string) (sqlc.Team, error))(ctx, domain)
+	}
+	return sqlc.Team{}, nil
+}
+
+func (q *builtQuerier) GetTeamByID(ctx context.Context, id pgtype.UUID) (sqlc.Team, error) {
+	if fn, ok := q.fns["getTeamByID"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (sqlc.Team, error))(ctx, id)
+	}
+	return sqlc.Team{}, nil
+}
+
+func (q *builtQuerier) GetTeamByIDForUpdate(ctx context.Context, id pgtype.UUID) (sqlc.Team, error) {
+	if fn, ok := q.fns["getTeamByIDForUpdate"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (sqlc.Team, error))(ctx, id)
+	}
+	return sqlc.Team{}, nil
+}
+
+func (q *builtQuerier) GetTeamDomain(ctx context.Context, arg sqlc.GetTeamDomainParams) (sqlc.TeamDomain, error) {
+	if fn, ok := q.fns["getTeamDomain"]; ok {
+		return fn.(func(context.Context, sqlc.GetTeamDomainParams) (sqlc.TeamDomain, error))(ctx, arg)
+	}
+	return sqlc.TeamDomain{}, nil
+}
+
+func (q *builtQuerier) GetTeamJoinRequestForUpdate(ctx context.Context, arg sqlc.GetTeamJoinRequestForUpdateParams) (sqlc.TeamJoinRequest, error) {
+	if fn, ok := q.fns["getTeamJoinRequestForUpdate"]; ok {
+		return fn.(func(context.Context, sqlc.GetTeamJoinRequestForUpdateParams) (sqlc.TeamJoinRequest, error))(ctx, arg)
+	}
+	return sqlc.TeamJoinRequest{}, nil
+}
+
+func (q *builtQuerier) GetTeamMembership(ctx context.Context, arg sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+	if fn, ok := q.fns["getTeamMembership"]; ok {
+		return fn.(func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error))(ctx, arg)
+	}
+	return sqlc.TeamMembership{}, nil
+}
+
+func (q *builtQuerier) GetTeamMembershipByUserID(ctx context.Context, userID pgtype.UUID) (sqlc.TeamMembership, error) {
+	if fn, ok := q.fns["getTeamMembershipByUserID"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error))(ctx, userID)
+	}
+	return sqlc.TeamMembership{}, nil
+}
+
+func (q *builtQuerier) GetTeamSsoProvider(ctx context.Context, id pgtype.UUID) (sqlc.TeamSsoProvider, error) {
+	if fn, ok := q.fns["getTeamSsoProvider"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (sqlc.TeamSsoProvider, error))(ctx, id)
+	}
+	return sqlc.TeamSsoProvider{}, nil
+}
+
+func (q *builtQuerier) GetTimezoneState(ctx context.Context, userID pgtype.UUID) (sqlc.TimezoneState, error) {
+	if fn, ok := q.fns["getTimezoneState"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (sqlc.TimezoneState, error))(ctx, userID)
+	}
+	return sqlc.TimezoneState{}, nil
+}
+
+func (q *builtQuerier) GetTimezoneVisibility(ctx context.Context, userID pgtype.UUID) (sqlc.TimezoneVisibility, error) {
+	if fn, ok := q.fns["getTimezoneVisibility"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (sqlc.TimezoneVisibility, error))(ctx, userID)
+	}
+	return sqlc.TimezoneVisibility{}, nil
+}
+
+func (q *builtQuerier) GetUserByEmail(ctx context.Context, email string) (sqlc.User, error) {
+	if fn, ok := q.fns["getUserByEmail"]; ok {
+		return fn.(func(context.Context, string) (sqlc.User, error))(ctx, email)
+	}
+	return sqlc.User{}, nil
+}
+
+func (q *builtQuerier) GetUserByID(ctx context.Context, id pgtype.UUID) (sqlc.User, error) {
+	if fn, ok := q.fns["getUserByID"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (sqlc.User, error))(ctx, id)
+	}
+	return sqlc.User{}, nil
+}
+
+func (q *builtQuerier) GetVerifiedDomainVerification(ctx context.Context, arg sqlc.GetVerifiedDomainVerificationParams) (sqlc.DomainVerification, error) {
+	if fn, ok := q.fns["getVerifiedDomainVerification"]; ok {
+		return fn.(func(context.Context, sqlc.GetVerifiedDomainVerificationParams) (sqlc.DomainVerification, error))(ctx, arg)
+	}
+	return sqlc.DomainVerification{}, nil
+}
+
+func (q *builtQuerier) GetWorkingHours(ctx context.Context, userID pgtype.UUID) (sqlc.WorkingHour, error) {
+	if fn, ok := q.fns["getWorkingHours"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) (sqlc.WorkingHour, error))(ctx, userID)
+	}
+	return sqlc.WorkingHour{}, nil
+}
+
+func (q *builtQuerier) IncrementAttemptLimit(ctx context.Context, arg sqlc.IncrementAttemptLimitParams) (int32, error) {
+	if fn, ok := q.fns["incrementAttemptLimit"]; ok {
+		return fn.(func(context.Context, sqlc.IncrementAttemptLimitParams) (int32, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) IncrementRateLimitCounter(ctx context.Context, arg sqlc.IncrementRateLimitCounterParams) error {
+	if fn, ok := q.fns["incrementRateLimitCounter"]; ok {
+		return fn.(func(context.Context, sqlc.IncrementRateLimitCounterParams) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) ListActiveAuthSessions(ctx context.Context, arg sqlc.ListActiveAuthSessionsParams) ([]sqlc.ListActiveAuthSessionsRow, error) {
+	if fn, ok := q.fns["listActiveAuthSessions"]; ok {
+		return fn.(func(context.Context, sqlc.ListActiveAuthSessionsParams) ([]sqlc.ListActiveAuthSessionsRow, error))(ctx, arg)
+	}
+	return nil, nil
+}
+
+func (q *builtQuerier) ListDueDomainVerifications(ctx context.Context, arg sqlc.ListDueDomainVerificationsParams) ([]sqlc.DomainVerification, error) {
+	if fn, ok := q.fns["listDueDomainVerifications"]; ok {
+		return fn.(func(context.Context, sqlc.ListDueDomainVerificationsParams) ([]sqlc.DomainVerification, error))(ctx, arg)
+	}
+	return nil, nil
+}
+
+func (q *builtQuerier) ListDueTeamDomains(ctx context.Context, arg sqlc.ListDueTeamDomainsParams) ([]sqlc.TeamDomain, error) {
+	if fn, ok := q.fns["listDueTeamDomains"]; ok {
+		return fn.(func(context.Context, sqlc.ListDueTeamDomainsParams) ([]sqlc.TeamDomain, error))(ctx, arg)
+	}
+	return nil, nil
+}
+
+func (q *builtQuerier) ListInviteCodes(ctx context.Context, teamID pgtype.UUID) ([]sqlc.InviteCode, error) {
+	if fn, ok := q.fns["listInviteCodes"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) ([]sqlc.InviteCode, error))(ctx, teamID)
+	}
+	return nil, nil
+}
+
+func (q *builtQuerier) ListPendingTeamJoinRequests(ctx context.Context, teamID pgtype.UUID) ([]sqlc.ListPendingTeamJoinRequestsRow, error) {
+	if fn, ok := q.fns["listPendingTeamJoinRequests"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) ([]sqlc.ListPendingTeamJoinRequestsRow, error))(ctx, teamID)
+	}
+	return nil, nil
+}
+
+func (q *builtQuerier) ListTeamDomains(ctx context.Context, teamID pgtype.UUID) ([]sqlc.TeamDomain, error) {
+	if fn, ok := q.fns["listTeamDomains"]; ok {
+		return fn.(func(context.Context, pgtype.UUID) ([]sqlc.TeamDomain, error))(ctx, teamID)
+	}
+	return nil, nil
+}
+
+func (q *builtQuerier) ListTeamRoster(ctx context.Context, arg sqlc.ListTeamRosterParams) ([]sqlc.ListTeamRosterRow, error) {
+	if fn, ok := q.fns["listTeamRoster"]; ok {
+		return fn.(func(context.Context, sqlc.ListTeamRosterParams) ([]sqlc.ListTeamRosterRow, error))(ctx, arg)
+	}
+	return nil, nil
+}
+
+func (q *builtQuerier) MarkAuthSessionUsed(ctx context.Context, arg sqlc.MarkAuthSessionUsedParams) error {
+	if fn, ok := q.fns["markAuthSessionUsed"]; ok {
+		return fn.(func(context.Context, sqlc.MarkAuthSessionUsedParams) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) MarkDomainVerified(ctx context.Context, arg sqlc.MarkDomainVerifiedParams) (sqlc.DomainVerification, error) {
+	if fn, ok := q.fns["markDomainVerified"]; ok {
+		return fn.(func(context.Context, sqlc.MarkDomainVerifiedParams) (sqlc.DomainVerification, error))(ctx, arg)
+	}
+	return sqlc.DomainVerification{}, nil
+}
+
+func (q *builtQuerier) MarkEmailVerificationCodeUsed(ctx context.Context, arg sqlc.MarkEmailVerificationCodeUsedParams) error {
+	if fn, ok := q.fns["markEmailVerificationCodeUsed"]; ok {
+		return fn.(func(context.Context, sqlc.MarkEmailVerificationCodeUsedParams) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) RedeemInviteCode(ctx context.Context, arg sqlc.RedeemInviteCodeParams) (sqlc.InviteCode, error) {
+	if fn, ok := q.fns["redeemInviteCode"]; ok {
+		return fn.(func(context.Context, sqlc.RedeemInviteCodeParams) (sqlc.InviteCode, error))(ctx, arg)
+	}
+	return sqlc.InviteCode{}, nil
+}
+
+func (q *builtQuerier) RefreshInviteCode(ctx context.Context, arg sqlc.RefreshInviteCodeParams) (sqlc.InviteCode, error) {
+	if fn, ok := q.fns["refreshInviteCode"]; ok {
+		return fn.(func(context.Context, sqlc.RefreshInviteCodeParams) (sqlc.InviteCode, error))(ctx, arg)
+	}
+	return sqlc.InviteCode{}, nil
+}
+
+func (q *builtQuerier) RegisterAttemptFailure(ctx context.Context, arg sqlc.RegisterAttemptFailureParams) (pgtype.Timestamptz, error) {
+	if fn, ok := q.fns["registerAttemptFailure"]; ok {
+		return fn.(func(context.Context, sqlc.RegisterAttemptFailureParams) (pgtype.Timestamptz, error))(ctx, arg)
+	}
+	return pgtype.Timestamptz{}, nil
+}
+
+func (q *builtQuerier) ResetAttemptLimit(ctx context.Context, key string) error {
+	if fn, ok := q.fns["resetAttemptLimit"]; ok {
+		return fn.(func(context.Context, string) error)(ctx, key)
+	}
+	return nil
+}
+
+func (q *builtQuerier) RestoreHiddenReminder(ctx context.Context, arg sqlc.RestoreHiddenReminderParams) error {
+	if fn, ok := q.fns["restoreHiddenReminder"]; ok {
+		return fn.(func(context.Context, sqlc.RestoreHiddenReminderParams) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) ResumeSharingFromReminder(ctx context.Context, arg sqlc.ResumeSharingFromReminderParams) (int64, error) {
+	if fn, ok := q.fns["resumeSharingFromReminder"]; ok {
+		return fn.(func(context.Context, sqlc.ResumeSharingFromReminderParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) RevokeAuthSession(ctx context.Context, arg sqlc.RevokeAuthSessionParams) error {
+	if fn, ok := q.fns["revokeAuthSession"]; ok {
+		return fn.(func(context.Context, sqlc.RevokeAuthSessionParams) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) RevokeAuthSessionFamily(ctx context.Context, arg sqlc.RevokeAuthSessionFamilyParams) (int64, error) {
+	if fn, ok := q.fns["revokeAuthSessionFamily"]; ok {
+		return fn.(func(context.Context, sqlc.RevokeAuthSessionFamilyParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) RevokeDomainVerification(ctx context.Context, arg sqlc.RevokeDomainVerificationParams) error {
+	if fn, ok := q.fns["revokeDomainVerification"]; ok {
+		return fn.(func(context.Context, sqlc.RevokeDomainVerificationParams) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) RevokeOtherUserAuthSessions(ctx context.Context, arg sqlc.RevokeOtherUserAuthSessionsParams) (int64, error) {
+	if fn, ok := q.fns["revokeOtherUserAuthSessions"]; ok {
+		return fn.(func(context.Context, sqlc.RevokeOtherUserAuthSessionsParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
+}
+
+func (q *builtQuerier) RevokeSiblingAuthSessions(ctx context.Context, arg sqlc.RevokeSiblingAuthSessionsParams) error {
+	if fn, ok := q.fns["revokeSiblingAuthSessions"]; ok {
+		return fn.(func(context.Context, sqlc.RevokeSiblingAuthSessionsParams) error)(ctx, arg)
+	}
+	return nil
+}
+
+func (q *builtQuerier) RevokeTeamDomain(ctx context.Context, arg sqlc.RevokeTeamDomainParams) error {
+	if fn, ok := q.fns["revokeTeamDomain"]; ok {
+		return fn.(func(context.Context, sqlc.RevokeTeamDomainParams) error)(ctx, arg)
+	}
+	return nil
 }
 
-func (b *querierBuilder) build() sqlc.Querier {
-	return &builtQuerier{fns: b.fns}
+func (q *builtQuerier) RevokeUserAuthSessionFamily(ctx context.Context, arg sqlc.RevokeUserAuthSessionFamilyParams) (int64, error) {
+	if fn, ok := q.fns["revokeUserAuthSessionFamily"]; ok {
+		return fn.(func(context.Context, sqlc.RevokeUserAuthSessionFamilyParams) (int64, error))(ctx, arg)
+	}
+	return 0, nil
 }
 
-type builtQuerier struct {
-	fns map[string]interface{}
+func (q *builtQuerier) RevokeUserAuthSessions(ctx context.Context, arg sqlc.RevokeUserAuthSessionsParams) error {
+	if fn, ok := q.fns["revokeUserAuthSessions"]; ok {
+		return fn.(func(context.Context, sqlc.RevokeUserAuthSessionsParams) error)(ctx, arg)
+	}
+	return nil
 }
 
-func (q *builtQuerier) CountTeamMembers(ctx context.Context, teamID pgtype.UUID) (int64, error) {
-	if fn, ok := q.fns["countTeamMembers"]; ok {
-		return fn.(func(context.Context, pgtype.UUID) (int64, error))(ctx, teamID)
+func (q *builtQuerier) RotateAuthSession(ctx context.Context, arg sqlc.RotateAuthSessionParams) (int64, error) {
+	if fn, ok := q.fns["rotateAuthSession"]; ok {
+		return fn.(func(context.Context, sqlc.RotateAuthSessionParams) (int64, error))(ctx, arg)
 	}
 	return 0, nil
 }
 
-func (q *builtQuerier) CreateAuthSession(ctx context.Context, arg sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
-	if fn, ok := q.fns["createAuthSession"]; ok {
-		return fn.(func(context.Context, sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error))(ctx, arg)
+func (q *builtQuerier) SetTeamDomainVerification(ctx context.Context, arg sqlc.SetTeamDomainVerificationParams) error {
+	if fn, ok := q.fns["setTeamDomainVerification"]; ok {
+		return fn.(func(context.Context, sqlc.SetTeamDomainVerificationParams) error)(ctx, arg)
 	}
-	return sqlc.AuthSession{}, nil
+	return nil
 }
 
-func (q *builtQuerier) CreateEmailVerificationCode(ctx context.Context, arg sqlc.CreateEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
-	if fn, ok := q.fns["createEmailVerificationCode"]; ok {
-		return fn.(func(context.Context, sqlc.CreateEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error))(ctx, arg)
+func (q *builtQuerier) SetTeamDomainVerified(ctx context.Context, arg sqlc.SetTeamDomainVerifiedParams) error {
+	if fn, ok := q.fns["setTeamDomainVerified"]; ok {
+		return fn.(func(context.Context, sqlc.SetTeamDomainVerifiedParams) error)(ctx, arg)
 	}
-	return sqlc.EmailVerificationCode{}, nil
+	return nil
 }
 
-func (q *builtQuerier) CreateTeam(ctx context.Context, arg sqlc.CreateTeamParams) (sqlc.Team, error) {
-	if fn, ok := q.fns["createTeam"]; ok {
-		return fn.(func(context.Context, sqlc.CreateTeamParams) (sqlc.Team, error))(ctx, arg)
+func (q *builtQuerier) SetTeamPrimaryDomain(ctx context.Context, arg sqlc.SetTeamPrimaryDomainParams) (sqlc.Team, error) {
+	if fn, ok := q.fns["setTeamPrimaryDomain"]; ok {
+		return fn.(func(context.Context, sqlc.SetTeamPrimaryDomainParams) (sqlc.Team, error))(ctx, arg)
 	}
 	return sqlc.Team{}, nil
 }
 
-func (q *builtQuerier) CreateTeamMembership(ctx context.Context, arg sqlc.CreateTeamMembershipParams) error {
-	if fn, ok := q.fns["createTeamMembership"]; ok {
-		return fn.(func(context.Context, sqlc.CreateTeamMembershipParams) error)(ctx, arg)
+func (q *builtQuerier) TouchDomainVerification(ctx context.Context, arg sqlc.TouchDomainVerificationParams) error {
+	if fn, ok := q.fns["touchDomainVerification"]; ok {
+		return fn.(func(context.Context, sqlc.TouchDomainVerificationParams) error)(ctx, arg)
 	}
 	return nil
 }
 
-func (q *builtQuerier) CreateUser(ctx context.Context, arg sqlc.CreateUserParams) (sqlc.User, error) {
-	if fn, ok := q.fns["createUser"]; ok {
-		return fn.(func(context.Context, sqlc.CreateUserParams) (sqlc.User, error))(ctx, arg)
+func (q *builtQuerier) TouchTeamDomain(ctx context.Context, arg sqlc.TouchTeamDomainParams) error {
+	if fn, ok := q.fns["touchTeamDomain"]; ok {
+		return fn.(func(context.Context, sqlc.TouchTeamDomainParams) error)(ctx, arg)
 	}
-	return sqlc.User{}, nil
-}
-
-func (q *builtQuerier) GetAuthSessionByAccessHash(context.Context, sqlc.GetAuthSessionByAccessHashParams) (sqlc.AuthSession, error) {
-	panic("unexpected GetAuthSessionByAccessHash")
+	return nil
 }
 
-func (q *builtQuerier) GetAuthSessionByRefreshHash(ctx context.Context, arg sqlc.GetAuthSessionByRefreshHashParams) (sqlc.AuthSession, error) {
-	if fn, ok := q.fns["getAuthSessionByRefreshHash"]; ok {
-		return fn.(func(context.Context, sqlc.GetAuthSessionByRefreshHashParams) (sqlc.AuthSession, error))(ctx, arg)
+func (q *builtQuerier) TryAdvisoryLock(ctx context.Context, lockKey int64) (bool, error) {
+	if fn, ok := q.fns["tryAdvisoryLock"]; ok {
+		return fn.(func(context.Context, int64) (bool, error))(ctx, lockKey)
 	}
-	return sqlc.AuthSession{}, nil
+	return false, nil
 }
 
-func (q *builtQuerier) GetEmailVerificationCode(ctx context.Context, arg sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
-	if fn, ok := q.fns["getEmailVerificationCode"]; ok {
-		return fn.(func(context.Context, sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error))(ctx, arg)
+func (q *builtQuerier) UpdateTeamMembershipRole(ctx context.Context, arg sqlc.UpdateTeamMembershipRoleParams) (sqlc.TeamMembership, error) {
+	if fn, ok := q.fns["updateTeamMembershipRole"]; ok {
+		return fn.(func(context.Context, sqlc.UpdateTeamMembershipRoleParams) (sqlc.TeamMembership, error))(ctx, arg)
 	}
-	return sqlc.EmailVerificationCode{}, nil
+	return sqlc.TeamMembership{}, nil
 }
 
-func (q *builtQuerier) GetTeamByDomain(ctx context.Context, domain string) (sqlc.Team, error) {
-	if fn, ok := q.fns["getTeamByDomain"]; ok {
-		return fn.(func(context.Context, string) (sqlc.Team, error))(ctx, domain)
+func (q *builtQuerier) UpdateTeamSettings(ctx context.Context, arg sqlc.UpdateTeamSettingsParams) (sqlc.Team, error) {
+	if fn, ok := q.fns["updateTeamSettings"]; ok {
+		return fn.(func(context.Context, sqlc.UpdateTeamSettingsParams) (sqlc.Team, error))(ctx, arg)
 	}
 	return sqlc.Team{}, nil
 }
 
-func (q *builtQuerier) GetTeamMembership(ctx context.Context, arg sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
-	if fn, ok := q.fns["getTeamMembership"]; ok {
-		return fn.(func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error))(ctx, arg)
+func (q *builtQuerier) UpdateUserEmail(ctx context.Context, arg sqlc.UpdateUserEmailParams) (sqlc.User, error) {
+	if fn, ok := q.fns["updateUserEmail"]; ok {
+		return fn.(func(context.Context, sqlc.UpdateUserEmailParams) (sqlc.User, error))(ctx, arg)
 	}
-	return sqlc.TeamMembership{}, nil
+	return sqlc.User{}, nil
 }
 
-func (q *builtQuerier) GetUserByEmail(ctx context.Context, email string) (sqlc.User, error) {
-	if fn, ok := q.fns["getUserByEmail"]; ok {
-		return fn.(func(context.Context, string) (sqlc.User, error))(ctx, email)
+func (q *builtQuerier) UpdateUserVerifiedAt(ctx context.Context, arg sqlc.UpdateUserVerifiedAtParams) (sqlc.User, error) {
+	if fn, ok := q.fns["updateUserVerifiedAt"]; ok {
+		return fn.(func(context.Context, sqlc.UpdateUserVerifiedAtParams) (sqlc.User, error))(ctx, arg)
 	}
 	return sqlc.User{}, nil
 }
 
-func (q *builtQuerier) GetUserByID(context.Context, pgtype.UUID) (sqlc.User, error) {
-	panic("unexpected GetUserByID")
+func (q *builtQuerier) UpsertDomainVerification(ctx context.Context, arg sqlc.UpsertDomainVerificationParams) (sqlc.DomainVerification, error) {
+	if fn, ok := q.fns["upsertDomainVerification"]; ok {
+		return fn.(func(context.Context, sqlc.UpsertDomainVerificationParams) (sqlc.DomainVerification, error))(ctx, arg)
+	}
+	return sqlc.DomainVerification{}, nil
 }
 
-func (q *builtQuerier) MarkAuthSessionUsed(ctx context.Context, arg sqlc.MarkAuthSessionUsedParams) error {
-	if fn, ok := q.fns["markAuthSessionUsed"]; ok {
-		return fn.(func(context.Context, sqlc.MarkAuthSessionUsedParams) error)(ctx, arg)
+func (q *builtQuerier) UpsertTeamSsoProvider(ctx context.Context, arg sqlc.UpsertTeamSsoProviderParams) (sqlc.TeamSsoProvider, error) {
+	if fn, ok := q.fns["upsertTeamSsoProvider"]; ok {
+		return fn.(func(context.Context, sqlc.UpsertTeamSsoProviderParams) (sqlc.TeamSsoProvider, error))(ctx, arg)
 	}
-	return nil
+	return sqlc.TeamSsoProvider{}, nil
 }
 
-func (q *builtQuerier) MarkEmailVerificationCodeUsed(ctx context.Context, arg sqlc.MarkEmailVerificationCodeUsedParams) error {
-	if fn, ok := q.fns["markEmailVerificationCodeUsed"]; ok {
-		return fn.(func(context.Context, sqlc.MarkEmailVerificationCodeUsedParams) error)(ctx, arg)
+func (q *builtQuerier) UpsertTimezoneState(ctx context.Context, arg sqlc.UpsertTimezoneStateParams) (sqlc.TimezoneState, error) {
+	if fn, ok := q.fns["upsertTimezoneState"]; ok {
+		return fn.(func(context.Context, sqlc.UpsertTimezoneStateParams) (sqlc.TimezoneState, error))(ctx, arg)
 	}
-	return nil
+	return sqlc.TimezoneState{}, nil
 }
 
-func (q *builtQuerier) RevokeAuthSession(ctx context.Context, arg sqlc.RevokeAuthSessionParams) error {
-	if fn, ok := q.fns["revokeAuthSession"]; ok {
-		return fn.(func(context.Context, sqlc.RevokeAuthSessionParams) error)(ctx, arg)
+func (q *builtQuerier) UpsertTimezoneVisibility(ctx context.Context, arg sqlc.UpsertTimezoneVisibilityParams) (sqlc.TimezoneVisibility, error) {
+	if fn, ok := q.fns["upsertTimezoneVisibility"]; ok {
+		return fn.(func(context.Context, sqlc.UpsertTimezoneVisibilityParams) (sqlc.TimezoneVisibility, error))(ctx, arg)
 	}
-	return nil
+	return sqlc.TimezoneVisibility{}, nil
 }
 
-func (q *builtQuerier) RotateAuthSession(ctx context.Context, arg sqlc.RotateAuthSessionParams) error {
-	if fn, ok := q.fns["rotateAuthSession"]; ok {
-		return fn.(func(context.Context, sqlc.RotateAuthSessionParams) error)(ctx, arg)
+func (q *builtQuerier) UpsertWorkingHours(ctx context.Context, arg sqlc.UpsertWorkingHoursParams) (sqlc.WorkingHour, error) {
+	if fn, ok := q.fns["upsertWorkingHours"]; ok {
+		return fn.(func(context.Context, sqlc.UpsertWorkingHoursParams) (sqlc.WorkingHour, error))(ctx, arg)
 	}
-	return nil
+	return sqlc.WorkingHour{}, nil
 }
 
-func (q *builtQuerier) UpdateUserVerifiedAt(ctx context.Context, arg sqlc.UpdateUserVerifiedAtParams) (sqlc.User, error) {
-	if fn, ok := q.fns["updateUserVerifiedAt"]; ok {
-		return fn.(func(context.Context, sqlc.UpdateUserVerifiedAtParams) (sqlc.User, error))(ctx, arg)
+func (q *builtQuerier) VerifyTeamDomain(ctx context.Context, arg sqlc.VerifyTeamDomainParams) (sqlc.TeamDomain, error) {
+	if fn, ok := q.fns["verifyTeamDomain"]; ok {
+		return fn.(func(context.Context, sqlc.VerifyTeamDomainParams) (sqlc.TeamDomain, error))(ctx, arg)
 	}
-	return sqlc.User{}, nil
+	return sqlc.TeamDomain{}, nil
 }
 
 type testTx struct {
@@ -514,11 +1695,12 @@ func TestHandleVerifyCode(t *testing.T) {
 				}
 				return sqlc.User{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Email: arg.Email}, nil
 			}).
-			onGetTeamByDomain(func(context.Context, string) (sqlc.Team, error) {
-				return sqlc.Team{}, pgx.ErrNoRows
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
 			}).
-			onCreateTeam(func(_ context.Context, arg sqlc.CreateTeamParams) (sqlc.Team, error) {
-				return sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, Domain: arg.Domain, Name: arg.Name}, nil
+			// An open team nobody has joined yet: the first user becomes admin.
+			onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+				return sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, Domain: domain, Name: "Example", JoinPolicy: joinPolicyOpen}, nil
 			}).
 			onGetTeamMembership(func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
 				return sqlc.TeamMembership{}, pgx.ErrNoRows
@@ -534,6 +1716,9 @@ func TestHandleVerifyCode(t *testing.T) {
 				if !arg.AccessExpiresAt.Valid || !arg.RefreshExpiresAt.Valid {
 					t.Fatal("expected expires to be set")
 				}
+				if arg.DeviceName != "Work Mac" || arg.Platform != "macos" || arg.AppVersion != "1.4.0" {
+					t.Fatalf("unexpected device metadata: %q/%q/%q", arg.DeviceName, arg.Platform, arg.AppVersion)
+				}
 				return sqlc.AuthSession{}, nil
 			}).
 			build()
@@ -556,7 +1741,13 @@ func TestHandleVerifyCode(t *testing.T) {
 		}, nil)
 		api.clock = func() time.Time { return now }
 
-		body, _ := json.Marshal(verifyCodeRequest{Email: email, Code: code})
+		body, _ := json.Marshal(verifyCodeRequest{
+			Email:      email,
+			Code:       code,
+			DeviceName: "  Work Mac\n",
+			Platform:   "macos",
+			AppVersion: "1.4.0",
+		})
 		req := httptest.NewRequest(http.MethodPost, "/auth/verify-code", bytes.NewReader(body))
 		req.Header.Set("X-Device-Id", deviceID)
 		rec := httptest.NewRecorder()
@@ -572,6 +1763,16 @@ func TestHandleVerifyCode(t *testing.T) {
 		if !tx.committed {
 			t.Fatal("expected tx to be committed")
 		}
+		var resp authResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if resp.AccessToken == "" || resp.User == nil || resp.Role != "admin" || resp.Status != joinStatusMember {
+			t.Fatalf("unexpected auth response: %+v", resp)
+		}
+		if resp.Visibility == nil || resp.WorkingHours == nil || resp.ServerTime == nil {
+			t.Fatalf("expected bootstrap state alongside the tokens: %s", rec.Body.String())
+		}
 	})
 
 	t.Run("invalid code", func(t *testing.T) {
@@ -637,7 +1838,7 @@ func TestHandleVerifyCode(t *testing.T) {
 	t.Run("pre-locked", func(t *testing.T) {
 		api := New(&stubStore{}, &mailer.LogMailer{}, Settings{}, nil)
 		now := time.Now()
-		api.failLimit.RegisterFailure("user@example.com", 1, time.Minute, time.Minute, now)
+		api.failLimit.RegisterFailure(context.Background(), "user@example.com", 1, time.Minute, time.Minute, now)
 
 		body, _ := json.Marshal(verifyCodeRequest{Email: "user@example.com", Code: "ABCD2345"})
 		req := httptest.NewRequest(http.MethodPost, "/auth/verify-code", bytes.NewReader(body))
@@ -671,6 +1872,9 @@ func TestHandleVerifyCode(t *testing.T) {
 					EmailVerifiedAt: pgtype.Timestamptz{Time: now, Valid: true},
 				}, nil
 			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
 			onGetTeamByDomain(func(context.Context, string) (sqlc.Team, error) {
 				return sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, Domain: "example.com", Name: "example.com"}, nil
 			}).
@@ -716,22 +1920,34 @@ func TestHandleRefresh(t *testing.T) {
 		var rotated bool
 		var created bool
 		var marked bool
+		sessionID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+		familyID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
 
 		q := newQuerierBuilder().
-			onGetAuthSessionByRefreshHash(func(_ context.Context, _ sqlc.GetAuthSessionByRefreshHashParams) (sqlc.AuthSession, error) {
+			onGetAuthSessionByRefreshHashForUpdate(func(_ context.Context, _ sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error) {
 				return sqlc.AuthSession{
-					ID:               pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+					ID:               sessionID,
 					UserID:           pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
 					DeviceIDHash:     hashString(deviceID),
 					RefreshTokenHash: hashString(refreshToken),
+					FamilyID:         familyID,
+					DeviceName:       "Work Mac",
+					Platform:         "macos",
+					AppVersion:       "1.4.0",
 				}, nil
 			}).
-			onRotateAuthSession(func(context.Context, sqlc.RotateAuthSessionParams) error {
+			onRotateAuthSession(func(context.Context, sqlc.RotateAuthSessionParams) (int64, error) {
 				rotated = true
-				return nil
+				return 1, nil
 			}).
-			onCreateAuthSession(func(context.Context, sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
+			onCreateAuthSession(func(_ context.Context, arg sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
 				created = true
+				if arg.FamilyID != familyID || arg.ParentID != sessionID {
+					t.Fatalf("expected new session in family %v with parent %v, got %v/%v", familyID, sessionID, arg.FamilyID, arg.ParentID)
+				}
+				if arg.DeviceName != "Work Mac" || arg.Platform != "macos" || arg.AppVersion != "1.5.0" {
+					t.Fatalf("unexpected device metadata: %q/%q/%q", arg.DeviceName, arg.Platform, arg.AppVersion)
+				}
 				return sqlc.AuthSession{}, nil
 			}).
 			onMarkAuthSessionUsed(func(context.Context, sqlc.MarkAuthSessionUsedParams) error {
@@ -740,14 +1956,14 @@ func TestHandleRefresh(t *testing.T) {
 			}).
 			build()
 
-		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{
 			AccessTTL:    15 * time.Minute,
 			RefreshTTL:   24 * time.Hour,
 			RefreshGrace: 30 * time.Second,
 		}, nil)
 		api.clock = func() time.Time { return now }
 
-		body, _ := json.Marshal(refreshRequest{RefreshToken: refreshToken})
+		body, _ := json.Marshal(refreshRequest{RefreshToken: refreshToken, AppVersion: "1.5.0"})
 		req := httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewReader(body))
 		req.Header.Set("X-Device-Id", deviceID)
 		rec := httptest.NewRecorder()
@@ -769,7 +1985,7 @@ func TestHandleRefresh(t *testing.T) {
 		var rotated bool
 
 		q := newQuerierBuilder().
-			onGetAuthSessionByRefreshHash(func(_ context.Context, _ sqlc.GetAuthSessionByRefreshHashParams) (sqlc.AuthSession, error) {
+			onGetAuthSessionByRefreshHashForUpdate(func(_ context.Context, _ sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error) {
 				return sqlc.AuthSession{
 					ID:               pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
 					UserID:           pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
@@ -778,9 +1994,9 @@ func TestHandleRefresh(t *testing.T) {
 					RotatedAt:        pgtype.Timestamptz{Time: now.Add(-10 * time.Second), Valid: true},
 				}, nil
 			}).
-			onRotateAuthSession(func(context.Context, sqlc.RotateAuthSessionParams) error {
+			onRotateAuthSession(func(context.Context, sqlc.RotateAuthSessionParams) (int64, error) {
 				rotated = true
-				return nil
+				return 1, nil
 			}).
 			onCreateAuthSession(func(context.Context, sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
 				return sqlc.AuthSession{}, nil
@@ -790,7 +2006,7 @@ func TestHandleRefresh(t *testing.T) {
 			}).
 			build()
 
-		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{
 			AccessTTL:    15 * time.Minute,
 			RefreshTTL:   24 * time.Hour,
 			RefreshGrace: 30 * time.Second,
@@ -812,16 +2028,45 @@ func TestHandleRefresh(t *testing.T) {
 		}
 	})
 
+	t.Run("rotated concurrently", func(t *testing.T) {
+		deviceID := "device-123"
+		var created bool
+
+		q := newQuerierBuilder().
+			onGetAuthSessionByRefreshHashForUpdate(func(_ context.Context, _ sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error) {
+				return sqlc.AuthSession{DeviceIDHash: hashString(deviceID)}, nil
+			}).
+			onRotateAuthSession(func(context.Context, sqlc.RotateAuthSessionParams) (int64, error) {
+				return 0, nil
+			}).
+			onCreateAuthSession(func(context.Context, sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
+				created = true
+				return sqlc.AuthSession{}, nil
+			}).
+			build()
+
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{RefreshGrace: 30 * time.Second}, nil)
+
+		rec, _ := refreshRequestWith(api, "refresh-token", deviceID)
+
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+		if created {
+			t.Fatal("expected no session when rotation did not apply")
+		}
+	})
+
 	t.Run("invalid device", func(t *testing.T) {
 		q := newQuerierBuilder().
-			onGetAuthSessionByRefreshHash(func(_ context.Context, _ sqlc.GetAuthSessionByRefreshHashParams) (sqlc.AuthSession, error) {
+			onGetAuthSessionByRefreshHashForUpdate(func(_ context.Context, _ sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error) {
 				return sqlc.AuthSession{
 					DeviceIDHash: hashString("other-device"),
 				}, nil
 			}).
 			build()
 
-		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{
 			AccessTTL:    15 * time.Minute,
 			RefreshTTL:   24 * time.Hour,
 			RefreshGrace: 30 * time.Second,
@@ -843,18 +2088,30 @@ func TestHandleRefresh(t *testing.T) {
 		deviceID := "device-123"
 		refreshToken := "refresh-token"
 		now := time.Now()
+		familyID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+		var revoked sqlc.RevokeAuthSessionFamilyParams
+		var created bool
 
 		q := newQuerierBuilder().
-			onGetAuthSessionByRefreshHash(func(_ context.Context, _ sqlc.GetAuthSessionByRefreshHashParams) (sqlc.AuthSession, error) {
+			onGetAuthSessionByRefreshHashForUpdate(func(_ context.Context, _ sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error) {
 				return sqlc.AuthSession{
 					DeviceIDHash:     hashString(deviceID),
 					RefreshTokenHash: hashString(refreshToken),
 					RotatedAt:        pgtype.Timestamptz{Time: now.Add(-time.Minute), Valid: true},
+					FamilyID:         familyID,
 				}, nil
 			}).
+			onRevokeAuthSessionFamily(func(_ context.Context, arg sqlc.RevokeAuthSessionFamilyParams) (int64, error) {
+				revoked = arg
+				return 3, nil
+			}).
+			onCreateAuthSession(func(context.Context, sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
+				created = true
+				return sqlc.AuthSession{}, nil
+			}).
 			build()
 
-		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{
 			AccessTTL:    15 * time.Minute,
 			RefreshTTL:   24 * time.Hour,
 			RefreshGrace: 30 * time.Second,
@@ -868,6 +2125,99 @@ func TestHandleRefresh(t *testing.T) {
 
 		api.handleRefresh(rec, req)
 
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status 401, got %d", rec.Code)
+		}
+		if revoked.FamilyID != familyID || !revoked.RevokedAt.Time.Equal(now) {
+			t.Fatalf("expected family %v to be revoked at %v, got %+v", familyID, now, revoked)
+		}
+		if created {
+			t.Fatal("expected no session to be issued for a reused token")
+		}
+	})
+
+	// Cleanup keeps rotated rows until their refresh token expires, so a
+	// token replayed long after the cleanup retention still trips reuse
+	// detection instead of being treated as unknown.
+	t.Run("rotated token reused after cleanup retention", func(t *testing.T) {
+		deviceID := "device-123"
+		refreshToken := "refresh-token"
+		now := time.Now()
+		familyID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+		var lookup sqlc.GetAuthSessionByRefreshHashForUpdateParams
+		var revoked sqlc.RevokeAuthSessionFamilyParams
+		var created bool
+
+		q := newQuerierBuilder().
+			onGetAuthSessionByRefreshHashForUpdate(func(_ context.Context, arg sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error) {
+				lookup = arg
+				return sqlc.AuthSession{
+					DeviceIDHash:     hashString(deviceID),
+					RefreshTokenHash: hashString(refreshToken),
+					RefreshExpiresAt: pgtype.Timestamptz{Time: now.Add(22 * 24 * time.Hour), Valid: true},
+					RotatedAt:        pgtype.Timestamptz{Time: now.Add(-8 * 24 * time.Hour), Valid: true},
+					FamilyID:         familyID,
+				}, nil
+			}).
+			onRevokeAuthSessionFamily(func(_ context.Context, arg sqlc.RevokeAuthSessionFamilyParams) (int64, error) {
+				revoked = arg
+				return 2, nil
+			}).
+			onCreateAuthSession(func(context.Context, sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
+				created = true
+				return sqlc.AuthSession{}, nil
+			}).
+			build()
+
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{
+			AccessTTL:    15 * time.Minute,
+			RefreshTTL:   30 * 24 * time.Hour,
+			RefreshGrace: 30 * time.Second,
+		}, nil)
+		api.clock = func() time.Time { return now }
+
+		rec, _ := refreshRequestWith(api, refreshToken, deviceID)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status 401, got %d", rec.Code)
+		}
+		if !lookup.RefreshExpiresAt.Time.Equal(now) {
+			t.Fatalf("expected lookup bounded by refresh expiry only, got %+v", lookup)
+		}
+		if revoked.FamilyID != familyID || !revoked.RevokedAt.Time.Equal(now) {
+			t.Fatalf("expected family %v to be revoked at %v, got %+v", familyID, now, revoked)
+		}
+		if created {
+			t.Fatal("expected no session to be issued for a reused token")
+		}
+	})
+
+	t.Run("reused token is rejected when family revoke fails", func(t *testing.T) {
+		deviceID := "device-123"
+		now := time.Now()
+
+		q := newQuerierBuilder().
+			onGetAuthSessionByRefreshHashForUpdate(func(_ context.Context, _ sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error) {
+				return sqlc.AuthSession{
+					DeviceIDHash: hashString(deviceID),
+					RotatedAt:    pgtype.Timestamptz{Time: now.Add(-time.Minute), Valid: true},
+				}, nil
+			}).
+			onRevokeAuthSessionFamily(func(context.Context, sqlc.RevokeAuthSessionFamilyParams) (int64, error) {
+				return 0, errors.New("db down")
+			}).
+			build()
+
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{RefreshGrace: 30 * time.Second}, nil)
+		api.clock = func() time.Time { return now }
+
+		body, _ := json.Marshal(refreshRequest{RefreshToken: "refresh-token"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewReader(body))
+		req.Header.Set("X-Device-Id", deviceID)
+		rec := httptest.NewRecorder()
+
+		api.handleRefresh(rec, req)
+
 		if rec.Code != http.StatusUnauthorized {
 			t.Fatalf("expected status 401, got %d", rec.Code)
 		}
@@ -875,12 +2225,12 @@ func TestHandleRefresh(t *testing.T) {
 
 	t.Run("not found", func(t *testing.T) {
 		q := newQuerierBuilder().
-			onGetAuthSessionByRefreshHash(func(context.Context, sqlc.GetAuthSessionByRefreshHashParams) (sqlc.AuthSession, error) {
+			onGetAuthSessionByRefreshHashForUpdate(func(context.Context, sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error) {
 				return sqlc.AuthSession{}, pgx.ErrNoRows
 			}).
 			build()
 
-		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{}, nil)
 
 		body, _ := json.Marshal(refreshRequest{RefreshToken: "refresh-token"})
 		req := httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewReader(body))
@@ -1013,3 +2363,259 @@ func TestHandleLogoutInputValidation(t *testing.T) {
 	}
 }
 
+// fakeSessionStore is an in-memory auth_sessions table. A transaction holds
+// the row lock from GetAuthSessionByRefreshHashForUpdate until it commits or
+// rolls back, like SELECT ... FOR UPDATE on the presented session.
+type fakeSessionStore struct {
+	lock     sync.Mutex
+	mu       sync.Mutex
+	sessions map[pgtype.UUID]*sqlc.AuthSession
+	rotates  int
+	nextID   byte
+}
+
+func newFakeSessionStore() *fakeSessionStore {
+	return &fakeSessionStore{sessions: make(map[pgtype.UUID]*sqlc.AuthSession), nextID: 1}
+}
+
+func (f *fakeSessionStore) BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+	return &lockingTx{mu: &f.lock}, nil
+}
+
+func (f *fakeSessionStore) Querier() sqlc.Querier {
+	panic("refresh must run inside a transaction")
+}
+
+func (f *fakeSessionStore) WithTx(tx pgx.Tx) sqlc.Querier {
+	ltx := tx.(*lockingTx)
+	return newQuerierBuilder().
+		onGetAuthSessionByRefreshHashForUpdate(func(_ context.Context, arg sqlc.GetAuthSessionByRefreshHashForUpdateParams) (sqlc.AuthSession, error) {
+			f.lock.Lock()
+			ltx.locked = true
+			// Widen the race window so unsynchronised code would interleave.
+			time.Sleep(time.Millisecond)
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			for _, session := range f.sessions {
+				if bytes.Equal(session.RefreshTokenHash, arg.RefreshTokenHash) && !session.RevokedAt.Valid &&
+					session.RefreshExpiresAt.Time.After(arg.RefreshExpiresAt.Time) {
+					return *session, nil
+				}
+			}
+			return sqlc.AuthSession{}, pgx.ErrNoRows
+		}).
+		onRotateAuthSession(func(_ context.Context, arg sqlc.RotateAuthSessionParams) (int64, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			session := f.sessions[arg.ID]
+			if session == nil || session.RotatedAt.Valid || session.RevokedAt.Valid {
+				return 0, nil
+			}
+			session.RotatedAt = arg.RotatedAt
+			f.rotates++
+			return 1, nil
+		}).
+		onRevokeSiblingAuthSessions(func(_ context.Context, arg sqlc.RevokeSiblingAuthSessionsParams) error {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			for _, session := range f.sessions {
+				if session.ParentID == arg.ParentID && session.ID != arg.ID && !session.RotatedAt.Valid && !session.RevokedAt.Valid {
+					session.RevokedAt = arg.RevokedAt
+				}
+			}
+			return nil
+		}).
+		onRevokeAuthSessionFamily(func(_ context.Context, arg sqlc.RevokeAuthSessionFamilyParams) (int64, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			var n int64
+			for _, session := range f.sessions {
+				if session.FamilyID == arg.FamilyID && !session.RevokedAt.Valid {
+					session.RevokedAt = arg.RevokedAt
+					n++
+				}
+			}
+			return n, nil
+		}).
+		onCreateAuthSession(func(_ context.Context, arg sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
+			return f.add(sqlc.AuthSession{
+				UserID:           arg.UserID,
+				DeviceIDHash:     arg.DeviceIDHash,
+				AccessTokenHash:  arg.AccessTokenHash,
+				AccessExpiresAt:  arg.AccessExpiresAt,
+				RefreshTokenHash: arg.RefreshTokenHash,
+				RefreshExpiresAt: arg.RefreshExpiresAt,
+				FamilyID:         arg.FamilyID,
+				ParentID:         arg.ParentID,
+			}), nil
+		}).
+		onMarkAuthSessionUsed(func(_ context.Context, arg sqlc.MarkAuthSessionUsedParams) error {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if session := f.sessions[arg.ID]; session != nil {
+				session.LastUsedAt = arg.LastUsedAt
+			}
+			return nil
+		}).
+		build()
+}
+
+func (f *fakeSessionStore) add(session sqlc.AuthSession) sqlc.AuthSession {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session.ID = pgtype.UUID{Bytes: [16]byte{f.nextID}, Valid: true}
+	f.nextID++
+	if !session.FamilyID.Valid {
+		session.FamilyID = pgtype.UUID{Bytes: [16]byte{0xff, f.nextID}, Valid: true}
+	}
+	f.sessions[session.ID] = &session
+	return session
+}
+
+// live returns the sessions that can still be refreshed.
+func (f *fakeSessionStore) live() []sqlc.AuthSession {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []sqlc.AuthSession
+	for _, session := range f.sessions {
+		if !session.RevokedAt.Valid && !session.RotatedAt.Valid {
+			out = append(out, *session)
+		}
+	}
+	return out
+}
+
+func refreshRequestWith(api *API, refreshToken, deviceID string) (*httptest.ResponseRecorder, authResponse) {
+	body, _ := json.Marshal(refreshRequest{RefreshToken: refreshToken})
+	req := httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewReader(body))
+	req.Header.Set("X-Device-Id", deviceID)
+	rec := httptest.NewRecorder()
+	api.handleRefresh(rec, req)
+	var resp authResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp
+}
+
+func TestHandleRefreshConcurrentSameToken(t *testing.T) {
+	const callers = 8
+	deviceID := "device-123"
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	sessions := newFakeSessionStore()
+	root := sessions.add(sqlc.AuthSession{
+		UserID:           pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+		DeviceIDHash:     hashString(deviceID),
+		RefreshTokenHash: hashString("refresh-token"),
+		RefreshExpiresAt: toTimestamptz(now.Add(time.Hour)),
+	})
+
+	api := New(sessions, &mailer.LogMailer{}, Settings{
+		AccessTTL:    15 * time.Minute,
+		RefreshTTL:   24 * time.Hour,
+		RefreshGrace: 30 * time.Second,
+	}, nil)
+	api.clock = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	codes := make([]int, callers)
+	tokens := make([]string, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec, resp := refreshRequestWith(api, "refresh-token", deviceID)
+			codes[i] = rec.Code
+			tokens[i] = resp.RefreshToken
+		}(i)
+	}
+	wg.Wait()
+
+	// Every caller inside the grace window gets tokens, but the presented
+	// session is rotated exactly once and all new sessions hang off it.
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("caller %d: expected status 200, got %d", i, code)
+		}
+	}
+	if sessions.rotates != 1 {
+		t.Fatalf("expected exactly one rotation, got %d", sessions.rotates)
+	}
+	live := sessions.live()
+	if len(live) != callers {
+		t.Fatalf("expected %d sibling sessions, got %d", callers, len(live))
+	}
+	for _, session := range live {
+		if session.ParentID != root.ID || session.FamilyID != root.FamilyID {
+			t.Fatalf("expected sibling of root in family %v, got parent %v family %v", root.FamilyID, session.ParentID, session.FamilyID)
+		}
+	}
+
+	// Refreshing any sibling collapses the family back to a single chain.
+	if rec, _ := refreshRequestWith(api, tokens[0], deviceID); rec.Code != http.StatusOK {
+		t.Fatalf("expected sibling refresh to succeed, got %d", rec.Code)
+	}
+	live = sessions.live()
+	if len(live) != 1 || live[0].FamilyID != root.FamilyID {
+		t.Fatalf("expected a single live session in the family, got %+v", live)
+	}
+	if rec, _ := refreshRequestWith(api, tokens[1], deviceID); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected revoked sibling to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestHandleRefreshConcurrentChains(t *testing.T) {
+	deviceID := "device-123"
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	sessions := newFakeSessionStore()
+	root := sessions.add(sqlc.AuthSession{
+		UserID:           pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+		DeviceIDHash:     hashString(deviceID),
+		RefreshTokenHash: hashString("refresh-token"),
+		RefreshExpiresAt: toTimestamptz(now.Add(time.Hour)),
+	})
+
+	api := New(sessions, &mailer.LogMailer{}, Settings{
+		AccessTTL:    15 * time.Minute,
+		RefreshTTL:   24 * time.Hour,
+		RefreshGrace: 30 * time.Second,
+	}, nil)
+	api.clock = func() time.Time { return now }
+
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		rec, resp := refreshRequestWith(api, "refresh-token", deviceID)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		tokens = append(tokens, resp.RefreshToken)
+	}
+
+	// Both siblings refreshed at once: whichever locks first wins and revokes
+	// the other, so only one chain survives.
+	var wg sync.WaitGroup
+	codes := make([]int, len(tokens))
+	for i, token := range tokens {
+		wg.Add(1)
+		go func(i int, token string) {
+			defer wg.Done()
+			rec, _ := refreshRequestWith(api, token, deviceID)
+			codes[i] = rec.Code
+		}(i, token)
+	}
+	wg.Wait()
+
+	ok := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			ok++
+		} else if code != http.StatusUnauthorized {
+			t.Fatalf("unexpected status %d", code)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("expected exactly one sibling refresh to win, got %v", codes)
+	}
+	live := sessions.live()
+	if len(live) != 1 || live[0].FamilyID != root.FamilyID {
+		t.Fatalf("expected a single live session in the family, got %+v", live)
+	}
+}
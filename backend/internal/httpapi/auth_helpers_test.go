@@ -17,6 +17,11 @@ func TestNormalizeEmail(t *testing.T) {
 		t.Fatalf("expected normalized email, got %q", email)
 	}
 
+	email, ok = normalizeEmail("Jürgen@Bücher.DE")
+	if !ok || email != "jürgen@xn--bcher-kva.de" {
+		t.Fatalf("expected punycode domain, got %q", email)
+	}
+
 	if _, ok := normalizeEmail("not-an-email"); ok {
 		t.Fatal("expected invalid email to fail")
 	}
@@ -0,0 +1,88 @@
+package httpapi
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// canonicalHost lowercases a domain and maps internationalized names to their
+// punycode form so bücher.de and xn--bcher-kva.de are the same domain.
+func canonicalHost(domain string) (string, bool) {
+	domain = strings.TrimSuffix(strings.TrimSpace(domain), ".")
+	if domain == "" {
+		return "", false
+	}
+	host, err := idna.Lookup.ToASCII(domain)
+	if err != nil || host == "" {
+		return "", false
+	}
+	return host, true
+}
+
+// teamDomainHost canonicalizes a domain an admin wants to add to their team.
+// Public suffixes such as co.uk can never belong to a team.
+func teamDomainHost(domain string) (string, bool) {
+	host, ok := canonicalHost(domain)
+	if !ok {
+		return "", false
+	}
+	if _, err := publicsuffix.EffectiveTLDPlusOne(host); err != nil {
+		return "", false
+	}
+	return host, true
+}
+
+// registrableDomain returns the public suffix plus one label, e.g.
+// eng.acme.co.uk becomes acme.co.uk. Hosts the embedded public suffix list
+// can't split, such as single labels, are returned unchanged.
+func registrableDomain(host string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// hostWithin reports whether host is domain or one of its subdomains.
+func hostWithin(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// friendlyTeamName derives a default team name from the label in front of the
+// public suffix, so acme.co.uk becomes "Acme". A subdomain that keeps its own
+// team appends its labels: eng.acme.com becomes "Acme Eng".
+func friendlyTeamName(host string) string {
+	registrable := registrableDomain(host)
+	suffix, _ := publicsuffix.PublicSuffix(registrable)
+	base := strings.TrimSuffix(strings.TrimSuffix(registrable, suffix), ".")
+	if base == "" {
+		return host
+	}
+
+	words := []string{titleLabel(base)}
+	if sub := strings.TrimSuffix(strings.TrimSuffix(host, registrable), "."); sub != "" {
+		labels := strings.Split(sub, ".")
+		for i := len(labels) - 1; i >= 0; i-- {
+			words = append(words, titleLabel(labels[i]))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func titleLabel(label string) string {
+	if decoded, err := idna.ToUnicode(label); err == nil {
+		label = decoded
+	}
+	parts := strings.FieldsFunc(label, func(r rune) bool {
+		return r == '-' || r == '_'
+	})
+	for i, part := range parts {
+		r, size := utf8.DecodeRuneInString(part)
+		parts[i] = string(unicode.ToUpper(r)) + part[size:]
+	}
+	return strings.Join(parts, " ")
+}
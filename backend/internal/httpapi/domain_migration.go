@@ -0,0 +1,480 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	migrationStatusCompleted = "completed"
+	migrationStatusCancelled = "cancelled"
+)
+
+// Where a member stands in their team's domain migration. Members whose
+// address was never at the old domain, such as invited contractors, have
+// nothing to move.
+const (
+	memberMigrationPending       = "pending"
+	memberMigrationMigrated      = "migrated"
+	memberMigrationNotApplicable = "not_applicable"
+)
+
+var (
+	errNoDomainMigration    = errors.New("no domain migration in progress")
+	errMigrationNotNeeded   = errors.New("address is not at the old domain")
+	errMigrationWrongDomain = errors.New("address is not at the new domain")
+	errEmailInUse           = errors.New("email already in use")
+	errMembersNotMigrated   = errors.New("members still at the old domain")
+)
+
+type startDomainMigrationRequest struct {
+	ToDomain string `json:"to_domain"`
+}
+
+type migrationEmailRequest struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+type domainMigrationResponse struct {
+	ID               string     `json:"id"`
+	FromDomain       string     `json:"from_domain"`
+	ToDomain         string     `json:"to_domain"`
+	Status           string     `json:"status"`
+	RemainingMembers int64      `json:"remaining_members"`
+	CreatedAt        time.Time  `json:"created_at"`
+	FinishedAt       *time.Time `json:"finished_at,omitempty"`
+}
+
+type memberMigrationResponse struct {
+	FromDomain string `json:"from_domain"`
+	ToDomain   string `json:"to_domain"`
+	Status     string `json:"status"`
+}
+
+func (a *API) handleGetDomainMigration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	q := a.store.Querier()
+	migration, err := q.GetActiveTeamDomainMigration(ctx, teamID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errNoDomainMigration
+		}
+		a.writeDomainMigrationError(w, err)
+		return
+	}
+	a.writeDomainMigration(ctx, w, q, migration, http.StatusOK)
+}
+
+// handleStartDomainMigration opens a rebrand from the team's primary domain
+// to one of its verified aliases. Proving control of the new domain is the
+// alias verification, so a migration can't start before it.
+func (a *API) handleStartDomainMigration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	adminID, _ := userIDFromContext(ctx)
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	var req startDomainMigrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	host, ok := teamDomainHost(req.ToDomain)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid domain")
+		return
+	}
+
+	q := a.store.Querier()
+	team, err := q.GetTeamByID(ctx, teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start domain migration")
+		return
+	}
+	if host == team.Domain {
+		writeError(w, http.StatusBadRequest, "domain is already the primary domain")
+		return
+	}
+	alias, err := q.GetTeamDomain(ctx, sqlc.GetTeamDomainParams{TeamID: teamID, Domain: host})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusInternalServerError, "failed to start domain migration")
+		return
+	}
+	if err != nil || !alias.VerifiedAt.Valid {
+		writeError(w, http.StatusConflict, "add and verify the new domain first")
+		return
+	}
+
+	migration, err := q.CreateTeamDomainMigration(ctx, sqlc.CreateTeamDomainMigrationParams{
+		TeamID:          teamID,
+		FromDomain:      team.Domain,
+		ToDomain:        host,
+		CreatedByUserID: adminID,
+		CreatedAt:       toTimestamptz(a.clock()),
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			writeError(w, http.StatusConflict, "a domain migration is already in progress")
+			return
+		}
+		a.logger.Error("failed to start domain migration", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to start domain migration")
+		return
+	}
+
+	a.writeDomainMigration(ctx, w, q, migration, http.StatusCreated)
+}
+
+// handleCompleteDomainMigration makes the new domain primary and retires the
+// old one, so new signups at the old domain no longer find the team. It
+// waits until every member has confirmed a new address: the confirm
+// endpoints only work while the migration is active, so anyone still at the
+// old domain would be stranded. Admins remove members who won't move.
+//
+// The team stays verified through the new domain's own TXT record, which the
+// recheck job keeps covering now that the domain is primary; claims on the
+// old domain are dropped so nothing vouches for a domain the team left.
+func (a *API) handleCompleteDomainMigration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	var completed sqlc.TeamDomainMigration
+	err := a.withTeamLock(ctx, teamID, func(q sqlc.Querier) error {
+		migration, err := q.GetActiveTeamDomainMigration(ctx, teamID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errNoDomainMigration
+		}
+		if err != nil {
+			return err
+		}
+		remaining, err := q.CountTeamMembersAtDomain(ctx, sqlc.CountTeamMembersAtDomainParams{
+			TeamID: teamID,
+			Domain: migration.FromDomain,
+		})
+		if err != nil {
+			return err
+		}
+		if remaining > 0 {
+			return errMembersNotMigrated
+		}
+		alias, err := q.GetTeamDomain(ctx, sqlc.GetTeamDomainParams{TeamID: teamID, Domain: migration.ToDomain})
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if err != nil || !alias.VerifiedAt.Valid {
+			return errTeamDomainUnverified
+		}
+		if _, err := q.SetTeamPrimaryDomain(ctx, sqlc.SetTeamPrimaryDomainParams{
+			ID:     teamID,
+			Domain: migration.ToDomain,
+		}); err != nil {
+			return err
+		}
+		if _, err := q.DeleteTeamDomain(ctx, sqlc.DeleteTeamDomainParams{
+			TeamID: teamID,
+			Domain: migration.FromDomain,
+		}); err != nil {
+			return err
+		}
+		if err := q.SetTeamDomainVerified(ctx, sqlc.SetTeamDomainVerifiedParams{
+			ID:               teamID,
+			DomainVerifiedAt: alias.VerifiedAt,
+		}); err != nil {
+			return err
+		}
+		if _, err := q.DeleteTeamDomainVerifications(ctx, sqlc.DeleteTeamDomainVerificationsParams{
+			TeamID: teamID,
+			Domain: migration.FromDomain,
+		}); err != nil {
+			return err
+		}
+		completed, err = q.FinishTeamDomainMigration(ctx, sqlc.FinishTeamDomainMigrationParams{
+			ID:         migration.ID,
+			Status:     migrationStatusCompleted,
+			FinishedAt: toTimestamptz(a.clock()),
+		})
+		return err
+	})
+	if err != nil {
+		a.writeDomainMigrationError(w, err)
+		return
+	}
+
+	a.writeDomainMigration(ctx, w, a.store.Querier(), completed, http.StatusOK)
+}
+
+func (a *API) handleCancelDomainMigration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	q := a.store.Querier()
+	migration, err := q.GetActiveTeamDomainMigration(ctx, teamID)
+	if err == nil {
+		_, err = q.FinishTeamDomainMigration(ctx, sqlc.FinishTeamDomainMigrationParams{
+			ID:         migration.ID,
+			Status:     migrationStatusCancelled,
+			FinishedAt: toTimestamptz(a.clock()),
+		})
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errNoDomainMigration
+		}
+		a.writeDomainMigrationError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetMyDomainMigration tells a member whether their team is moving
+// domains and whether they still need to confirm a new address.
+func (a *API) handleGetMyDomainMigration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := userIDFromContext(ctx)
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	q := a.store.Querier()
+	migration, err := q.GetActiveTeamDomainMigration(ctx, teamID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errNoDomainMigration
+		}
+		a.writeDomainMigrationError(w, err)
+		return
+	}
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load domain migration")
+		return
+	}
+
+	status := memberMigrationNotApplicable
+	switch {
+	case hostWithin(user.EmailDomain, migration.ToDomain):
+		status = memberMigrationMigrated
+	case hostWithin(user.EmailDomain, migration.FromDomain):
+		status = memberMigrationPending
+	}
+	writeJSON(w, http.StatusOK, memberMigrationResponse{
+		FromDomain: migration.FromDomain,
+		ToDomain:   migration.ToDomain,
+		Status:     status,
+	})
+}
+
+// handleRequestMigrationCode mails a code to the member's new address. The
+// local part may change with the rebrand, so the member names the address.
+func (a *API) handleRequestMigrationCode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := userIDFromContext(ctx)
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	var req migrationEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	q := a.store.Querier()
+	if _, _, err := checkMigrationAddress(ctx, q, teamID, userID, email); err != nil {
+		a.writeDomainMigrationError(w, err)
+		return
+	}
+	if err := a.sendEmailCode(ctx, email, a.clock()); err != nil {
+		a.writeEmailCodeError(w, err, "failed to send verification code")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleConfirmMigration moves the member to their new address once they
+// prove they receive mail there. The user row keeps its ID, so memberships,
+// sessions and timezone state come along untouched.
+func (a *API) handleConfirmMigration(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := userIDFromContext(ctx)
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+	role, _ := roleFromContext(ctx)
+
+	var req migrationEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "email and code are required")
+		return
+	}
+	code := normalizeCode(req.Code)
+	if !isValidCode(code) {
+		writeError(w, http.StatusBadRequest, "invalid code format")
+		return
+	}
+
+	now := a.clock()
+	if err := a.checkCodeLockout(ctx, email, now); err != nil {
+		a.writeEmailCodeError(w, err, "failed to check rate limit")
+		return
+	}
+
+	tx, err := a.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.store.WithTx(tx)
+	user, host, err := checkMigrationAddress(ctx, q, teamID, userID, email)
+	if err != nil {
+		a.writeDomainMigrationError(w, err)
+		return
+	}
+	if err := a.redeemEmailCode(ctx, q, email, code, now); err != nil {
+		a.writeEmailCodeError(w, err, "failed to verify code")
+		return
+	}
+	user, err = q.UpdateUserEmail(ctx, sqlc.UpdateUserEmailParams{
+		ID:          user.ID,
+		Email:       email,
+		EmailDomain: host,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			err = errEmailInUse
+		}
+		a.writeDomainMigrationError(w, err)
+		return
+	}
+	team, err := q.GetTeamByID(ctx, teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load account")
+		return
+	}
+
+	if a.commitBootstrap(ctx, w, tx, q, user, team, role, now, http.StatusOK) {
+		a.resetCodeLockout(ctx, email)
+	}
+}
+
+// checkMigrationAddress makes sure the team is migrating, the member still
+// has an address at the old domain and email is an unused address at the new
+// one. It returns the member and the new address's domain.
+func checkMigrationAddress(ctx context.Context, q sqlc.Querier, teamID, userID pgtype.UUID, email string) (sqlc.User, string, error) {
+	migration, err := q.GetActiveTeamDomainMigration(ctx, teamID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.User{}, "", errNoDomainMigration
+	}
+	if err != nil {
+		return sqlc.User{}, "", err
+	}
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		return sqlc.User{}, "", err
+	}
+	if !hostWithin(user.EmailDomain, migration.FromDomain) {
+		return sqlc.User{}, "", errMigrationNotNeeded
+	}
+	host, _ := emailDomain(email)
+	if !hostWithin(host, migration.ToDomain) {
+		return sqlc.User{}, "", errMigrationWrongDomain
+	}
+	if _, err := q.GetUserByEmail(ctx, email); err == nil {
+		return sqlc.User{}, "", errEmailInUse
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.User{}, "", err
+	}
+	return user, host, nil
+}
+
+func (a *API) writeDomainMigration(ctx context.Context, w http.ResponseWriter, q sqlc.Querier, migration sqlc.TeamDomainMigration, status int) {
+	remaining, err := q.CountTeamMembersAtDomain(ctx, sqlc.CountTeamMembersAtDomainParams{
+		TeamID: migration.TeamID,
+		Domain: migration.FromDomain,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load domain migration")
+		return
+	}
+
+	resp := domainMigrationResponse{
+		ID:               uuidString(migration.ID),
+		FromDomain:       migration.FromDomain,
+		ToDomain:         migration.ToDomain,
+		Status:           migration.Status,
+		RemainingMembers: remaining,
+		CreatedAt:        migration.CreatedAt.Time,
+	}
+	if migration.FinishedAt.Valid {
+		finishedAt := migration.FinishedAt.Time
+		resp.FinishedAt = &finishedAt
+	}
+	writeJSON(w, status, resp)
+}
+
+func (a *API) writeDomainMigrationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errNoDomainMigration):
+		writeError(w, http.StatusNotFound, "no domain migration in progress")
+	case errors.Is(err, errMigrationNotNeeded):
+		writeError(w, http.StatusConflict, "your address is not at the old domain")
+	case errors.Is(err, errMigrationWrongDomain):
+		writeError(w, http.StatusBadRequest, "address must be at the new domain")
+	case errors.Is(err, errEmailInUse):
+		writeError(w, http.StatusConflict, "an account already uses this address")
+	case errors.Is(err, errMembersNotMigrated):
+		writeError(w, http.StatusConflict, "some members still need to confirm their new address")
+	case errors.Is(err, errTeamDomainUnverified):
+		writeError(w, http.StatusConflict, "the new domain is no longer verified")
+	default:
+		a.logger.Error("failed to migrate domain", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to migrate domain")
+	}
+}
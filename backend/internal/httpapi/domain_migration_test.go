@@ -0,0 +1,432 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestHandleStartDomainMigration(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	newQuerier := func(createErr error, created *sqlc.CreateTeamDomainMigrationParams) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "acme.com"}, nil
+			}).
+			onGetTeamDomain(func(_ context.Context, arg sqlc.GetTeamDomainParams) (sqlc.TeamDomain, error) {
+				switch arg.Domain {
+				case "acme.io":
+					return sqlc.TeamDomain{TeamID: teamID, Domain: arg.Domain, VerifiedAt: toTimestamptz(now)}, nil
+				case "acme.dev":
+					return sqlc.TeamDomain{TeamID: teamID, Domain: arg.Domain}, nil
+				}
+				return sqlc.TeamDomain{}, pgx.ErrNoRows
+			}).
+			onCreateTeamDomainMigration(func(_ context.Context, arg sqlc.CreateTeamDomainMigrationParams) (sqlc.TeamDomainMigration, error) {
+				if createErr != nil {
+					return sqlc.TeamDomainMigration{}, createErr
+				}
+				*created = arg
+				return sqlc.TeamDomainMigration{
+					TeamID:     arg.TeamID,
+					FromDomain: arg.FromDomain,
+					ToDomain:   arg.ToDomain,
+					Status:     "active",
+					CreatedAt:  arg.CreatedAt,
+				}, nil
+			}).
+			onCountTeamMembersAtDomain(func(_ context.Context, arg sqlc.CountTeamMembersAtDomainParams) (int64, error) {
+				if arg.Domain != "acme.com" {
+					t.Fatalf("unexpected domain: %q", arg.Domain)
+				}
+				return 12, nil
+			}).
+			build()
+	}
+
+	tests := []struct {
+		name       string
+		domain     string
+		createErr  error
+		wantStatus int
+	}{
+		{name: "verified alias", domain: "Acme.IO", wantStatus: http.StatusCreated},
+		{name: "unverified alias", domain: "acme.dev", wantStatus: http.StatusConflict},
+		{name: "unknown domain", domain: "globex.com", wantStatus: http.StatusConflict},
+		{name: "already primary", domain: "acme.com", wantStatus: http.StatusBadRequest},
+		{name: "invalid", domain: "co.uk", wantStatus: http.StatusBadRequest},
+		{name: "already in progress", domain: "acme.io", createErr: &pgconn.PgError{Code: pgUniqueViolation}, wantStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created sqlc.CreateTeamDomainMigrationParams
+			api := New(&stubStore{querier: newQuerier(tt.createErr, &created)}, &mailer.LogMailer{}, Settings{}, nil)
+			api.clock = func() time.Time { return now }
+
+			body, _ := json.Marshal(startDomainMigrationRequest{ToDomain: tt.domain})
+			req := authedRequest(http.MethodPost, "/team/domain-migration", body, adminID, teamID, "admin")
+			rec := httptest.NewRecorder()
+			api.handleStartDomainMigration(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusCreated {
+				return
+			}
+			if created.TeamID != teamID || created.FromDomain != "acme.com" || created.ToDomain != "acme.io" || created.CreatedByUserID != adminID {
+				t.Fatalf("unexpected migration: %+v", created)
+			}
+			var resp domainMigrationResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Status != "active" || resp.RemainingMembers != 12 || !resp.CreatedAt.Equal(now) || resp.FinishedAt != nil {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestHandleCompleteDomainMigration(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	migrationID := pgtype.UUID{Bytes: [16]byte{7}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	aliasVerifiedAt := toTimestamptz(now.Add(-48 * time.Hour))
+
+	tests := []struct {
+		name       string
+		remaining  int64
+		unverified bool
+		wantStatus int
+	}{
+		{name: "everyone moved", wantStatus: http.StatusOK},
+		{name: "members still at the old domain", remaining: 2, wantStatus: http.StatusConflict},
+		{name: "new domain verification revoked", unverified: true, wantStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var primary, removed string
+			var counted sqlc.CountTeamMembersAtDomainParams
+			var verified sqlc.SetTeamDomainVerifiedParams
+			var dropped sqlc.DeleteTeamDomainVerificationsParams
+			var finished sqlc.FinishTeamDomainMigrationParams
+			q := newQuerierBuilder().
+				onGetTeamByIDForUpdate(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+					return sqlc.Team{ID: id, Domain: "acme.com"}, nil
+				}).
+				onGetActiveTeamDomainMigration(func(context.Context, pgtype.UUID) (sqlc.TeamDomainMigration, error) {
+					return sqlc.TeamDomainMigration{ID: migrationID, TeamID: teamID, FromDomain: "acme.com", ToDomain: "acme.io", Status: "active"}, nil
+				}).
+				onCountTeamMembersAtDomain(func(_ context.Context, arg sqlc.CountTeamMembersAtDomainParams) (int64, error) {
+					counted = arg
+					return tt.remaining, nil
+				}).
+				onGetTeamDomain(func(_ context.Context, arg sqlc.GetTeamDomainParams) (sqlc.TeamDomain, error) {
+					domain := sqlc.TeamDomain{TeamID: arg.TeamID, Domain: arg.Domain, Token: pgtype.Text{String: "tok", Valid: true}}
+					if arg.Domain == "acme.io" && !tt.unverified {
+						domain.VerifiedAt = aliasVerifiedAt
+					}
+					return domain, nil
+				}).
+				onSetTeamDomainVerified(func(_ context.Context, arg sqlc.SetTeamDomainVerifiedParams) error {
+					verified = arg
+					return nil
+				}).
+				onDeleteTeamDomainVerifications(func(_ context.Context, arg sqlc.DeleteTeamDomainVerificationsParams) (int64, error) {
+					dropped = arg
+					return 1, nil
+				}).
+				onSetTeamPrimaryDomain(func(_ context.Context, arg sqlc.SetTeamPrimaryDomainParams) (sqlc.Team, error) {
+					primary = arg.Domain
+					return sqlc.Team{ID: arg.ID, Domain: arg.Domain}, nil
+				}).
+				onDeleteTeamDomain(func(_ context.Context, arg sqlc.DeleteTeamDomainParams) (int64, error) {
+					removed = arg.Domain
+					return 1, nil
+				}).
+				onFinishTeamDomainMigration(func(_ context.Context, arg sqlc.FinishTeamDomainMigrationParams) (sqlc.TeamDomainMigration, error) {
+					finished = arg
+					return sqlc.TeamDomainMigration{ID: arg.ID, TeamID: teamID, FromDomain: "acme.com", ToDomain: "acme.io", Status: arg.Status, FinishedAt: arg.FinishedAt}, nil
+				}).
+				build()
+			api := New(newTxStore(q), &mailer.LogMailer{}, Settings{}, nil)
+			api.clock = func() time.Time { return now }
+
+			req := authedRequest(http.MethodPost, "/team/domain-migration/complete", nil, adminID, teamID, "admin")
+			rec := httptest.NewRecorder()
+			api.handleCompleteDomainMigration(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if counted.TeamID != teamID || counted.Domain != "acme.com" {
+				t.Fatalf("expected members at acme.com to be counted, got %+v", counted)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if primary != "" || removed != "" || verified.ID.Valid || finished.ID.Valid {
+					t.Fatalf("expected the migration to stay active, got primary %q, removed %q, finish %+v", primary, removed, finished)
+				}
+				return
+			}
+			if primary != "acme.io" || removed != "acme.com" {
+				t.Fatalf("expected acme.io primary and acme.com removed, got %q and %q", primary, removed)
+			}
+			if verified.ID != teamID || verified.DomainVerifiedAt != aliasVerifiedAt {
+				t.Fatalf("expected the team verified as of acme.io's verification, got %+v", verified)
+			}
+			if dropped.TeamID != teamID || dropped.Domain != "acme.com" {
+				t.Fatalf("expected acme.com claims to be dropped, got %+v", dropped)
+			}
+			if finished.ID != migrationID || finished.Status != migrationStatusCompleted || !finished.FinishedAt.Time.Equal(now) {
+				t.Fatalf("unexpected finish: %+v", finished)
+			}
+			var resp domainMigrationResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Status != migrationStatusCompleted || resp.FinishedAt == nil || !resp.FinishedAt.Equal(now) {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestHandleCancelDomainMigration(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+
+	tests := []struct {
+		name       string
+		active     bool
+		wantStatus int
+	}{
+		{name: "active", active: true, wantStatus: http.StatusNoContent},
+		{name: "none", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status string
+			q := newQuerierBuilder().
+				onGetActiveTeamDomainMigration(func(context.Context, pgtype.UUID) (sqlc.TeamDomainMigration, error) {
+					if !tt.active {
+						return sqlc.TeamDomainMigration{}, pgx.ErrNoRows
+					}
+					return sqlc.TeamDomainMigration{TeamID: teamID, Status: "active"}, nil
+				}).
+				onFinishTeamDomainMigration(func(_ context.Context, arg sqlc.FinishTeamDomainMigrationParams) (sqlc.TeamDomainMigration, error) {
+					status = arg.Status
+					return sqlc.TeamDomainMigration{Status: arg.Status}, nil
+				}).
+				build()
+			api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+			req := authedRequest(http.MethodDelete, "/team/domain-migration", nil, adminID, teamID, "admin")
+			rec := httptest.NewRecorder()
+			api.handleCancelDomainMigration(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.active && status != migrationStatusCancelled {
+				t.Fatalf("expected cancelled, got %q", status)
+			}
+		})
+	}
+}
+
+func TestHandleGetMyDomainMigration(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+
+	tests := map[string]string{
+		"acme.com":     memberMigrationPending,
+		"eu.acme.com":  memberMigrationPending,
+		"acme.io":      memberMigrationMigrated,
+		"contract.org": memberMigrationNotApplicable,
+	}
+	for domain, want := range tests {
+		t.Run(domain, func(t *testing.T) {
+			q := newQuerierBuilder().
+				onGetActiveTeamDomainMigration(func(context.Context, pgtype.UUID) (sqlc.TeamDomainMigration, error) {
+					return sqlc.TeamDomainMigration{TeamID: teamID, FromDomain: "acme.com", ToDomain: "acme.io", Status: "active"}, nil
+				}).
+				onGetUserByID(func(_ context.Context, id pgtype.UUID) (sqlc.User, error) {
+					return sqlc.User{ID: id, Email: "ana@" + domain, EmailDomain: domain}, nil
+				}).
+				build()
+			api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+			req := authedRequest(http.MethodGet, "/me/domain-migration", nil, userID, teamID, "member")
+			rec := httptest.NewRecorder()
+			api.handleGetMyDomainMigration(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+			var resp memberMigrationResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Status != want || resp.ToDomain != "acme.io" {
+				t.Fatalf("expected status %q, got %+v", want, resp)
+			}
+		})
+	}
+}
+
+func TestHandleRequestMigrationCode(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+
+	newQuerier := func(userDomain string) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetActiveTeamDomainMigration(func(context.Context, pgtype.UUID) (sqlc.TeamDomainMigration, error) {
+				return sqlc.TeamDomainMigration{TeamID: teamID, FromDomain: "acme.com", ToDomain: "acme.io", Status: "active"}, nil
+			}).
+			onGetUserByID(func(_ context.Context, id pgtype.UUID) (sqlc.User, error) {
+				return sqlc.User{ID: id, Email: "ana@" + userDomain, EmailDomain: userDomain}, nil
+			}).
+			onGetUserByEmail(func(_ context.Context, email string) (sqlc.User, error) {
+				if email == "taken@acme.io" {
+					return sqlc.User{Email: email}, nil
+				}
+				return sqlc.User{}, pgx.ErrNoRows
+			}).
+			build()
+	}
+
+	tests := []struct {
+		name       string
+		userDomain string
+		email      string
+		wantStatus int
+	}{
+		{name: "new address", userDomain: "acme.com", email: "Ana.Lopez@acme.io", wantStatus: http.StatusNoContent},
+		{name: "wrong domain", userDomain: "acme.com", email: "ana@gmail.com", wantStatus: http.StatusBadRequest},
+		{name: "already moved", userDomain: "acme.io", email: "ana2@acme.io", wantStatus: http.StatusConflict},
+		{name: "address taken", userDomain: "acme.com", email: "taken@acme.io", wantStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &stubMailer{}
+			api := New(&stubStore{querier: newQuerier(tt.userDomain)}, m, Settings{
+				RequestCodeEmailLimit:  3,
+				RequestCodeEmailWindow: time.Minute,
+			}, nil)
+
+			body, _ := json.Marshal(migrationEmailRequest{Email: tt.email})
+			req := authedRequest(http.MethodPost, "/me/domain-migration/request-code", body, userID, teamID, "member")
+			rec := httptest.NewRecorder()
+			api.handleRequestMigrationCode(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			wantCalls := 0
+			if tt.wantStatus == http.StatusNoContent {
+				wantCalls = 1
+			}
+			if m.calls != wantCalls {
+				t.Fatalf("expected %d mails, got %d", wantCalls, m.calls)
+			}
+			if wantCalls == 1 && m.lastEmail != "ana.lopez@acme.io" {
+				t.Fatalf("unexpected recipient: %q", m.lastEmail)
+			}
+		})
+	}
+}
+
+func TestHandleConfirmMigration(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	newQuerier := func(codeValid bool, updated *sqlc.UpdateUserEmailParams) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetActiveTeamDomainMigration(func(context.Context, pgtype.UUID) (sqlc.TeamDomainMigration, error) {
+				return sqlc.TeamDomainMigration{TeamID: teamID, FromDomain: "acme.com", ToDomain: "acme.io", Status: "active"}, nil
+			}).
+			onGetUserByID(func(_ context.Context, id pgtype.UUID) (sqlc.User, error) {
+				return sqlc.User{ID: id, Email: "ana@acme.com", EmailDomain: "acme.com"}, nil
+			}).
+			onGetUserByEmail(func(context.Context, string) (sqlc.User, error) {
+				return sqlc.User{}, pgx.ErrNoRows
+			}).
+			onGetEmailVerificationCode(func(_ context.Context, arg sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+				if !codeValid || arg.Email != "ana@acme.io" {
+					return sqlc.EmailVerificationCode{}, pgx.ErrNoRows
+				}
+				return sqlc.EmailVerificationCode{ID: pgtype.UUID{Bytes: [16]byte{8}, Valid: true}}, nil
+			}).
+			onUpdateUserEmail(func(_ context.Context, arg sqlc.UpdateUserEmailParams) (sqlc.User, error) {
+				*updated = arg
+				return sqlc.User{ID: arg.ID, Email: arg.Email, EmailDomain: arg.EmailDomain}, nil
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "acme.com"}, nil
+			}).
+			build()
+	}
+
+	tests := []struct {
+		name       string
+		codeValid  bool
+		wantStatus int
+	}{
+		{name: "valid code", codeValid: true, wantStatus: http.StatusOK},
+		{name: "invalid code", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var updated sqlc.UpdateUserEmailParams
+			tx := &testTx{}
+			api := New(&stubStore{
+				querier: newQuerier(tt.codeValid, &updated),
+				beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+					return tx, nil
+				},
+			}, &mailer.LogMailer{}, Settings{
+				VerifyCodeEmailLimit:  5,
+				VerifyCodeEmailWindow: 15 * time.Minute,
+				VerifyCodeLock:        15 * time.Minute,
+			}, nil)
+			api.clock = func() time.Time { return now }
+
+			body, _ := json.Marshal(migrationEmailRequest{Email: "ana@acme.io", Code: "ABCD2345"})
+			req := authedRequest(http.MethodPost, "/me/domain-migration/confirm", body, userID, teamID, "member")
+			rec := httptest.NewRecorder()
+			api.handleConfirmMigration(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if !tt.codeValid {
+				if updated.Email != "" || tx.committed {
+					t.Fatalf("expected no update, got %+v", updated)
+				}
+				return
+			}
+			if updated.ID != userID || updated.Email != "ana@acme.io" || updated.EmailDomain != "acme.io" {
+				t.Fatalf("unexpected update: %+v", updated)
+			}
+			if !tx.committed {
+				t.Fatal("expected transaction to commit")
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestCanonicalHost(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Example.COM", "example.com"},
+		{"example.com.", "example.com"},
+		{"bücher.de", "xn--bcher-kva.de"},
+		{"XN--BCHER-KVA.de", "xn--bcher-kva.de"},
+		{"例子.测试", "xn--fsqu00a.xn--0zwm56d"},
+	}
+	for _, tt := range tests {
+		got, ok := canonicalHost(tt.in)
+		if !ok || got != tt.want {
+			t.Fatalf("canonicalHost(%q) = %q, %v; want %q", tt.in, got, ok, tt.want)
+		}
+	}
+	for _, in := range []string{"", " ", "exa mple.com", "-bad-.com"} {
+		if _, ok := canonicalHost(in); ok {
+			t.Fatalf("expected %q to be rejected", in)
+		}
+	}
+}
+
+func TestTeamDomainHost(t *testing.T) {
+	for in, want := range map[string]string{"Acme.IO": "acme.io", "eng.acme.co.uk": "eng.acme.co.uk", "bücher.de": "xn--bcher-kva.de"} {
+		if got, ok := teamDomainHost(in); !ok || got != want {
+			t.Fatalf("teamDomainHost(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "com", "co.uk", "exa mple.com"} {
+		if _, ok := teamDomainHost(in); ok {
+			t.Fatalf("expected %q to be rejected", in)
+		}
+	}
+}
+
+func TestRegistrableDomain(t *testing.T) {
+	tests := map[string]string{
+		"acme.com":             "acme.com",
+		"eng.acme.com":         "acme.com",
+		"mail.eu.acme.co.uk":   "acme.co.uk",
+		"team.github.io":       "team.github.io",
+		"localhost":            "localhost",
+		"co.uk":                "co.uk",
+		"xn--bcher-kva.de":     "xn--bcher-kva.de",
+		"a.b.xn--bcher-kva.de": "xn--bcher-kva.de",
+	}
+	for in, want := range tests {
+		if got := registrableDomain(in); got != want {
+			t.Fatalf("registrableDomain(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFriendlyTeamName(t *testing.T) {
+	tests := map[string]string{
+		"acme.com":         "Acme",
+		"acme.co.uk":       "Acme",
+		"eng.acme.com":     "Acme Eng",
+		"us.eng.acme.com":  "Acme Eng Us",
+		"big-corp.io":      "Big Corp",
+		"xn--bcher-kva.de": "Bücher",
+		"localhost":        "localhost",
+	}
+	for in, want := range tests {
+		if got := friendlyTeamName(in); got != want {
+			t.Fatalf("friendlyTeamName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFindDomainTeam(t *testing.T) {
+	acme := sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{1}, Valid: true}, Domain: "acme.com", Name: "Acme", IncludeSubdomains: true}
+
+	newQuerier := func(teams map[string]sqlc.Team) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+				if team, ok := teams[domain]; ok {
+					return team, nil
+				}
+				return sqlc.Team{}, pgx.ErrNoRows
+			}).
+			build()
+	}
+
+	tests := []struct {
+		name       string
+		teams      map[string]sqlc.Team
+		host       string
+		wantTeam   pgtype.UUID
+		wantDomain string
+	}{
+		{
+			name:       "subdomain joins parent team",
+			teams:      map[string]sqlc.Team{"acme.com": acme},
+			host:       "eng.acme.com",
+			wantTeam:   acme.ID,
+			wantDomain: "acme.com",
+		},
+		{
+			name:       "unclaimed subdomain claims registrable domain",
+			teams:      map[string]sqlc.Team{},
+			host:       "eng.acme.co.uk",
+			wantDomain: "acme.co.uk",
+		},
+		{
+			name:       "parent keeps subdomains separate",
+			teams:      map[string]sqlc.Team{"acme.com": {ID: acme.ID, Domain: "acme.com"}},
+			host:       "eng.acme.com",
+			wantDomain: "eng.acme.com",
+		},
+		{
+			name: "existing subdomain team wins",
+			teams: map[string]sqlc.Team{
+				"acme.com":     acme,
+				"eng.acme.com": {ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, Domain: "eng.acme.com"},
+			},
+			host:       "eng.acme.com",
+			wantTeam:   pgtype.UUID{Bytes: [16]byte{3}, Valid: true},
+			wantDomain: "eng.acme.com",
+		},
+		{
+			name:       "parent with separate subdomains still matches itself",
+			teams:      map[string]sqlc.Team{"acme.com": {ID: acme.ID, Domain: "acme.com"}},
+			host:       "acme.com",
+			wantTeam:   acme.ID,
+			wantDomain: "acme.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			team, domain, err := findDomainTeam(context.Background(), newQuerier(tt.teams), tt.host)
+			if tt.wantTeam.Valid {
+				if err != nil || team.ID != tt.wantTeam {
+					t.Fatalf("expected team %v, got %v (err=%v)", tt.wantTeam, team.ID, err)
+				}
+			} else if !errors.Is(err, pgx.ErrNoRows) {
+				t.Fatalf("expected ErrNoRows, got %v", err)
+			}
+			if domain != tt.wantDomain {
+				t.Fatalf("expected domain %q, got %q", tt.wantDomain, domain)
+			}
+		})
+	}
+}
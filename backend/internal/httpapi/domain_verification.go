@@ -0,0 +1,290 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"timesync/backend/internal/domainverify"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	errDomainNotVerifiable        = errors.New("domain can't be verified")
+	errDomainTeamNotFound         = errors.New("no team for domain")
+	errDomainAlreadyVerified      = errors.New("domain already verified")
+	errDomainVerificationNotFound = errors.New("domain verification not found")
+)
+
+type domainVerificationResponse struct {
+	Domain      string     `json:"domain"`
+	RecordType  string     `json:"record_type"`
+	RecordName  string     `json:"record_name"`
+	RecordValue string     `json:"record_value"`
+	Verified    bool       `json:"verified"`
+	VerifiedAt  *time.Time `json:"verified_at,omitempty"`
+	CheckedAt   *time.Time `json:"checked_at,omitempty"`
+}
+
+// handleStartDomainVerification hands the caller a TXT record that proves
+// they control their email domain. Each user gets their own token, so only
+// whoever published the record can claim the team with it. Calling it again
+// returns the same record.
+func (a *API) handleStartDomainVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q := a.store.Querier()
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start domain verification")
+		return
+	}
+	team, err := a.verifiableDomainTeam(ctx, q, user)
+	if err != nil {
+		a.writeDomainVerificationError(w, err)
+		return
+	}
+	if team.DomainVerifiedAt.Valid {
+		verification, err := q.GetDomainVerification(ctx, sqlc.GetDomainVerificationParams{
+			TeamID: team.ID,
+			UserID: user.ID,
+		})
+		if err == nil && verification.VerifiedAt.Valid {
+			writeJSON(w, http.StatusOK, newDomainVerificationResponse(verification))
+			return
+		}
+		a.writeDomainVerificationError(w, errDomainAlreadyVerified)
+		return
+	}
+
+	token, err := domainverify.NewToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start domain verification")
+		return
+	}
+	verification, err := q.UpsertDomainVerification(ctx, sqlc.UpsertDomainVerificationParams{
+		TeamID:    team.ID,
+		UserID:    user.ID,
+		Domain:    team.Domain,
+		Token:     token,
+		CreatedAt: toTimestamptz(a.clock()),
+	})
+	if err != nil {
+		a.logger.Error("failed to save domain verification", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to start domain verification")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newDomainVerificationResponse(verification))
+}
+
+// handleCheckDomainVerification looks up the caller's TXT record. Once it is
+// published the team's domain is verified and the caller becomes its admin;
+// anyone who was admin only because they signed up first drops to member.
+// The lookup happens before the team lock so slow DNS doesn't block the team.
+func (a *API) handleCheckDomainVerification(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q := a.store.Querier()
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to check domain verification")
+		return
+	}
+	team, err := a.verifiableDomainTeam(ctx, q, user)
+	if err != nil {
+		a.writeDomainVerificationError(w, err)
+		return
+	}
+	verification, err := q.GetDomainVerification(ctx, sqlc.GetDomainVerificationParams{
+		TeamID: team.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = errDomainVerificationNotFound
+		}
+		a.writeDomainVerificationError(w, err)
+		return
+	}
+	if verification.VerifiedAt.Valid && team.DomainVerifiedAt.Valid {
+		writeJSON(w, http.StatusOK, newDomainVerificationResponse(verification))
+		return
+	}
+	if team.DomainVerifiedAt.Valid {
+		a.writeDomainVerificationError(w, errDomainAlreadyVerified)
+		return
+	}
+
+	published, err := domainverify.Check(ctx, a.resolver, verification.Domain, verification.Token)
+	if err != nil {
+		a.logger.Warn("domain verification lookup failed", slog.String("domain", verification.Domain), slog.Any("err", err))
+		writeError(w, http.StatusBadGateway, "failed to look up TXT record")
+		return
+	}
+
+	now := a.clock()
+	if !published {
+		if err := q.TouchDomainVerification(ctx, sqlc.TouchDomainVerificationParams{
+			ID:        verification.ID,
+			CheckedAt: toTimestamptz(now),
+		}); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to check domain verification")
+			return
+		}
+		verification.CheckedAt = toTimestamptz(now)
+		writeJSON(w, http.StatusOK, newDomainVerificationResponse(verification))
+		return
+	}
+
+	err = a.withTeamLock(ctx, team.ID, func(q sqlc.Querier) error {
+		locked, err := q.GetTeamByID(ctx, team.ID)
+		if err != nil {
+			return err
+		}
+		if locked.DomainVerifiedAt.Valid {
+			return errDomainAlreadyVerified
+		}
+		verification, err = q.MarkDomainVerified(ctx, sqlc.MarkDomainVerifiedParams{
+			ID:         verification.ID,
+			VerifiedAt: toTimestamptz(now),
+		})
+		if err != nil {
+			return err
+		}
+		if err := q.SetTeamDomainVerified(ctx, sqlc.SetTeamDomainVerifiedParams{
+			ID:               team.ID,
+			DomainVerifiedAt: toTimestamptz(now),
+		}); err != nil {
+			return err
+		}
+		return promoteDomainOwner(ctx, q, user, locked, now, a.settings.TeamSizeLimit)
+	})
+	if err != nil {
+		a.writeDomainVerificationError(w, err)
+		return
+	}
+
+	a.logger.Info("domain verified", slog.String("domain", verification.Domain), slog.String("user_id", uuidString(user.ID)))
+	writeJSON(w, http.StatusOK, newDomainVerificationResponse(verification))
+}
+
+// verifiableDomainTeam returns the team that owns the user's email domain. The
+// user must not belong to a different team.
+func (a *API) verifiableDomainTeam(ctx context.Context, q sqlc.Querier, user sqlc.User) (sqlc.Team, error) {
+	if a.freeMail.contains(user.EmailDomain) {
+		return sqlc.Team{}, errDomainNotVerifiable
+	}
+	team, _, err := findDomainTeam(ctx, q, user.EmailDomain)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.Team{}, errDomainTeamNotFound
+	}
+	if err != nil {
+		return sqlc.Team{}, err
+	}
+
+	membership, err := q.GetTeamMembershipByUserID(ctx, user.ID)
+	if err == nil && membership.TeamID != team.ID {
+		return sqlc.Team{}, errAlreadyInTeam
+	}
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.Team{}, err
+	}
+	return team, nil
+}
+
+// promoteDomainOwner makes user the team's only admin, adding them if they
+// aren't a member yet.
+func promoteDomainOwner(ctx context.Context, q sqlc.Querier, user sqlc.User, team sqlc.Team, now time.Time, teamSizeLimit int) error {
+	_, err := q.GetTeamMembership(ctx, sqlc.GetTeamMembershipParams{
+		TeamID: team.ID,
+		UserID: user.ID,
+	})
+	switch {
+	case err == nil:
+		if _, err := q.UpdateTeamMembershipRole(ctx, sqlc.UpdateTeamMembershipRoleParams{
+			TeamID: team.ID,
+			UserID: user.ID,
+			Role:   "admin",
+		}); err != nil {
+			return err
+		}
+	case errors.Is(err, pgx.ErrNoRows):
+		count, err := q.CountTeamMembers(ctx, team.ID)
+		if err != nil {
+			return err
+		}
+		if count >= int64(teamSizeLimit) {
+			return errTeamFull
+		}
+		if err := q.CreateTeamMembership(ctx, sqlc.CreateTeamMembershipParams{
+			TeamID:   team.ID,
+			UserID:   user.ID,
+			Role:     "admin",
+			JoinedAt: toTimestamptz(now),
+		}); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	_, err = q.DemoteOtherTeamAdmins(ctx, sqlc.DemoteOtherTeamAdminsParams{
+		TeamID: team.ID,
+		UserID: user.ID,
+	})
+	return err
+}
+
+func (a *API) writeDomainVerificationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errDomainNotVerifiable):
+		writeError(w, http.StatusForbidden, "free mail domains can't be verified")
+	case errors.Is(err, errDomainTeamNotFound):
+		writeError(w, http.StatusNotFound, "no team for your email domain")
+	case errors.Is(err, errDomainVerificationNotFound):
+		writeError(w, http.StatusNotFound, "domain verification not started")
+	case errors.Is(err, errDomainAlreadyVerified):
+		writeError(w, http.StatusConflict, "domain already verified")
+	case errors.Is(err, errAlreadyInTeam):
+		writeError(w, http.StatusConflict, "already a member of another team")
+	case errors.Is(err, errTeamFull):
+		writeError(w, http.StatusConflict, "team is full")
+	default:
+		a.logger.Error("failed to verify domain", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to verify domain")
+	}
+}
+
+func newDomainVerificationResponse(verification sqlc.DomainVerification) domainVerificationResponse {
+	resp := domainVerificationResponse{
+		Domain:      verification.Domain,
+		RecordType:  "TXT",
+		RecordName:  domainverify.RecordName(verification.Domain),
+		RecordValue: domainverify.RecordValue(verification.Token),
+		Verified:    verification.VerifiedAt.Valid,
+	}
+	if verification.VerifiedAt.Valid {
+		verifiedAt := verification.VerifiedAt.Time
+		resp.VerifiedAt = &verifiedAt
+	}
+	if verification.CheckedAt.Valid {
+		checkedAt := verification.CheckedAt.Time
+		resp.CheckedAt = &checkedAt
+	}
+	return resp
+}
@@ -0,0 +1,331 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type fakeResolver struct {
+	records map[string][]string
+	err     error
+	lookups int
+}
+
+func (r *fakeResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	r.lookups++
+	if r.err != nil {
+		return nil, r.err
+	}
+	records, ok := r.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func TestHandleStartDomainVerification(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	type fixture struct {
+		emailDomain  string
+		verified     bool
+		ownsVerified bool
+		otherTeam    bool
+		upserted     *sqlc.UpsertDomainVerificationParams
+	}
+	newQuerier := func(f fixture) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetUserByID(func(context.Context, pgtype.UUID) (sqlc.User, error) {
+				return sqlc.User{ID: userID, Email: "sam@" + f.emailDomain, EmailDomain: f.emailDomain}, nil
+			}).
+			onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+				if domain != "acme.com" {
+					return sqlc.Team{}, pgx.ErrNoRows
+				}
+				team := sqlc.Team{ID: teamID, Domain: domain, IncludeSubdomains: true}
+				if f.verified {
+					team.DomainVerifiedAt = toTimestamptz(now.Add(-time.Hour))
+				}
+				return team, nil
+			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				if f.otherTeam {
+					return sqlc.TeamMembership{TeamID: pgtype.UUID{Bytes: [16]byte{9}, Valid: true}}, nil
+				}
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onGetDomainVerification(func(_ context.Context, arg sqlc.GetDomainVerificationParams) (sqlc.DomainVerification, error) {
+				if !f.ownsVerified {
+					return sqlc.DomainVerification{}, pgx.ErrNoRows
+				}
+				return sqlc.DomainVerification{TeamID: arg.TeamID, UserID: arg.UserID, Domain: "acme.com", Token: "mine", VerifiedAt: toTimestamptz(now)}, nil
+			}).
+			onUpsertDomainVerification(func(_ context.Context, arg sqlc.UpsertDomainVerificationParams) (sqlc.DomainVerification, error) {
+				*f.upserted = arg
+				return sqlc.DomainVerification{TeamID: arg.TeamID, UserID: arg.UserID, Domain: arg.Domain, Token: arg.Token, CreatedAt: arg.CreatedAt}, nil
+			}).
+			build()
+	}
+
+	tests := []struct {
+		name       string
+		fixture    fixture
+		wantStatus int
+		wantRecord bool
+	}{
+		{name: "issues record", fixture: fixture{emailDomain: "eng.acme.com"}, wantStatus: http.StatusOK, wantRecord: true},
+		{name: "free mail", fixture: fixture{emailDomain: "gmail.com"}, wantStatus: http.StatusForbidden},
+		{name: "no team", fixture: fixture{emailDomain: "other.com"}, wantStatus: http.StatusNotFound},
+		{name: "member elsewhere", fixture: fixture{emailDomain: "acme.com", otherTeam: true}, wantStatus: http.StatusConflict},
+		{name: "verified by someone else", fixture: fixture{emailDomain: "acme.com", verified: true}, wantStatus: http.StatusConflict},
+		{name: "verified by caller", fixture: fixture{emailDomain: "acme.com", verified: true, ownsVerified: true}, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var upserted sqlc.UpsertDomainVerificationParams
+			tt.fixture.upserted = &upserted
+			api := New(newTxStore(newQuerier(tt.fixture)), &mailer.LogMailer{}, Settings{}, nil)
+			api.clock = func() time.Time { return now }
+
+			req := authedRequest(http.MethodPost, "/team/domain-verification", nil, userID, pgtype.UUID{}, "")
+			rec := httptest.NewRecorder()
+			api.handleStartDomainVerification(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if !tt.wantRecord {
+				if upserted.Token != "" {
+					t.Fatalf("unexpected verification: %+v", upserted)
+				}
+				return
+			}
+			if upserted.TeamID != teamID || upserted.UserID != userID || upserted.Domain != "acme.com" || upserted.Token == "" || !upserted.CreatedAt.Time.Equal(now) {
+				t.Fatalf("unexpected verification: %+v", upserted)
+			}
+			var resp domainVerificationResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.RecordType != "TXT" || resp.RecordName != "_timesync.acme.com" || resp.RecordValue != "timesync-domain-verification="+upserted.Token || resp.Verified {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestHandleCheckDomainVerification(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	verificationID := pgtype.UUID{Bytes: [16]byte{4}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	type calls struct {
+		touched  bool
+		verified bool
+		role     string
+		created  string
+		demoted  sqlc.DemoteOtherTeamAdminsParams
+	}
+	newQuerier := func(member, started bool, c *calls) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetUserByID(func(context.Context, pgtype.UUID) (sqlc.User, error) {
+				return sqlc.User{ID: userID, Email: "it@acme.com", EmailDomain: "acme.com"}, nil
+			}).
+			onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+				return sqlc.Team{ID: teamID, Domain: domain}, nil
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "acme.com"}, nil
+			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				if member {
+					return sqlc.TeamMembership{TeamID: teamID, UserID: userID, Role: "member"}, nil
+				}
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onGetTeamMembership(func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+				if member {
+					return sqlc.TeamMembership{TeamID: teamID, UserID: userID, Role: "member"}, nil
+				}
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onGetDomainVerification(func(context.Context, sqlc.GetDomainVerificationParams) (sqlc.DomainVerification, error) {
+				if !started {
+					return sqlc.DomainVerification{}, pgx.ErrNoRows
+				}
+				return sqlc.DomainVerification{ID: verificationID, TeamID: teamID, UserID: userID, Domain: "acme.com", Token: "tok"}, nil
+			}).
+			onTouchDomainVerification(func(_ context.Context, arg sqlc.TouchDomainVerificationParams) error {
+				c.touched = arg.ID == verificationID && arg.CheckedAt.Time.Equal(now)
+				return nil
+			}).
+			onMarkDomainVerified(func(_ context.Context, arg sqlc.MarkDomainVerifiedParams) (sqlc.DomainVerification, error) {
+				return sqlc.DomainVerification{ID: arg.ID, Domain: "acme.com", Token: "tok", VerifiedAt: arg.VerifiedAt, CheckedAt: arg.VerifiedAt}, nil
+			}).
+			onSetTeamDomainVerified(func(_ context.Context, arg sqlc.SetTeamDomainVerifiedParams) error {
+				c.verified = arg.ID == teamID && arg.DomainVerifiedAt.Time.Equal(now)
+				return nil
+			}).
+			onUpdateTeamMembershipRole(func(_ context.Context, arg sqlc.UpdateTeamMembershipRoleParams) (sqlc.TeamMembership, error) {
+				c.role = arg.Role
+				return sqlc.TeamMembership{TeamID: arg.TeamID, UserID: arg.UserID, Role: arg.Role}, nil
+			}).
+			onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+				return 3, nil
+			}).
+			onCreateTeamMembership(func(_ context.Context, arg sqlc.CreateTeamMembershipParams) error {
+				c.created = arg.Role
+				return nil
+			}).
+			onDemoteOtherTeamAdmins(func(_ context.Context, arg sqlc.DemoteOtherTeamAdminsParams) (int64, error) {
+				c.demoted = arg
+				return 1, nil
+			}).
+			build()
+	}
+	published := &fakeResolver{records: map[string][]string{"_timesync.acme.com": {"timesync-domain-verification=tok"}}}
+
+	t.Run("record missing", func(t *testing.T) {
+		var c calls
+		api := New(newTxStore(newQuerier(true, true, &c)), &mailer.LogMailer{}, Settings{TeamSizeLimit: 30}, nil)
+		api.clock = func() time.Time { return now }
+		api.resolver = &fakeResolver{}
+
+		req := authedRequest(http.MethodPost, "/team/domain-verification/check", nil, userID, teamID, "member")
+		rec := httptest.NewRecorder()
+		api.handleCheckDomainVerification(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp domainVerificationResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Verified || resp.CheckedAt == nil || !c.touched || c.verified || c.role != "" {
+			t.Fatalf("expected an unverified check, got %+v (%+v)", resp, c)
+		}
+	})
+
+	t.Run("member reclaims squatted team", func(t *testing.T) {
+		var c calls
+		tx := &testTx{}
+		store := &stubStore{
+			querier: newQuerier(true, true, &c),
+			beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+				return tx, nil
+			},
+		}
+		api := New(store, &mailer.LogMailer{}, Settings{TeamSizeLimit: 30}, nil)
+		api.clock = func() time.Time { return now }
+		api.resolver = published
+
+		req := authedRequest(http.MethodPost, "/team/domain-verification/check", nil, userID, teamID, "member")
+		rec := httptest.NewRecorder()
+		api.handleCheckDomainVerification(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp domainVerificationResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if !resp.Verified || resp.VerifiedAt == nil || !resp.VerifiedAt.Equal(now) {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+		if !c.verified || c.role != "admin" || c.created != "" {
+			t.Fatalf("expected caller promoted on a verified team, got %+v", c)
+		}
+		if c.demoted.TeamID != teamID || c.demoted.UserID != userID {
+			t.Fatalf("expected other admins demoted, got %+v", c.demoted)
+		}
+		if !tx.committed {
+			t.Fatal("expected tx to be committed")
+		}
+	})
+
+	t.Run("outsider joins as admin", func(t *testing.T) {
+		var c calls
+		api := New(newTxStore(newQuerier(false, true, &c)), &mailer.LogMailer{}, Settings{TeamSizeLimit: 30}, nil)
+		api.clock = func() time.Time { return now }
+		api.resolver = published
+
+		req := authedRequest(http.MethodPost, "/team/domain-verification/check", nil, userID, pgtype.UUID{}, "")
+		rec := httptest.NewRecorder()
+		api.handleCheckDomainVerification(rec, req)
+
+		if rec.Code != http.StatusOK || c.created != "admin" || c.role != "" {
+			t.Fatalf("expected an admin membership, got %d %+v", rec.Code, c)
+		}
+	})
+
+	t.Run("not started", func(t *testing.T) {
+		var c calls
+		resolver := &fakeResolver{}
+		api := New(newTxStore(newQuerier(true, false, &c)), &mailer.LogMailer{}, Settings{TeamSizeLimit: 30}, nil)
+		api.resolver = resolver
+
+		req := authedRequest(http.MethodPost, "/team/domain-verification/check", nil, userID, teamID, "member")
+		rec := httptest.NewRecorder()
+		api.handleCheckDomainVerification(rec, req)
+
+		if rec.Code != http.StatusNotFound || resolver.lookups != 0 {
+			t.Fatalf("expected status 404 without a lookup, got %d (%d lookups)", rec.Code, resolver.lookups)
+		}
+	})
+
+	t.Run("lookup failure", func(t *testing.T) {
+		var c calls
+		api := New(newTxStore(newQuerier(true, true, &c)), &mailer.LogMailer{}, Settings{TeamSizeLimit: 30}, nil)
+		api.resolver = &fakeResolver{err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}}
+
+		req := authedRequest(http.MethodPost, "/team/domain-verification/check", nil, userID, teamID, "member")
+		rec := httptest.NewRecorder()
+		api.handleCheckDomainVerification(rec, req)
+
+		if rec.Code != http.StatusBadGateway || c.touched || c.verified {
+			t.Fatalf("expected status 502 with no changes, got %d %+v", rec.Code, c)
+		}
+	})
+}
+
+func TestEnsureMembershipVerifiedDomain(t *testing.T) {
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	user := sqlc.User{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}}
+	q := newQuerierBuilder().
+		onGetTeamMembership(func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+			return sqlc.TeamMembership{}, pgx.ErrNoRows
+		}).
+		onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+			return 0, nil
+		}).
+		build()
+
+	unverified := sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}}
+	role, err := ensureMembership(context.Background(), q, user, unverified, true, now, 30)
+	if err != nil || role != "admin" {
+		t.Fatalf("expected the first user of an empty team to be admin, got %q, %v", role, err)
+	}
+
+	verified := unverified
+	verified.DomainVerifiedAt = toTimestamptz(now)
+	role, err = ensureMembership(context.Background(), q, user, verified, true, now, 30)
+	if err != nil || role != "member" {
+		t.Fatalf("expected a verified team to withhold admin, got %q, %v", role, err)
+	}
+}
@@ -0,0 +1,267 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// What happens to a member's team when their new address belongs to another
+// domain. Staying keeps them in their team as an external member, the same
+// way invited contractors belong to it; moving leaves it and applies the new
+// domain's join policy.
+const (
+	emailChangeStay = "stay"
+	emailChangeMove = "move"
+)
+
+var errEmailUnchanged = errors.New("email unchanged")
+
+type changeEmailRequest struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+	Team  string `json:"team,omitempty"`
+}
+
+// handleRequestEmailChange mails a code to the address the user wants to
+// switch to. Users without a team can change their address too.
+func (a *API) handleRequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req changeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	q := a.store.Querier()
+	if _, err := checkNewEmail(ctx, q, userID, email); err != nil {
+		a.writeEmailChangeError(w, err)
+		return
+	}
+	if err := a.sendEmailCode(ctx, email, a.clock()); err != nil {
+		a.writeEmailCodeError(w, err, "failed to send verification code")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleConfirmEmailChange swaps the user's address once they prove they
+// receive mail at the new one, then tells the old address about it so a
+// hijacked account doesn't go unnoticed. It answers with the bootstrap state,
+// since moving teams can leave the user pending or without a team.
+func (a *API) handleConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req changeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "email and code are required")
+		return
+	}
+	code := normalizeCode(req.Code)
+	if !isValidCode(code) {
+		writeError(w, http.StatusBadRequest, "invalid code format")
+		return
+	}
+	action := req.Team
+	if action == "" {
+		action = emailChangeStay
+	}
+	if action != emailChangeStay && action != emailChangeMove {
+		writeError(w, http.StatusBadRequest, "team must be stay or move")
+		return
+	}
+
+	now := a.clock()
+	if err := a.checkCodeLockout(ctx, email, now); err != nil {
+		a.writeEmailCodeError(w, err, "failed to check rate limit")
+		return
+	}
+
+	tx, err := a.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.store.WithTx(tx)
+	user, err := checkNewEmail(ctx, q, userID, email)
+	if err != nil {
+		a.writeEmailChangeError(w, err)
+		return
+	}
+	if err := a.redeemEmailCode(ctx, q, email, code, now); err != nil {
+		a.writeEmailCodeError(w, err, "failed to verify code")
+		return
+	}
+
+	host, ok := emailDomain(email)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid email")
+		return
+	}
+	oldEmail := user.Email
+	user, err = q.UpdateUserEmail(ctx, sqlc.UpdateUserEmailParams{
+		ID:          user.ID,
+		Email:       email,
+		EmailDomain: host,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			err = errEmailInUse
+		}
+		a.writeEmailChangeError(w, err)
+		return
+	}
+
+	bootstrap, err := a.settleEmailChangeTeam(ctx, q, user, oldEmail, host, action, now)
+	if err != nil {
+		a.writeEmailChangeError(w, err)
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save email")
+		return
+	}
+
+	a.resetCodeLockout(ctx, email)
+	if err := a.mailer.SendEmailChanged(ctx, oldEmail, email); err != nil {
+		a.logger.Error("failed to send email change notice", slog.Any("err", err))
+	}
+	a.logger.Info("security: email address changed", slog.String("user_id", uuidString(user.ID)))
+
+	writeJSON(w, http.StatusOK, bootstrap)
+}
+
+// settleEmailChangeTeam applies the team consequences of user's new address
+// at host. Members stay put unless they asked to move and the new domain
+// resolves to a different team; moving fails for invite-only teams, so nobody
+// trades their team for nothing. Members whose team requires single sign-on
+// for their old address can't stay on an address outside the team's domains,
+// where email codes would let them in without the provider. Users without a
+// team go through the join policy as if they had just signed in; a team
+// requiring single sign-on only takes them through its provider, so moving
+// there fails too.
+func (a *API) settleEmailChangeTeam(ctx context.Context, q sqlc.Querier, user sqlc.User, oldEmail, host, action string, now time.Time) (bootstrapResponse, error) {
+	team, role, err := existingTeam(ctx, q, user)
+	switch {
+	case err == nil:
+		if !a.freeMail.contains(host) {
+			domainTeam, _, err := findDomainTeam(ctx, q, host)
+			if err == nil && domainTeam.ID == team.ID {
+				return loadBootstrap(ctx, q, user, team, role, now)
+			}
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return bootstrapResponse{}, err
+			}
+		}
+		if action == emailChangeStay {
+			provider, err := domainSSOProvider(ctx, q, oldEmail)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return bootstrapResponse{}, err
+			}
+			if err == nil && provider.TeamID == team.ID && provider.Required {
+				return bootstrapResponse{}, errSSORequired
+			}
+			return loadBootstrap(ctx, q, user, team, role, now)
+		}
+		if _, err := q.GetTeamByIDForUpdate(ctx, team.ID); err != nil {
+			return bootstrapResponse{}, err
+		}
+		if err := removeMembership(ctx, q, team.ID, user.ID); err != nil {
+			return bootstrapResponse{}, err
+		}
+	case !errors.Is(err, pgx.ErrNoRows):
+		return bootstrapResponse{}, err
+	}
+
+	err = checkSSORequired(ctx, q, user.Email)
+	switch {
+	case errors.Is(err, errSSORequired) && action != emailChangeMove:
+		// They join by signing in through the team's provider instead.
+		return loadJoinOutcome(ctx, q, user, sqlc.Team{}, "", joinStatusChooseTeam, nil, now)
+	case err != nil:
+		return bootstrapResponse{}, err
+	}
+
+	status := joinStatusMember
+	team, role, joinRequest, err := a.joinDomainTeam(ctx, q, user, host, false, now)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		status = joinStatusChooseTeam
+	case errors.Is(err, errInviteRequired) && action != emailChangeMove:
+		// A user without a team can still redeem an invite afterwards.
+		status = joinStatusChooseTeam
+	case err != nil:
+		return bootstrapResponse{}, err
+	case joinRequest != nil:
+		status = joinRequest.Status
+	}
+	return loadJoinOutcome(ctx, q, user, team, role, status, joinRequest, now)
+}
+
+// checkNewEmail makes sure email is a different address than the user's and
+// not taken by another account. It returns the user.
+func checkNewEmail(ctx context.Context, q sqlc.Querier, userID pgtype.UUID, email string) (sqlc.User, error) {
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		return sqlc.User{}, err
+	}
+	if user.Email == email {
+		return sqlc.User{}, errEmailUnchanged
+	}
+	if _, err := q.GetUserByEmail(ctx, email); err == nil {
+		return sqlc.User{}, errEmailInUse
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.User{}, err
+	}
+	return user, nil
+}
+
+func (a *API) writeEmailChangeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errEmailUnchanged):
+		writeError(w, http.StatusBadRequest, "email is unchanged")
+	case errors.Is(err, errEmailInUse):
+		writeError(w, http.StatusConflict, "an account already uses this address")
+	case errors.Is(err, errLastAdmin):
+		writeError(w, http.StatusConflict, "team must keep at least one admin")
+	case errors.Is(err, errTeamFull), errors.Is(err, errInviteRequired):
+		a.writeJoinError(w, err)
+	case errors.Is(err, errSSORequired):
+		writeError(w, http.StatusForbidden, "single sign-on required")
+	default:
+		a.logger.Error("failed to change email", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to change email")
+	}
+}
@@ -0,0 +1,332 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestHandleRequestEmailChange(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+
+	q := newQuerierBuilder().
+		onGetUserByID(func(_ context.Context, id pgtype.UUID) (sqlc.User, error) {
+			return sqlc.User{ID: id, Email: "ana@acme.com", EmailDomain: "acme.com"}, nil
+		}).
+		onGetUserByEmail(func(_ context.Context, email string) (sqlc.User, error) {
+			if email == "taken@globex.com" {
+				return sqlc.User{Email: email}, nil
+			}
+			return sqlc.User{}, pgx.ErrNoRows
+		}).
+		build()
+
+	tests := []struct {
+		name       string
+		email      string
+		wantStatus int
+	}{
+		{name: "new address", email: "Ana@Globex.com", wantStatus: http.StatusNoContent},
+		{name: "unchanged", email: "ana@acme.com", wantStatus: http.StatusBadRequest},
+		{name: "taken", email: "taken@globex.com", wantStatus: http.StatusConflict},
+		{name: "missing", email: " ", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &stubMailer{}
+			api := New(&stubStore{querier: q}, m, Settings{
+				RequestCodeEmailLimit:  3,
+				RequestCodeEmailWindow: time.Minute,
+			}, nil)
+
+			body, _ := json.Marshal(changeEmailRequest{Email: tt.email})
+			req := authedRequest(http.MethodPost, "/me/email/request-code", body, userID, pgtype.UUID{}, "")
+			rec := httptest.NewRecorder()
+			api.handleRequestEmailChange(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus == http.StatusNoContent && (m.calls != 1 || m.lastEmail != "ana@globex.com") {
+				t.Fatalf("expected code mailed to new address, got %d calls to %q", m.calls, m.lastEmail)
+			}
+			if tt.wantStatus != http.StatusNoContent && m.calls != 0 {
+				t.Fatalf("expected no mail, got %d", m.calls)
+			}
+		})
+	}
+}
+
+func TestHandleConfirmEmailChange(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	acmeID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	globexID := pgtype.UUID{Bytes: [16]byte{4}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	type outcome struct {
+		updated sqlc.UpdateUserEmailParams
+		left    bool
+		joined  pgtype.UUID
+	}
+	newQuerier := func(member bool, role string, members int64, codeValid bool, globexPolicy string, ssoRequired bool, out *outcome) sqlc.Querier {
+		if role == "" {
+			role = "member"
+		}
+		return newQuerierBuilder().
+			onGetUserByID(func(_ context.Context, id pgtype.UUID) (sqlc.User, error) {
+				return sqlc.User{ID: id, Email: "ana@acme.com", EmailDomain: "acme.com"}, nil
+			}).
+			onGetUserByEmail(func(context.Context, string) (sqlc.User, error) {
+				return sqlc.User{}, pgx.ErrNoRows
+			}).
+			onGetEmailVerificationCode(func(_ context.Context, arg sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+				if !codeValid {
+					return sqlc.EmailVerificationCode{}, pgx.ErrNoRows
+				}
+				return sqlc.EmailVerificationCode{ID: pgtype.UUID{Bytes: [16]byte{8}, Valid: true}, Email: arg.Email}, nil
+			}).
+			onUpdateUserEmail(func(_ context.Context, arg sqlc.UpdateUserEmailParams) (sqlc.User, error) {
+				out.updated = arg
+				return sqlc.User{ID: arg.ID, Email: arg.Email, EmailDomain: arg.EmailDomain}, nil
+			}).
+			onGetTeamMembershipByUserID(func(_ context.Context, id pgtype.UUID) (sqlc.TeamMembership, error) {
+				if !member {
+					return sqlc.TeamMembership{}, pgx.ErrNoRows
+				}
+				return sqlc.TeamMembership{TeamID: acmeID, UserID: id, Role: role}, nil
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "acme.com", JoinPolicy: joinPolicyOpen}, nil
+			}).
+			onGetTeamByIDForUpdate(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id}, nil
+			}).
+			onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+				if domain != "globex.com" || globexPolicy == "" {
+					return sqlc.Team{}, pgx.ErrNoRows
+				}
+				team := sqlc.Team{ID: globexID, Domain: domain, JoinPolicy: globexPolicy}
+				if ssoRequired {
+					team.DomainVerifiedAt = toTimestamptz(now)
+				}
+				return team, nil
+			}).
+			onGetTeamSsoProvider(func(_ context.Context, id pgtype.UUID) (sqlc.TeamSsoProvider, error) {
+				return sqlc.TeamSsoProvider{TeamID: id, Protocol: ssoProtocolOIDC, Required: ssoRequired}, nil
+			}).
+			onGetTeamMembership(func(_ context.Context, arg sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+				if member && arg.TeamID == acmeID {
+					return sqlc.TeamMembership{TeamID: acmeID, UserID: arg.UserID, Role: role}, nil
+				}
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onCountTeamAdmins(func(context.Context, pgtype.UUID) (int64, error) {
+				return 1, nil
+			}).
+			onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+				return members, nil
+			}).
+			onDeleteTeam(func(_ context.Context, id pgtype.UUID) error {
+				out.left = id == acmeID
+				return nil
+			}).
+			onDeleteTeamMembership(func(_ context.Context, arg sqlc.DeleteTeamMembershipParams) (int64, error) {
+				out.left = arg.TeamID == acmeID
+				return 1, nil
+			}).
+			onCreateTeamMembership(func(_ context.Context, arg sqlc.CreateTeamMembershipParams) error {
+				out.joined = arg.TeamID
+				return nil
+			}).
+			build()
+	}
+
+	tests := []struct {
+		name         string
+		member       bool
+		role         string
+		members      int64
+		codeValid    bool
+		globexPolicy string
+		ssoRequired  bool
+		team         string
+		wantStatus   int
+		wantJoin     string
+		wantLeft     bool
+		wantJoined   pgtype.UUID
+	}{
+		{name: "stay as external member", member: true, codeValid: true, globexPolicy: joinPolicyOpen, wantStatus: http.StatusOK, wantJoin: joinStatusMember},
+		{name: "move to open team", member: true, codeValid: true, globexPolicy: joinPolicyOpen, team: emailChangeMove, wantStatus: http.StatusOK, wantJoin: joinStatusMember, wantLeft: true, wantJoined: globexID},
+		{name: "move to unclaimed domain", member: true, codeValid: true, team: emailChangeMove, wantStatus: http.StatusOK, wantJoin: joinStatusChooseTeam, wantLeft: true},
+		{name: "sole admin moves and the team is deleted", member: true, role: "admin", members: 1, codeValid: true, globexPolicy: joinPolicyOpen, team: emailChangeMove, wantStatus: http.StatusOK, wantJoin: joinStatusMember, wantLeft: true, wantJoined: globexID},
+		{name: "last admin of a team cannot move", member: true, role: "admin", members: 2, codeValid: true, globexPolicy: joinPolicyOpen, team: emailChangeMove, wantStatus: http.StatusConflict},
+		{name: "move to invite-only team", member: true, codeValid: true, globexPolicy: joinPolicyInviteOnly, team: emailChangeMove, wantStatus: http.StatusForbidden},
+		{name: "move to team requiring sso", member: true, codeValid: true, globexPolicy: joinPolicyOpen, ssoRequired: true, team: emailChangeMove, wantStatus: http.StatusForbidden},
+		{name: "no team and sso required", codeValid: true, globexPolicy: joinPolicyOpen, ssoRequired: true, wantStatus: http.StatusOK, wantJoin: joinStatusChooseTeam},
+		{name: "no team joins open team", codeValid: true, globexPolicy: joinPolicyOpen, wantStatus: http.StatusOK, wantJoin: joinStatusMember, wantJoined: globexID},
+		{name: "no team and invite-only", codeValid: true, globexPolicy: joinPolicyInviteOnly, wantStatus: http.StatusOK, wantJoin: joinStatusChooseTeam},
+		{name: "invalid code", member: true, wantStatus: http.StatusUnauthorized},
+		{name: "unknown team choice", member: true, codeValid: true, team: "leave", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out outcome
+			tx := &testTx{}
+			m := &stubMailer{}
+			api := New(&stubStore{
+				querier: newQuerier(tt.member, tt.role, tt.members, tt.codeValid, tt.globexPolicy, tt.ssoRequired, &out),
+				beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+					return tx, nil
+				},
+			}, m, Settings{
+				TeamSizeLimit:         30,
+				VerifyCodeEmailLimit:  5,
+				VerifyCodeEmailWindow: 15 * time.Minute,
+				VerifyCodeLock:        15 * time.Minute,
+			}, nil)
+			api.clock = func() time.Time { return now }
+
+			teamID := pgtype.UUID{}
+			if tt.member {
+				teamID = acmeID
+			}
+			body, _ := json.Marshal(changeEmailRequest{Email: "ana@globex.com", Code: "ABCD2345", Team: tt.team})
+			req := authedRequest(http.MethodPost, "/me/email/confirm", body, userID, teamID, "member")
+			rec := httptest.NewRecorder()
+			api.handleConfirmEmailChange(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				if tx.committed || m.changedCalls != 0 {
+					t.Fatalf("expected no change, got committed=%v notices=%d", tx.committed, m.changedCalls)
+				}
+				return
+			}
+			if out.updated.ID != userID || out.updated.Email != "ana@globex.com" || out.updated.EmailDomain != "globex.com" {
+				t.Fatalf("unexpected update: %+v", out.updated)
+			}
+			if out.left != tt.wantLeft || out.joined != tt.wantJoined {
+				t.Fatalf("expected left=%v joined=%v, got %+v", tt.wantLeft, tt.wantJoined, out)
+			}
+			if !tx.committed {
+				t.Fatal("expected transaction to commit")
+			}
+			if m.changedCalls != 1 || m.lastEmail != "ana@acme.com" || m.lastNewEmail != "ana@globex.com" {
+				t.Fatalf("expected notice to old address, got %d to %q", m.changedCalls, m.lastEmail)
+			}
+			var resp bootstrapResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Status != tt.wantJoin || resp.User == nil || resp.User.Email != "ana@globex.com" {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+// TestConfirmEmailChangeKeepsRequiredSSO has a member of a team that requires
+// single sign-on try to stay in it on a free mail address, then sign in with
+// an emailed code at either address.
+func TestConfirmEmailChangeKeepsRequiredSSO(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	acmeID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	const email = "ana@acme.com"
+	var joined pgtype.UUID
+	q := newQuerierBuilder().
+		onGetUserByID(func(_ context.Context, id pgtype.UUID) (sqlc.User, error) {
+			return sqlc.User{ID: id, Email: email, EmailDomain: "acme.com"}, nil
+		}).
+		onGetUserByEmail(func(_ context.Context, address string) (sqlc.User, error) {
+			if address != email {
+				return sqlc.User{}, pgx.ErrNoRows
+			}
+			return sqlc.User{ID: userID, Email: email, EmailVerifiedAt: toTimestamptz(now)}, nil
+		}).
+		onCreateUser(func(_ context.Context, arg sqlc.CreateUserParams) (sqlc.User, error) {
+			return sqlc.User{ID: pgtype.UUID{Bytes: [16]byte{9}, Valid: true}, Email: arg.Email}, nil
+		}).
+		onGetEmailVerificationCode(func(_ context.Context, arg sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+			return sqlc.EmailVerificationCode{ID: pgtype.UUID{Bytes: [16]byte{8}, Valid: true}, Email: arg.Email}, nil
+		}).
+		onUpdateUserEmail(func(_ context.Context, arg sqlc.UpdateUserEmailParams) (sqlc.User, error) {
+			return sqlc.User{ID: arg.ID, Email: arg.Email, EmailDomain: arg.EmailDomain}, nil
+		}).
+		onGetTeamMembershipByUserID(func(_ context.Context, id pgtype.UUID) (sqlc.TeamMembership, error) {
+			if id != userID {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}
+			return sqlc.TeamMembership{TeamID: acmeID, UserID: id, Role: "member"}, nil
+		}).
+		onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+			return sqlc.Team{ID: id, Domain: "acme.com", JoinPolicy: joinPolicyOpen, DomainVerifiedAt: toTimestamptz(now)}, nil
+		}).
+		onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+			if domain != "acme.com" {
+				return sqlc.Team{}, pgx.ErrNoRows
+			}
+			return sqlc.Team{ID: acmeID, Domain: domain, JoinPolicy: joinPolicyOpen, DomainVerifiedAt: toTimestamptz(now)}, nil
+		}).
+		onGetTeamSsoProvider(func(_ context.Context, id pgtype.UUID) (sqlc.TeamSsoProvider, error) {
+			return sqlc.TeamSsoProvider{TeamID: id, Protocol: ssoProtocolOIDC, Required: true}, nil
+		}).
+		onCreateTeamMembership(func(_ context.Context, arg sqlc.CreateTeamMembershipParams) error {
+			joined = arg.TeamID
+			return nil
+		}).
+		build()
+
+	tx := &testTx{}
+	api := New(&stubStore{
+		querier: q,
+		beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+			return tx, nil
+		},
+	}, &stubMailer{}, Settings{
+		AccessTTL:             15 * time.Minute,
+		RefreshTTL:            24 * time.Hour,
+		TeamSizeLimit:         30,
+		VerifyCodeEmailLimit:  5,
+		VerifyCodeEmailWindow: 15 * time.Minute,
+		VerifyCodeLock:        15 * time.Minute,
+	}, nil)
+	api.clock = func() time.Time { return now }
+
+	body, _ := json.Marshal(changeEmailRequest{Email: "ana@gmail.com", Code: "ABCD2345", Team: emailChangeStay})
+	req := authedRequest(http.MethodPost, "/me/email/confirm", body, userID, acmeID, "member")
+	rec := httptest.NewRecorder()
+	api.handleConfirmEmailChange(rec, req)
+	if rec.Code != http.StatusForbidden || tx.committed {
+		t.Fatalf("expected staying off the team's domains to be refused, got %d (committed=%v)", rec.Code, tx.committed)
+	}
+
+	signIn := func(address string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(verifyCodeRequest{Email: address, Code: "ABCD2345"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/verify-code", bytes.NewReader(body))
+		req.Header.Set("X-Device-Id", "device-123")
+		rec := httptest.NewRecorder()
+		api.handleVerifyCode(rec, req)
+		return rec
+	}
+	if rec := signIn("ana@acme.com"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected code sign-in at the team's domain to require sso, got %d", rec.Code)
+	}
+	// The address never changed, so a code for the free mail address is a
+	// stranger's sign-in and doesn't reach the team.
+	if rec := signIn("ana@gmail.com"); rec.Code == http.StatusOK || joined.Valid {
+		t.Fatalf("expected the free mail address to stay out of the team, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,125 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	errCodeRequestLimited = errors.New("too many code requests")
+	errCodeInvalid        = errors.New("invalid code")
+	errCodeLocked         = errors.New("too many code attempts")
+)
+
+// sendEmailCode mails a fresh verification code to email, limited per
+// address. Every flow that proves a new address shares it.
+func (a *API) sendEmailCode(ctx context.Context, email string, now time.Time) error {
+	codeRow, err := a.issueEmailCode(ctx, email, now)
+	if err != nil {
+		return err
+	}
+	if err := a.mailer.SendVerificationCode(ctx, email, codeRow.Code); err != nil {
+		return fmt.Errorf("send code: %w", err)
+	}
+	return nil
+}
+
+// issueEmailCode stores a fresh verification code for email without mailing
+// it, limited per address.
+func (a *API) issueEmailCode(ctx context.Context, email string, now time.Time) (sqlc.EmailVerificationCode, error) {
+	allowed, err := a.emailLimit.Allow(ctx, email, a.settings.RequestCodeEmailLimit, a.settings.RequestCodeEmailWindow, now)
+	if err != nil {
+		return sqlc.EmailVerificationCode{}, fmt.Errorf("check request limit: %w", err)
+	}
+	if !allowed {
+		return sqlc.EmailVerificationCode{}, errCodeRequestLimited
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return sqlc.EmailVerificationCode{}, fmt.Errorf("generate code: %w", err)
+	}
+	codeRow, err := a.store.Querier().CreateEmailVerificationCode(ctx, sqlc.CreateEmailVerificationCodeParams{
+		Email:     email,
+		Code:      code,
+		ExpiresAt: toTimestamptz(now.Add(a.settings.CodeTTL)),
+	})
+	if err != nil {
+		return sqlc.EmailVerificationCode{}, fmt.Errorf("create code: %w", err)
+	}
+	return codeRow, nil
+}
+
+// checkCodeLockout fails while email is locked out after too many wrong
+// codes. Callers check before opening the transaction for redeemEmailCode.
+func (a *API) checkCodeLockout(ctx context.Context, email string, now time.Time) error {
+	locked, err := a.failLimit.IsLocked(ctx, email, now)
+	if err != nil {
+		return fmt.Errorf("check lockout: %w", err)
+	}
+	if locked {
+		return errCodeLocked
+	}
+	return nil
+}
+
+// redeemEmailCode marks a valid code for email used inside q's transaction.
+// Misses count towards the address's lockout outside the transaction, so they
+// stick even though the caller rolls back.
+func (a *API) redeemEmailCode(ctx context.Context, q sqlc.Querier, email, code string, now time.Time) error {
+	codeRow, err := q.GetEmailVerificationCode(ctx, sqlc.GetEmailVerificationCodeParams{
+		Email:     email,
+		Code:      code,
+		ExpiresAt: toTimestamptz(now),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		locked, err := a.failLimit.RegisterFailure(ctx, email, a.settings.VerifyCodeEmailLimit, a.settings.VerifyCodeEmailWindow, a.settings.VerifyCodeLock, now)
+		if err != nil {
+			return fmt.Errorf("record failed attempt: %w", err)
+		}
+		if locked {
+			return errCodeLocked
+		}
+		return errCodeInvalid
+	}
+	if err != nil {
+		return err
+	}
+
+	return q.MarkEmailVerificationCodeUsed(ctx, sqlc.MarkEmailVerificationCodeUsedParams{
+		ID:     codeRow.ID,
+		UsedAt: toTimestamptz(now),
+	})
+}
+
+// resetCodeLockout clears failed attempts once a code was accepted and the
+// caller's transaction committed.
+func (a *API) resetCodeLockout(ctx context.Context, email string) {
+	if err := a.failLimit.Reset(ctx, email); err != nil {
+		a.logger.Error("failed to reset lockout", slog.Any("err", err))
+	}
+}
+
+func (a *API) writeEmailCodeError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, errCodeRequestLimited):
+		writeError(w, http.StatusTooManyRequests, "too many requests")
+	case errors.Is(err, errCodeLocked):
+		writeError(w, http.StatusTooManyRequests, "too many attempts")
+	case errors.Is(err, errCodeInvalid):
+		writeError(w, http.StatusUnauthorized, "invalid code")
+	case errors.Is(err, errSSORequired):
+		writeError(w, http.StatusForbidden, "single sign-on required")
+	default:
+		a.logger.Error(message, slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
@@ -0,0 +1,66 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestSendEmailCodeLimit(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	m := &stubMailer{}
+	q := newQuerierBuilder().
+		onCreateEmailVerificationCode(func(_ context.Context, arg sqlc.CreateEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+			return sqlc.EmailVerificationCode{Email: arg.Email, Code: arg.Code, ExpiresAt: arg.ExpiresAt}, nil
+		}).
+		build()
+	api := New(&stubStore{querier: q}, m, Settings{
+		RequestCodeEmailLimit:  1,
+		RequestCodeEmailWindow: time.Minute,
+	}, nil)
+
+	if err := api.sendEmailCode(context.Background(), "ana@acme.com", now); err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	if err := api.sendEmailCode(context.Background(), "ana@acme.com", now); !errors.Is(err, errCodeRequestLimited) {
+		t.Fatalf("expected request limit, got %v", err)
+	}
+	if m.calls != 1 || m.lastCode == "" {
+		t.Fatalf("expected one code mailed, got %d", m.calls)
+	}
+}
+
+func TestRedeemEmailCodeLockout(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	q := newQuerierBuilder().
+		onGetEmailVerificationCode(func(context.Context, sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+			return sqlc.EmailVerificationCode{}, pgx.ErrNoRows
+		}).
+		build()
+	api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{
+		VerifyCodeEmailLimit:  2,
+		VerifyCodeEmailWindow: 15 * time.Minute,
+		VerifyCodeLock:        15 * time.Minute,
+	}, nil)
+	ctx := context.Background()
+
+	if err := api.redeemEmailCode(ctx, q, "ana@acme.com", "ABCD2345", now); !errors.Is(err, errCodeInvalid) {
+		t.Fatalf("expected invalid code, got %v", err)
+	}
+	if err := api.redeemEmailCode(ctx, q, "ana@acme.com", "ABCD2345", now); !errors.Is(err, errCodeLocked) {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+	if err := api.checkCodeLockout(ctx, "ana@acme.com", now); !errors.Is(err, errCodeLocked) {
+		t.Fatalf("expected locked address, got %v", err)
+	}
+	api.resetCodeLockout(ctx, "ana@acme.com")
+	if err := api.checkCodeLockout(ctx, "ana@acme.com", now); err != nil {
+		t.Fatalf("expected lockout cleared, got %v", err)
+	}
+}
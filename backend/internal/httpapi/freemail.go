@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"errors"
+	"strings"
+
+	"timesync/backend/internal/sqlc"
+)
+
+//go:embed data/freemail.txt
+var freeMailList string
+
+var errInviteRequired = errors.New("invite required")
+
+// freeMailDomains holds consumer mail providers whose users never create or
+// auto-join a domain team.
+type freeMailDomains map[string]struct{}
+
+// newFreeMailDomains parses the embedded list, adds extra and removes allowed.
+// Allowed wins so a deployment can opt a listed provider back into domain
+// teams.
+func newFreeMailDomains(list string, extra, allowed []string) freeMailDomains {
+	out := make(freeMailDomains)
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		if domain := normalizeListDomain(scanner.Text()); domain != "" {
+			out[domain] = struct{}{}
+		}
+	}
+	for _, value := range extra {
+		if domain := normalizeListDomain(value); domain != "" {
+			out[domain] = struct{}{}
+		}
+	}
+	for _, value := range allowed {
+		delete(out, normalizeListDomain(value))
+	}
+	return out
+}
+
+func normalizeListDomain(value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.HasPrefix(value, "#") {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(value, "@"))
+}
+
+// contains reports whether host or its registrable domain is listed, so
+// regional hosts like mail.yahoo.co.uk don't slip past yahoo.co.uk.
+func (d freeMailDomains) contains(host string) bool {
+	if _, ok := d[host]; ok {
+		return true
+	}
+	_, ok := d[registrableDomain(host)]
+	return ok
+}
+
+// existingTeam returns the team the user already belongs to, or pgx.ErrNoRows
+// when they have none.
+func existingTeam(ctx context.Context, q sqlc.Querier, user sqlc.User) (sqlc.Team, string, error) {
+	membership, err := q.GetTeamMembershipByUserID(ctx, user.ID)
+	if err != nil {
+		return sqlc.Team{}, "", err
+	}
+	team, err := q.GetTeamByID(ctx, membership.TeamID)
+	if err != nil {
+		return sqlc.Team{}, "", err
+	}
+	return team, membership.Role, nil
+}
@@ -0,0 +1,173 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestNewFreeMailDomains(t *testing.T) {
+	domains := newFreeMailDomains(freeMailList, []string{" Mail.Example ", "@post.example", ""}, []string{"GMX.net"})
+
+	for _, domain := range []string{"gmail.com", "outlook.com", "icloud.com", "mail.example", "post.example", "mail.yahoo.co.uk"} {
+		if !domains.contains(domain) {
+			t.Fatalf("expected %q to be a free mail domain", domain)
+		}
+	}
+	for _, domain := range []string{"gmx.net", "example.com", ""} {
+		if domains.contains(domain) {
+			t.Fatalf("expected %q not to be a free mail domain", domain)
+		}
+	}
+	for domain := range domains {
+		if domain != normalizeListDomain(domain) {
+			t.Fatalf("embedded list entry %q is not normalized", domain)
+		}
+	}
+}
+
+func TestExistingTeam(t *testing.T) {
+	user := sqlc.User{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Email: "user@gmail.com"}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+
+	t.Run("member", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{TeamID: teamID, UserID: user.ID, Role: "member"}, nil
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "acme.com", Name: "acme.com"}, nil
+			}).
+			build()
+
+		team, role, err := existingTeam(context.Background(), q, user)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if team.ID != teamID || role != "member" {
+			t.Fatalf("unexpected team/role: %v/%q", team.ID, role)
+		}
+	})
+
+	t.Run("no membership", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			build()
+
+		if _, _, err := existingTeam(context.Background(), q, user); !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("expected ErrNoRows, got %v", err)
+		}
+	})
+}
+
+func TestHandleVerifyCodeFreeMail(t *testing.T) {
+	now := time.Now()
+
+	newQuerier := func(email string, teamLookups *int) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetEmailVerificationCode(func(context.Context, sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+				return sqlc.EmailVerificationCode{ID: pgtype.UUID{Bytes: [16]byte{1}, Valid: true}}, nil
+			}).
+			onMarkEmailVerificationCodeUsed(func(context.Context, sqlc.MarkEmailVerificationCodeUsedParams) error {
+				return nil
+			}).
+			onGetUserByEmail(func(context.Context, string) (sqlc.User, error) {
+				return sqlc.User{
+					ID:              pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+					Email:           email,
+					EmailVerifiedAt: pgtype.Timestamptz{Time: now, Valid: true},
+				}, nil
+			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+				*teamLookups++
+				return sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, Domain: domain, Name: domain}, nil
+			}).
+			onGetTeamMembership(func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+				return 1, nil
+			}).
+			build()
+	}
+
+	verify := func(api *API, email string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(verifyCodeRequest{Email: email, Code: "ABCD2345"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/verify-code", bytes.NewReader(body))
+		req.Header.Set("X-Device-Id", "device-123")
+		rec := httptest.NewRecorder()
+		api.handleVerifyCode(rec, req)
+		return rec
+	}
+
+	settings := Settings{
+		VerifyCodeEmailLimit:  5,
+		VerifyCodeEmailWindow: 15 * time.Minute,
+		VerifyCodeLock:        15 * time.Minute,
+		TeamSizeLimit:         1,
+	}
+
+	t.Run("requires invite", func(t *testing.T) {
+		var teamLookups int
+		api := New(newTxStore(newQuerier("someone@gmail.com", &teamLookups)), &mailer.LogMailer{}, settings, nil)
+		api.clock = func() time.Time { return now }
+
+		rec := verify(api, "someone@gmail.com")
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected status 403, got %d", rec.Code)
+		}
+		if teamLookups != 0 {
+			t.Fatalf("expected no domain team lookup, got %d", teamLookups)
+		}
+	})
+
+	t.Run("configured domain requires invite", func(t *testing.T) {
+		var teamLookups int
+		custom := settings
+		custom.FreeMailDomains = []string{"mail.example"}
+		api := New(newTxStore(newQuerier("someone@mail.example", &teamLookups)), &mailer.LogMailer{}, custom, nil)
+		api.clock = func() time.Time { return now }
+
+		rec := verify(api, "someone@mail.example")
+		if rec.Code != http.StatusForbidden {
+			t.Fatalf("expected status 403, got %d", rec.Code)
+		}
+		if teamLookups != 0 {
+			t.Fatalf("expected no domain team lookup, got %d", teamLookups)
+		}
+	})
+
+	t.Run("allowed domain resolves domain team", func(t *testing.T) {
+		var teamLookups int
+		custom := settings
+		custom.FreeMailAllowedDomains = []string{"gmail.com"}
+		api := New(newTxStore(newQuerier("someone@gmail.com", &teamLookups)), &mailer.LogMailer{}, custom, nil)
+		api.clock = func() time.Time { return now }
+
+		rec := verify(api, "someone@gmail.com")
+		// The stubbed team is full, which proves the domain team was used.
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+		// One lookup checks for a single sign-on requirement, one joins.
+		if teamLookups != 2 {
+			t.Fatalf("expected two domain team lookups, got %d", teamLookups)
+		}
+	})
+}
@@ -0,0 +1,281 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	errInviteInvalid = errors.New("invalid invite code")
+	errAlreadyInTeam = errors.New("already a member of another team")
+)
+
+type createInviteRequest struct {
+	Email string `json:"email"`
+}
+
+type inviteResponse struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Expired   bool      `json:"expired"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type inviteListResponse struct {
+	Invites []inviteResponse `json:"invites"`
+}
+
+func (a *API) handleCreateInvite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := userIDFromContext(ctx)
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	var req createInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+
+	q := a.store.Querier()
+	invitee, err := q.GetUserByEmail(ctx, email)
+	if err == nil {
+		_, err = q.GetTeamMembershipByUserID(ctx, invitee.ID)
+		if err == nil {
+			writeError(w, http.StatusConflict, "user already belongs to a team")
+			return
+		}
+	}
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusInternalServerError, "failed to create invite")
+		return
+	}
+
+	count, err := q.CountTeamMembers(ctx, teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create invite")
+		return
+	}
+	if count >= int64(a.settings.TeamSizeLimit) {
+		writeError(w, http.StatusConflict, "team is full")
+		return
+	}
+
+	team, err := q.GetTeamByID(ctx, teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create invite")
+		return
+	}
+
+	now := a.clock()
+	var invite sqlc.InviteCode
+	err = withFreshCode(func(code string) (err error) {
+		invite, err = q.CreateInviteCode(ctx, sqlc.CreateInviteCodeParams{
+			TeamID:          teamID,
+			Email:           email,
+			Code:            code,
+			ExpiresAt:       toTimestamptz(now.Add(a.settings.InviteTTL)),
+			CreatedByUserID: userID,
+		})
+		return err
+	})
+	if err != nil {
+		a.logger.Error("failed to create invite", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to create invite")
+		return
+	}
+
+	if err := a.mailer.SendInviteCode(ctx, email, team.Name, invite.Code); err != nil {
+		a.logger.Error("failed to send invite code", slog.String("email", email), slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to send invite code")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newInviteResponse(invite, now))
+}
+
+func (a *API) handleListInvites(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	invites, err := a.store.Querier().ListInviteCodes(ctx, teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list invites")
+		return
+	}
+
+	now := a.clock()
+	resp := inviteListResponse{Invites: make([]inviteResponse, 0, len(invites))}
+	for _, invite := range invites {
+		resp.Invites = append(resp.Invites, newInviteResponse(invite, now))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleResendInvite issues a fresh code and expiry for a pending invite and
+// emails it again; the previous code stops working.
+func (a *API) handleResendInvite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+	inviteID, ok := parseUUID(chi.URLParam(r, "inviteID"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid invite id")
+		return
+	}
+
+	now := a.clock()
+	q := a.store.Querier()
+	var invite sqlc.InviteCode
+	err := withFreshCode(func(code string) (err error) {
+		invite, err = q.RefreshInviteCode(ctx, sqlc.RefreshInviteCodeParams{
+			ID:        inviteID,
+			TeamID:    teamID,
+			Code:      code,
+			ExpiresAt: toTimestamptz(now.Add(a.settings.InviteTTL)),
+		})
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "invite not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to resend invite")
+		return
+	}
+
+	team, err := q.GetTeamByID(ctx, teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to resend invite")
+		return
+	}
+
+	if err := a.mailer.SendInviteCode(ctx, invite.Email, team.Name, invite.Code); err != nil {
+		a.logger.Error("failed to send invite code", slog.String("email", invite.Email), slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to send invite code")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newInviteResponse(invite, now))
+}
+
+func (a *API) handleRevokeInvite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+	inviteID, ok := parseUUID(chi.URLParam(r, "inviteID"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid invite id")
+		return
+	}
+
+	deleted, err := a.store.Querier().DeleteInviteCode(ctx, sqlc.DeleteInviteCodeParams{
+		ID:     inviteID,
+		TeamID: teamID,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to revoke invite")
+		return
+	}
+	if deleted == 0 {
+		writeError(w, http.StatusNotFound, "invite not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newInviteResponse(invite sqlc.InviteCode, now time.Time) inviteResponse {
+	return inviteResponse{
+		ID:        uuidString(invite.ID),
+		Email:     invite.Email,
+		Code:      invite.Code,
+		ExpiresAt: invite.ExpiresAt.Time,
+		Expired:   !now.Before(invite.ExpiresAt.Time),
+		CreatedAt: invite.CreatedAt.Time,
+	}
+}
+
+// redeemInvite consumes an email-bound invite and joins the user to the
+// inviting team. It must run inside the verify transaction: the conditional
+// update makes the code single-use and the team row lock serialises
+// concurrent joins against the size limit.
+func redeemInvite(ctx context.Context, q sqlc.Querier, user sqlc.User, code string, now time.Time, teamSizeLimit int) (sqlc.Team, string, error) {
+	invite, err := q.RedeemInviteCode(ctx, sqlc.RedeemInviteCodeParams{
+		Code:       code,
+		Email:      user.Email,
+		RedeemedAt: toTimestamptz(now),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return sqlc.Team{}, "", errInviteInvalid
+		}
+		return sqlc.Team{}, "", err
+	}
+
+	team, err := q.GetTeamByIDForUpdate(ctx, invite.TeamID)
+	if err != nil {
+		return sqlc.Team{}, "", err
+	}
+
+	existing, err := q.GetTeamMembershipByUserID(ctx, user.ID)
+	if err == nil {
+		if existing.TeamID == team.ID {
+			return team, existing.Role, nil
+		}
+		return sqlc.Team{}, "", errAlreadyInTeam
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.Team{}, "", err
+	}
+
+	count, err := q.CountTeamMembers(ctx, team.ID)
+	if err != nil {
+		return sqlc.Team{}, "", err
+	}
+	if count >= int64(teamSizeLimit) {
+		return sqlc.Team{}, "", errTeamFull
+	}
+
+	role := "member"
+	if err := q.CreateTeamMembership(ctx, sqlc.CreateTeamMembershipParams{
+		TeamID:   team.ID,
+		UserID:   user.ID,
+		Role:     role,
+		JoinedAt: toTimestamptz(now),
+	}); err != nil {
+		return sqlc.Team{}, "", err
+	}
+
+	return team, role, nil
+}
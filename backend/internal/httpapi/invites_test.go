@@ -0,0 +1,613 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func withURLParam(req *http.Request, key, value string) *http.Request {
+	rctx := chi.RouteContext(req.Context())
+	if rctx == nil {
+		rctx = chi.NewRouteContext()
+	}
+	rctx.URLParams.Add(key, value)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestHandleCreateInvite(t *testing.T) {
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	settings := Settings{TeamSizeLimit: 30, InviteTTL: 72 * time.Hour}
+
+	t.Run("success", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetUserByEmail(func(context.Context, string) (sqlc.User, error) {
+				return sqlc.User{}, pgx.ErrNoRows
+			}).
+			onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+				return 3, nil
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "example.com", Name: "Example"}, nil
+			}).
+			onCreateInviteCode(func(_ context.Context, arg sqlc.CreateInviteCodeParams) (sqlc.InviteCode, error) {
+				if arg.TeamID != teamID || arg.CreatedByUserID != adminID {
+					t.Fatalf("unexpected invite params: %+v", arg)
+				}
+				if arg.Email != "contractor@gmail.com" || !isValidCode(arg.Code) {
+					t.Fatalf("unexpected email/code: %q/%q", arg.Email, arg.Code)
+				}
+				if !arg.ExpiresAt.Time.Equal(now.Add(72 * time.Hour)) {
+					t.Fatalf("unexpected expires at: %v", arg.ExpiresAt.Time)
+				}
+				return sqlc.InviteCode{
+					ID:        pgtype.UUID{Bytes: [16]byte{5}, Valid: true},
+					TeamID:    arg.TeamID,
+					Email:     arg.Email,
+					Code:      arg.Code,
+					ExpiresAt: arg.ExpiresAt,
+				}, nil
+			}).
+			build()
+
+		m := &stubMailer{}
+		api := New(&stubStore{querier: q}, m, settings, nil)
+		api.clock = func() time.Time { return now }
+
+		body, _ := json.Marshal(createInviteRequest{Email: " Contractor@Gmail.com "})
+		req := authedRequest(http.MethodPost, "/team/invites", body, adminID, teamID, "admin")
+		rec := httptest.NewRecorder()
+
+		api.handleCreateInvite(rec, req)
+
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d", rec.Code)
+		}
+		if m.inviteCalls != 1 || m.lastEmail != "contractor@gmail.com" || m.lastTeam != "Example" {
+			t.Fatalf("unexpected mailer state: %+v", m)
+		}
+		var resp inviteResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if resp.Code != m.lastCode || resp.Expired {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("code already taken", func(t *testing.T) {
+		var codes []string
+		q := newQuerierBuilder().
+			onGetUserByEmail(func(context.Context, string) (sqlc.User, error) {
+				return sqlc.User{}, pgx.ErrNoRows
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Name: "Example"}, nil
+			}).
+			onCreateInviteCode(func(_ context.Context, arg sqlc.CreateInviteCodeParams) (sqlc.InviteCode, error) {
+				codes = append(codes, arg.Code)
+				if len(codes) == 1 {
+					return sqlc.InviteCode{}, &pgconn.PgError{Code: pgUniqueViolation}
+				}
+				return sqlc.InviteCode{ID: pgtype.UUID{Bytes: [16]byte{5}, Valid: true}, Email: arg.Email, Code: arg.Code, ExpiresAt: arg.ExpiresAt}, nil
+			}).
+			build()
+
+		m := &stubMailer{}
+		api := New(&stubStore{querier: q}, m, settings, nil)
+		api.clock = func() time.Time { return now }
+
+		body, _ := json.Marshal(createInviteRequest{Email: "contractor@gmail.com"})
+		rec := httptest.NewRecorder()
+		api.handleCreateInvite(rec, authedRequest(http.MethodPost, "/team/invites", body, adminID, teamID, "admin"))
+
+		if rec.Code != http.StatusCreated || len(codes) != 2 {
+			t.Fatalf("expected status 201 after a second code, got %d after %d", rec.Code, len(codes))
+		}
+		if m.lastCode != codes[1] {
+			t.Fatalf("expected the stored code %q to be mailed, got %q", codes[1], m.lastCode)
+		}
+	})
+
+	t.Run("existing member", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetUserByEmail(func(context.Context, string) (sqlc.User, error) {
+				return sqlc.User{ID: pgtype.UUID{Bytes: [16]byte{4}, Valid: true}}, nil
+			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{TeamID: teamID}, nil
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &stubMailer{}, settings, nil)
+
+		body, _ := json.Marshal(createInviteRequest{Email: "member@example.com"})
+		req := authedRequest(http.MethodPost, "/team/invites", body, adminID, teamID, "admin")
+		rec := httptest.NewRecorder()
+
+		api.handleCreateInvite(rec, req)
+
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+	})
+
+	t.Run("team full", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetUserByEmail(func(context.Context, string) (sqlc.User, error) {
+				return sqlc.User{}, pgx.ErrNoRows
+			}).
+			onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+				return 30, nil
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &stubMailer{}, settings, nil)
+
+		body, _ := json.Marshal(createInviteRequest{Email: "new@example.com"})
+		req := authedRequest(http.MethodPost, "/team/invites", body, adminID, teamID, "admin")
+		rec := httptest.NewRecorder()
+
+		api.handleCreateInvite(rec, req)
+
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+	})
+
+	t.Run("mailer failure", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetUserByEmail(func(context.Context, string) (sqlc.User, error) {
+				return sqlc.User{}, pgx.ErrNoRows
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &stubMailer{err: errors.New("smtp down")}, settings, nil)
+
+		body, _ := json.Marshal(createInviteRequest{Email: "new@example.com"})
+		req := authedRequest(http.MethodPost, "/team/invites", body, adminID, teamID, "admin")
+		rec := httptest.NewRecorder()
+
+		api.handleCreateInvite(rec, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", rec.Code)
+		}
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		api := New(&stubStore{}, &stubMailer{}, settings, nil)
+
+		body, _ := json.Marshal(createInviteRequest{Email: "nope"})
+		req := authedRequest(http.MethodPost, "/team/invites", body, adminID, teamID, "admin")
+		rec := httptest.NewRecorder()
+
+		api.handleCreateInvite(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleListInvites(t *testing.T) {
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	q := newQuerierBuilder().
+		onListInviteCodes(func(_ context.Context, id pgtype.UUID) ([]sqlc.InviteCode, error) {
+			if id != teamID {
+				t.Fatalf("unexpected team id: %v", id)
+			}
+			return []sqlc.InviteCode{
+				{Email: "a@example.com", Code: "ABCD2345", ExpiresAt: toTimestamptz(now.Add(time.Hour))},
+				{Email: "b@example.com", Code: "EFGH2345", ExpiresAt: toTimestamptz(now.Add(-time.Hour))},
+			}, nil
+		}).
+		build()
+
+	api := New(&stubStore{querier: q}, &stubMailer{}, Settings{}, nil)
+	api.clock = func() time.Time { return now }
+
+	req := authedRequest(http.MethodGet, "/team/invites", nil, adminID, teamID, "admin")
+	rec := httptest.NewRecorder()
+
+	api.handleListInvites(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var resp inviteListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(resp.Invites) != 2 || resp.Invites[0].Expired || !resp.Invites[1].Expired {
+		t.Fatalf("unexpected invites: %+v", resp.Invites)
+	}
+}
+
+func TestHandleResendInvite(t *testing.T) {
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	inviteID := "05000000-0000-0000-0000-000000000000"
+
+	t.Run("success", func(t *testing.T) {
+		var newCode string
+		q := newQuerierBuilder().
+			onRefreshInviteCode(func(_ context.Context, arg sqlc.RefreshInviteCodeParams) (sqlc.InviteCode, error) {
+				if uuidString(arg.ID) != inviteID || arg.TeamID != teamID {
+					t.Fatalf("unexpected refresh params: %+v", arg)
+				}
+				newCode = arg.Code
+				return sqlc.InviteCode{ID: arg.ID, Email: "a@example.com", Code: arg.Code, ExpiresAt: arg.ExpiresAt}, nil
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Name: "Example"}, nil
+			}).
+			build()
+
+		m := &stubMailer{}
+		api := New(&stubStore{querier: q}, m, Settings{InviteTTL: time.Hour}, nil)
+
+		req := authedRequest(http.MethodPost, "/team/invites/"+inviteID+"/resend", nil, adminID, teamID, "admin")
+		req = withURLParam(req, "inviteID", inviteID)
+		rec := httptest.NewRecorder()
+
+		api.handleResendInvite(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if m.inviteCalls != 1 || m.lastCode != newCode {
+			t.Fatalf("expected new code to be mailed, got %+v", m)
+		}
+	})
+
+	t.Run("code already taken", func(t *testing.T) {
+		var codes []string
+		q := newQuerierBuilder().
+			onRefreshInviteCode(func(_ context.Context, arg sqlc.RefreshInviteCodeParams) (sqlc.InviteCode, error) {
+				codes = append(codes, arg.Code)
+				if len(codes) == 1 {
+					return sqlc.InviteCode{}, &pgconn.PgError{Code: pgUniqueViolation}
+				}
+				return sqlc.InviteCode{ID: arg.ID, Email: "a@example.com", Code: arg.Code, ExpiresAt: arg.ExpiresAt}, nil
+			}).
+			build()
+
+		m := &stubMailer{}
+		api := New(&stubStore{querier: q}, m, Settings{InviteTTL: time.Hour}, nil)
+
+		req := authedRequest(http.MethodPost, "/team/invites/"+inviteID+"/resend", nil, adminID, teamID, "admin")
+		req = withURLParam(req, "inviteID", inviteID)
+		rec := httptest.NewRecorder()
+
+		api.handleResendInvite(rec, req)
+
+		if rec.Code != http.StatusOK || len(codes) != 2 || m.lastCode != codes[1] {
+			t.Fatalf("expected the second code to be stored and mailed, got %d, codes %v, mailed %q", rec.Code, codes, m.lastCode)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onRefreshInviteCode(func(context.Context, sqlc.RefreshInviteCodeParams) (sqlc.InviteCode, error) {
+				return sqlc.InviteCode{}, pgx.ErrNoRows
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &stubMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodPost, "/team/invites/"+inviteID+"/resend", nil, adminID, teamID, "admin")
+		req = withURLParam(req, "inviteID", inviteID)
+		rec := httptest.NewRecorder()
+
+		api.handleResendInvite(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404, got %d", rec.Code)
+		}
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		api := New(&stubStore{}, &stubMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodPost, "/team/invites/nope/resend", nil, adminID, teamID, "admin")
+		req = withURLParam(req, "inviteID", "nope")
+		rec := httptest.NewRecorder()
+
+		api.handleResendInvite(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleRevokeInvite(t *testing.T) {
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	inviteID := "05000000-0000-0000-0000-000000000000"
+
+	tests := []struct {
+		name    string
+		deleted int64
+		err     error
+		code    int
+	}{
+		{"success", 1, nil, http.StatusNoContent},
+		{"not found", 0, nil, http.StatusNotFound},
+		{"db failure", 0, errors.New("delete failed"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newQuerierBuilder().
+				onDeleteInviteCode(func(_ context.Context, arg sqlc.DeleteInviteCodeParams) (int64, error) {
+					if arg.TeamID != teamID {
+						t.Fatalf("unexpected team id: %v", arg.TeamID)
+					}
+					return tt.deleted, tt.err
+				}).
+				build()
+
+			api := New(&stubStore{querier: q}, &stubMailer{}, Settings{}, nil)
+
+			req := authedRequest(http.MethodDelete, "/team/invites/"+inviteID, nil, adminID, teamID, "admin")
+			req = withURLParam(req, "inviteID", inviteID)
+			rec := httptest.NewRecorder()
+
+			api.handleRevokeInvite(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRedeemInvite(t *testing.T) {
+	user := sqlc.User{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Email: "contractor@gmail.com"}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	redeemed := func(_ context.Context, arg sqlc.RedeemInviteCodeParams) (sqlc.InviteCode, error) {
+		if arg.Email != user.Email || !arg.RedeemedAt.Time.Equal(now) {
+			t.Fatalf("unexpected redeem params: %+v", arg)
+		}
+		return sqlc.InviteCode{TeamID: teamID, Email: arg.Email, Code: arg.Code}, nil
+	}
+	lockTeam := func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+		return sqlc.Team{ID: id, Domain: "example.com", Name: "Example"}, nil
+	}
+
+	t.Run("joins inviting team as member", func(t *testing.T) {
+		var created sqlc.CreateTeamMembershipParams
+		q := newQuerierBuilder().
+			onRedeemInviteCode(redeemed).
+			onGetTeamByIDForUpdate(lockTeam).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+				return 4, nil
+			}).
+			onCreateTeamMembership(func(_ context.Context, arg sqlc.CreateTeamMembershipParams) error {
+				created = arg
+				return nil
+			}).
+			build()
+
+		team, role, err := redeemInvite(context.Background(), q, user, "ABCD2345", now, 30)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if team.ID != teamID || role != "member" {
+			t.Fatalf("unexpected team/role: %v/%q", team.ID, role)
+		}
+		if created.TeamID != teamID || created.UserID != user.ID || created.Role != "member" {
+			t.Fatalf("unexpected membership: %+v", created)
+		}
+	})
+
+	t.Run("invalid or used code", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onRedeemInviteCode(func(context.Context, sqlc.RedeemInviteCodeParams) (sqlc.InviteCode, error) {
+				return sqlc.InviteCode{}, pgx.ErrNoRows
+			}).
+			build()
+
+		if _, _, err := redeemInvite(context.Background(), q, user, "ABCD2345", now, 30); !errors.Is(err, errInviteInvalid) {
+			t.Fatalf("expected errInviteInvalid, got %v", err)
+		}
+	})
+
+	t.Run("member of another team", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onRedeemInviteCode(redeemed).
+			onGetTeamByIDForUpdate(lockTeam).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{TeamID: pgtype.UUID{Bytes: [16]byte{8}, Valid: true}}, nil
+			}).
+			build()
+
+		if _, _, err := redeemInvite(context.Background(), q, user, "ABCD2345", now, 30); !errors.Is(err, errAlreadyInTeam) {
+			t.Fatalf("expected errAlreadyInTeam, got %v", err)
+		}
+	})
+
+	t.Run("already member of inviting team", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onRedeemInviteCode(redeemed).
+			onGetTeamByIDForUpdate(lockTeam).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{TeamID: teamID, Role: "admin"}, nil
+			}).
+			onCreateTeamMembership(func(context.Context, sqlc.CreateTeamMembershipParams) error {
+				t.Fatal("expected no new membership")
+				return nil
+			}).
+			build()
+
+		_, role, err := redeemInvite(context.Background(), q, user, "ABCD2345", now, 30)
+		if err != nil || role != "admin" {
+			t.Fatalf("expected existing role, got %q/%v", role, err)
+		}
+	})
+
+	t.Run("team full", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onRedeemInviteCode(redeemed).
+			onGetTeamByIDForUpdate(lockTeam).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+				return 30, nil
+			}).
+			build()
+
+		if _, _, err := redeemInvite(context.Background(), q, user, "ABCD2345", now, 30); !errors.Is(err, errTeamFull) {
+			t.Fatalf("expected errTeamFull, got %v", err)
+		}
+	})
+}
+
+func TestHandleVerifyCodeWithInvite(t *testing.T) {
+	email := "contractor@gmail.com"
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	baseBuilder := func() *querierBuilder {
+		return newQuerierBuilder().
+			onGetEmailVerificationCode(func(context.Context, sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+				return sqlc.EmailVerificationCode{ID: pgtype.UUID{Bytes: [16]byte{1}, Valid: true}}, nil
+			}).
+			onGetUserByEmail(func(context.Context, string) (sqlc.User, error) {
+				return sqlc.User{}, pgx.ErrNoRows
+			}).
+			onCreateUser(func(_ context.Context, arg sqlc.CreateUserParams) (sqlc.User, error) {
+				return sqlc.User{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Email: arg.Email}, nil
+			}).
+			onGetTeamByDomain(func(context.Context, string) (sqlc.Team, error) {
+				t.Fatal("expected domain team lookup to be skipped")
+				return sqlc.Team{}, nil
+			})
+	}
+	settings := Settings{
+		AccessTTL:             15 * time.Minute,
+		RefreshTTL:            24 * time.Hour,
+		VerifyCodeEmailLimit:  5,
+		VerifyCodeEmailWindow: 15 * time.Minute,
+		VerifyCodeLock:        15 * time.Minute,
+		TeamSizeLimit:         30,
+	}
+
+	t.Run("joins inviting team", func(t *testing.T) {
+		tx := &testTx{}
+		q := baseBuilder().
+			onRedeemInviteCode(func(_ context.Context, arg sqlc.RedeemInviteCodeParams) (sqlc.InviteCode, error) {
+				if arg.Code != "EFGH2345" {
+					t.Fatalf("unexpected invite code: %q", arg.Code)
+				}
+				return sqlc.InviteCode{TeamID: teamID}, nil
+			}).
+			onGetTeamByIDForUpdate(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "example.com", Name: "Example"}, nil
+			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			build()
+
+		api := New(&stubStore{
+			querier: q,
+			beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+				return tx, nil
+			},
+		}, &mailer.LogMailer{}, settings, nil)
+		api.clock = func() time.Time { return now }
+
+		body, _ := json.Marshal(verifyCodeRequest{Email: email, Code: "ABCD2345", InviteCode: "efgh2345"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/verify-code", bytes.NewReader(body))
+		req.Header.Set("X-Device-Id", "device-123")
+		rec := httptest.NewRecorder()
+
+		api.handleVerifyCode(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var resp authResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if resp.Team == nil || resp.Team.Domain != "example.com" || resp.Role != "member" {
+			t.Fatalf("unexpected team/role: %+v/%q", resp.Team, resp.Role)
+		}
+		if !tx.committed {
+			t.Fatal("expected tx to be committed")
+		}
+	})
+
+	t.Run("invalid invite rolls back", func(t *testing.T) {
+		tx := &testTx{}
+		q := baseBuilder().
+			onRedeemInviteCode(func(context.Context, sqlc.RedeemInviteCodeParams) (sqlc.InviteCode, error) {
+				return sqlc.InviteCode{}, pgx.ErrNoRows
+			}).
+			build()
+
+		api := New(&stubStore{
+			querier: q,
+			beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+				return tx, nil
+			},
+		}, &mailer.LogMailer{}, settings, nil)
+
+		body, _ := json.Marshal(verifyCodeRequest{Email: email, Code: "ABCD2345", InviteCode: "EFGH2345"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/verify-code", bytes.NewReader(body))
+		req.Header.Set("X-Device-Id", "device-123")
+		rec := httptest.NewRecorder()
+
+		api.handleVerifyCode(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+		if tx.committed || !tx.rolled {
+			t.Fatal("expected tx to be rolled back")
+		}
+	})
+
+	t.Run("malformed invite code", func(t *testing.T) {
+		api := New(&stubStore{}, &mailer.LogMailer{}, settings, nil)
+
+		body, _ := json.Marshal(verifyCodeRequest{Email: email, Code: "ABCD2345", InviteCode: "bad!"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/verify-code", bytes.NewReader(body))
+		req.Header.Set("X-Device-Id", "device-123")
+		rec := httptest.NewRecorder()
+
+		api.handleVerifyCode(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,289 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Join policies decide what happens when a verified user's domain matches a
+// team they don't belong to yet.
+const (
+	joinPolicyOpen       = "open"
+	joinPolicyApproval   = "approval"
+	joinPolicyInviteOnly = "invite_only"
+)
+
+// Statuses returned by verify-code and GET /me. Pending and rejected mirror
+// the join request; choose_team means no team exists for the domain yet.
+// Approved only ever appears on join requests, since an approved user is a
+// member.
+const (
+	joinStatusMember     = "member"
+	joinStatusPending    = "pending"
+	joinStatusApproved   = "approved"
+	joinStatusRejected   = "rejected"
+	joinStatusChooseTeam = "choose_team"
+)
+
+var (
+	errJoinRequestNotFound = errors.New("join request not found")
+	errJoinRequestDecided  = errors.New("join request already decided")
+)
+
+type joinRequestResponse struct {
+	ID        string     `json:"id"`
+	TeamID    string     `json:"team_id"`
+	TeamName  string     `json:"team_name"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	DecidedAt *time.Time `json:"decided_at,omitempty"`
+}
+
+type pendingJoinRequestResponse struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type pendingJoinRequestListResponse struct {
+	Requests []pendingJoinRequestResponse `json:"requests"`
+}
+
+func isJoinPolicy(policy string) bool {
+	return policy == joinPolicyOpen || policy == joinPolicyApproval || policy == joinPolicyInviteOnly
+}
+
+// joinDomainTeam applies the domain team's join policy to a verified user who
+// isn't in a team yet. Open teams add the user right away, approval teams
+// queue a join request and invite-only teams turn them away. It returns
+// pgx.ErrNoRows when nobody has claimed the domain.
+func (a *API) joinDomainTeam(ctx context.Context, q sqlc.Querier, user sqlc.User, host string, isNewUser bool, now time.Time) (sqlc.Team, string, *sqlc.TeamJoinRequest, error) {
+	if a.freeMail.contains(host) {
+		return sqlc.Team{}, "", nil, errInviteRequired
+	}
+	team, _, err := findDomainTeam(ctx, q, host)
+	if err != nil {
+		return sqlc.Team{}, "", nil, err
+	}
+
+	switch team.JoinPolicy {
+	case joinPolicyInviteOnly:
+		return team, "", nil, errInviteRequired
+	case joinPolicyApproval:
+		request, err := q.CreateTeamJoinRequest(ctx, sqlc.CreateTeamJoinRequestParams{
+			TeamID:    team.ID,
+			UserID:    user.ID,
+			CreatedAt: toTimestamptz(now),
+		})
+		if err != nil {
+			return sqlc.Team{}, "", nil, err
+		}
+		return team, "", &request, nil
+	default:
+		role, err := ensureMembership(ctx, q, user, team, isNewUser, now, a.settings.TeamSizeLimit)
+		if err != nil {
+			return sqlc.Team{}, "", nil, err
+		}
+		return team, role, nil, nil
+	}
+}
+
+// writeJoinError maps the errors from joining a team, whether by domain,
+// invite or team creation.
+func (a *API) writeJoinError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errTeamFull):
+		writeError(w, http.StatusConflict, "team is full")
+	case errors.Is(err, errInviteInvalid):
+		writeError(w, http.StatusBadRequest, "invalid invite code")
+	case errors.Is(err, errAlreadyInTeam):
+		writeError(w, http.StatusConflict, "already a member of another team")
+	case errors.Is(err, errInviteRequired):
+		writeError(w, http.StatusForbidden, "invite code required")
+	default:
+		a.logger.Error("failed to create membership", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to create membership")
+	}
+}
+
+// handleGetJoinRequest lets a signed-in user without a team poll the outcome
+// of their latest join request.
+func (a *API) handleGetJoinRequest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q := a.store.Querier()
+	request, err := q.GetLatestTeamJoinRequestByUserID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "join request not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load join request")
+		return
+	}
+	team, err := q.GetTeamByID(ctx, request.TeamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load join request")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newJoinRequestResponse(request, team))
+}
+
+func (a *API) handleListJoinRequests(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	rows, err := a.store.Querier().ListPendingTeamJoinRequests(ctx, teamID)
+	if err != nil {
+		a.logger.Error("failed to list join requests", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to list join requests")
+		return
+	}
+
+	requests := make([]pendingJoinRequestResponse, 0, len(rows))
+	for _, row := range rows {
+		requests = append(requests, pendingJoinRequestResponse{
+			ID:        uuidString(row.ID),
+			UserID:    uuidString(row.UserID),
+			Email:     row.Email,
+			CreatedAt: row.CreatedAt.Time,
+		})
+	}
+	writeJSON(w, http.StatusOK, pendingJoinRequestListResponse{Requests: requests})
+}
+
+func (a *API) handleApproveJoinRequest(w http.ResponseWriter, r *http.Request) {
+	a.decideJoinRequest(w, r, joinStatusApproved)
+}
+
+func (a *API) handleRejectJoinRequest(w http.ResponseWriter, r *http.Request) {
+	a.decideJoinRequest(w, r, joinStatusRejected)
+}
+
+// decideJoinRequest approves or rejects a request under the team lock so the
+// size limit holds against concurrent approvals and invites. A rejected
+// request can still be approved later; an approved one is final.
+func (a *API) decideJoinRequest(w http.ResponseWriter, r *http.Request, status string) {
+	ctx := r.Context()
+	adminID, _ := userIDFromContext(ctx)
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+	requestID, ok := parseUUID(chi.URLParam(r, "requestID"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid join request id")
+		return
+	}
+
+	now := a.clock()
+	var (
+		decided sqlc.TeamJoinRequest
+		team    sqlc.Team
+	)
+	err := a.withTeamLock(ctx, teamID, func(q sqlc.Querier) error {
+		request, err := q.GetTeamJoinRequestForUpdate(ctx, sqlc.GetTeamJoinRequestForUpdateParams{
+			ID:     requestID,
+			TeamID: teamID,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errJoinRequestNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if request.Status == joinStatusApproved || request.Status == status {
+			return errJoinRequestDecided
+		}
+		if status == joinStatusApproved {
+			if err := addRequestedMember(ctx, q, request, now, a.settings.TeamSizeLimit); err != nil {
+				return err
+			}
+		}
+		decided, err = q.DecideTeamJoinRequest(ctx, sqlc.DecideTeamJoinRequestParams{
+			ID:              request.ID,
+			Status:          status,
+			DecidedAt:       toTimestamptz(now),
+			DecidedByUserID: adminID,
+		})
+		if err != nil {
+			return err
+		}
+		team, err = q.GetTeamByID(ctx, teamID)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errJoinRequestNotFound):
+			writeError(w, http.StatusNotFound, "join request not found")
+		case errors.Is(err, errJoinRequestDecided):
+			writeError(w, http.StatusConflict, "join request already decided")
+		case errors.Is(err, errTeamFull), errors.Is(err, errAlreadyInTeam):
+			a.writeJoinError(w, err)
+		default:
+			a.logger.Error("failed to decide join request", slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to update join request")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newJoinRequestResponse(decided, team))
+}
+
+func addRequestedMember(ctx context.Context, q sqlc.Querier, request sqlc.TeamJoinRequest, now time.Time, teamSizeLimit int) error {
+	if _, err := q.GetTeamMembershipByUserID(ctx, request.UserID); err == nil {
+		return errAlreadyInTeam
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	count, err := q.CountTeamMembers(ctx, request.TeamID)
+	if err != nil {
+		return err
+	}
+	if count >= int64(teamSizeLimit) {
+		return errTeamFull
+	}
+
+	return q.CreateTeamMembership(ctx, sqlc.CreateTeamMembershipParams{
+		TeamID:   request.TeamID,
+		UserID:   request.UserID,
+		Role:     "member",
+		JoinedAt: toTimestamptz(now),
+	})
+}
+
+func newJoinRequestResponse(request sqlc.TeamJoinRequest, team sqlc.Team) joinRequestResponse {
+	resp := joinRequestResponse{
+		ID:        uuidString(request.ID),
+		TeamID:    uuidString(request.TeamID),
+		TeamName:  team.Name,
+		Status:    request.Status,
+		CreatedAt: request.CreatedAt.Time,
+	}
+	if request.DecidedAt.Valid {
+		decidedAt := request.DecidedAt.Time
+		resp.DecidedAt = &decidedAt
+	}
+	return resp
+}
@@ -0,0 +1,449 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestJoinDomainTeam(t *testing.T) {
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	user := sqlc.User{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Email: "sam@acme.com"}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+
+	newQuerier := func(policy string, created *bool, requested *sqlc.CreateTeamJoinRequestParams) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+				if domain != "acme.com" {
+					return sqlc.Team{}, pgx.ErrNoRows
+				}
+				return sqlc.Team{ID: teamID, Domain: domain, JoinPolicy: policy, IncludeSubdomains: true}, nil
+			}).
+			onGetTeamMembership(func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+				return 2, nil
+			}).
+			onCreateTeamMembership(func(context.Context, sqlc.CreateTeamMembershipParams) error {
+				*created = true
+				return nil
+			}).
+			onCreateTeamJoinRequest(func(_ context.Context, arg sqlc.CreateTeamJoinRequestParams) (sqlc.TeamJoinRequest, error) {
+				*requested = arg
+				return sqlc.TeamJoinRequest{TeamID: arg.TeamID, UserID: arg.UserID, Status: joinStatusPending, CreatedAt: arg.CreatedAt}, nil
+			}).
+			build()
+	}
+
+	api := New(&stubStore{}, &mailer.LogMailer{}, Settings{TeamSizeLimit: 30}, nil)
+
+	t.Run("open joins", func(t *testing.T) {
+		var created bool
+		var requested sqlc.CreateTeamJoinRequestParams
+		team, role, request, err := api.joinDomainTeam(context.Background(), newQuerier(joinPolicyOpen, &created, &requested), user, "acme.com", false, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if team.ID != teamID || role != "member" || request != nil || !created {
+			t.Fatalf("unexpected outcome: team=%v role=%q request=%v created=%v", team.ID, role, request, created)
+		}
+	})
+
+	t.Run("approval queues a request", func(t *testing.T) {
+		var created bool
+		var requested sqlc.CreateTeamJoinRequestParams
+		_, role, request, err := api.joinDomainTeam(context.Background(), newQuerier(joinPolicyApproval, &created, &requested), user, "eng.acme.com", false, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if role != "" || created || request == nil || request.Status != joinStatusPending {
+			t.Fatalf("unexpected outcome: role=%q created=%v request=%v", role, created, request)
+		}
+		if requested.TeamID != teamID || requested.UserID != user.ID || !requested.CreatedAt.Time.Equal(now) {
+			t.Fatalf("unexpected join request params: %+v", requested)
+		}
+	})
+
+	t.Run("invite only", func(t *testing.T) {
+		var created bool
+		var requested sqlc.CreateTeamJoinRequestParams
+		_, _, _, err := api.joinDomainTeam(context.Background(), newQuerier(joinPolicyInviteOnly, &created, &requested), user, "acme.com", false, now)
+		if !errors.Is(err, errInviteRequired) || created {
+			t.Fatalf("expected errInviteRequired without membership, got %v (created=%v)", err, created)
+		}
+	})
+
+	t.Run("unclaimed domain", func(t *testing.T) {
+		var created bool
+		var requested sqlc.CreateTeamJoinRequestParams
+		_, _, _, err := api.joinDomainTeam(context.Background(), newQuerier(joinPolicyOpen, &created, &requested), user, "other.com", false, now)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			t.Fatalf("expected ErrNoRows, got %v", err)
+		}
+	})
+
+	t.Run("free mail", func(t *testing.T) {
+		var created bool
+		var requested sqlc.CreateTeamJoinRequestParams
+		_, _, _, err := api.joinDomainTeam(context.Background(), newQuerier(joinPolicyOpen, &created, &requested), user, "gmail.com", false, now)
+		if !errors.Is(err, errInviteRequired) {
+			t.Fatalf("expected errInviteRequired, got %v", err)
+		}
+	})
+}
+
+func TestHandleVerifyCodeJoinOutcomes(t *testing.T) {
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+
+	newQuerier := func(team *sqlc.Team, sessionCreated *bool) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetEmailVerificationCode(func(context.Context, sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+				return sqlc.EmailVerificationCode{ID: pgtype.UUID{Bytes: [16]byte{1}, Valid: true}}, nil
+			}).
+			onGetUserByEmail(func(_ context.Context, email string) (sqlc.User, error) {
+				return sqlc.User{
+					ID:              pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+					Email:           email,
+					EmailVerifiedAt: pgtype.Timestamptz{Time: now, Valid: true},
+				}, nil
+			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onGetTeamByDomain(func(context.Context, string) (sqlc.Team, error) {
+				if team == nil {
+					return sqlc.Team{}, pgx.ErrNoRows
+				}
+				return *team, nil
+			}).
+			onGetTeamMembership(func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onCreateTeam(func(context.Context, sqlc.CreateTeamParams) (sqlc.Team, error) {
+				t.Fatal("verify must not create teams")
+				return sqlc.Team{}, nil
+			}).
+			onCreateTeamMembership(func(context.Context, sqlc.CreateTeamMembershipParams) error {
+				t.Fatal("unexpected membership")
+				return nil
+			}).
+			onCreateTeamJoinRequest(func(_ context.Context, arg sqlc.CreateTeamJoinRequestParams) (sqlc.TeamJoinRequest, error) {
+				return sqlc.TeamJoinRequest{
+					ID:        pgtype.UUID{Bytes: [16]byte{7}, Valid: true},
+					TeamID:    arg.TeamID,
+					UserID:    arg.UserID,
+					Status:    joinStatusPending,
+					CreatedAt: arg.CreatedAt,
+				}, nil
+			}).
+			onCreateAuthSession(func(context.Context, sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
+				*sessionCreated = true
+				return sqlc.AuthSession{}, nil
+			}).
+			build()
+	}
+
+	verify := func(t *testing.T, team *sqlc.Team) (*httptest.ResponseRecorder, bool) {
+		t.Helper()
+		var sessionCreated bool
+		api := New(newTxStore(newQuerier(team, &sessionCreated)), &mailer.LogMailer{}, Settings{
+			VerifyCodeEmailLimit:  5,
+			VerifyCodeEmailWindow: 15 * time.Minute,
+			VerifyCodeLock:        15 * time.Minute,
+			TeamSizeLimit:         30,
+		}, nil)
+		api.clock = func() time.Time { return now }
+
+		body, _ := json.Marshal(verifyCodeRequest{Email: "sam@acme.com", Code: "ABCD2345"})
+		req := httptest.NewRequest(http.MethodPost, "/auth/verify-code", bytes.NewReader(body))
+		req.Header.Set("X-Device-Id", "device-123")
+		rec := httptest.NewRecorder()
+		api.handleVerifyCode(rec, req)
+		return rec, sessionCreated
+	}
+
+	t.Run("choose team", func(t *testing.T) {
+		rec, sessionCreated := verify(t, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp authResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if resp.Status != joinStatusChooseTeam || resp.Team != nil || resp.AccessToken == "" || !sessionCreated {
+			t.Fatalf("unexpected response: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("pending approval", func(t *testing.T) {
+		team := sqlc.Team{ID: teamID, Domain: "acme.com", Name: "Acme", JoinPolicy: joinPolicyApproval}
+		rec, _ := verify(t, &team)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp authResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if resp.Status != joinStatusPending || resp.Team != nil || resp.Role != "" {
+			t.Fatalf("unexpected response: %s", rec.Body.String())
+		}
+		if resp.JoinRequest == nil || resp.JoinRequest.TeamName != "Acme" || resp.JoinRequest.Status != joinStatusPending {
+			t.Fatalf("unexpected join request: %+v", resp.JoinRequest)
+		}
+	})
+
+	t.Run("invite only", func(t *testing.T) {
+		team := sqlc.Team{ID: teamID, Domain: "acme.com", Name: "Acme", JoinPolicy: joinPolicyInviteOnly}
+		rec, sessionCreated := verify(t, &team)
+		if rec.Code != http.StatusForbidden || sessionCreated {
+			t.Fatalf("expected status 403 without a session, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleGetJoinRequest(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	decidedAt := time.Date(2024, 5, 7, 9, 0, 0, 0, time.UTC)
+
+	t.Run("latest request", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetLatestTeamJoinRequestByUserID(func(_ context.Context, id pgtype.UUID) (sqlc.TeamJoinRequest, error) {
+				if id != userID {
+					t.Fatalf("unexpected user id: %v", id)
+				}
+				return sqlc.TeamJoinRequest{
+					ID:        pgtype.UUID{Bytes: [16]byte{7}, Valid: true},
+					TeamID:    teamID,
+					UserID:    userID,
+					Status:    joinStatusRejected,
+					DecidedAt: pgtype.Timestamptz{Time: decidedAt, Valid: true},
+				}, nil
+			}).
+			onGetTeamByID(func(context.Context, pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: teamID, Name: "Acme"}, nil
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodGet, "/me/join-request", nil, userID, pgtype.UUID{}, "")
+		rec := httptest.NewRecorder()
+		api.handleGetJoinRequest(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var resp joinRequestResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Status != joinStatusRejected || resp.TeamName != "Acme" || resp.DecidedAt == nil || !resp.DecidedAt.Equal(decidedAt) {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetLatestTeamJoinRequestByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamJoinRequest, error) {
+				return sqlc.TeamJoinRequest{}, pgx.ErrNoRows
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodGet, "/me/join-request", nil, userID, pgtype.UUID{}, "")
+		rec := httptest.NewRecorder()
+		api.handleGetJoinRequest(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleListJoinRequests(t *testing.T) {
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	createdAt := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	q := newQuerierBuilder().
+		onListPendingTeamJoinRequests(func(_ context.Context, id pgtype.UUID) ([]sqlc.ListPendingTeamJoinRequestsRow, error) {
+			if id != teamID {
+				t.Fatalf("unexpected team id: %v", id)
+			}
+			return []sqlc.ListPendingTeamJoinRequestsRow{{
+				ID:        pgtype.UUID{Bytes: [16]byte{7}, Valid: true},
+				UserID:    pgtype.UUID{Bytes: [16]byte{2}, Valid: true},
+				Email:     "sam@acme.com",
+				CreatedAt: pgtype.Timestamptz{Time: createdAt, Valid: true},
+			}}, nil
+		}).
+		build()
+	api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+	req := authedRequest(http.MethodGet, "/team/join-requests", nil, adminID, teamID, "admin")
+	rec := httptest.NewRecorder()
+	api.handleListJoinRequests(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var resp pendingJoinRequestListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Requests) != 1 || resp.Requests[0].Email != "sam@acme.com" || !resp.Requests[0].CreatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestDecideJoinRequest(t *testing.T) {
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	requesterID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	requestID := pgtype.UUID{Bytes: [16]byte{7}, Valid: true}
+	now := time.Date(2024, 5, 7, 9, 0, 0, 0, time.UTC)
+
+	type result struct {
+		membership *sqlc.CreateTeamMembershipParams
+		decided    *sqlc.DecideTeamJoinRequestParams
+	}
+
+	run := func(t *testing.T, status string, memberCount int64, existingMember bool, handler func(*API) http.HandlerFunc) (*httptest.ResponseRecorder, result) {
+		t.Helper()
+		var res result
+		q := newQuerierBuilder().
+			onGetTeamByIDForUpdate(func(context.Context, pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: teamID}, nil
+			}).
+			onGetTeamByID(func(context.Context, pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: teamID, Name: "Acme"}, nil
+			}).
+			onGetTeamJoinRequestForUpdate(func(_ context.Context, arg sqlc.GetTeamJoinRequestForUpdateParams) (sqlc.TeamJoinRequest, error) {
+				if arg.ID != requestID || arg.TeamID != teamID {
+					return sqlc.TeamJoinRequest{}, pgx.ErrNoRows
+				}
+				return sqlc.TeamJoinRequest{ID: requestID, TeamID: teamID, UserID: requesterID, Status: status}, nil
+			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				if existingMember {
+					return sqlc.TeamMembership{TeamID: pgtype.UUID{Bytes: [16]byte{4}, Valid: true}}, nil
+				}
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+				return memberCount, nil
+			}).
+			onCreateTeamMembership(func(_ context.Context, arg sqlc.CreateTeamMembershipParams) error {
+				res.membership = &arg
+				return nil
+			}).
+			onDecideTeamJoinRequest(func(_ context.Context, arg sqlc.DecideTeamJoinRequestParams) (sqlc.TeamJoinRequest, error) {
+				res.decided = &arg
+				return sqlc.TeamJoinRequest{ID: arg.ID, TeamID: teamID, UserID: requesterID, Status: arg.Status, DecidedAt: arg.DecidedAt}, nil
+			}).
+			build()
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{TeamSizeLimit: 3}, nil)
+		api.clock = func() time.Time { return now }
+
+		req := authedRequest(http.MethodPost, "/team/join-requests/x", nil, adminID, teamID, "admin")
+		req = withURLParam(req, "requestID", uuidString(requestID))
+		rec := httptest.NewRecorder()
+		handler(api)(rec, req)
+		return rec, res
+	}
+	approve := func(api *API) http.HandlerFunc { return api.handleApproveJoinRequest }
+	reject := func(api *API) http.HandlerFunc { return api.handleRejectJoinRequest }
+
+	t.Run("approve adds member", func(t *testing.T) {
+		rec, res := run(t, joinStatusPending, 1, false, approve)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if res.membership == nil || res.membership.UserID != requesterID || res.membership.Role != "member" {
+			t.Fatalf("unexpected membership: %+v", res.membership)
+		}
+		if res.decided == nil || res.decided.Status != joinStatusApproved || res.decided.DecidedByUserID != adminID || !res.decided.DecidedAt.Time.Equal(now) {
+			t.Fatalf("unexpected decision: %+v", res.decided)
+		}
+	})
+
+	t.Run("approve previously rejected", func(t *testing.T) {
+		rec, res := run(t, joinStatusRejected, 1, false, approve)
+		if rec.Code != http.StatusOK || res.membership == nil {
+			t.Fatalf("expected approval, got %d", rec.Code)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		rec, res := run(t, joinStatusPending, 1, false, reject)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if res.membership != nil || res.decided == nil || res.decided.Status != joinStatusRejected {
+			t.Fatalf("unexpected result: %+v", res)
+		}
+	})
+
+	t.Run("already approved", func(t *testing.T) {
+		rec, res := run(t, joinStatusApproved, 1, false, reject)
+		if rec.Code != http.StatusConflict || res.decided != nil {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+	})
+
+	t.Run("team full", func(t *testing.T) {
+		rec, res := run(t, joinStatusPending, 3, false, approve)
+		if rec.Code != http.StatusConflict || res.membership != nil || res.decided != nil {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+	})
+
+	t.Run("already in a team", func(t *testing.T) {
+		rec, res := run(t, joinStatusPending, 1, true, approve)
+		if rec.Code != http.StatusConflict || res.membership != nil {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		api := New(newTxStore(newQuerierBuilder().build()), &mailer.LogMailer{}, Settings{}, nil)
+		req := authedRequest(http.MethodPost, "/team/join-requests/x", nil, adminID, teamID, "admin")
+		req = withURLParam(req, "requestID", "not-a-uuid")
+		rec := httptest.NewRecorder()
+		api.handleApproveJoinRequest(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetTeamJoinRequestForUpdate(func(context.Context, sqlc.GetTeamJoinRequestForUpdateParams) (sqlc.TeamJoinRequest, error) {
+				return sqlc.TeamJoinRequest{}, pgx.ErrNoRows
+			}).
+			build()
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{}, nil)
+		req := authedRequest(http.MethodPost, "/team/join-requests/x", nil, adminID, teamID, "admin")
+		req = withURLParam(req, "requestID", uuidString(requestID))
+		rec := httptest.NewRecorder()
+		api.handleRejectJoinRequest(rec, req)
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404, got %d", rec.Code)
+		}
+	})
+}
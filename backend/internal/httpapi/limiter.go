@@ -1,13 +1,32 @@
 package httpapi
 
 import (
+	"context"
 	"sync"
 	"time"
 )
 
+const (
+	RateLimitBackendMemory   = "memory"
+	RateLimitBackendPostgres = "postgres"
+
+	limiterSweepInterval = 5 * time.Minute
+)
+
+// attemptLimiter counts attempts per key for the per-email request limit and
+// the verify-code lockout. The Postgres implementation shares state across
+// replicas and survives restarts; the in-memory one is for single instances.
+type attemptLimiter interface {
+	Allow(ctx context.Context, key string, max int, window time.Duration, now time.Time) (bool, error)
+	RegisterFailure(ctx context.Context, key string, max int, window, lock time.Duration, now time.Time) (locked bool, err error)
+	IsLocked(ctx context.Context, key string, now time.Time) (bool, error)
+	Reset(ctx context.Context, key string) error
+}
+
 type attemptTracker struct {
-	mu    sync.Mutex
-	state map[string]*attemptState
+	mu        sync.Mutex
+	state     map[string]*attemptState
+	lastSweep time.Time
 }
 
 type attemptState struct {
@@ -22,9 +41,10 @@ func newAttemptTracker() *attemptTracker {
 	}
 }
 
-func (t *attemptTracker) Allow(key string, max int, window time.Duration, now time.Time) bool {
+func (t *attemptTracker) Allow(_ context.Context, key string, max int, window time.Duration, now time.Time) (bool, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.sweep(now)
 
 	st := t.state[key]
 	if st == nil || now.After(st.resetAt) {
@@ -32,19 +52,20 @@ func (t *attemptTracker) Allow(key string, max int, window time.Duration, now ti
 			count:   1,
 			resetAt: now.Add(window),
 		}
-		return true
+		return true, nil
 	}
 
 	if st.count >= max {
-		return false
+		return false, nil
 	}
 	st.count++
-	return true
+	return true, nil
 }
 
-func (t *attemptTracker) RegisterFailure(key string, max int, window, lock time.Duration, now time.Time) (locked bool) {
+func (t *attemptTracker) RegisterFailure(_ context.Context, key string, max int, window, lock time.Duration, now time.Time) (bool, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.sweep(now)
 
 	st := t.state[key]
 	if st == nil || now.After(st.resetAt) {
@@ -56,30 +77,45 @@ func (t *attemptTracker) RegisterFailure(key string, max int, window, lock time.
 	}
 
 	if now.Before(st.lockUntil) {
-		return true
+		return true, nil
 	}
 
 	st.count++
 	if st.count >= max {
 		st.lockUntil = now.Add(lock)
-		return true
+		return true, nil
 	}
-	return false
+	return false, nil
 }
 
-func (t *attemptTracker) IsLocked(key string, now time.Time) bool {
+func (t *attemptTracker) IsLocked(_ context.Context, key string, now time.Time) (bool, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	st := t.state[key]
 	if st == nil {
-		return false
+		return false, nil
 	}
-	return now.Before(st.lockUntil)
+	return now.Before(st.lockUntil), nil
 }
 
-func (t *attemptTracker) Reset(key string) {
+func (t *attemptTracker) Reset(_ context.Context, key string) error {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	delete(t.state, key)
+	return nil
+}
+
+// sweep drops entries whose window and lock have both passed, at most once per
+// limiterSweepInterval, so the map only holds keys seen recently.
+func (t *attemptTracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < limiterSweepInterval {
+		return
+	}
+	t.lastSweep = now
+	for key, st := range t.state {
+		if now.After(st.resetAt) && !now.Before(st.lockUntil) {
+			delete(t.state, key)
+		}
+	}
 }
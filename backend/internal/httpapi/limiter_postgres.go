@@ -0,0 +1,119 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const limitCounterTimeout = 2 * time.Second
+
+// pgAttemptLimiter keeps attempt counts and lockouts in attempt_limits. Each
+// call is a single upsert, so concurrent requests on any replica see one
+// shared count. Keys are prefixed so separate limiters never collide, and
+// hashed so neither emails nor IP addresses are written to the database.
+type pgAttemptLimiter struct {
+	q      sqlc.Querier
+	prefix string
+}
+
+func newPostgresAttemptLimiter(q sqlc.Querier, name string) *pgAttemptLimiter {
+	return &pgAttemptLimiter{q: q, prefix: name + ":"}
+}
+
+func (l *pgAttemptLimiter) key(key string) string {
+	return limiterKey(l.prefix, key)
+}
+
+// Allow counts the attempt. The count stops one past max, so callers that
+// keep knocking while blocked don't push it further, matching attemptTracker.
+func (l *pgAttemptLimiter) Allow(ctx context.Context, key string, max int, window time.Duration, now time.Time) (bool, error) {
+	count, err := l.q.IncrementAttemptLimit(ctx, sqlc.IncrementAttemptLimitParams{
+		Key:         l.key(key),
+		ResetAt:     toTimestamptz(now.Add(window)),
+		Now:         toTimestamptz(now),
+		MaxAttempts: int32(max),
+	})
+	if err != nil {
+		return false, err
+	}
+	return int(count) <= max, nil
+}
+
+func (l *pgAttemptLimiter) RegisterFailure(ctx context.Context, key string, max int, window, lock time.Duration, now time.Time) (bool, error) {
+	lockUntil, err := l.q.RegisterAttemptFailure(ctx, sqlc.RegisterAttemptFailureParams{
+		Key:         l.key(key),
+		ResetAt:     toTimestamptz(now.Add(window)),
+		MaxAttempts: int32(max),
+		LockUntil:   toTimestamptz(now.Add(lock)),
+		Now:         toTimestamptz(now),
+	})
+	if err != nil {
+		return false, err
+	}
+	return lockUntil.Valid && now.Before(lockUntil.Time), nil
+}
+
+func (l *pgAttemptLimiter) IsLocked(ctx context.Context, key string, now time.Time) (bool, error) {
+	lockUntil, err := l.q.GetAttemptLockUntil(ctx, l.key(key))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return lockUntil.Valid && now.Before(lockUntil.Time), nil
+}
+
+func (l *pgAttemptLimiter) Reset(ctx context.Context, key string) error {
+	return l.q.ResetAttemptLimit(ctx, l.key(key))
+}
+
+// pgLimitCounter is an httprate.LimitCounter backed by rate_limit_counters so
+// IP limits are enforced across replicas.
+type pgLimitCounter struct {
+	q      sqlc.Querier
+	prefix string
+}
+
+func newPostgresLimitCounter(q sqlc.Querier, name string) *pgLimitCounter {
+	return &pgLimitCounter{q: q, prefix: name + ":"}
+}
+
+func (c *pgLimitCounter) key(key string) string {
+	return limiterKey(c.prefix, key)
+}
+
+func (c *pgLimitCounter) Config(int, time.Duration) {}
+
+func (c *pgLimitCounter) Increment(key string, currentWindow time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), limitCounterTimeout)
+	defer cancel()
+	return c.q.IncrementRateLimitCounter(ctx, sqlc.IncrementRateLimitCounterParams{
+		Key:         c.key(key),
+		WindowStart: toTimestamptz(currentWindow),
+	})
+}
+
+func (c *pgLimitCounter) Get(key string, currentWindow, previousWindow time.Time) (int, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), limitCounterTimeout)
+	defer cancel()
+	counts, err := c.q.GetRateLimitCounts(ctx, sqlc.GetRateLimitCountsParams{
+		CurrentWindow:  toTimestamptz(currentWindow),
+		PreviousWindow: toTimestamptz(previousWindow),
+		Key:            c.key(key),
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(counts.CurrentCount), int(counts.PreviousCount), nil
+}
+
+func limiterKey(prefix, key string) string {
+	return hex.EncodeToString(hashString(prefix + key))
+}
@@ -0,0 +1,171 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestPostgresAttemptLimiterAllow(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	var count int32
+	var gotKey string
+	q := newQuerierBuilder().
+		onIncrementAttemptLimit(func(_ context.Context, arg sqlc.IncrementAttemptLimitParams) (int32, error) {
+			gotKey = arg.Key
+			if !arg.ResetAt.Time.Equal(now.Add(time.Minute)) || !arg.Now.Time.Equal(now) || arg.MaxAttempts != 2 {
+				t.Fatalf("unexpected params: %+v", arg)
+			}
+			count++
+			return count, nil
+		}).
+		build()
+
+	limiter := newPostgresAttemptLimiter(q, "request_code_email")
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if !mustBool(t)(limiter.Allow(ctx, "user@example.com", 2, time.Minute, now)) {
+			t.Fatalf("expected attempt %d to pass", i+1)
+		}
+	}
+	if mustBool(t)(limiter.Allow(ctx, "user@example.com", 2, time.Minute, now)) {
+		t.Fatal("expected third attempt to fail")
+	}
+	if gotKey != limiterKey("request_code_email:", "user@example.com") {
+		t.Fatalf("unexpected key: %q", gotKey)
+	}
+}
+
+func TestPostgresAttemptLimiterRegisterFailure(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	var lockUntil pgtype.Timestamptz
+	q := newQuerierBuilder().
+		onRegisterAttemptFailure(func(_ context.Context, arg sqlc.RegisterAttemptFailureParams) (pgtype.Timestamptz, error) {
+			if arg.MaxAttempts != 3 || !arg.LockUntil.Time.Equal(now.Add(10*time.Minute)) {
+				t.Fatalf("unexpected params: %+v", arg)
+			}
+			return lockUntil, nil
+		}).
+		build()
+
+	limiter := newPostgresAttemptLimiter(q, "verify_code_email")
+	ctx := context.Background()
+	if mustBool(t)(limiter.RegisterFailure(ctx, "user@example.com", 3, time.Minute, 10*time.Minute, now)) {
+		t.Fatal("expected no lock without lock_until")
+	}
+
+	lockUntil = toTimestamptz(now.Add(10 * time.Minute))
+	if !mustBool(t)(limiter.RegisterFailure(ctx, "user@example.com", 3, time.Minute, 10*time.Minute, now)) {
+		t.Fatal("expected lock")
+	}
+
+	lockUntil = toTimestamptz(now.Add(-time.Second))
+	if mustBool(t)(limiter.RegisterFailure(ctx, "user@example.com", 3, time.Minute, 10*time.Minute, now)) {
+		t.Fatal("expected expired lock to be ignored")
+	}
+}
+
+func TestPostgresAttemptLimiterIsLocked(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	var lockErr error
+	q := newQuerierBuilder().
+		onGetAttemptLockUntil(func(_ context.Context, key string) (pgtype.Timestamptz, error) {
+			if key != limiterKey("verify_code_email:", "user@example.com") {
+				t.Fatalf("unexpected key: %q", key)
+			}
+			return toTimestamptz(now.Add(time.Minute)), lockErr
+		}).
+		build()
+
+	limiter := newPostgresAttemptLimiter(q, "verify_code_email")
+	ctx := context.Background()
+	if !mustBool(t)(limiter.IsLocked(ctx, "user@example.com", now)) {
+		t.Fatal("expected lock to be active")
+	}
+	if mustBool(t)(limiter.IsLocked(ctx, "user@example.com", now.Add(2*time.Minute))) {
+		t.Fatal("expected lock to expire")
+	}
+
+	lockErr = pgx.ErrNoRows
+	if mustBool(t)(limiter.IsLocked(ctx, "user@example.com", now)) {
+		t.Fatal("expected unknown key to be unlocked")
+	}
+
+	lockErr = errors.New("db down")
+	if _, err := limiter.IsLocked(ctx, "user@example.com", now); err == nil {
+		t.Fatal("expected error to surface")
+	}
+}
+
+func TestPostgresLimitCounter(t *testing.T) {
+	current := time.Date(2024, 7, 1, 8, 1, 0, 0, time.UTC)
+	previous := current.Add(-time.Minute)
+	counts := map[time.Time]int32{}
+	q := newQuerierBuilder().
+		onIncrementRateLimitCounter(func(_ context.Context, arg sqlc.IncrementRateLimitCounterParams) error {
+			if arg.Key != limiterKey("verify_code_ip:", "10.0.0.1") {
+				t.Fatalf("unexpected key: %q", arg.Key)
+			}
+			counts[arg.WindowStart.Time]++
+			return nil
+		}).
+		onGetRateLimitCounts(func(_ context.Context, arg sqlc.GetRateLimitCountsParams) (sqlc.GetRateLimitCountsRow, error) {
+			return sqlc.GetRateLimitCountsRow{
+				CurrentCount:  counts[arg.CurrentWindow.Time],
+				PreviousCount: counts[arg.PreviousWindow.Time],
+			}, nil
+		}).
+		build()
+
+	counter := newPostgresLimitCounter(q, "verify_code_ip")
+	for _, window := range []time.Time{previous, current, current} {
+		if err := counter.Increment("10.0.0.1", window); err != nil {
+			t.Fatalf("increment: %v", err)
+		}
+	}
+	cur, prev, err := counter.Get("10.0.0.1", current, previous)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if cur != 2 || prev != 1 {
+		t.Fatalf("unexpected counts: current=%d previous=%d", cur, prev)
+	}
+}
+
+func TestRequestCodeFailsClosedWhenLimiterErrors(t *testing.T) {
+	q := newQuerierBuilder().
+		onIncrementAttemptLimit(func(context.Context, sqlc.IncrementAttemptLimitParams) (int32, error) {
+			return 0, errors.New("db down")
+		}).
+		onIncrementRateLimitCounter(func(context.Context, sqlc.IncrementRateLimitCounterParams) error {
+			return nil
+		}).
+		build()
+	api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{
+		RateLimitBackend:       RateLimitBackendPostgres,
+		RequestCodeIPLimit:     10,
+		RequestCodeIPWindow:    time.Minute,
+		RequestCodeEmailLimit:  3,
+		RequestCodeEmailWindow: time.Minute,
+	}, nil)
+
+	body, _ := json.Marshal(requestCodeRequest{Email: "user@example.com"})
+	req := httptest.NewRequest(http.MethodPost, "/auth/request-code", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	api.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rec.Code)
+	}
+}
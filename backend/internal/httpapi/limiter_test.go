@@ -1,76 +1,116 @@
 package httpapi
 
 import (
+	"context"
 	"testing"
 	"time"
 )
 
 func TestAttemptTrackerAllow(t *testing.T) {
+	ctx := context.Background()
 	tracker := newAttemptTracker()
 	now := time.Now()
 	window := 10 * time.Minute
 
-	if !tracker.Allow("alpha", 2, window, now) {
+	if !mustBool(t)(tracker.Allow(ctx, "alpha", 2, window, now)) {
 		t.Fatal("expected first allow to pass")
 	}
-	if !tracker.Allow("alpha", 2, window, now) {
+	if !mustBool(t)(tracker.Allow(ctx, "alpha", 2, window, now)) {
 		t.Fatal("expected second allow to pass")
 	}
-	if tracker.Allow("alpha", 2, window, now) {
+	if mustBool(t)(tracker.Allow(ctx, "alpha", 2, window, now)) {
 		t.Fatal("expected third allow to fail")
 	}
 }
 
 func TestAttemptTrackerAllowResetsAfterWindow(t *testing.T) {
+	ctx := context.Background()
 	tracker := newAttemptTracker()
 	window := 5 * time.Minute
 	now := time.Now()
 
-	if !tracker.Allow("beta", 1, window, now) {
+	if !mustBool(t)(tracker.Allow(ctx, "beta", 1, window, now)) {
 		t.Fatal("expected allow to pass")
 	}
-	if tracker.Allow("beta", 1, window, now) {
+	if mustBool(t)(tracker.Allow(ctx, "beta", 1, window, now)) {
 		t.Fatal("expected second allow to fail")
 	}
 
 	later := now.Add(window + time.Second)
-	if !tracker.Allow("beta", 1, window, later) {
+	if !mustBool(t)(tracker.Allow(ctx, "beta", 1, window, later)) {
 		t.Fatal("expected allow to pass after window reset")
 	}
 }
 
 func TestAttemptTrackerRegisterFailureLocks(t *testing.T) {
+	ctx := context.Background()
 	tracker := newAttemptTracker()
 	now := time.Now()
 	window := 15 * time.Minute
 	lock := 10 * time.Minute
 
-	if tracker.RegisterFailure("gamma", 3, window, lock, now) {
+	if mustBool(t)(tracker.RegisterFailure(ctx, "gamma", 3, window, lock, now)) {
 		t.Fatal("expected not locked on first failure")
 	}
-	if tracker.RegisterFailure("gamma", 3, window, lock, now) {
+	if mustBool(t)(tracker.RegisterFailure(ctx, "gamma", 3, window, lock, now)) {
 		t.Fatal("expected not locked on second failure")
 	}
-	if !tracker.RegisterFailure("gamma", 3, window, lock, now) {
+	if !mustBool(t)(tracker.RegisterFailure(ctx, "gamma", 3, window, lock, now)) {
 		t.Fatal("expected locked on third failure")
 	}
-	if !tracker.IsLocked("gamma", now.Add(time.Minute)) {
+	if !mustBool(t)(tracker.IsLocked(ctx, "gamma", now.Add(time.Minute))) {
 		t.Fatal("expected lock to be active")
 	}
-	if tracker.IsLocked("gamma", now.Add(lock+time.Second)) {
+	if mustBool(t)(tracker.IsLocked(ctx, "gamma", now.Add(lock+time.Second))) {
 		t.Fatal("expected lock to expire")
 	}
 }
 
 func TestAttemptTrackerReset(t *testing.T) {
+	ctx := context.Background()
 	tracker := newAttemptTracker()
 	now := time.Now()
 
-	if !tracker.Allow("delta", 1, time.Minute, now) {
+	if !mustBool(t)(tracker.Allow(ctx, "delta", 1, time.Minute, now)) {
 		t.Fatal("expected allow to pass")
 	}
-	tracker.Reset("delta")
-	if !tracker.Allow("delta", 1, time.Minute, now) {
+	if err := tracker.Reset(ctx, "delta"); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	if !mustBool(t)(tracker.Allow(ctx, "delta", 1, time.Minute, now)) {
 		t.Fatal("expected allow to pass after reset")
 	}
 }
+
+func TestAttemptTrackerSweepEvictsExpiredKeys(t *testing.T) {
+	ctx := context.Background()
+	tracker := newAttemptTracker()
+	now := time.Now()
+
+	mustBool(t)(tracker.Allow(ctx, "stale", 1, time.Minute, now))
+	mustBool(t)(tracker.RegisterFailure(ctx, "locked", 1, time.Minute, time.Hour, now))
+
+	later := now.Add(limiterSweepInterval + time.Minute)
+	mustBool(t)(tracker.Allow(ctx, "fresh", 1, time.Minute, later))
+
+	if _, ok := tracker.state["stale"]; ok {
+		t.Fatal("expected expired key to be swept")
+	}
+	if _, ok := tracker.state["locked"]; !ok {
+		t.Fatal("expected locked key to survive the sweep")
+	}
+	if _, ok := tracker.state["fresh"]; !ok {
+		t.Fatal("expected fresh key to be kept")
+	}
+}
+
+func mustBool(t *testing.T) func(bool, error) bool {
+	t.Helper()
+	return func(v bool, err error) bool {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return v
+	}
+}
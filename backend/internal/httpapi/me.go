@@ -0,0 +1,120 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// bootstrapResponse is the state a client needs to rebuild itself on launch.
+// GET /me returns it on its own and verify-code returns it next to the
+// tokens. Only members get the full state, which both build with
+// loadBootstrap; everyone else gets their join status from loadJoinOutcome.
+type bootstrapResponse struct {
+	Status       string                 `json:"status"`
+	JoinRequest  *joinRequestResponse   `json:"join_request,omitempty"`
+	User         *userResponse          `json:"user,omitempty"`
+	Team         *teamResponse          `json:"team,omitempty"`
+	Role         string                 `json:"role,omitempty"`
+	Visibility   *visibilityResponse    `json:"visibility,omitempty"`
+	WorkingHours *workingHoursResponse  `json:"working_hours,omitempty"`
+	Timezone     *timezoneStateResponse `json:"timezone,omitempty"`
+	ServerTime   *time.Time             `json:"server_time,omitempty"`
+}
+
+func (a *API) handleGetMe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	q := a.store.Querier()
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		a.logger.Error("failed to load user", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to load account")
+		return
+	}
+
+	var resp bootstrapResponse
+	if teamID, ok := teamIDFromContext(ctx); ok {
+		role, _ := roleFromContext(ctx)
+		var team sqlc.Team
+		team, err = q.GetTeamByID(ctx, teamID)
+		if err == nil {
+			resp, err = loadBootstrap(ctx, q, user, team, role, a.clock())
+		}
+	} else {
+		resp, err = loadJoinStatus(ctx, q, user, a.clock())
+	}
+	if err != nil {
+		a.logger.Error("failed to load bootstrap state", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to load account")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// loadJoinStatus reports where a user without a team stands: pending or
+// rejected while their latest join request says so, and choose_team once
+// there is nothing left to wait for.
+func loadJoinStatus(ctx context.Context, q sqlc.Querier, user sqlc.User, now time.Time) (bootstrapResponse, error) {
+	request, err := q.GetLatestTeamJoinRequestByUserID(ctx, user.ID)
+	if errors.Is(err, pgx.ErrNoRows) || err == nil && request.Status == joinStatusApproved {
+		// An approved user who no longer has a team has left it since.
+		return loadJoinOutcome(ctx, q, user, sqlc.Team{}, "", joinStatusChooseTeam, nil, now)
+	}
+	if err != nil {
+		return bootstrapResponse{}, err
+	}
+	team, err := q.GetTeamByID(ctx, request.TeamID)
+	if err != nil {
+		return bootstrapResponse{}, err
+	}
+	return loadJoinOutcome(ctx, q, user, team, "", request.Status, &request, now)
+}
+
+func loadBootstrap(ctx context.Context, q sqlc.Querier, user sqlc.User, team sqlc.Team, role string, now time.Time) (bootstrapResponse, error) {
+	visibility, err := loadVisibility(ctx, q, user.ID, now)
+	if err != nil {
+		return bootstrapResponse{}, err
+	}
+	hours, err := loadWorkingHours(ctx, q, user.ID)
+	if err != nil {
+		return bootstrapResponse{}, err
+	}
+
+	// Timezone is left out until the client first reports one.
+	var timezone *timezoneStateResponse
+	state, err := q.GetTimezoneState(ctx, user.ID)
+	if err == nil {
+		resp := newTimezoneStateResponse(state)
+		timezone = &resp
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return bootstrapResponse{}, err
+	}
+
+	teamResp := newTeamResponse(team)
+	return bootstrapResponse{
+		Status: joinStatusMember,
+		User: &userResponse{
+			ID:    uuidString(user.ID),
+			Email: user.Email,
+		},
+		Team:         &teamResp,
+		Role:         role,
+		Visibility:   &visibility,
+		WorkingHours: &hours,
+		Timezone:     timezone,
+		ServerTime:   &now,
+	}, nil
+}
@@ -0,0 +1,174 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestHandleGetMe(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	baseQuerier := func() *querierBuilder {
+		return newQuerierBuilder().
+			onGetUserByID(func(_ context.Context, id pgtype.UUID) (sqlc.User, error) {
+				return sqlc.User{ID: id, Email: "ana@example.com"}, nil
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "example.com", Name: "Example"}, nil
+			})
+	}
+	getMe := func(api *API) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		api.handleGetMe(rec, authedRequest(http.MethodGet, "/me", nil, userID, teamID, "admin"))
+		return rec
+	}
+
+	t.Run("full state", func(t *testing.T) {
+		q := baseQuerier().
+			onGetTimezoneVisibility(func(context.Context, pgtype.UUID) (sqlc.TimezoneVisibility, error) {
+				return sqlc.TimezoneVisibility{HiddenUntil: toTimestamptz(now.Add(24 * time.Hour))}, nil
+			}).
+			onGetWorkingHours(func(context.Context, pgtype.UUID) (sqlc.WorkingHour, error) {
+				return sqlc.WorkingHour{StartMinute: 600, EndMinute: 1080, WorkingDays: []int32{1, 2, 3}}, nil
+			}).
+			onGetTimezoneState(func(context.Context, pgtype.UUID) (sqlc.TimezoneState, error) {
+				return sqlc.TimezoneState{Timezone: "Europe/Berlin", UtcOffsetMinutes: 120, CountryCode: "DE"}, nil
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+
+		rec := getMe(api)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var resp bootstrapResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.User == nil || resp.User.Email != "ana@example.com" || resp.Team == nil || resp.Team.Name != "Example" || resp.Role != "admin" {
+			t.Fatalf("unexpected identity: %+v", resp)
+		}
+		if resp.Visibility == nil || !resp.Visibility.Hidden {
+			t.Fatalf("expected hidden visibility, got %+v", resp.Visibility)
+		}
+		if resp.WorkingHours == nil || !resp.WorkingHours.Configured || resp.WorkingHours.StartMinute != 600 {
+			t.Fatalf("unexpected working hours: %+v", resp.WorkingHours)
+		}
+		if resp.Timezone == nil || resp.Timezone.Timezone != "Europe/Berlin" {
+			t.Fatalf("unexpected timezone: %+v", resp.Timezone)
+		}
+		if resp.ServerTime == nil || !resp.ServerTime.Equal(now) {
+			t.Fatalf("unexpected server time: %v", resp.ServerTime)
+		}
+	})
+
+	t.Run("new user gets defaults", func(t *testing.T) {
+		q := baseQuerier().
+			onGetTimezoneVisibility(func(context.Context, pgtype.UUID) (sqlc.TimezoneVisibility, error) {
+				return sqlc.TimezoneVisibility{}, pgx.ErrNoRows
+			}).
+			onGetWorkingHours(func(context.Context, pgtype.UUID) (sqlc.WorkingHour, error) {
+				return sqlc.WorkingHour{}, pgx.ErrNoRows
+			}).
+			onGetTimezoneState(func(context.Context, pgtype.UUID) (sqlc.TimezoneState, error) {
+				return sqlc.TimezoneState{}, pgx.ErrNoRows
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+
+		rec := getMe(api)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var resp bootstrapResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if resp.Visibility == nil || resp.Visibility.Hidden {
+			t.Fatalf("expected visible by default, got %+v", resp.Visibility)
+		}
+		if resp.WorkingHours == nil || resp.WorkingHours.Configured || resp.WorkingHours.StartMinute != defaultStartMinute {
+			t.Fatalf("expected default working hours, got %+v", resp.WorkingHours)
+		}
+		if resp.Timezone != nil || strings.Contains(rec.Body.String(), `"timezone"`) {
+			t.Fatalf("expected no timezone before the first report: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("db failure", func(t *testing.T) {
+		q := baseQuerier().
+			onGetWorkingHours(func(context.Context, pgtype.UUID) (sqlc.WorkingHour, error) {
+				return sqlc.WorkingHour{}, errors.New("db down")
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		if rec := getMe(api); rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", rec.Code)
+		}
+	})
+
+	t.Run("without a team", func(t *testing.T) {
+		otherTeam := pgtype.UUID{Bytes: [16]byte{8}, Valid: true}
+		tests := []struct {
+			name       string
+			request    sqlc.TeamJoinRequest
+			requestErr error
+			wantStatus string
+		}{
+			{name: "pending request", request: sqlc.TeamJoinRequest{TeamID: otherTeam, Status: joinStatusPending}, wantStatus: joinStatusPending},
+			{name: "rejected request", request: sqlc.TeamJoinRequest{TeamID: otherTeam, Status: joinStatusRejected}, wantStatus: joinStatusRejected},
+			{name: "approved then left", request: sqlc.TeamJoinRequest{TeamID: otherTeam, Status: joinStatusApproved}, wantStatus: joinStatusChooseTeam},
+			{name: "no request", requestErr: pgx.ErrNoRows, wantStatus: joinStatusChooseTeam},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				q := baseQuerier().
+					onGetLatestTeamJoinRequestByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamJoinRequest, error) {
+						return tt.request, tt.requestErr
+					}).
+					build()
+				api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+				api.clock = func() time.Time { return now }
+
+				rec := httptest.NewRecorder()
+				api.handleGetMe(rec, authedRequest(http.MethodGet, "/me", nil, userID, pgtype.UUID{}, ""))
+
+				if rec.Code != http.StatusOK {
+					t.Fatalf("expected status 200, got %d", rec.Code)
+				}
+				var resp bootstrapResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if resp.Status != tt.wantStatus || resp.Team != nil || resp.Visibility != nil {
+					t.Fatalf("expected only status %q, got %+v", tt.wantStatus, resp)
+				}
+				if hasRequest := resp.JoinRequest != nil; hasRequest != (tt.wantStatus != joinStatusChooseTeam) {
+					t.Fatalf("unexpected join request: %+v", resp.JoinRequest)
+				}
+				if resp.JoinRequest != nil && resp.JoinRequest.TeamName != "Example" {
+					t.Fatalf("expected the requested team, got %+v", resp.JoinRequest)
+				}
+			})
+		}
+	})
+}
@@ -0,0 +1,238 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	errLastAdmin      = errors.New("team must keep at least one admin")
+	errMemberNotFound = errors.New("member not found")
+)
+
+type updateMemberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type membershipResponse struct {
+	UserID   string    `json:"user_id"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+func (a *API) handleUpdateMemberRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+	memberID, ok := parseUUID(chi.URLParam(r, "userID"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req updateMemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Role != "admin" && req.Role != "member" {
+		writeError(w, http.StatusBadRequest, "role must be admin or member")
+		return
+	}
+
+	var updated sqlc.TeamMembership
+	err := a.withTeamLock(ctx, teamID, func(q sqlc.Querier) error {
+		membership, err := getMembership(ctx, q, teamID, memberID)
+		if err != nil {
+			return err
+		}
+		if membership.Role == req.Role {
+			updated = membership
+			return nil
+		}
+		if err := ensureOtherAdmin(ctx, q, membership); err != nil {
+			return err
+		}
+		updated, err = q.UpdateTeamMembershipRole(ctx, sqlc.UpdateTeamMembershipRoleParams{
+			TeamID: teamID,
+			UserID: memberID,
+			Role:   req.Role,
+		})
+		return err
+	})
+	if err != nil {
+		a.writeMembershipError(w, err, "failed to update role")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newMembershipResponse(updated))
+}
+
+func (a *API) handleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+	memberID, ok := parseUUID(chi.URLParam(r, "userID"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	now := a.clock()
+	err := a.withTeamLock(ctx, teamID, func(q sqlc.Querier) error {
+		if err := removeMembership(ctx, q, teamID, memberID); err != nil {
+			return err
+		}
+		// Cut off roster access immediately instead of waiting for the
+		// access token to expire.
+		return q.RevokeUserAuthSessions(ctx, sqlc.RevokeUserAuthSessionsParams{
+			UserID:    memberID,
+			RevokedAt: toTimestamptz(now),
+		})
+	})
+	if err != nil {
+		a.writeMembershipError(w, err, "failed to remove member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) handleLeaveTeam(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	err := a.withTeamLock(ctx, teamID, func(q sqlc.Querier) error {
+		return removeMembership(ctx, q, teamID, userID)
+	})
+	if err != nil {
+		a.writeMembershipError(w, err, "failed to leave team")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) writeMembershipError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, errMemberNotFound):
+		writeError(w, http.StatusNotFound, "member not found")
+	case errors.Is(err, errLastAdmin):
+		writeError(w, http.StatusConflict, "team must keep at least one admin")
+	default:
+		a.logger.Error(message, slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, message)
+	}
+}
+
+// withTeamLock runs fn in a transaction holding the team row lock, so that
+// concurrent role changes and removals see each other's admin counts and two
+// admins cannot demote each other at the same moment.
+func (a *API) withTeamLock(ctx context.Context, teamID pgtype.UUID, fn func(q sqlc.Querier) error) error {
+	tx, err := a.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.store.WithTx(tx)
+	if _, err := q.GetTeamByIDForUpdate(ctx, teamID); err != nil {
+		return err
+	}
+	if err := fn(q); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func getMembership(ctx context.Context, q sqlc.Querier, teamID, userID pgtype.UUID) (sqlc.TeamMembership, error) {
+	membership, err := q.GetTeamMembership(ctx, sqlc.GetTeamMembershipParams{
+		TeamID: teamID,
+		UserID: userID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.TeamMembership{}, errMemberNotFound
+	}
+	return membership, err
+}
+
+// ensureOtherAdmin fails if membership is the team's only admin.
+func ensureOtherAdmin(ctx context.Context, q sqlc.Querier, membership sqlc.TeamMembership) error {
+	if membership.Role != "admin" {
+		return nil
+	}
+	admins, err := q.CountTeamAdmins(ctx, membership.TeamID)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return errLastAdmin
+	}
+	return nil
+}
+
+// removeMembership deletes a membership unless it belongs to the team's only
+// admin, who has to promote someone else first. An admin who is also the
+// last member has nobody to promote, so the team is deleted with them.
+func removeMembership(ctx context.Context, q sqlc.Querier, teamID, userID pgtype.UUID) error {
+	membership, err := getMembership(ctx, q, teamID, userID)
+	if err != nil {
+		return err
+	}
+	if err := ensureOtherAdmin(ctx, q, membership); errors.Is(err, errLastAdmin) {
+		members, err := q.CountTeamMembers(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		if members > 1 {
+			return errLastAdmin
+		}
+		return q.DeleteTeam(ctx, teamID)
+	} else if err != nil {
+		return err
+	}
+	deleted, err := q.DeleteTeamMembership(ctx, sqlc.DeleteTeamMembershipParams{
+		TeamID: teamID,
+		UserID: userID,
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errMemberNotFound
+	}
+	return nil
+}
+
+func newMembershipResponse(membership sqlc.TeamMembership) membershipResponse {
+	return membershipResponse{
+		UserID:   uuidString(membership.UserID),
+		Role:     membership.Role,
+		JoinedAt: membership.JoinedAt.Time,
+	}
+}
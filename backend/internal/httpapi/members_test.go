@@ -0,0 +1,405 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// lockingTx releases the fake team row lock when the transaction ends.
+type lockingTx struct {
+	testTx
+	mu     *sync.Mutex
+	locked bool
+}
+
+func (t *lockingTx) Commit(ctx context.Context) error {
+	t.release()
+	return t.testTx.Commit(ctx)
+}
+
+func (t *lockingTx) Rollback(ctx context.Context) error {
+	t.release()
+	return t.testTx.Rollback(ctx)
+}
+
+func (t *lockingTx) release() {
+	if t.locked {
+		t.locked = false
+		t.mu.Unlock()
+	}
+}
+
+// fakeTeam is an in-memory team whose row lock behaves like SELECT ... FOR
+// UPDATE: it is held from GetTeamByIDForUpdate until the transaction ends.
+type fakeTeam struct {
+	lock    sync.Mutex
+	mu      sync.Mutex
+	teamID  pgtype.UUID
+	roles   map[pgtype.UUID]string
+	revoked map[pgtype.UUID]bool
+	deleted bool
+}
+
+func newFakeTeam(teamID pgtype.UUID, roles map[pgtype.UUID]string) *fakeTeam {
+	return &fakeTeam{teamID: teamID, roles: roles, revoked: make(map[pgtype.UUID]bool)}
+}
+
+func (f *fakeTeam) store() *stubStore {
+	var current *lockingTx
+	var currentMu sync.Mutex
+	q := newQuerierBuilder().
+		onGetTeamByIDForUpdate(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+			f.lock.Lock()
+			currentMu.Lock()
+			current.locked = true
+			currentMu.Unlock()
+			// Widen the race window so unsynchronised code would interleave.
+			time.Sleep(time.Millisecond)
+			return sqlc.Team{ID: id}, nil
+		}).
+		onGetTeamMembership(func(_ context.Context, arg sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			role, ok := f.roles[arg.UserID]
+			if !ok {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}
+			return sqlc.TeamMembership{TeamID: f.teamID, UserID: arg.UserID, Role: role}, nil
+		}).
+		onCountTeamAdmins(func(context.Context, pgtype.UUID) (int64, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			var n int64
+			for _, role := range f.roles {
+				if role == "admin" {
+					n++
+				}
+			}
+			return n, nil
+		}).
+		onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			return int64(len(f.roles)), nil
+		}).
+		onDeleteTeam(func(context.Context, pgtype.UUID) error {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.deleted = true
+			f.roles = map[pgtype.UUID]string{}
+			return nil
+		}).
+		onUpdateTeamMembershipRole(func(_ context.Context, arg sqlc.UpdateTeamMembershipRoleParams) (sqlc.TeamMembership, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.roles[arg.UserID] = arg.Role
+			return sqlc.TeamMembership{TeamID: arg.TeamID, UserID: arg.UserID, Role: arg.Role}, nil
+		}).
+		onDeleteTeamMembership(func(_ context.Context, arg sqlc.DeleteTeamMembershipParams) (int64, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if _, ok := f.roles[arg.UserID]; !ok {
+				return 0, nil
+			}
+			delete(f.roles, arg.UserID)
+			return 1, nil
+		}).
+		onRevokeUserAuthSessions(func(_ context.Context, arg sqlc.RevokeUserAuthSessionsParams) error {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.revoked[arg.UserID] = true
+			return nil
+		}).
+		build()
+
+	return &stubStore{
+		querier: q,
+		beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+			tx := &lockingTx{mu: &f.lock}
+			currentMu.Lock()
+			current = tx
+			currentMu.Unlock()
+			return tx, nil
+		},
+	}
+}
+
+func (f *fakeTeam) admins() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, role := range f.roles {
+		if role == "admin" {
+			n++
+		}
+	}
+	return n
+}
+
+func roleRequest(t *testing.T, api *API, actor, target, teamID pgtype.UUID, role string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(updateMemberRoleRequest{Role: role})
+	req := authedRequest(http.MethodPatch, "/team/members/"+uuidString(target), body, actor, teamID, "admin")
+	req = withURLParam(req, "userID", uuidString(target))
+	rec := httptest.NewRecorder()
+	api.handleUpdateMemberRole(rec, req)
+	return rec
+}
+
+func TestHandleUpdateMemberRole(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	alice := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	bob := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	carol := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+
+	t.Run("promote member", func(t *testing.T) {
+		team := newFakeTeam(teamID, map[pgtype.UUID]string{alice: "admin", bob: "member"})
+		api := New(team.store(), &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := roleRequest(t, api, alice, bob, teamID, "admin")
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if team.roles[bob] != "admin" {
+			t.Fatalf("expected bob to be admin, got %q", team.roles[bob])
+		}
+	})
+
+	t.Run("demote self when another admin exists", func(t *testing.T) {
+		team := newFakeTeam(teamID, map[pgtype.UUID]string{alice: "admin", bob: "admin"})
+		api := New(team.store(), &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := roleRequest(t, api, alice, alice, teamID, "member")
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if team.admins() != 1 {
+			t.Fatalf("expected one admin, got %d", team.admins())
+		}
+	})
+
+	t.Run("cannot demote last admin", func(t *testing.T) {
+		team := newFakeTeam(teamID, map[pgtype.UUID]string{alice: "admin", bob: "member"})
+		api := New(team.store(), &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := roleRequest(t, api, alice, alice, teamID, "member")
+
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+	})
+
+	t.Run("concurrent mutual demotion keeps an admin", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			team := newFakeTeam(teamID, map[pgtype.UUID]string{alice: "admin", bob: "admin", carol: "member"})
+			api := New(team.store(), &mailer.LogMailer{}, Settings{}, nil)
+
+			var wg sync.WaitGroup
+			codes := make([]int, 2)
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				codes[0] = roleRequest(t, api, alice, bob, teamID, "member").Code
+			}()
+			go func() {
+				defer wg.Done()
+				codes[1] = roleRequest(t, api, bob, alice, teamID, "member").Code
+			}()
+			wg.Wait()
+
+			if team.admins() != 1 {
+				t.Fatalf("expected exactly one admin, got %d", team.admins())
+			}
+			if !(codes[0] == http.StatusOK && codes[1] == http.StatusConflict) &&
+				!(codes[0] == http.StatusConflict && codes[1] == http.StatusOK) {
+				t.Fatalf("expected one success and one conflict, got %v", codes)
+			}
+		}
+	})
+
+	t.Run("member not found", func(t *testing.T) {
+		team := newFakeTeam(teamID, map[pgtype.UUID]string{alice: "admin"})
+		api := New(team.store(), &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := roleRequest(t, api, alice, carol, teamID, "admin")
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404, got %d", rec.Code)
+		}
+	})
+
+	t.Run("invalid role", func(t *testing.T) {
+		api := New(&stubStore{}, &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := roleRequest(t, api, alice, bob, teamID, "owner")
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("begin failure", func(t *testing.T) {
+		api := New(&stubStore{
+			beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+				return nil, errors.New("db down")
+			},
+		}, &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := roleRequest(t, api, alice, bob, teamID, "admin")
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleRemoveMember(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	alice := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	bob := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+
+	remove := func(api *API, target pgtype.UUID) *httptest.ResponseRecorder {
+		req := authedRequest(http.MethodDelete, "/team/members/"+uuidString(target), nil, alice, teamID, "admin")
+		req = withURLParam(req, "userID", uuidString(target))
+		rec := httptest.NewRecorder()
+		api.handleRemoveMember(rec, req)
+		return rec
+	}
+
+	t.Run("removes member and revokes sessions", func(t *testing.T) {
+		team := newFakeTeam(teamID, map[pgtype.UUID]string{alice: "admin", bob: "member"})
+		api := New(team.store(), &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := remove(api, bob)
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", rec.Code)
+		}
+		if _, ok := team.roles[bob]; ok {
+			t.Fatal("expected bob to be removed")
+		}
+		if !team.revoked[bob] {
+			t.Fatal("expected bob's sessions to be revoked")
+		}
+	})
+
+	t.Run("cannot remove last admin", func(t *testing.T) {
+		team := newFakeTeam(teamID, map[pgtype.UUID]string{alice: "admin", bob: "member"})
+		api := New(team.store(), &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := remove(api, alice)
+
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+		if team.revoked[alice] {
+			t.Fatal("expected sessions to stay active")
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		team := newFakeTeam(teamID, map[pgtype.UUID]string{alice: "admin"})
+		api := New(team.store(), &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := remove(api, bob)
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404, got %d", rec.Code)
+		}
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		api := New(&stubStore{}, &mailer.LogMailer{}, Settings{}, nil)
+		req := authedRequest(http.MethodDelete, "/team/members/nope", nil, alice, teamID, "admin")
+		req = withURLParam(req, "userID", "nope")
+		rec := httptest.NewRecorder()
+
+		api.handleRemoveMember(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleLeaveTeam(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	alice := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	bob := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+
+	leave := func(api *API, user pgtype.UUID, role string) *httptest.ResponseRecorder {
+		req := authedRequest(http.MethodPost, "/team/leave", nil, user, teamID, role)
+		rec := httptest.NewRecorder()
+		api.handleLeaveTeam(rec, req)
+		return rec
+	}
+
+	t.Run("member leaves", func(t *testing.T) {
+		team := newFakeTeam(teamID, map[pgtype.UUID]string{alice: "admin", bob: "member"})
+		api := New(team.store(), &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := leave(api, bob, "member")
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", rec.Code)
+		}
+		if _, ok := team.roles[bob]; ok {
+			t.Fatal("expected bob to have left")
+		}
+	})
+
+	t.Run("last admin must promote first", func(t *testing.T) {
+		team := newFakeTeam(teamID, map[pgtype.UUID]string{alice: "admin", bob: "member"})
+		api := New(team.store(), &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := leave(api, alice, "admin")
+
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+		if team.deleted {
+			t.Fatal("expected team to be kept")
+		}
+	})
+
+	t.Run("sole member deletes the team", func(t *testing.T) {
+		team := newFakeTeam(teamID, map[pgtype.UUID]string{alice: "admin"})
+		api := New(team.store(), &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := leave(api, alice, "admin")
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", rec.Code)
+		}
+		if !team.deleted {
+			t.Fatal("expected the empty team to be deleted")
+		}
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		api := New(&stubStore{}, &mailer.LogMailer{}, Settings{}, nil)
+		req := httptest.NewRequest(http.MethodPost, "/team/leave", bytes.NewReader(nil))
+		rec := httptest.NewRecorder()
+
+		api.handleLeaveTeam(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status 401, got %d", rec.Code)
+		}
+	})
+}
@@ -1,15 +1,26 @@
 package httpapi
 
 import (
+	"context"
 	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type contextKey string
 
 const (
-	contextKeyUserID contextKey = "userID"
+	contextKeyUserID    contextKey = "userID"
+	contextKeyTeamID    contextKey = "teamID"
+	contextKeyRole      contextKey = "role"
+	contextKeySessionID contextKey = "sessionID"
+	contextKeyFamilyID  contextKey = "familyID"
 )
 
 func keyByDeviceID(r *http.Request) (string, error) {
@@ -19,3 +30,146 @@ func keyByDeviceID(r *http.Request) (string, error) {
 	}
 	return "device:" + deviceID, nil
 }
+
+// keyByUserID keys a limit to the signed-in user, so it only fits routes
+// behind requireAuth or requireSession.
+func keyByUserID(r *http.Request) (string, error) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		return "", errors.New("missing user id")
+	}
+	return "user:" + uuidString(userID), nil
+}
+
+// requireAuth authenticates the request with a bearer access token bound to
+// the caller's X-Device-Id and stores the session, user, team and role in the
+// request context.
+func (a *API) requireAuth(next http.Handler) http.Handler {
+	return a.authenticate(next, true)
+}
+
+// requireSession authenticates like requireAuth but also admits users without
+// a team, so they can create one, redeem an invite or follow a join request.
+// Team and role are only set in the context for members.
+func (a *API) requireSession(next http.Handler) http.Handler {
+	return a.authenticate(next, false)
+}
+
+func (a *API) authenticate(next http.Handler, requireTeam bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accessToken, ok := bearerToken(r)
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "missing access token")
+			return
+		}
+
+		deviceID := strings.TrimSpace(r.Header.Get("X-Device-Id"))
+		if deviceID == "" {
+			writeError(w, http.StatusBadRequest, "X-Device-Id is required")
+			return
+		}
+
+		ctx := r.Context()
+		now := a.clock()
+		q := a.store.Querier()
+		session, err := q.GetAuthSessionByAccessHash(ctx, sqlc.GetAuthSessionByAccessHashParams{
+			AccessTokenHash: hashString(accessToken),
+			AccessExpiresAt: toTimestamptz(now),
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				writeError(w, http.StatusUnauthorized, "invalid access token")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "failed to authenticate")
+			return
+		}
+
+		if !hashEqual(session.DeviceIDHash, hashString(deviceID)) {
+			writeError(w, http.StatusUnauthorized, "invalid device")
+			return
+		}
+
+		membership, err := q.GetTeamMembershipByUserID(ctx, session.UserID)
+		if err != nil {
+			if !errors.Is(err, pgx.ErrNoRows) {
+				writeError(w, http.StatusInternalServerError, "failed to authenticate")
+				return
+			}
+			if requireTeam {
+				writeError(w, http.StatusForbidden, "team membership required")
+				return
+			}
+		}
+
+		if err := q.MarkAuthSessionUsed(ctx, sqlc.MarkAuthSessionUsedParams{
+			ID:         session.ID,
+			LastUsedAt: toTimestamptz(now),
+		}); err != nil {
+			a.logger.Error("failed to mark session used", slog.Any("err", err))
+		}
+
+		ctx = contextWithAuth(ctx, session.ID, session.UserID, membership.TeamID, membership.Role)
+		ctx = context.WithValue(ctx, contextKeyFamilyID, session.FamilyID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (a *API) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, ok := roleFromContext(r.Context())
+		if !ok || role != "admin" {
+			writeError(w, http.StatusForbidden, "admin role required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func contextWithAuth(ctx context.Context, sessionID, userID, teamID pgtype.UUID, role string) context.Context {
+	ctx = context.WithValue(ctx, contextKeySessionID, sessionID)
+	ctx = context.WithValue(ctx, contextKeyUserID, userID)
+	ctx = context.WithValue(ctx, contextKeyTeamID, teamID)
+	ctx = context.WithValue(ctx, contextKeyRole, role)
+	return ctx
+}
+
+func sessionIDFromContext(ctx context.Context) (pgtype.UUID, bool) {
+	id, ok := ctx.Value(contextKeySessionID).(pgtype.UUID)
+	return id, ok && id.Valid
+}
+
+// familyIDFromContext returns the session family of the caller's sign-in,
+// which stays the same across refreshes.
+func familyIDFromContext(ctx context.Context) (pgtype.UUID, bool) {
+	id, ok := ctx.Value(contextKeyFamilyID).(pgtype.UUID)
+	return id, ok && id.Valid
+}
+
+func userIDFromContext(ctx context.Context) (pgtype.UUID, bool) {
+	id, ok := ctx.Value(contextKeyUserID).(pgtype.UUID)
+	return id, ok && id.Valid
+}
+
+func teamIDFromContext(ctx context.Context) (pgtype.UUID, bool) {
+	id, ok := ctx.Value(contextKeyTeamID).(pgtype.UUID)
+	return id, ok && id.Valid
+}
+
+func roleFromContext(ctx context.Context) (string, bool) {
+	role, ok := ctx.Value(contextKeyRole).(string)
+	return role, ok && role != ""
+}
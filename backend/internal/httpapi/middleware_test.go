@@ -1,8 +1,18 @@
 package httpapi
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 func TestKeyByDeviceID(t *testing.T) {
@@ -25,3 +35,337 @@ func TestKeyByDeviceIDMissing(t *testing.T) {
 		t.Fatal("expected error for missing device id")
 	}
 }
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		token  string
+		ok     bool
+	}{
+		{"valid", "Bearer abc123", "abc123", true},
+		{"case insensitive scheme", "bearer abc123", "abc123", true},
+		{"missing", "", "", false},
+		{"wrong scheme", "Basic abc123", "", false},
+		{"empty token", "Bearer   ", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			token, ok := bearerToken(req)
+			if ok != tt.ok || token != tt.token {
+				t.Fatalf("expected %q/%v, got %q/%v", tt.token, tt.ok, token, ok)
+			}
+		})
+	}
+}
+
+func TestContextAccessorsEmpty(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := sessionIDFromContext(ctx); ok {
+		t.Fatal("expected no session id")
+	}
+	if _, ok := userIDFromContext(ctx); ok {
+		t.Fatal("expected no user id")
+	}
+	if _, ok := teamIDFromContext(ctx); ok {
+		t.Fatal("expected no team id")
+	}
+	if _, ok := roleFromContext(ctx); ok {
+		t.Fatal("expected no role")
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	accessToken := "access-token"
+	deviceID := "device-123"
+	sessionID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	familyID := pgtype.UUID{Bytes: [16]byte{4}, Valid: true}
+
+	validSession := func(_ context.Context, arg sqlc.GetAuthSessionByAccessHashParams) (sqlc.AuthSession, error) {
+		if !hashEqual(arg.AccessTokenHash, hashString(accessToken)) {
+			return sqlc.AuthSession{}, pgx.ErrNoRows
+		}
+		return sqlc.AuthSession{
+			ID:           sessionID,
+			UserID:       userID,
+			DeviceIDHash: hashString(deviceID),
+			FamilyID:     familyID,
+		}, nil
+	}
+	adminMembership := func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+		return sqlc.TeamMembership{TeamID: teamID, UserID: userID, Role: "admin"}, nil
+	}
+
+	t.Run("success", func(t *testing.T) {
+		now := time.Date(2024, 1, 3, 8, 0, 0, 0, time.UTC)
+		var marked bool
+		q := newQuerierBuilder().
+			onGetAuthSessionByAccessHash(func(ctx context.Context, arg sqlc.GetAuthSessionByAccessHashParams) (sqlc.AuthSession, error) {
+				if !arg.AccessExpiresAt.Valid || !arg.AccessExpiresAt.Time.Equal(now) {
+					t.Fatalf("unexpected expires at: %v", arg.AccessExpiresAt.Time)
+				}
+				return validSession(ctx, arg)
+			}).
+			onGetTeamMembershipByUserID(adminMembership).
+			onMarkAuthSessionUsed(func(_ context.Context, arg sqlc.MarkAuthSessionUsedParams) error {
+				if arg.ID != sessionID || !arg.LastUsedAt.Time.Equal(now) {
+					t.Fatalf("unexpected mark params: %+v", arg)
+				}
+				marked = true
+				return nil
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+
+		var called bool
+		handler := api.requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			ctx := r.Context()
+			if id, ok := sessionIDFromContext(ctx); !ok || id != sessionID {
+				t.Fatalf("unexpected session id: %v", id)
+			}
+			if id, ok := userIDFromContext(ctx); !ok || id != userID {
+				t.Fatalf("unexpected user id: %v", id)
+			}
+			if id, ok := teamIDFromContext(ctx); !ok || id != teamID {
+				t.Fatalf("unexpected team id: %v", id)
+			}
+			if role, ok := roleFromContext(ctx); !ok || role != "admin" {
+				t.Fatalf("unexpected role: %q", role)
+			}
+			if id, ok := familyIDFromContext(ctx); !ok || id != familyID {
+				t.Fatalf("unexpected family id: %v", id)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("X-Device-Id", deviceID)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", rec.Code)
+		}
+		if !called {
+			t.Fatal("expected next handler to be called")
+		}
+		if !marked {
+			t.Fatal("expected session to be marked used")
+		}
+	})
+
+	t.Run("mark used failure does not block", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetAuthSessionByAccessHash(validSession).
+			onGetTeamMembershipByUserID(adminMembership).
+			onMarkAuthSessionUsed(func(context.Context, sqlc.MarkAuthSessionUsedParams) error {
+				return errors.New("update failed")
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		handler := api.requireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		req.Header.Set("X-Device-Id", deviceID)
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", rec.Code)
+		}
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		deviceID      string
+		session       func(context.Context, sqlc.GetAuthSessionByAccessHashParams) (sqlc.AuthSession, error)
+		membership    func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error)
+		code          int
+	}{
+		{
+			name:     "missing token",
+			deviceID: deviceID,
+			code:     http.StatusUnauthorized,
+		},
+		{
+			name:          "wrong scheme",
+			authorization: "Basic " + accessToken,
+			deviceID:      deviceID,
+			code:          http.StatusUnauthorized,
+		},
+		{
+			name:          "missing device",
+			authorization: "Bearer " + accessToken,
+			code:          http.StatusBadRequest,
+		},
+		{
+			name:          "unknown token",
+			authorization: "Bearer other-token",
+			deviceID:      deviceID,
+			session:       validSession,
+			code:          http.StatusUnauthorized,
+		},
+		{
+			name:          "session lookup failure",
+			authorization: "Bearer " + accessToken,
+			deviceID:      deviceID,
+			session: func(context.Context, sqlc.GetAuthSessionByAccessHashParams) (sqlc.AuthSession, error) {
+				return sqlc.AuthSession{}, errors.New("db down")
+			},
+			code: http.StatusInternalServerError,
+		},
+		{
+			name:          "device mismatch",
+			authorization: "Bearer " + accessToken,
+			deviceID:      "other-device",
+			session:       validSession,
+			code:          http.StatusUnauthorized,
+		},
+		{
+			name:          "no membership",
+			authorization: "Bearer " + accessToken,
+			deviceID:      deviceID,
+			session:       validSession,
+			membership: func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			},
+			code: http.StatusForbidden,
+		},
+		{
+			name:          "membership lookup failure",
+			authorization: "Bearer " + accessToken,
+			deviceID:      deviceID,
+			session:       validSession,
+			membership: func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, errors.New("db down")
+			},
+			code: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newQuerierBuilder()
+			if tt.session != nil {
+				b.onGetAuthSessionByAccessHash(tt.session)
+			}
+			if tt.membership != nil {
+				b.onGetTeamMembershipByUserID(tt.membership)
+			}
+
+			api := New(&stubStore{querier: b.build()}, &mailer.LogMailer{}, Settings{}, nil)
+			handler := api.requireAuth(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				t.Fatal("expected next handler not to be called")
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.deviceID != "" {
+				req.Header.Set("X-Device-Id", tt.deviceID)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, rec.Code)
+			}
+		})
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	accessToken := "access-token"
+	deviceID := "device-123"
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+
+	q := newQuerierBuilder().
+		onGetAuthSessionByAccessHash(func(context.Context, sqlc.GetAuthSessionByAccessHashParams) (sqlc.AuthSession, error) {
+			return sqlc.AuthSession{
+				ID:           pgtype.UUID{Bytes: [16]byte{1}, Valid: true},
+				UserID:       userID,
+				DeviceIDHash: hashString(deviceID),
+			}, nil
+		}).
+		onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+			return sqlc.TeamMembership{}, pgx.ErrNoRows
+		}).
+		build()
+	api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+	var called bool
+	handler := api.requireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		ctx := r.Context()
+		if id, ok := userIDFromContext(ctx); !ok || id != userID {
+			t.Fatalf("unexpected user id: %v", id)
+		}
+		if _, ok := teamIDFromContext(ctx); ok {
+			t.Fatal("expected no team in context")
+		}
+		if _, ok := roleFromContext(ctx); ok {
+			t.Fatal("expected no role in context")
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("X-Device-Id", deviceID)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !called {
+		t.Fatalf("expected next handler to be called, got status %d", rec.Code)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	api := New(&stubStore{}, &mailer.LogMailer{}, Settings{}, nil)
+	handler := api.requireAdmin(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		role string
+		code int
+	}{
+		{"admin", http.StatusNoContent},
+		{"member", http.StatusForbidden},
+		{"", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			req := authedRequest(http.MethodGet, "/", nil, userID, teamID, tt.role)
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"timesync/backend/internal/oidcrp"
+	"timesync/backend/internal/sqlc"
+)
+
+// oidcCallbackPath is the redirect URI admins register with their provider.
+const oidcCallbackPath = "/auth/sso/oidc/callback"
+
+func (a *API) oidcRedirectURL() string {
+	return strings.TrimRight(a.settings.PublicBaseURL, "/") + oidcCallbackPath
+}
+
+func (a *API) oidcConfig(provider sqlc.TeamSsoProvider) oidcrp.Config {
+	return oidcrp.Config{
+		Issuer:       provider.OidcIssuer,
+		ClientID:     provider.OidcClientID,
+		ClientSecret: provider.OidcClientSecret,
+		RedirectURL:  a.oidcRedirectURL(),
+	}
+}
+
+// handleOIDCCallback is where the provider sends the browser back. The code
+// is exchanged with the login's PKCE verifier and the ID token checked
+// against the issuer's keys before its email is trusted. The login is
+// claimed before the exchange, which runs with no transaction open.
+func (a *API) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		writeSSOPage(w, http.StatusUnauthorized, "Your identity provider didn't sign you in. Start again from TimeSync.")
+		return
+	}
+	state, code := query.Get("state"), query.Get("code")
+	if state == "" || code == "" {
+		writeSSOPage(w, http.StatusBadRequest, "This sign-in link is incomplete. Start again from TimeSync.")
+		return
+	}
+
+	ctx := r.Context()
+	now := a.clock()
+	login, provider, ok := a.claimSSOLogin(ctx, w, state, ssoProtocolOIDC, now)
+	if !ok {
+		return
+	}
+
+	identity, err := a.oidc.Exchange(ctx, a.oidcConfig(provider), code, login.CodeVerifier, login.Nonce, now)
+	if err != nil {
+		a.logger.Warn("oidc sign-in rejected", slog.String("team_id", uuidString(login.TeamID)), slog.Any("err", err))
+		writeSSOPage(w, http.StatusUnauthorized, "Your identity provider's answer couldn't be verified. Start again from TimeSync.")
+		return
+	}
+
+	a.finishSSOLogin(ctx, w, login, identity.Email, now)
+}
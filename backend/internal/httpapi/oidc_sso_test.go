@@ -0,0 +1,207 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/oidcrp"
+	"timesync/backend/internal/oidcrp/oidctest"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var handoffPattern = regexp.MustCompile(`href="timesync://sso\?token=([^"]+)"`)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// signInAtProvider follows authURL at the stand-in provider, which signs its
+// preset user straight in, and returns the callback it redirects to.
+func signInAtProvider(t *testing.T, provider *oidctest.Provider, authURL string) *url.URL {
+	t.Helper()
+	client := provider.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected redirect to callback, got %d", resp.StatusCode)
+	}
+	return callback
+}
+
+func TestHandleOIDCCallback(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	loginID := pgtype.UUID{Bytes: [16]byte{7}, Valid: true}
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name          string
+		email         string
+		emailVerified bool
+		state         string
+		providerError bool
+		lapsed        bool
+		wantStatus    int
+	}{
+		{name: "verified team address", email: "ana@acme.com", emailVerified: true, wantStatus: http.StatusOK},
+		{name: "unverified email", email: "ana@acme.com", wantStatus: http.StatusUnauthorized},
+		{name: "address outside team domains", email: "eve@globex.com", emailVerified: true, wantStatus: http.StatusForbidden},
+		{name: "unknown state", email: "ana@acme.com", emailVerified: true, state: "forged", wantStatus: http.StatusBadRequest},
+		{name: "provider refused", email: "ana@acme.com", emailVerified: true, providerError: true, wantStatus: http.StatusUnauthorized},
+		{name: "domain verification lapsed", email: "ana@acme.com", emailVerified: true, lapsed: true, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := oidctest.NewProvider("timesync")
+			defer provider.Close()
+			provider.Email = tt.email
+			provider.EmailVerified = tt.emailVerified
+			provider.Now = func() time.Time { return now }
+
+			verifiedAt := toTimestamptz(now)
+			var login sqlc.SsoLogin
+			var authenticated sqlc.AuthenticateSsoLoginParams
+			q := newQuerierBuilder().
+				onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+					switch domain {
+					case "acme.com":
+						return sqlc.Team{ID: teamID, Domain: domain, DomainVerifiedAt: verifiedAt}, nil
+					case "globex.com":
+						return sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{9}, Valid: true}, Domain: domain, DomainVerifiedAt: toTimestamptz(now)}, nil
+					}
+					return sqlc.Team{}, pgx.ErrNoRows
+				}).
+				onGetTeamSsoProvider(func(_ context.Context, id pgtype.UUID) (sqlc.TeamSsoProvider, error) {
+					return sqlc.TeamSsoProvider{TeamID: id, Protocol: ssoProtocolOIDC, OidcIssuer: provider.Issuer, OidcClientID: "timesync"}, nil
+				}).
+				onCreateSsoLogin(func(_ context.Context, arg sqlc.CreateSsoLoginParams) (sqlc.SsoLogin, error) {
+					login = sqlc.SsoLogin{
+						ID:           loginID,
+						TeamID:       arg.TeamID,
+						StateHash:    arg.StateHash,
+						DeviceIDHash: arg.DeviceIDHash,
+						Nonce:        arg.Nonce,
+						CodeVerifier: arg.CodeVerifier,
+						ExpiresAt:    arg.ExpiresAt,
+					}
+					return login, nil
+				}).
+				onClaimSsoLogin(func(_ context.Context, arg sqlc.ClaimSsoLoginParams) (sqlc.SsoLogin, error) {
+					if !hashEqual(arg.StateHash, login.StateHash) || !login.ExpiresAt.Time.After(arg.ExpiresAt.Time) || login.ClaimedAt.Valid {
+						return sqlc.SsoLogin{}, pgx.ErrNoRows
+					}
+					login.ClaimedAt = arg.ClaimedAt
+					return login, nil
+				}).
+				onAuthenticateSsoLogin(func(_ context.Context, arg sqlc.AuthenticateSsoLoginParams) error {
+					authenticated = arg
+					return nil
+				}).
+				build()
+			tx := &testTx{}
+			var began bool
+			api := New(&stubStore{
+				querier: q,
+				beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+					began = true
+					return tx, nil
+				},
+			}, &mailer.LogMailer{}, Settings{PublicBaseURL: "https://timesync.example"}, nil)
+			api.clock = func() time.Time { return now }
+			// Every call to the provider notes whether a transaction was
+			// open at the time.
+			var txOpenAtProvider bool
+			transport := provider.Client().Transport
+			api.oidc = oidcrp.New(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				if began && !tx.committed && !tx.rolled {
+					txOpenAtProvider = true
+				}
+				return transport.RoundTrip(req)
+			})})
+
+			body, _ := json.Marshal(startSSORequest{Email: "ana@acme.com"})
+			startRec := httptest.NewRecorder()
+			startReq := httptest.NewRequest(http.MethodPost, "/auth/sso/start", bytes.NewReader(body))
+			startReq.Header.Set("X-Device-Id", "device-123")
+			api.handleStartSSO(startRec, startReq)
+			if startRec.Code != http.StatusOK {
+				t.Fatalf("start failed with %d: %s", startRec.Code, startRec.Body.String())
+			}
+			var start startSSOResponse
+			if err := json.NewDecoder(startRec.Body).Decode(&start); err != nil {
+				t.Fatalf("decode start: %v", err)
+			}
+
+			// The recheck may revoke the domain while the user is at the
+			// provider.
+			if tt.lapsed {
+				verifiedAt = pgtype.Timestamptz{}
+			}
+			callback := signInAtProvider(t, provider, start.AuthorizationURL)
+			if callback.Path != oidcCallbackPath {
+				t.Fatalf("unexpected callback %s", callback)
+			}
+			query := callback.Query()
+			if tt.state != "" {
+				query.Set("state", tt.state)
+			}
+			if tt.providerError {
+				query = url.Values{"error": {"access_denied"}, "state": {query.Get("state")}}
+			}
+
+			rec := httptest.NewRecorder()
+			api.handleOIDCCallback(rec, httptest.NewRequest(http.MethodGet, oidcCallbackPath+"?"+query.Encode(), nil))
+			if txOpenAtProvider {
+				t.Fatal("expected the provider to be called with no transaction open")
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				if authenticated.ID.Valid || tx.committed || handoffPattern.MatchString(rec.Body.String()) {
+					t.Fatal("expected no hand-off")
+				}
+				return
+			}
+
+			match := handoffPattern.FindStringSubmatch(rec.Body.String())
+			if match == nil {
+				t.Fatalf("expected app hand-off, got %s", rec.Body.String())
+			}
+			token, _ := url.QueryUnescape(match[1])
+			if authenticated.ID != loginID || authenticated.Email.String != "ana@acme.com" || !hashEqual(authenticated.HandoffTokenHash, hashString(token)) {
+				t.Fatalf("unexpected authentication: %+v", authenticated)
+			}
+			if !tx.committed {
+				t.Fatal("expected transaction to commit")
+			}
+
+			authenticated = sqlc.AuthenticateSsoLoginParams{}
+			replay := httptest.NewRecorder()
+			api.handleOIDCCallback(replay, httptest.NewRequest(http.MethodGet, oidcCallbackPath+"?"+query.Encode(), nil))
+			if replay.Code != http.StatusBadRequest || authenticated.ID.Valid {
+				t.Fatalf("expected replayed callback to be refused, got %d", replay.Code)
+			}
+		})
+	}
+}
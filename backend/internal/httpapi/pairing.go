@@ -0,0 +1,433 @@
+package httpapi
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// pairingTTL bounds the whole pairing, from showing the code to the new
+// device picking up its session.
+const pairingTTL = 5 * time.Minute
+
+// appPairURL is what the pairing QR code encodes; scanning it opens the app
+// on the new device with the code filled in.
+const appPairURL = "timesync://pair"
+
+// A pairing waits for a new device to redeem its code, then for the existing
+// device to approve that device, and completes once the new device has its
+// session.
+const (
+	pairingStatusWaiting   = "waiting"
+	pairingStatusClaimed   = "claimed"
+	pairingStatusApproved  = "approved"
+	pairingStatusCompleted = "completed"
+	pairingStatusExpired   = "expired"
+)
+
+type pairingDeviceResponse struct {
+	DeviceName string `json:"device_name"`
+	Platform   string `json:"platform"`
+	AppVersion string `json:"app_version"`
+}
+
+type pairingResponse struct {
+	ID        string                 `json:"id"`
+	Code      string                 `json:"code"`
+	QRPayload string                 `json:"qr_payload"`
+	Status    string                 `json:"status"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Device    *pairingDeviceResponse `json:"device,omitempty"`
+	Verifier  string                 `json:"verifier,omitempty"`
+}
+
+type redeemPairingRequest struct {
+	Code       string `json:"code"`
+	DeviceName string `json:"device_name,omitempty"`
+	Platform   string `json:"platform,omitempty"`
+	AppVersion string `json:"app_version,omitempty"`
+}
+
+type redeemPairingResponse struct {
+	ClaimToken string    `json:"claim_token"`
+	Verifier   string    `json:"verifier"`
+	Status     string    `json:"status"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type completePairingRequest struct {
+	ClaimToken string `json:"claim_token"`
+}
+
+type pairingStatusResponse struct {
+	Status string `json:"status"`
+}
+
+// handleCreatePairing lets a signed-in device show a pairing code, as text
+// and as a QR payload, for the user's new device.
+func (a *API) handleCreatePairing(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	sessionID, _ := sessionIDFromContext(ctx)
+
+	now := a.clock()
+	var pairing sqlc.DevicePairing
+	err := withFreshCode(func(code string) (err error) {
+		pairing, err = a.store.Querier().CreateDevicePairing(ctx, sqlc.CreateDevicePairingParams{
+			UserID:    userID,
+			SessionID: sessionID,
+			Code:      code,
+			ExpiresAt: toTimestamptz(now.Add(pairingTTL)),
+			CreatedAt: toTimestamptz(now),
+		})
+		return err
+	})
+	if err != nil {
+		a.logger.Error("failed to create pairing", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to create pairing")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newPairingResponse(pairing, now))
+}
+
+// handleGetPairing is polled by the existing device to learn when a new
+// device has redeemed the code and which device it is.
+func (a *API) handleGetPairing(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	pairingID, ok := parseUUID(chi.URLParam(r, "pairingID"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid pairing id")
+		return
+	}
+
+	pairing, err := a.store.Querier().GetDevicePairing(ctx, sqlc.GetDevicePairingParams{
+		ID:     pairingID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "pairing not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load pairing")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newPairingResponse(pairing, a.clock()))
+}
+
+// handleApprovePairing lets the existing device confirm the device that
+// redeemed its code. Until then, knowing the code alone gets nobody a
+// session. Both devices show the pairing's verifier to compare before
+// approving; if someone else redeemed the code first, the user's own new
+// device is told so instead of showing a verifier at all.
+func (a *API) handleApprovePairing(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	pairingID, ok := parseUUID(chi.URLParam(r, "pairingID"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid pairing id")
+		return
+	}
+
+	familyID, _ := familyIDFromContext(ctx)
+
+	// Only the sign-in that showed the code can approve it, even after its
+	// session was refreshed.
+	q := a.store.Querier()
+	now := a.clock()
+	pairing, err := q.ApproveDevicePairing(ctx, sqlc.ApproveDevicePairingParams{
+		ID:         pairingID,
+		UserID:     userID,
+		ApprovedAt: toTimestamptz(now),
+		FamilyID:   familyID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Tell a missing pairing apart from one that can't be approved now.
+		_, err = q.GetDevicePairing(ctx, sqlc.GetDevicePairingParams{ID: pairingID, UserID: userID})
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "pairing not found")
+			return
+		}
+		if err == nil {
+			writeError(w, http.StatusConflict, "pairing is not waiting for approval")
+			return
+		}
+	}
+	if err != nil {
+		a.logger.Error("failed to approve pairing", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to approve pairing")
+		return
+	}
+
+	a.logger.Info("security: device pairing approved",
+		slog.String("user_id", uuidString(userID)),
+		slog.String("pairing_id", uuidString(pairing.ID)))
+	writeJSON(w, http.StatusOK, newPairingResponse(pairing, now))
+}
+
+// handleCancelPairing withdraws a pairing, which also rejects a device that
+// already redeemed the code.
+func (a *API) handleCancelPairing(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	pairingID, ok := parseUUID(chi.URLParam(r, "pairingID"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid pairing id")
+		return
+	}
+
+	deleted, err := a.store.Querier().DeleteDevicePairing(ctx, sqlc.DeleteDevicePairingParams{
+		ID:     pairingID,
+		UserID: userID,
+	})
+	if err != nil {
+		a.logger.Error("failed to cancel pairing", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to cancel pairing")
+		return
+	}
+	if deleted == 0 {
+		writeError(w, http.StatusNotFound, "pairing not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRedeemPairing is called by the new device with the code it was shown.
+// A code can be redeemed once; the device gets a claim token to collect its
+// session with after approval, bound to its device id. A device arriving
+// after the code was redeemed is told so, so the user cancels the pairing
+// rather than approving whoever got there first.
+func (a *API) handleRedeemPairing(w http.ResponseWriter, r *http.Request) {
+	var req redeemPairingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	code := normalizeCode(req.Code)
+	if !isValidCode(code) {
+		writeError(w, http.StatusBadRequest, "invalid code format")
+		return
+	}
+
+	deviceID := strings.TrimSpace(r.Header.Get("X-Device-Id"))
+	if deviceID == "" {
+		writeError(w, http.StatusBadRequest, "X-Device-Id is required")
+		return
+	}
+
+	claimToken, claimHash, err := generateToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to redeem pairing code")
+		return
+	}
+
+	ctx := r.Context()
+	q := a.store.Querier()
+	now := a.clock()
+	pairing, err := q.ClaimDevicePairing(ctx, sqlc.ClaimDevicePairingParams{
+		Code:           code,
+		ClaimTokenHash: claimHash,
+		DeviceIDHash:   hashString(deviceID),
+		DeviceName:     deviceField(req.DeviceName, maxDeviceNameLength),
+		Platform:       deviceField(req.Platform, maxDeviceFieldLength),
+		AppVersion:     deviceField(req.AppVersion, maxDeviceFieldLength),
+		ClaimedAt:      toTimestamptz(now),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		var claimed int64
+		claimed, err = q.CountClaimedDevicePairings(ctx, sqlc.CountClaimedDevicePairingsParams{
+			Code:      code,
+			ExpiresAt: toTimestamptz(now),
+		})
+		if err == nil && claimed > 0 {
+			writeError(w, http.StatusConflict, "pairing code was already redeemed by another device")
+			return
+		}
+		if err == nil {
+			writeError(w, http.StatusBadRequest, "invalid pairing code")
+			return
+		}
+	}
+	if err != nil {
+		a.logger.Error("failed to redeem pairing code", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to redeem pairing code")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, redeemPairingResponse{
+		ClaimToken: claimToken,
+		Verifier:   pairingVerifier(claimHash),
+		Status:     pairingStatusClaimed,
+		ExpiresAt:  pairing.ExpiresAt.Time,
+	})
+}
+
+// handleCompletePairing is polled by the new device. Once the existing device
+// approved, it issues the new device its own session through the same path
+// as handleVerifyCode, as long as the approving sign-in hasn't been signed
+// out since.
+func (a *API) handleCompletePairing(w http.ResponseWriter, r *http.Request) {
+	var req completePairingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	claimToken := strings.TrimSpace(req.ClaimToken)
+	if claimToken == "" {
+		writeError(w, http.StatusBadRequest, "claim_token is required")
+		return
+	}
+
+	deviceID := strings.TrimSpace(r.Header.Get("X-Device-Id"))
+	if deviceID == "" {
+		writeError(w, http.StatusBadRequest, "X-Device-Id is required")
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// The pairing row stays locked until commit, so concurrent polls can't
+	// both collect a session.
+	now := a.clock()
+	q := a.store.WithTx(tx)
+	pairing, err := q.GetDevicePairingByClaimTokenForUpdate(ctx, sqlc.GetDevicePairingByClaimTokenForUpdateParams{
+		ClaimTokenHash: hashString(claimToken),
+		ExpiresAt:      toTimestamptz(now),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusGone, "pairing expired or cancelled")
+			return
+		}
+		a.logger.Error("failed to load pairing", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to complete pairing")
+		return
+	}
+	if !hashEqual(pairing.DeviceIDHash, hashString(deviceID)) {
+		writeError(w, http.StatusUnauthorized, "invalid device")
+		return
+	}
+	if pairing.CompletedAt.Valid {
+		writeError(w, http.StatusGone, "pairing already completed")
+		return
+	}
+	if !pairing.ApprovedAt.Valid {
+		writeJSON(w, http.StatusAccepted, pairingStatusResponse{Status: pairingStatusClaimed})
+		return
+	}
+
+	active, err := q.CountActivePairingSessions(ctx, sqlc.CountActivePairingSessionsParams{
+		SessionID: pairing.SessionID,
+		Now:       toTimestamptz(now),
+	})
+	if err != nil {
+		a.logger.Error("failed to check pairing session", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to complete pairing")
+		return
+	}
+	if active == 0 {
+		writeError(w, http.StatusGone, "the approving device has been signed out")
+		return
+	}
+
+	if err := q.CompleteDevicePairing(ctx, sqlc.CompleteDevicePairingParams{
+		ID:          pairing.ID,
+		CompletedAt: toTimestamptz(now),
+	}); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to complete pairing")
+		return
+	}
+	user, err := q.GetUserByID(ctx, pairing.UserID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to complete pairing")
+		return
+	}
+
+	a.completeSignIn(ctx, w, tx, q, user.Email, "", signInByPairing, signInDevice{
+		ID:         deviceID,
+		Name:       pairing.DeviceName,
+		Platform:   pairing.Platform,
+		AppVersion: pairing.AppVersion,
+	}, now)
+}
+
+func newPairingResponse(pairing sqlc.DevicePairing, now time.Time) pairingResponse {
+	resp := pairingResponse{
+		ID:        uuidString(pairing.ID),
+		Code:      pairing.Code,
+		QRPayload: appPairURL + "?code=" + url.QueryEscape(pairing.Code),
+		Status:    pairingStatus(pairing, now),
+		ExpiresAt: pairing.ExpiresAt.Time,
+	}
+	if pairing.ClaimedAt.Valid {
+		resp.Device = &pairingDeviceResponse{
+			DeviceName: pairing.DeviceName,
+			Platform:   pairing.Platform,
+			AppVersion: pairing.AppVersion,
+		}
+		resp.Verifier = pairingVerifier(pairing.ClaimTokenHash)
+	}
+	return resp
+}
+
+// pairingVerifier is a six-digit number derived from the claim token hash,
+// shown on both devices so the user can check they are pairing with the
+// device in their hand.
+func pairingVerifier(claimTokenHash []byte) string {
+	if len(claimTokenHash) < 4 {
+		return ""
+	}
+	return fmt.Sprintf("%06d", binary.BigEndian.Uint32(claimTokenHash)%1000000)
+}
+
+func pairingStatus(pairing sqlc.DevicePairing, now time.Time) string {
+	switch {
+	case pairing.CompletedAt.Valid:
+		return pairingStatusCompleted
+	case !now.Before(pairing.ExpiresAt.Time):
+		return pairingStatusExpired
+	case pairing.ApprovedAt.Valid:
+		return pairingStatusApproved
+	case pairing.ClaimedAt.Valid:
+		return pairingStatusClaimed
+	default:
+		return pairingStatusWaiting
+	}
+}
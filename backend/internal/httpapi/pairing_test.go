@@ -0,0 +1,365 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestHandleCreatePairing(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	var created sqlc.CreateDevicePairingParams
+	q := newQuerierBuilder().
+		onCreateDevicePairing(func(_ context.Context, arg sqlc.CreateDevicePairingParams) (sqlc.DevicePairing, error) {
+			created = arg
+			return sqlc.DevicePairing{ID: pgtype.UUID{Bytes: [16]byte{6}, Valid: true}, UserID: arg.UserID, Code: arg.Code, ExpiresAt: arg.ExpiresAt}, nil
+		}).
+		build()
+	api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+	api.clock = func() time.Time { return now }
+
+	req := authedRequest(http.MethodPost, "/auth/pairings", nil, userID, pgtype.UUID{}, "")
+	rec := httptest.NewRecorder()
+	api.handleCreatePairing(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if created.UserID != userID || !created.SessionID.Valid || !isValidCode(created.Code) || !created.ExpiresAt.Time.Equal(now.Add(pairingTTL)) {
+		t.Fatalf("unexpected pairing: %+v", created)
+	}
+	var resp pairingResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Code != created.Code || resp.QRPayload != "timesync://pair?code="+created.Code || resp.Status != pairingStatusWaiting || resp.Device != nil || resp.Verifier != "" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestHandleCreatePairingRetriesOpenCode(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+
+	tests := []struct {
+		name       string
+		collisions int
+		wantStatus int
+		wantCalls  int
+	}{
+		{name: "code already open", collisions: 1, wantStatus: http.StatusCreated, wantCalls: 2},
+		{name: "every code open", collisions: codeAttempts, wantStatus: http.StatusInternalServerError, wantCalls: codeAttempts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			q := newQuerierBuilder().
+				onCreateDevicePairing(func(_ context.Context, arg sqlc.CreateDevicePairingParams) (sqlc.DevicePairing, error) {
+					calls++
+					if calls <= tt.collisions {
+						return sqlc.DevicePairing{}, &pgconn.PgError{Code: pgUniqueViolation}
+					}
+					return sqlc.DevicePairing{ID: pgtype.UUID{Bytes: [16]byte{6}, Valid: true}, UserID: arg.UserID, Code: arg.Code, ExpiresAt: arg.ExpiresAt}, nil
+				}).
+				build()
+			api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+			req := authedRequest(http.MethodPost, "/auth/pairings", nil, userID, pgtype.UUID{}, "")
+			rec := httptest.NewRecorder()
+			api.handleCreatePairing(rec, req)
+
+			if rec.Code != tt.wantStatus || calls != tt.wantCalls {
+				t.Fatalf("expected status %d after %d attempts, got %d after %d", tt.wantStatus, tt.wantCalls, rec.Code, calls)
+			}
+		})
+	}
+}
+
+func TestHandleRedeemPairing(t *testing.T) {
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	var claimed sqlc.ClaimDevicePairingParams
+	q := newQuerierBuilder().
+		onClaimDevicePairing(func(_ context.Context, arg sqlc.ClaimDevicePairingParams) (sqlc.DevicePairing, error) {
+			if arg.Code != "ABCD2345" {
+				return sqlc.DevicePairing{}, pgx.ErrNoRows
+			}
+			claimed = arg
+			return sqlc.DevicePairing{Code: arg.Code, ExpiresAt: toTimestamptz(now.Add(pairingTTL))}, nil
+		}).
+		onCountClaimedDevicePairings(func(_ context.Context, arg sqlc.CountClaimedDevicePairingsParams) (int64, error) {
+			if arg.Code != "TAKN2345" || !arg.ExpiresAt.Time.Equal(now) {
+				return 0, nil
+			}
+			return 1, nil
+		}).
+		build()
+
+	tests := []struct {
+		name       string
+		code       string
+		deviceID   string
+		wantStatus int
+	}{
+		{name: "open code", code: "abcd2345", deviceID: "new-mac", wantStatus: http.StatusAccepted},
+		{name: "unknown code", code: "ZZZZ2345", deviceID: "new-mac", wantStatus: http.StatusBadRequest},
+		{name: "already redeemed", code: "takn2345", deviceID: "new-mac", wantStatus: http.StatusConflict},
+		{name: "malformed code", code: "abc", deviceID: "new-mac", wantStatus: http.StatusBadRequest},
+		{name: "missing device", code: "ABCD2345", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claimed = sqlc.ClaimDevicePairingParams{}
+			api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+			api.clock = func() time.Time { return now }
+
+			body, _ := json.Marshal(redeemPairingRequest{Code: tt.code, DeviceName: "Home Mac", Platform: "macos"})
+			req := httptest.NewRequest(http.MethodPost, "/auth/pairings/redeem", bytes.NewReader(body))
+			if tt.deviceID != "" {
+				req.Header.Set("X-Device-Id", tt.deviceID)
+			}
+			rec := httptest.NewRecorder()
+			api.handleRedeemPairing(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusAccepted {
+				return
+			}
+			var resp redeemPairingResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.ClaimToken == "" || !hashEqual(claimed.ClaimTokenHash, hashString(resp.ClaimToken)) {
+				t.Fatalf("claim token does not match stored hash: %+v", resp)
+			}
+			// The existing device derives its verifier from the stored hash.
+			if len(resp.Verifier) != 6 || resp.Verifier != pairingVerifier(claimed.ClaimTokenHash) {
+				t.Fatalf("unexpected verifier %q", resp.Verifier)
+			}
+			if !hashEqual(claimed.DeviceIDHash, hashString("new-mac")) || claimed.DeviceName != "Home Mac" || !claimed.ClaimedAt.Time.Equal(now) {
+				t.Fatalf("unexpected claim: %+v", claimed)
+			}
+		})
+	}
+}
+
+func TestHandleApprovePairing(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	claimedID := pgtype.UUID{Bytes: [16]byte{6}, Valid: true}
+	waitingID := pgtype.UUID{Bytes: [16]byte{7}, Valid: true}
+	familyID := pgtype.UUID{Bytes: [16]byte{8}, Valid: true}
+	otherFamilyID := pgtype.UUID{Bytes: [16]byte{10}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	q := newQuerierBuilder().
+		onApproveDevicePairing(func(_ context.Context, arg sqlc.ApproveDevicePairingParams) (sqlc.DevicePairing, error) {
+			// The pairing was shown by a session in familyID.
+			if arg.ID != claimedID || arg.UserID != userID || arg.FamilyID != familyID {
+				return sqlc.DevicePairing{}, pgx.ErrNoRows
+			}
+			return sqlc.DevicePairing{
+				ID:             arg.ID,
+				ExpiresAt:      toTimestamptz(now.Add(time.Minute)),
+				ClaimedAt:      toTimestamptz(now),
+				ApprovedAt:     arg.ApprovedAt,
+				ClaimTokenHash: hashString("claim-token"),
+				DeviceName:     "Home Mac",
+			}, nil
+		}).
+		onGetDevicePairing(func(_ context.Context, arg sqlc.GetDevicePairingParams) (sqlc.DevicePairing, error) {
+			if arg.ID != waitingID && arg.ID != claimedID {
+				return sqlc.DevicePairing{}, pgx.ErrNoRows
+			}
+			return sqlc.DevicePairing{ID: arg.ID}, nil
+		}).
+		build()
+	api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+	api.clock = func() time.Time { return now }
+
+	tests := []struct {
+		name     string
+		id       pgtype.UUID
+		familyID pgtype.UUID
+		want     int
+	}{
+		{name: "claimed", id: claimedID, familyID: familyID, want: http.StatusOK},
+		{name: "another sign-in of the user", id: claimedID, familyID: otherFamilyID, want: http.StatusConflict},
+		{name: "waiting", id: waitingID, familyID: familyID, want: http.StatusConflict},
+		{name: "unknown", id: pgtype.UUID{Bytes: [16]byte{9}, Valid: true}, familyID: familyID, want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req := sessionRequest(http.MethodPost, "/auth/pairings/"+uuidString(tt.id)+"/approve", userID, tt.familyID)
+		req = withURLParam(req, "pairingID", uuidString(tt.id))
+		rec := httptest.NewRecorder()
+		api.handleApprovePairing(rec, req)
+
+		if rec.Code != tt.want {
+			t.Fatalf("%s: expected status %d, got %d", tt.name, tt.want, rec.Code)
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		var resp pairingResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Status != pairingStatusApproved || resp.Device == nil || resp.Device.DeviceName != "Home Mac" || resp.Verifier != pairingVerifier(hashString("claim-token")) {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	}
+}
+
+func TestHandleCompletePairing(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	approverID := pgtype.UUID{Bytes: [16]byte{5}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	newQuerier := func(pairing sqlc.DevicePairing, found, signedOut bool, completed *bool, session *sqlc.CreateAuthSessionParams) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetDevicePairingByClaimTokenForUpdate(func(_ context.Context, arg sqlc.GetDevicePairingByClaimTokenForUpdateParams) (sqlc.DevicePairing, error) {
+				if !found || !hashEqual(arg.ClaimTokenHash, hashString("claim-token")) || !arg.ExpiresAt.Time.Equal(now) {
+					return sqlc.DevicePairing{}, pgx.ErrNoRows
+				}
+				return pairing, nil
+			}).
+			onCountActivePairingSessions(func(_ context.Context, arg sqlc.CountActivePairingSessionsParams) (int64, error) {
+				if signedOut || arg.SessionID != approverID || !arg.Now.Time.Equal(now) {
+					return 0, nil
+				}
+				return 1, nil
+			}).
+			onCompleteDevicePairing(func(_ context.Context, arg sqlc.CompleteDevicePairingParams) error {
+				*completed = arg.CompletedAt.Time.Equal(now)
+				return nil
+			}).
+			onGetUserByID(func(_ context.Context, id pgtype.UUID) (sqlc.User, error) {
+				return sqlc.User{ID: id, Email: "ana@acme.com", EmailDomain: "acme.com"}, nil
+			}).
+			onGetUserByEmail(func(_ context.Context, email string) (sqlc.User, error) {
+				return sqlc.User{ID: userID, Email: email, EmailDomain: "acme.com", EmailVerifiedAt: toTimestamptz(now)}, nil
+			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{TeamID: teamID, UserID: userID, Role: "member"}, nil
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "acme.com"}, nil
+			}).
+			onCreateAuthSession(func(_ context.Context, arg sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
+				*session = arg
+				return sqlc.AuthSession{}, nil
+			}).
+			build()
+	}
+
+	claimed := sqlc.DevicePairing{
+		ID:           pgtype.UUID{Bytes: [16]byte{6}, Valid: true},
+		UserID:       userID,
+		SessionID:    approverID,
+		DeviceIDHash: hashString("new-mac"),
+		DeviceName:   "Home Mac",
+		Platform:     "macos",
+		ClaimedAt:    toTimestamptz(now),
+	}
+	approved := claimed
+	approved.ApprovedAt = toTimestamptz(now)
+	done := approved
+	done.CompletedAt = toTimestamptz(now)
+
+	tests := []struct {
+		name       string
+		pairing    sqlc.DevicePairing
+		notFound   bool
+		signedOut  bool
+		deviceID   string
+		wantStatus int
+	}{
+		{name: "approved", pairing: approved, deviceID: "new-mac", wantStatus: http.StatusOK},
+		{name: "approver signed out", pairing: approved, signedOut: true, deviceID: "new-mac", wantStatus: http.StatusGone},
+		{name: "awaiting approval", pairing: claimed, deviceID: "new-mac", wantStatus: http.StatusAccepted},
+		{name: "other device", pairing: approved, deviceID: "other-mac", wantStatus: http.StatusUnauthorized},
+		{name: "already completed", pairing: done, deviceID: "new-mac", wantStatus: http.StatusGone},
+		{name: "cancelled or expired", notFound: true, deviceID: "new-mac", wantStatus: http.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var completed bool
+			var session sqlc.CreateAuthSessionParams
+			tx := &testTx{}
+			api := New(&stubStore{
+				querier: newQuerier(tt.pairing, !tt.notFound, tt.signedOut, &completed, &session),
+				beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+					return tx, nil
+				},
+			}, &mailer.LogMailer{}, Settings{
+				AccessTTL:  15 * time.Minute,
+				RefreshTTL: 24 * time.Hour,
+			}, nil)
+			api.clock = func() time.Time { return now }
+
+			body, _ := json.Marshal(completePairingRequest{ClaimToken: "claim-token"})
+			req := httptest.NewRequest(http.MethodPost, "/auth/pairings/complete", bytes.NewReader(body))
+			req.Header.Set("X-Device-Id", tt.deviceID)
+			rec := httptest.NewRecorder()
+			api.handleCompletePairing(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				if completed || tx.committed {
+					t.Fatal("expected no session to be issued")
+				}
+				return
+			}
+			if !completed || !tx.committed {
+				t.Fatalf("expected pairing completed and committed, got completed=%v committed=%v", completed, tx.committed)
+			}
+			if session.UserID != userID || !hashEqual(session.DeviceIDHash, hashString("new-mac")) || session.DeviceName != "Home Mac" || session.Platform != "macos" {
+				t.Fatalf("unexpected session: %+v", session)
+			}
+			var resp authResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.AccessToken == "" || resp.RefreshToken == "" || resp.Status != joinStatusMember {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestPairingStatus(t *testing.T) {
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	live := toTimestamptz(now.Add(time.Minute))
+
+	tests := []struct {
+		pairing sqlc.DevicePairing
+		want    string
+	}{
+		{pairing: sqlc.DevicePairing{ExpiresAt: live}, want: pairingStatusWaiting},
+		{pairing: sqlc.DevicePairing{ExpiresAt: live, ClaimedAt: toTimestamptz(now)}, want: pairingStatusClaimed},
+		{pairing: sqlc.DevicePairing{ExpiresAt: live, ClaimedAt: toTimestamptz(now), ApprovedAt: toTimestamptz(now)}, want: pairingStatusApproved},
+		{pairing: sqlc.DevicePairing{ExpiresAt: toTimestamptz(now), ClaimedAt: toTimestamptz(now)}, want: pairingStatusExpired},
+		{pairing: sqlc.DevicePairing{ExpiresAt: toTimestamptz(now), CompletedAt: toTimestamptz(now)}, want: pairingStatusCompleted},
+	}
+	for _, tt := range tests {
+		if got := pairingStatus(tt.pairing, now); got != tt.want {
+			t.Fatalf("expected %q, got %q for %+v", tt.want, got, tt.pairing)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package httpapi
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const purposeResumeSharing = "resume_sharing"
+
+var errLinksDisabled = errors.New("link signing is not configured")
+
+var resumeSharingTemplate = template.Must(template.New("resume").Parse(`<!doctype html>
+<html lang="en">
+<head><meta charset="utf-8"><title>TimeSync</title></head>
+<body>
+<p>{{.Message}}</p>
+{{if .Token}}<form method="post" action="/links/resume-sharing">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Resume sharing</button>
+</form>{{end}}
+</body>
+</html>
+`))
+
+type resumeSharingPage struct {
+	Message string
+	Token   string
+}
+
+// ResumeSharingURL builds the link mailed with a hidden-sharing reminder. The
+// token is bound to the reminder's timestamp, so it stops working once used
+// or once the user changes their visibility again.
+func (a *API) ResumeSharingURL(userID pgtype.UUID, reminderAt time.Time) (string, error) {
+	if a.links == nil {
+		return "", errLinksDisabled
+	}
+	subject := uuidString(userID) + ":" + strconv.FormatInt(reminderAt.UnixMicro(), 10)
+	token := a.links.Sign(purposeResumeSharing, subject, reminderAt.Add(a.settings.ResumeLinkTTL))
+	return strings.TrimRight(a.settings.PublicBaseURL, "/") + "/links/resume-sharing?token=" + url.QueryEscape(token), nil
+}
+
+// handleResumeSharingPage only renders a confirmation form. Mail scanners
+// prefetch links, so opening one must never change visibility by itself.
+func (a *API) handleResumeSharingPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if _, _, ok := a.parseResumeToken(token); !ok {
+		writeResumePage(w, http.StatusBadRequest, resumeSharingPage{Message: "This link is invalid or has expired."})
+		return
+	}
+	writeResumePage(w, http.StatusOK, resumeSharingPage{
+		Message: "Your timezone is hidden from your team. Resume sharing?",
+		Token:   token,
+	})
+}
+
+func (a *API) handleResumeSharing(w http.ResponseWriter, r *http.Request) {
+	userID, reminderAt, ok := a.parseResumeToken(r.PostFormValue("token"))
+	if !ok {
+		writeResumePage(w, http.StatusBadRequest, resumeSharingPage{Message: "This link is invalid or has expired."})
+		return
+	}
+
+	resumed, err := a.store.Querier().ResumeSharingFromReminder(r.Context(), sqlc.ResumeSharingFromReminderParams{
+		UserID:         userID,
+		LastReminderAt: toTimestamptz(reminderAt),
+	})
+	if err != nil {
+		a.logger.Error("failed to resume sharing", slog.Any("err", err))
+		writeResumePage(w, http.StatusInternalServerError, resumeSharingPage{Message: "Something went wrong. Please try again."})
+		return
+	}
+	if resumed == 0 {
+		writeResumePage(w, http.StatusGone, resumeSharingPage{Message: "This link has already been used or your sharing settings have changed since."})
+		return
+	}
+
+	writeResumePage(w, http.StatusOK, resumeSharingPage{Message: "Sharing resumed. Your team can see your local time again."})
+}
+
+func (a *API) parseResumeToken(token string) (pgtype.UUID, time.Time, bool) {
+	if a.links == nil || token == "" {
+		return pgtype.UUID{}, time.Time{}, false
+	}
+	subject, err := a.links.Verify(purposeResumeSharing, token, a.clock())
+	if err != nil {
+		return pgtype.UUID{}, time.Time{}, false
+	}
+	rawUserID, rawReminderAt, ok := strings.Cut(subject, ":")
+	if !ok {
+		return pgtype.UUID{}, time.Time{}, false
+	}
+	userID, ok := parseUUID(rawUserID)
+	if !ok {
+		return pgtype.UUID{}, time.Time{}, false
+	}
+	micros, err := strconv.ParseInt(rawReminderAt, 10, 64)
+	if err != nil {
+		return pgtype.UUID{}, time.Time{}, false
+	}
+	return userID, time.UnixMicro(micros), true
+}
+
+func writeResumePage(w http.ResponseWriter, status int, page resumeSharingPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = resumeSharingTemplate.Execute(w, page)
+}
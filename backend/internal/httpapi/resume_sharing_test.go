@@ -0,0 +1,164 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func newResumeAPI(q sqlc.Querier, now time.Time) *API {
+	api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{
+		PublicBaseURL:  "https://api.example.com/",
+		LinkSigningKey: "secret",
+		ResumeLinkTTL:  72 * time.Hour,
+	}, nil)
+	api.clock = func() time.Time { return now }
+	return api
+}
+
+func resumeToken(t *testing.T, link string) string {
+	t.Helper()
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse link: %v", err)
+	}
+	return parsed.Query().Get("token")
+}
+
+func TestResumeSharingURL(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	reminderAt := time.Date(2024, 7, 1, 8, 0, 0, 123456000, time.UTC)
+	api := newResumeAPI(nil, reminderAt)
+
+	link, err := api.ResumeSharingURL(userID, reminderAt)
+	if err != nil {
+		t.Fatalf("url error: %v", err)
+	}
+	if !strings.HasPrefix(link, "https://api.example.com/links/resume-sharing?token=") {
+		t.Fatalf("unexpected link: %q", link)
+	}
+
+	gotUser, gotAt, ok := api.parseResumeToken(resumeToken(t, link))
+	if !ok || gotUser != userID || !gotAt.Equal(reminderAt) {
+		t.Fatalf("unexpected token contents: %v %v %v", gotUser, gotAt, ok)
+	}
+
+	api.clock = func() time.Time { return reminderAt.Add(72 * time.Hour) }
+	if _, _, ok := api.parseResumeToken(resumeToken(t, link)); ok {
+		t.Fatal("expected token to expire")
+	}
+
+	disabled := New(&stubStore{}, &mailer.LogMailer{}, Settings{}, nil)
+	if _, err := disabled.ResumeSharingURL(userID, reminderAt); err == nil {
+		t.Fatal("expected error without signing key")
+	}
+}
+
+func TestHandleResumeSharingPage(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	t.Run("renders confirmation without resuming", func(t *testing.T) {
+		// No querier: opening the link must not touch the database.
+		api := newResumeAPI(nil, now)
+		link, _ := api.ResumeSharingURL(userID, now)
+		req := httptest.NewRequest(http.MethodGet, link, nil)
+		rec := httptest.NewRecorder()
+
+		api.handleResumeSharingPage(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, `method="post"`) || !strings.Contains(body, resumeToken(t, link)) {
+			t.Fatalf("expected confirmation form, got %q", body)
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		api := newResumeAPI(nil, now)
+		req := httptest.NewRequest(http.MethodGet, "/links/resume-sharing?token=forged", nil)
+		rec := httptest.NewRecorder()
+
+		api.handleResumeSharingPage(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleResumeSharing(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	post := func(api *API, token string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {token}}
+		req := httptest.NewRequest(http.MethodPost, "/links/resume-sharing", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		api.handleResumeSharing(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		name     string
+		affected int64
+		err      error
+		want     int
+	}{
+		{"resumes sharing", 1, nil, http.StatusOK},
+		{"already used", 0, nil, http.StatusGone},
+		{"query failure", 0, errors.New("db down"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var params sqlc.ResumeSharingFromReminderParams
+			q := newQuerierBuilder().
+				onResumeSharingFromReminder(func(_ context.Context, arg sqlc.ResumeSharingFromReminderParams) (int64, error) {
+					params = arg
+					return tt.affected, tt.err
+				}).
+				build()
+			api := newResumeAPI(q, now)
+			link, _ := api.ResumeSharingURL(userID, now)
+
+			rec := post(api, resumeToken(t, link))
+
+			if rec.Code != tt.want {
+				t.Fatalf("expected status %d, got %d", tt.want, rec.Code)
+			}
+			if params.UserID != userID || !params.LastReminderAt.Time.Equal(now) {
+				t.Fatalf("unexpected params: %+v", params)
+			}
+		})
+	}
+
+	t.Run("forged token", func(t *testing.T) {
+		api := newResumeAPI(nil, now)
+		other := newResumeAPI(nil, now)
+		other.links = nil
+		forged := New(&stubStore{}, &mailer.LogMailer{}, Settings{LinkSigningKey: "other", ResumeLinkTTL: time.Hour}, nil)
+		forged.clock = api.clock
+		link, _ := forged.ResumeSharingURL(userID, now)
+
+		if rec := post(api, resumeToken(t, link)); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+		if rec := post(other, resumeToken(t, link)); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400 without signing key, got %d", rec.Code)
+		}
+	})
+}
@@ -3,10 +3,14 @@ package httpapi
 import (
 	"context"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
+	"timesync/backend/internal/domainverify"
+	"timesync/backend/internal/linktoken"
 	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/oidcrp"
 	"timesync/backend/internal/sqlc"
 
 	"github.com/go-chi/chi/v5"
@@ -30,8 +34,25 @@ type Settings struct {
 	VerifyCodeLock         time.Duration
 	VerifyCodeIPLimit      int
 	VerifyCodeIPWindow     time.Duration
+	VerifyLinkIPLimit      int
+	VerifyLinkIPWindow     time.Duration
+	PairingRedeemIPLimit   int
+	PairingRedeemIPWindow  time.Duration
+	SSOStartIPLimit        int
+	SSOStartIPWindow       time.Duration
+	SSOCompleteIPLimit     int
+	SSOCompleteIPWindow    time.Duration
 	RefreshDeviceLimit     int
 	RefreshDeviceWindow    time.Duration
+	DomainCheckUserLimit   int
+	DomainCheckUserWindow  time.Duration
+	InviteTTL              time.Duration
+	PublicBaseURL          string
+	LinkSigningKey         string
+	ResumeLinkTTL          time.Duration
+	RateLimitBackend       string
+	FreeMailDomains        []string
+	FreeMailAllowedDomains []string
 }
 
 type API struct {
@@ -40,8 +61,12 @@ type API struct {
 	logger     *slog.Logger
 	settings   Settings
 	clock      func() time.Time
-	emailLimit *attemptTracker
-	failLimit  *attemptTracker
+	emailLimit attemptLimiter
+	failLimit  attemptLimiter
+	links      *linktoken.Signer
+	freeMail   freeMailDomains
+	resolver   domainverify.Resolver
+	oidc       *oidcrp.Client
 }
 
 type Store interface {
@@ -54,7 +79,7 @@ func New(store Store, mailer mailer.Mailer, settings Settings, logger *slog.Logg
 	if logger == nil {
 		logger = slog.Default()
 	}
-	return &API{
+	api := &API{
 		store:      store,
 		mailer:     mailer,
 		logger:     logger,
@@ -62,10 +87,25 @@ func New(store Store, mailer mailer.Mailer, settings Settings, logger *slog.Logg
 		clock:      time.Now,
 		emailLimit: newAttemptTracker(),
 		failLimit:  newAttemptTracker(),
+		freeMail:   newFreeMailDomains(freeMailList, settings.FreeMailDomains, settings.FreeMailAllowedDomains),
+		resolver:   net.DefaultResolver,
+		oidc:       oidcrp.New(nil),
 	}
+	if settings.RateLimitBackend == RateLimitBackendPostgres {
+		api.emailLimit = newPostgresAttemptLimiter(store.Querier(), "request_code_email")
+		api.failLimit = newPostgresAttemptLimiter(store.Querier(), "verify_code_email")
+	}
+	if settings.LinkSigningKey != "" {
+		api.links = linktoken.NewSigner([]byte(settings.LinkSigningKey))
+	}
+	return api
 }
 
 func (a *API) Handler() http.Handler {
+	// Both domain checks share one limit per user, since each runs live DNS
+	// lookups.
+	domainCheckLimit := a.rateLimit("domain_check_user", a.settings.DomainCheckUserLimit, a.settings.DomainCheckUserWindow, keyByUserID)
+
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
 	router.Use(middleware.RealIP)
@@ -78,17 +118,136 @@ func (a *API) Handler() http.Handler {
 	})
 
 	router.Route("/auth", func(r chi.Router) {
-		r.With(httprate.Limit(a.settings.RequestCodeIPLimit, a.settings.RequestCodeIPWindow, httprate.WithKeyFuncs(httprate.KeyByIP))).
+		r.With(a.rateLimit("request_code_ip", a.settings.RequestCodeIPLimit, a.settings.RequestCodeIPWindow, httprate.KeyByIP)).
 			Post("/request-code", a.handleRequestCode)
 
-		r.With(httprate.Limit(a.settings.VerifyCodeIPLimit, a.settings.VerifyCodeIPWindow, httprate.WithKeyFuncs(httprate.KeyByIP))).
+		r.With(a.rateLimit("verify_code_ip", a.settings.VerifyCodeIPLimit, a.settings.VerifyCodeIPWindow, httprate.KeyByIP)).
 			Post("/verify-code", a.handleVerifyCode)
 
-		r.With(httprate.Limit(a.settings.RefreshDeviceLimit, a.settings.RefreshDeviceWindow, httprate.WithKeyFuncs(keyByDeviceID))).
+		r.With(a.rateLimit("verify_link_ip", a.settings.VerifyLinkIPLimit, a.settings.VerifyLinkIPWindow, httprate.KeyByIP)).
+			Post("/verify-link", a.handleVerifyLink)
+
+		r.With(a.rateLimit("refresh_device", a.settings.RefreshDeviceLimit, a.settings.RefreshDeviceWindow, keyByDeviceID)).
 			Post("/refresh", a.handleRefresh)
 
 		r.Post("/logout", a.handleLogout)
+
+		r.Route("/sso", func(r chi.Router) {
+			r.With(a.rateLimit("sso_start_ip", a.settings.SSOStartIPLimit, a.settings.SSOStartIPWindow, httprate.KeyByIP)).
+				Post("/start", a.handleStartSSO)
+			r.Get("/oidc/callback", a.handleOIDCCallback)
+			r.Get("/saml/metadata", a.handleSAMLMetadata)
+			r.Post("/saml/acs", a.handleSAMLACS)
+			r.With(a.rateLimit("sso_complete_ip", a.settings.SSOCompleteIPLimit, a.settings.SSOCompleteIPWindow, httprate.KeyByIP)).
+				Post("/complete", a.handleCompleteSSO)
+		})
+
+		r.Route("/pairings", func(r chi.Router) {
+			r.With(a.rateLimit("pairing_redeem_ip", a.settings.PairingRedeemIPLimit, a.settings.PairingRedeemIPWindow, httprate.KeyByIP)).
+				Post("/redeem", a.handleRedeemPairing)
+			r.Post("/complete", a.handleCompletePairing)
+
+			r.Group(func(r chi.Router) {
+				r.Use(a.requireSession)
+				r.Post("/", a.handleCreatePairing)
+				r.Get("/{pairingID}", a.handleGetPairing)
+				r.Post("/{pairingID}/approve", a.handleApprovePairing)
+				r.Delete("/{pairingID}", a.handleCancelPairing)
+			})
+		})
+	})
+
+	router.Get("/links/sign-in", a.handleSignInLinkPage)
+	router.Get("/links/resume-sharing", a.handleResumeSharingPage)
+	router.Post("/links/resume-sharing", a.handleResumeSharing)
+
+	router.Group(func(r chi.Router) {
+		r.Use(a.requireSession)
+
+		r.Get("/me", a.handleGetMe)
+		r.Get("/me/join-request", a.handleGetJoinRequest)
+		r.Post("/me/email/request-code", a.handleRequestEmailChange)
+		r.Post("/me/email/confirm", a.handleConfirmEmailChange)
+		r.Post("/team", a.handleCreateTeam)
+		r.Post("/team/join", a.handleJoinTeam)
+		r.Post("/team/domain-verification", a.handleStartDomainVerification)
+		r.With(domainCheckLimit).Post("/team/domain-verification/check", a.handleCheckDomainVerification)
+	})
+
+	router.Group(func(r chi.Router) {
+		r.Use(a.requireAuth)
+
+		r.Put("/me/timezone", a.handleReportTimezone)
+		r.Get("/me/working-hours", a.handleGetWorkingHours)
+		r.Put("/me/working-hours", a.handleUpdateWorkingHours)
+		r.Get("/me/visibility", a.handleGetVisibility)
+		r.Put("/me/visibility", a.handleHideTimezone)
+		r.Delete("/me/visibility", a.handleUnhideTimezone)
+		r.Get("/me/sessions", a.handleListSessions)
+		r.Post("/me/sessions/revoke-others", a.handleRevokeOtherSessions)
+		r.Delete("/me/sessions/{sessionID}", a.handleRevokeSession)
+		r.Get("/me/domain-migration", a.handleGetMyDomainMigration)
+		r.Post("/me/domain-migration/request-code", a.handleRequestMigrationCode)
+		r.Post("/me/domain-migration/confirm", a.handleConfirmMigration)
+		r.Post("/team/leave", a.handleLeaveTeam)
+		r.With(a.requireAdmin).Patch("/team", a.handleUpdateTeam)
+
+		r.Route("/team/members", func(r chi.Router) {
+			r.Get("/", a.handleListTeamMembers)
+			r.With(a.requireAdmin).Patch("/{userID}", a.handleUpdateMemberRole)
+			r.With(a.requireAdmin).Delete("/{userID}", a.handleRemoveMember)
+			r.With(a.requireAdmin).Post("/{userID}/sign-out", a.handleSignOutMember)
+		})
+
+		r.Route("/team/join-requests", func(r chi.Router) {
+			r.Use(a.requireAdmin)
+			r.Get("/", a.handleListJoinRequests)
+			r.Post("/{requestID}/approve", a.handleApproveJoinRequest)
+			r.Post("/{requestID}/reject", a.handleRejectJoinRequest)
+		})
+
+		r.Route("/team/domains", func(r chi.Router) {
+			r.Use(a.requireAdmin)
+			r.Get("/", a.handleListTeamDomains)
+			r.Post("/", a.handleAddTeamDomain)
+			r.With(domainCheckLimit).Post("/{domain}/verify", a.handleVerifyTeamDomain)
+			r.Post("/{domain}/primary", a.handleSetPrimaryTeamDomain)
+			r.Delete("/{domain}", a.handleRemoveTeamDomain)
+		})
+
+		r.Route("/team/domain-migration", func(r chi.Router) {
+			r.Use(a.requireAdmin)
+			r.Get("/", a.handleGetDomainMigration)
+			r.Post("/", a.handleStartDomainMigration)
+			r.Post("/complete", a.handleCompleteDomainMigration)
+			r.Delete("/", a.handleCancelDomainMigration)
+		})
+
+		r.Route("/team/sso", func(r chi.Router) {
+			r.Use(a.requireAdmin)
+			r.Get("/", a.handleGetTeamSSO)
+			r.Put("/", a.handlePutTeamSSO)
+			r.Delete("/", a.handleDeleteTeamSSO)
+		})
+
+		r.Route("/team/invites", func(r chi.Router) {
+			r.Use(a.requireAdmin)
+			r.Get("/", a.handleListInvites)
+			r.Post("/", a.handleCreateInvite)
+			r.Post("/{inviteID}/resend", a.handleResendInvite)
+			r.Delete("/{inviteID}", a.handleRevokeInvite)
+		})
 	})
 
 	return router
 }
+
+// rateLimit builds an httprate limiter whose counters live in the configured
+// backend; name keeps each limiter's keys apart in the shared table.
+func (a *API) rateLimit(name string, limit int, window time.Duration, keyFn httprate.KeyFunc) func(http.Handler) http.Handler {
+	opts := []httprate.Option{httprate.WithKeyFuncs(keyFn)}
+	if a.settings.RateLimitBackend == RateLimitBackendPostgres {
+		opts = append(opts, httprate.WithLimitCounter(newPostgresLimitCounter(a.store.Querier(), name)))
+	}
+	return httprate.Limit(limit, window, opts...)
+}
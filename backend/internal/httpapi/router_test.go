@@ -1,12 +1,18 @@
 package httpapi
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 func TestHandlerHealth(t *testing.T) {
@@ -37,3 +43,105 @@ func TestHandlerHealth(t *testing.T) {
 		t.Fatalf("unexpected body: %q", body)
 	}
 }
+
+// TestHandlerIPLimitsAreSeparate exhausts the verify-code IP limit and checks
+// that the other credential-redeeming endpoints still answer the same IP, on
+// both backends.
+func TestHandlerIPLimitsAreSeparate(t *testing.T) {
+	for _, backend := range []string{RateLimitBackendMemory, RateLimitBackendPostgres} {
+		t.Run(backend, func(t *testing.T) {
+			var mu sync.Mutex
+			counts := map[string]int32{}
+			q := newQuerierBuilder().
+				onIncrementRateLimitCounter(func(_ context.Context, arg sqlc.IncrementRateLimitCounterParams) error {
+					mu.Lock()
+					defer mu.Unlock()
+					counts[arg.Key+arg.WindowStart.Time.String()]++
+					return nil
+				}).
+				onGetRateLimitCounts(func(_ context.Context, arg sqlc.GetRateLimitCountsParams) (sqlc.GetRateLimitCountsRow, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					return sqlc.GetRateLimitCountsRow{
+						CurrentCount:  counts[arg.Key+arg.CurrentWindow.Time.String()],
+						PreviousCount: counts[arg.Key+arg.PreviousWindow.Time.String()],
+					}, nil
+				}).
+				build()
+			api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{
+				RateLimitBackend:      backend,
+				RequestCodeIPLimit:    1,
+				RequestCodeIPWindow:   time.Hour,
+				VerifyCodeIPLimit:     1,
+				VerifyCodeIPWindow:    time.Hour,
+				VerifyLinkIPLimit:     1,
+				VerifyLinkIPWindow:    time.Hour,
+				PairingRedeemIPLimit:  1,
+				PairingRedeemIPWindow: time.Hour,
+				SSOStartIPLimit:       1,
+				SSOStartIPWindow:      time.Hour,
+				SSOCompleteIPLimit:    1,
+				SSOCompleteIPWindow:   time.Hour,
+			}, nil)
+			handler := api.Handler()
+
+			post := func(path string) int {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}")))
+				return rec.Code
+			}
+			post("/auth/verify-code")
+			if code := post("/auth/verify-code"); code != http.StatusTooManyRequests {
+				t.Fatalf("expected verify-code to be limited, got %d", code)
+			}
+			post("/auth/request-code")
+			for _, path := range []string{"/auth/verify-link", "/auth/sso/start", "/auth/sso/complete", "/auth/pairings/redeem"} {
+				if code := post(path); code == http.StatusTooManyRequests {
+					t.Fatalf("expected %s to have its own limit", path)
+				}
+			}
+		})
+	}
+}
+
+// TestHandlerDomainCheckLimit checks that both routes running live DNS
+// lookups share one limit per user, and that users don't exhaust each
+// other's.
+func TestHandlerDomainCheckLimit(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	q := newQuerierBuilder().
+		onGetAuthSessionByAccessHash(func(_ context.Context, arg sqlc.GetAuthSessionByAccessHashParams) (sqlc.AuthSession, error) {
+			userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+			if hashEqual(arg.AccessTokenHash, hashString("other")) {
+				userID.Bytes[0] = 2
+			}
+			return sqlc.AuthSession{UserID: userID, DeviceIDHash: hashString("device")}, nil
+		}).
+		onGetTeamMembershipByUserID(func(_ context.Context, userID pgtype.UUID) (sqlc.TeamMembership, error) {
+			return sqlc.TeamMembership{TeamID: teamID, UserID: userID, Role: "admin"}, nil
+		}).
+		build()
+	api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{
+		DomainCheckUserLimit:  1,
+		DomainCheckUserWindow: time.Hour,
+	}, nil)
+	handler := api.Handler()
+
+	post := func(path, token string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("X-Device-Id", "device")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := post("/team/domain-verification/check", "mine"); code == http.StatusTooManyRequests {
+		t.Fatal("expected the first check to pass the limit")
+	}
+	if code := post("/team/domains/example.com/verify", "mine"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected domain verify to share the check limit, got %d", code)
+	}
+	if code := post("/team/domain-verification/check", "other"); code == http.StatusTooManyRequests {
+		t.Fatal("expected another user to have their own limit")
+	}
+}
@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"timesync/backend/internal/samlsp"
+	"timesync/backend/internal/sqlc"
+)
+
+const (
+	// samlMetadataPath serves the service provider metadata admins upload to
+	// their identity provider; its URL doubles as the entity ID.
+	samlMetadataPath = "/auth/sso/saml/metadata"
+	// samlACSPath is the assertion consumer service the provider posts to.
+	samlACSPath = "/auth/sso/saml/acs"
+	// samlMaxFormBytes caps the posted form; signed responses with a few
+	// certificates stay well below it.
+	samlMaxFormBytes = 1 << 20
+)
+
+func (a *API) samlEntityID() string {
+	return strings.TrimRight(a.settings.PublicBaseURL, "/") + samlMetadataPath
+}
+
+func (a *API) samlACSURL() string {
+	return strings.TrimRight(a.settings.PublicBaseURL, "/") + samlACSPath
+}
+
+func (a *API) samlConfig(provider sqlc.TeamSsoProvider) samlsp.Config {
+	return samlsp.Config{
+		EntityID:    a.samlEntityID(),
+		ACSURL:      a.samlACSURL(),
+		IDPMetadata: []byte(provider.SamlIdpMetadata),
+	}
+}
+
+// handleSAMLMetadata describes the service provider. Every team registers
+// the same one; the login's RelayState tells their responses apart.
+func (a *API) handleSAMLMetadata(w http.ResponseWriter, r *http.Request) {
+	metadata, err := samlsp.Metadata(a.samlEntityID(), a.samlACSURL())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to build metadata")
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(metadata)
+}
+
+// handleSAMLACS is where the provider posts the browser back. The response
+// must be signed by a certificate from the team's uploaded metadata and
+// answer the request this login sent before its email is trusted. Logins
+// the provider starts on its own carry no RelayState and are refused.
+func (a *API) handleSAMLACS(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, samlMaxFormBytes)
+	if err := r.ParseForm(); err != nil {
+		writeSSOPage(w, http.StatusBadRequest, "This sign-in answer couldn't be read. Start again from TimeSync.")
+		return
+	}
+	state, samlResponse := r.PostForm.Get("RelayState"), r.PostForm.Get("SAMLResponse")
+	if state == "" || samlResponse == "" {
+		writeSSOPage(w, http.StatusBadRequest, "This sign-in answer is incomplete. Start again from TimeSync.")
+		return
+	}
+
+	ctx := r.Context()
+	now := a.clock()
+	login, provider, ok := a.claimSSOLogin(ctx, w, state, ssoProtocolSAML, now)
+	if !ok {
+		return
+	}
+
+	identity, err := samlsp.ParseResponse(a.samlConfig(provider), samlResponse, login.Nonce)
+	if err != nil {
+		a.logger.Warn("saml sign-in rejected", slog.String("team_id", uuidString(login.TeamID)), slog.Any("err", err))
+		writeSSOPage(w, http.StatusUnauthorized, "Your identity provider's answer couldn't be verified. Start again from TimeSync.")
+		return
+	}
+
+	a.finishSSOLogin(ctx, w, login, identity.Email, now)
+}
@@ -0,0 +1,195 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/samlsp/samltest"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestHandleSAMLACS(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	loginID := pgtype.UUID{Bytes: [16]byte{7}, Valid: true}
+	now := time.Now().UTC().Truncate(time.Second)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		email      string
+		state      string
+		forged     bool
+		protocol   string
+		lapsed     bool
+		wantStatus int
+	}{
+		{name: "team address", email: "ana@acme.com", wantStatus: http.StatusOK},
+		{name: "address outside team domains", email: "eve@globex.com", wantStatus: http.StatusForbidden},
+		{name: "unknown relay state", email: "ana@acme.com", state: "forged", wantStatus: http.StatusBadRequest},
+		{name: "signed with another key", email: "ana@acme.com", forged: true, wantStatus: http.StatusUnauthorized},
+		{name: "provider switched to oidc", email: "ana@acme.com", protocol: ssoProtocolOIDC, wantStatus: http.StatusBadRequest},
+		{name: "domain verification lapsed", email: "ana@acme.com", lapsed: true, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := samltest.NewProvider()
+			defer idp.Close()
+			idp.Email = tt.email
+			metadata := string(idp.Metadata())
+
+			protocol := ssoProtocolSAML
+			verifiedAt := toTimestamptz(now)
+			var login sqlc.SsoLogin
+			var authenticated sqlc.AuthenticateSsoLoginParams
+			q := newQuerierBuilder().
+				onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+					switch domain {
+					case "acme.com":
+						return sqlc.Team{ID: teamID, Domain: domain, DomainVerifiedAt: verifiedAt}, nil
+					case "globex.com":
+						return sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{9}, Valid: true}, Domain: domain, DomainVerifiedAt: toTimestamptz(now)}, nil
+					}
+					return sqlc.Team{}, pgx.ErrNoRows
+				}).
+				onGetTeamSsoProvider(func(_ context.Context, id pgtype.UUID) (sqlc.TeamSsoProvider, error) {
+					return sqlc.TeamSsoProvider{TeamID: id, Protocol: protocol, SamlIdpEntityID: idp.EntityID, SamlIdpMetadata: metadata}, nil
+				}).
+				onCreateSsoLogin(func(_ context.Context, arg sqlc.CreateSsoLoginParams) (sqlc.SsoLogin, error) {
+					login = sqlc.SsoLogin{
+						ID:           loginID,
+						TeamID:       arg.TeamID,
+						StateHash:    arg.StateHash,
+						DeviceIDHash: arg.DeviceIDHash,
+						Nonce:        arg.Nonce,
+						CodeVerifier: arg.CodeVerifier,
+						ExpiresAt:    arg.ExpiresAt,
+					}
+					return login, nil
+				}).
+				onClaimSsoLogin(func(_ context.Context, arg sqlc.ClaimSsoLoginParams) (sqlc.SsoLogin, error) {
+					if !hashEqual(arg.StateHash, login.StateHash) || !login.ExpiresAt.Time.After(arg.ExpiresAt.Time) || login.ClaimedAt.Valid {
+						return sqlc.SsoLogin{}, pgx.ErrNoRows
+					}
+					login.ClaimedAt = arg.ClaimedAt
+					return login, nil
+				}).
+				onAuthenticateSsoLogin(func(_ context.Context, arg sqlc.AuthenticateSsoLoginParams) error {
+					authenticated = arg
+					return nil
+				}).
+				build()
+			tx := &testTx{}
+			api := New(&stubStore{
+				querier: q,
+				beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+					return tx, nil
+				},
+			}, &mailer.LogMailer{}, Settings{PublicBaseURL: "https://timesync.example"}, nil)
+			api.clock = func() time.Time { return now }
+
+			metadataRec := httptest.NewRecorder()
+			api.handleSAMLMetadata(metadataRec, httptest.NewRequest(http.MethodGet, samlMetadataPath, nil))
+			if err := idp.Register(metadataRec.Body.Bytes()); err != nil {
+				t.Fatalf("register service provider: %v", err)
+			}
+
+			body, _ := json.Marshal(startSSORequest{Email: "ana@acme.com"})
+			startRec := httptest.NewRecorder()
+			startReq := httptest.NewRequest(http.MethodPost, "/auth/sso/start", bytes.NewReader(body))
+			startReq.Header.Set("X-Device-Id", "device-123")
+			api.handleStartSSO(startRec, startReq)
+			if startRec.Code != http.StatusOK {
+				t.Fatalf("start failed with %d: %s", startRec.Code, startRec.Body.String())
+			}
+			var start startSSOResponse
+			if err := json.NewDecoder(startRec.Body).Decode(&start); err != nil {
+				t.Fatalf("decode start: %v", err)
+			}
+			if start.Protocol != ssoProtocolSAML || login.Nonce == "" || login.CodeVerifier != "" {
+				t.Fatalf("unexpected start: %+v, login %+v", start, login)
+			}
+
+			if tt.forged {
+				idp.SigningKey = otherKey
+			}
+			// The recheck may revoke the domain while the user is at the
+			// provider.
+			if tt.lapsed {
+				verifiedAt = pgtype.Timestamptz{}
+			}
+			post, err := idp.SignIn(start.AuthorizationURL)
+			if err != nil {
+				t.Fatalf("sign in at provider: %v", err)
+			}
+			if post.URL != "https://timesync.example"+samlACSPath {
+				t.Fatalf("unexpected post to %s", post.URL)
+			}
+			form := url.Values{"SAMLResponse": {post.SAMLResponse}, "RelayState": {post.RelayState}}
+			if tt.state != "" {
+				form.Set("RelayState", tt.state)
+			}
+			if tt.protocol != "" {
+				protocol = tt.protocol
+			}
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, samlACSPath, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			api.handleSAMLACS(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				if authenticated.ID.Valid || tx.committed || handoffPattern.MatchString(rec.Body.String()) {
+					t.Fatal("expected no hand-off")
+				}
+				return
+			}
+
+			match := handoffPattern.FindStringSubmatch(rec.Body.String())
+			if match == nil {
+				t.Fatalf("expected app hand-off, got %s", rec.Body.String())
+			}
+			token, _ := url.QueryUnescape(match[1])
+			if authenticated.ID != loginID || authenticated.Email.String != "ana@acme.com" || !hashEqual(authenticated.HandoffTokenHash, hashString(token)) {
+				t.Fatalf("unexpected authentication: %+v", authenticated)
+			}
+			if !tx.committed {
+				t.Fatal("expected transaction to commit")
+			}
+		})
+	}
+}
+
+func TestHandleSAMLMetadata(t *testing.T) {
+	api := New(&stubStore{}, &mailer.LogMailer{}, Settings{PublicBaseURL: "https://timesync.example/"}, nil)
+
+	rec := httptest.NewRecorder()
+	api.handleSAMLMetadata(rec, httptest.NewRequest(http.MethodGet, samlMetadataPath, nil))
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/samlmetadata+xml" {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `entityID="https://timesync.example/auth/sso/saml/metadata"`) ||
+		!strings.Contains(body, `Location="https://timesync.example/auth/sso/saml/acs"`) {
+		t.Fatalf("unexpected metadata: %s", body)
+	}
+}
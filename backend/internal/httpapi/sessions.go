@@ -0,0 +1,195 @@
+package httpapi
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	maxDeviceNameLength  = 64
+	maxDeviceFieldLength = 32
+)
+
+type sessionResponse struct {
+	ID           string    `json:"id"`
+	DeviceName   string    `json:"device_name"`
+	Platform     string    `json:"platform"`
+	AppVersion   string    `json:"app_version"`
+	LastActiveAt time.Time `json:"last_active_at"`
+	Current      bool      `json:"current"`
+}
+
+type sessionListResponse struct {
+	Sessions []sessionResponse `json:"sessions"`
+}
+
+// handleListSessions lists the caller's signed-in devices. A device is a
+// session family, so its id stays the same across refreshes.
+func (a *API) handleListSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	currentFamily, _ := familyIDFromContext(ctx)
+
+	rows, err := a.store.Querier().ListActiveAuthSessions(ctx, sqlc.ListActiveAuthSessionsParams{
+		UserID:           userID,
+		RefreshExpiresAt: toTimestamptz(a.clock()),
+	})
+	if err != nil {
+		a.logger.Error("failed to list sessions", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to list sessions")
+		return
+	}
+
+	sessions := make([]sessionResponse, 0, len(rows))
+	for _, row := range rows {
+		lastActive := row.CreatedAt.Time
+		if row.LastUsedAt.Valid && row.LastUsedAt.Time.After(lastActive) {
+			lastActive = row.LastUsedAt.Time
+		}
+		sessions = append(sessions, sessionResponse{
+			ID:           uuidString(row.FamilyID),
+			DeviceName:   row.DeviceName,
+			Platform:     row.Platform,
+			AppVersion:   row.AppVersion,
+			LastActiveAt: lastActive,
+			Current:      row.FamilyID == currentFamily,
+		})
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		if sessions[i].Current != sessions[j].Current {
+			return sessions[i].Current
+		}
+		return sessions[i].LastActiveAt.After(sessions[j].LastActiveAt)
+	})
+
+	writeJSON(w, http.StatusOK, sessionListResponse{Sessions: sessions})
+}
+
+// handleRevokeSession signs one of the caller's devices out. Revoking the
+// current device works like logout.
+func (a *API) handleRevokeSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	familyID, ok := parseUUID(chi.URLParam(r, "sessionID"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid session id")
+		return
+	}
+
+	revoked, err := a.store.Querier().RevokeUserAuthSessionFamily(ctx, sqlc.RevokeUserAuthSessionFamilyParams{
+		UserID:    userID,
+		FamilyID:  familyID,
+		RevokedAt: toTimestamptz(a.clock()),
+	})
+	if err != nil {
+		a.logger.Error("failed to revoke session", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	if revoked == 0 {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) handleRevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	currentFamily, ok := familyIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	if _, err := a.store.Querier().RevokeOtherUserAuthSessions(ctx, sqlc.RevokeOtherUserAuthSessionsParams{
+		UserID:    userID,
+		FamilyID:  currentFamily,
+		RevokedAt: toTimestamptz(a.clock()),
+	}); err != nil {
+		a.logger.Error("failed to revoke other sessions", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to revoke sessions")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSignOutMember is the admin's emergency action for a lost or stolen
+// device: every session the member has is revoked, but the membership stays.
+func (a *API) handleSignOutMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+	memberID, ok := parseUUID(chi.URLParam(r, "userID"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	q := a.store.Querier()
+	if _, err := q.GetTeamMembership(ctx, sqlc.GetTeamMembershipParams{
+		TeamID: teamID,
+		UserID: memberID,
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "member not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to sign out member")
+		return
+	}
+
+	if err := q.RevokeUserAuthSessions(ctx, sqlc.RevokeUserAuthSessionsParams{
+		UserID:    memberID,
+		RevokedAt: toTimestamptz(a.clock()),
+	}); err != nil {
+		a.logger.Error("failed to sign out member", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to sign out member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deviceField cleans client-supplied device metadata: control characters are
+// dropped and the value is cut to max runes.
+func deviceField(value string, max int) string {
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value)
+	value = strings.TrimSpace(value)
+	if runes := []rune(value); len(runes) > max {
+		value = strings.TrimSpace(string(runes[:max]))
+	}
+	return value
+}
@@ -0,0 +1,248 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func sessionRequest(method, target string, userID, familyID pgtype.UUID) *http.Request {
+	req := authedRequest(method, target, nil, userID, pgtype.UUID{Bytes: [16]byte{9}, Valid: true}, "member")
+	return req.WithContext(context.WithValue(req.Context(), contextKeyFamilyID, familyID))
+}
+
+func TestHandleListSessions(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	current := pgtype.UUID{Bytes: [16]byte{0xa1}, Valid: true}
+	phone := pgtype.UUID{Bytes: [16]byte{0xa2}, Valid: true}
+	laptop := pgtype.UUID{Bytes: [16]byte{0xa3}, Valid: true}
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	q := newQuerierBuilder().
+		onListActiveAuthSessions(func(_ context.Context, arg sqlc.ListActiveAuthSessionsParams) ([]sqlc.ListActiveAuthSessionsRow, error) {
+			if arg.UserID != userID || !arg.RefreshExpiresAt.Time.Equal(now) {
+				t.Fatalf("unexpected params: %+v", arg)
+			}
+			return []sqlc.ListActiveAuthSessionsRow{
+				{FamilyID: phone, DeviceName: "Phone", Platform: "ios", CreatedAt: toTimestamptz(now.Add(-3 * time.Hour))},
+				{FamilyID: laptop, DeviceName: "Laptop", Platform: "macos", CreatedAt: toTimestamptz(now.Add(-5 * time.Hour)), LastUsedAt: toTimestamptz(now.Add(-time.Hour))},
+				{FamilyID: current, DeviceName: "Work Mac", Platform: "macos", AppVersion: "1.4.0", CreatedAt: toTimestamptz(now.Add(-10 * time.Hour))},
+			}, nil
+		}).
+		build()
+	api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+	api.clock = func() time.Time { return now }
+
+	rec := httptest.NewRecorder()
+	api.handleListSessions(rec, sessionRequest(http.MethodGet, "/me/sessions", userID, current))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	var resp sessionListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Sessions) != 3 {
+		t.Fatalf("expected 3 sessions, got %d", len(resp.Sessions))
+	}
+	got := []string{resp.Sessions[0].DeviceName, resp.Sessions[1].DeviceName, resp.Sessions[2].DeviceName}
+	if strings.Join(got, ",") != "Work Mac,Laptop,Phone" {
+		t.Fatalf("unexpected order: %v", got)
+	}
+	if !resp.Sessions[0].Current || resp.Sessions[1].Current || resp.Sessions[0].ID != uuidString(current) {
+		t.Fatalf("unexpected current flags: %+v", resp.Sessions)
+	}
+	if !resp.Sessions[1].LastActiveAt.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("expected last use to count as activity, got %v", resp.Sessions[1].LastActiveAt)
+	}
+	if strings.Contains(rec.Body.String(), "device_id") {
+		t.Fatal("expected device id hash to stay private")
+	}
+}
+
+func TestHandleRevokeSession(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	current := pgtype.UUID{Bytes: [16]byte{0xa1}, Valid: true}
+	other := pgtype.UUID{Bytes: [16]byte{0xa2}, Valid: true}
+
+	t.Run("revokes own device", func(t *testing.T) {
+		var got sqlc.RevokeUserAuthSessionFamilyParams
+		q := newQuerierBuilder().
+			onRevokeUserAuthSessionFamily(func(_ context.Context, arg sqlc.RevokeUserAuthSessionFamilyParams) (int64, error) {
+				got = arg
+				return 1, nil
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		req := withURLParam(sessionRequest(http.MethodDelete, "/me/sessions/"+uuidString(other), userID, current), "sessionID", uuidString(other))
+		rec := httptest.NewRecorder()
+		api.handleRevokeSession(rec, req)
+
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", rec.Code)
+		}
+		if got.UserID != userID || got.FamilyID != other || !got.RevokedAt.Valid {
+			t.Fatalf("unexpected params: %+v", got)
+		}
+	})
+
+	t.Run("unknown or foreign device", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onRevokeUserAuthSessionFamily(func(context.Context, sqlc.RevokeUserAuthSessionFamilyParams) (int64, error) {
+				return 0, nil
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		req := withURLParam(sessionRequest(http.MethodDelete, "/me/sessions/"+uuidString(other), userID, current), "sessionID", uuidString(other))
+		rec := httptest.NewRecorder()
+		api.handleRevokeSession(rec, req)
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404, got %d", rec.Code)
+		}
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		api := New(&stubStore{querier: newQuerierBuilder().build()}, &mailer.LogMailer{}, Settings{}, nil)
+
+		req := withURLParam(sessionRequest(http.MethodDelete, "/me/sessions/nope", userID, current), "sessionID", "nope")
+		rec := httptest.NewRecorder()
+		api.handleRevokeSession(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleRevokeOtherSessions(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	current := pgtype.UUID{Bytes: [16]byte{0xa1}, Valid: true}
+
+	var got sqlc.RevokeOtherUserAuthSessionsParams
+	q := newQuerierBuilder().
+		onRevokeOtherUserAuthSessions(func(_ context.Context, arg sqlc.RevokeOtherUserAuthSessionsParams) (int64, error) {
+			got = arg
+			return 4, nil
+		}).
+		build()
+	api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+	rec := httptest.NewRecorder()
+	api.handleRevokeOtherSessions(rec, sessionRequest(http.MethodPost, "/me/sessions/revoke-others", userID, current))
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status 204, got %d", rec.Code)
+	}
+	if got.UserID != userID || got.FamilyID != current {
+		t.Fatalf("expected to keep the current family, got %+v", got)
+	}
+}
+
+func TestHandleSignOutMember(t *testing.T) {
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	memberID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+
+	signOut := func(api *API) *httptest.ResponseRecorder {
+		req := authedRequest(http.MethodPost, "/team/members/"+uuidString(memberID)+"/sign-out", nil, adminID, teamID, "admin")
+		req = withURLParam(req, "userID", uuidString(memberID))
+		rec := httptest.NewRecorder()
+		api.handleSignOutMember(rec, req)
+		return rec
+	}
+
+	t.Run("revokes every session", func(t *testing.T) {
+		var revoked sqlc.RevokeUserAuthSessionsParams
+		q := newQuerierBuilder().
+			onGetTeamMembership(func(_ context.Context, arg sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+				if arg.TeamID != teamID || arg.UserID != memberID {
+					t.Fatalf("unexpected membership lookup: %+v", arg)
+				}
+				return sqlc.TeamMembership{TeamID: teamID, UserID: memberID, Role: "member"}, nil
+			}).
+			onRevokeUserAuthSessions(func(_ context.Context, arg sqlc.RevokeUserAuthSessionsParams) error {
+				revoked = arg
+				return nil
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		if rec := signOut(api); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected status 204, got %d", rec.Code)
+		}
+		if revoked.UserID != memberID || !revoked.RevokedAt.Valid {
+			t.Fatalf("unexpected revoke params: %+v", revoked)
+		}
+	})
+
+	t.Run("member of another team", func(t *testing.T) {
+		var revoked bool
+		q := newQuerierBuilder().
+			onGetTeamMembership(func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			onRevokeUserAuthSessions(func(context.Context, sqlc.RevokeUserAuthSessionsParams) error {
+				revoked = true
+				return nil
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		if rec := signOut(api); rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404, got %d", rec.Code)
+		}
+		if revoked {
+			t.Fatal("expected no sessions to be revoked")
+		}
+	})
+
+	t.Run("db failure", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetTeamMembership(func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, nil
+			}).
+			onRevokeUserAuthSessions(func(context.Context, sqlc.RevokeUserAuthSessionsParams) error {
+				return errors.New("db down")
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		if rec := signOut(api); rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", rec.Code)
+		}
+	})
+}
+
+func TestDeviceField(t *testing.T) {
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"  Work Mac\n", 64, "Work Mac"},
+		{"Bob's\u0000 Phone", 64, "Bob's Phone"},
+		{"abcdef", 3, "abc"},
+		{"ééééé", 2, "éé"},
+		{"", 10, ""},
+	}
+	for _, tt := range tests {
+		if got := deviceField(tt.in, tt.max); got != tt.want {
+			t.Fatalf("deviceField(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+		}
+	}
+}
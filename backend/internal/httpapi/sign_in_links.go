@@ -0,0 +1,182 @@
+package httpapi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	purposeSignIn = "sign_in"
+	// appSignInURL is the custom URL scheme the app registers; the hosted
+	// page hands the link's token to it.
+	appSignInURL = "timesync://sign-in"
+)
+
+var signInLinkTemplate = template.Must(template.New("sign-in").Parse(`<!doctype html>
+<html lang="en">
+<head><meta charset="utf-8"><title>TimeSync</title>
+{{if .AppURL}}<meta http-equiv="refresh" content="0;url={{.AppURL}}">{{end}}</head>
+<body>
+<p>{{.Message}}</p>
+{{if .AppURL}}<p><a href="{{.AppURL}}">Open TimeSync</a></p>{{end}}
+</body>
+</html>
+`))
+
+type signInLinkPage struct {
+	Message string
+	AppURL  template.URL
+}
+
+type verifyLinkRequest struct {
+	Token      string `json:"token"`
+	InviteCode string `json:"invite_code,omitempty"`
+	DeviceName string `json:"device_name,omitempty"`
+	Platform   string `json:"platform,omitempty"`
+	AppVersion string `json:"app_version,omitempty"`
+}
+
+// signInURL builds the magic link mailed next to a sign-in code. The token
+// names the code row, so redeeming either one uses up both, and carries a
+// hash of the requesting device id, so a forwarded email can't sign in on
+// another machine. It reports false when links are disabled or the app sent
+// no device id.
+func (a *API) signInURL(codeRow sqlc.EmailVerificationCode, deviceID string) (string, bool) {
+	if a.links == nil || deviceID == "" {
+		return "", false
+	}
+	subject := uuidString(codeRow.ID) + ":" + hex.EncodeToString(hashString(deviceID))
+	token := a.links.Sign(purposeSignIn, subject, codeRow.ExpiresAt.Time)
+	return strings.TrimRight(a.settings.PublicBaseURL, "/") + "/links/sign-in?token=" + url.QueryEscape(token), true
+}
+
+// handleSignInLinkPage hands the token over to the app. Nothing is redeemed
+// here: mail scanners prefetch links, and only the app on the requesting
+// device can use the token.
+func (a *API) handleSignInLinkPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if _, _, ok := a.parseSignInToken(token); !ok {
+		writeSignInPage(w, http.StatusBadRequest, signInLinkPage{Message: "This sign-in link is invalid or has expired. Request a new one from TimeSync."})
+		return
+	}
+	writeSignInPage(w, http.StatusOK, signInLinkPage{
+		Message: "Opening TimeSync… If nothing happens, open this link on the Mac where you requested it.",
+		AppURL:  template.URL(appSignInURL + "?token=" + url.QueryEscape(token)),
+	})
+}
+
+// handleVerifyLink signs in with a magic link's token, as handed to the app
+// by the link page. It redeems the link's code and then follows the same
+// path as handleVerifyCode.
+func (a *API) handleVerifyLink(w http.ResponseWriter, r *http.Request) {
+	var req verifyLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	inviteCode := normalizeCode(req.InviteCode)
+	if inviteCode != "" && !isValidCode(inviteCode) {
+		writeError(w, http.StatusBadRequest, "invalid invite code format")
+		return
+	}
+
+	deviceID := strings.TrimSpace(r.Header.Get("X-Device-Id"))
+	if deviceID == "" {
+		writeError(w, http.StatusBadRequest, "X-Device-Id is required")
+		return
+	}
+
+	codeID, deviceHash, ok := a.parseSignInToken(strings.TrimSpace(req.Token))
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "invalid link")
+		return
+	}
+	if !hashEqual(deviceHash, hashString(deviceID)) {
+		writeError(w, http.StatusUnauthorized, "link was requested on another device")
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// The code row stays locked until commit, so two taps on the same link
+	// can't both sign in.
+	now := a.clock()
+	q := a.store.WithTx(tx)
+	codeRow, err := q.GetEmailVerificationCodeByIDForUpdate(ctx, sqlc.GetEmailVerificationCodeByIDForUpdateParams{
+		ID:        codeID,
+		ExpiresAt: toTimestamptz(now),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusGone, "link already used or expired")
+			return
+		}
+		a.logger.Error("failed to load sign-in link", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to verify link")
+		return
+	}
+	if err := a.checkCodeLockout(ctx, codeRow.Email, now); err != nil {
+		a.writeEmailCodeError(w, err, "failed to check rate limit")
+		return
+	}
+	if err := q.MarkEmailVerificationCodeUsed(ctx, sqlc.MarkEmailVerificationCodeUsedParams{
+		ID:     codeRow.ID,
+		UsedAt: toTimestamptz(now),
+	}); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to verify link")
+		return
+	}
+
+	a.completeSignIn(ctx, w, tx, q, codeRow.Email, inviteCode, signInByEmail, signInDevice{
+		ID:         deviceID,
+		Name:       req.DeviceName,
+		Platform:   req.Platform,
+		AppVersion: req.AppVersion,
+	}, now)
+}
+
+func (a *API) parseSignInToken(token string) (pgtype.UUID, []byte, bool) {
+	if a.links == nil || token == "" {
+		return pgtype.UUID{}, nil, false
+	}
+	subject, err := a.links.Verify(purposeSignIn, token, a.clock())
+	if err != nil {
+		return pgtype.UUID{}, nil, false
+	}
+	rawCodeID, rawDeviceHash, ok := strings.Cut(subject, ":")
+	if !ok {
+		return pgtype.UUID{}, nil, false
+	}
+	codeID, ok := parseUUID(rawCodeID)
+	if !ok {
+		return pgtype.UUID{}, nil, false
+	}
+	deviceHash, err := hex.DecodeString(rawDeviceHash)
+	if err != nil {
+		return pgtype.UUID{}, nil, false
+	}
+	return codeID, deviceHash, true
+}
+
+func writeSignInPage(w http.ResponseWriter, status int, page signInLinkPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = signInLinkTemplate.Execute(w, page)
+}
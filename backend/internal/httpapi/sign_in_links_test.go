@@ -0,0 +1,211 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestHandleRequestCodeSignInLink(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	codeID := pgtype.UUID{Bytes: [16]byte{8}, Valid: true}
+	q := newQuerierBuilder().
+		onCreateEmailVerificationCode(func(_ context.Context, arg sqlc.CreateEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+			return sqlc.EmailVerificationCode{ID: codeID, Email: arg.Email, Code: arg.Code, ExpiresAt: arg.ExpiresAt}, nil
+		}).
+		build()
+
+	tests := []struct {
+		name     string
+		deviceID string
+		key      string
+		wantLink bool
+	}{
+		{name: "device and key", deviceID: "device-123", key: "secret", wantLink: true},
+		{name: "no device id", key: "secret"},
+		{name: "links disabled", deviceID: "device-123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &stubMailer{}
+			api := New(&stubStore{querier: q}, m, Settings{
+				CodeTTL:                10 * time.Minute,
+				RequestCodeEmailLimit:  3,
+				RequestCodeEmailWindow: time.Minute,
+				PublicBaseURL:          "https://timesync.example/",
+				LinkSigningKey:         tt.key,
+			}, nil)
+			api.clock = func() time.Time { return now }
+
+			body, _ := json.Marshal(requestCodeRequest{Email: "user@example.com"})
+			req := httptest.NewRequest(http.MethodPost, "/auth/request-code", bytes.NewReader(body))
+			if tt.deviceID != "" {
+				req.Header.Set("X-Device-Id", tt.deviceID)
+			}
+			rec := httptest.NewRecorder()
+			api.handleRequestCode(rec, req)
+
+			if rec.Code != http.StatusNoContent {
+				t.Fatalf("expected status 204, got %d", rec.Code)
+			}
+			if !tt.wantLink {
+				if m.calls != 1 || m.linkCalls != 0 {
+					t.Fatalf("expected code-only mail, got %d codes and %d links", m.calls, m.linkCalls)
+				}
+				return
+			}
+			if m.linkCalls != 1 || m.calls != 0 || m.lastCode == "" {
+				t.Fatalf("expected one link mail, got %d codes and %d links", m.calls, m.linkCalls)
+			}
+			link, err := url.Parse(m.lastLink)
+			if err != nil || link.Scheme != "https" || link.Host != "timesync.example" || link.Path != "/links/sign-in" {
+				t.Fatalf("unexpected link: %q", m.lastLink)
+			}
+			gotCodeID, deviceHash, ok := api.parseSignInToken(link.Query().Get("token"))
+			if !ok || gotCodeID != codeID || !hashEqual(deviceHash, hashString(tt.deviceID)) {
+				t.Fatalf("unexpected token in %q", m.lastLink)
+			}
+		})
+	}
+}
+
+func TestHandleSignInLinkPage(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	api := New(&stubStore{querier: newQuerierBuilder().build()}, &mailer.LogMailer{}, Settings{LinkSigningKey: "secret"}, nil)
+	api.clock = func() time.Time { return now }
+	link, _ := api.signInURL(sqlc.EmailVerificationCode{
+		ID:        pgtype.UUID{Bytes: [16]byte{8}, Valid: true},
+		ExpiresAt: toTimestamptz(now.Add(10 * time.Minute)),
+	}, "device-123")
+	token := link[strings.Index(link, "token=")+len("token="):]
+
+	rec := httptest.NewRecorder()
+	api.handleSignInLinkPage(rec, httptest.NewRequest(http.MethodGet, "/links/sign-in?token="+token, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `href="timesync://sign-in?token=`+token+`"`) {
+		t.Fatalf("expected app hand-off link, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	api.handleSignInLinkPage(rec, httptest.NewRequest(http.MethodGet, "/links/sign-in?token=forged", nil))
+	if rec.Code != http.StatusBadRequest || strings.Contains(rec.Body.String(), "timesync://") {
+		t.Fatalf("expected rejection, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleVerifyLink(t *testing.T) {
+	now := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	codeID := pgtype.UUID{Bytes: [16]byte{8}, Valid: true}
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+
+	newQuerier := func(used bool, marked *bool, session *sqlc.CreateAuthSessionParams) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetEmailVerificationCodeByIDForUpdate(func(_ context.Context, arg sqlc.GetEmailVerificationCodeByIDForUpdateParams) (sqlc.EmailVerificationCode, error) {
+				if used || arg.ID != codeID || !arg.ExpiresAt.Time.Equal(now) {
+					return sqlc.EmailVerificationCode{}, pgx.ErrNoRows
+				}
+				return sqlc.EmailVerificationCode{ID: arg.ID, Email: "user@example.com"}, nil
+			}).
+			onMarkEmailVerificationCodeUsed(func(_ context.Context, arg sqlc.MarkEmailVerificationCodeUsedParams) error {
+				*marked = arg.ID == codeID && arg.UsedAt.Time.Equal(now)
+				return nil
+			}).
+			onGetUserByEmail(func(_ context.Context, email string) (sqlc.User, error) {
+				return sqlc.User{ID: userID, Email: email, EmailDomain: "example.com", EmailVerifiedAt: toTimestamptz(now)}, nil
+			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{TeamID: teamID, UserID: userID, Role: "member"}, nil
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "example.com"}, nil
+			}).
+			onCreateAuthSession(func(_ context.Context, arg sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
+				*session = arg
+				return sqlc.AuthSession{}, nil
+			}).
+			build()
+	}
+
+	tests := []struct {
+		name       string
+		deviceID   string
+		token      string
+		used       bool
+		wantStatus int
+	}{
+		{name: "same device", deviceID: "device-123", wantStatus: http.StatusOK},
+		{name: "forwarded to another device", deviceID: "device-999", wantStatus: http.StatusUnauthorized},
+		{name: "already used", deviceID: "device-123", used: true, wantStatus: http.StatusGone},
+		{name: "forged token", deviceID: "device-123", token: "forged", wantStatus: http.StatusUnauthorized},
+		{name: "missing device", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var marked bool
+			var session sqlc.CreateAuthSessionParams
+			tx := &testTx{}
+			api := New(&stubStore{
+				querier: newQuerier(tt.used, &marked, &session),
+				beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+					return tx, nil
+				},
+			}, &mailer.LogMailer{}, Settings{
+				AccessTTL:      15 * time.Minute,
+				RefreshTTL:     24 * time.Hour,
+				LinkSigningKey: "secret",
+			}, nil)
+			api.clock = func() time.Time { return now }
+
+			token := tt.token
+			if token == "" {
+				link, _ := api.signInURL(sqlc.EmailVerificationCode{ID: codeID, ExpiresAt: toTimestamptz(now.Add(10 * time.Minute))}, "device-123")
+				token, _ = url.QueryUnescape(link[strings.Index(link, "token=")+len("token="):])
+			}
+			body, _ := json.Marshal(verifyLinkRequest{Token: token, DeviceName: "Work Mac", Platform: "macos"})
+			req := httptest.NewRequest(http.MethodPost, "/auth/verify-link", bytes.NewReader(body))
+			if tt.deviceID != "" {
+				req.Header.Set("X-Device-Id", tt.deviceID)
+			}
+			rec := httptest.NewRecorder()
+			api.handleVerifyLink(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				if marked || tx.committed {
+					t.Fatal("expected the link to stay unused")
+				}
+				return
+			}
+			if !marked || !tx.committed {
+				t.Fatalf("expected code marked used and committed, got marked=%v committed=%v", marked, tx.committed)
+			}
+			if session.UserID != userID || !hashEqual(session.DeviceIDHash, hashString("device-123")) || session.DeviceName != "Work Mac" {
+				t.Fatalf("unexpected session: %+v", session)
+			}
+			var resp authResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.AccessToken == "" || resp.Status != joinStatusMember || resp.Team == nil {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
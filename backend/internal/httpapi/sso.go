@@ -0,0 +1,331 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"timesync/backend/internal/oidcrp"
+	"timesync/backend/internal/samlsp"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	ssoProtocolOIDC = "oidc"
+	ssoProtocolSAML = "saml"
+	// ssoLoginTTL bounds the whole round trip, from the app starting a login
+	// through the browser to the app redeeming the hand-off.
+	ssoLoginTTL = 10 * time.Minute
+	// appSSOURL receives the hand-off token once the identity provider has
+	// vouched for the user.
+	appSSOURL = "timesync://sso"
+)
+
+var errSSORequired = errors.New("single sign-on required")
+
+type startSSORequest struct {
+	Email string `json:"email"`
+}
+
+type startSSOResponse struct {
+	Protocol         string `json:"protocol"`
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type completeSSORequest struct {
+	Token      string `json:"token"`
+	InviteCode string `json:"invite_code,omitempty"`
+	DeviceName string `json:"device_name,omitempty"`
+	Platform   string `json:"platform,omitempty"`
+	AppVersion string `json:"app_version,omitempty"`
+}
+
+// domainSSOProvider returns the identity provider of the team that owns
+// email's domain, or pgx.ErrNoRows when that team has none. A provider only
+// speaks for the domain while the team's verification holds; once the
+// recheck revokes it, sign-in falls back to email codes.
+func domainSSOProvider(ctx context.Context, q sqlc.Querier, email string) (sqlc.TeamSsoProvider, error) {
+	domain, ok := emailDomain(email)
+	if !ok {
+		return sqlc.TeamSsoProvider{}, pgx.ErrNoRows
+	}
+	team, _, err := findDomainTeam(ctx, q, domain)
+	if err != nil {
+		return sqlc.TeamSsoProvider{}, err
+	}
+	if !team.DomainVerifiedAt.Valid {
+		return sqlc.TeamSsoProvider{}, pgx.ErrNoRows
+	}
+	return q.GetTeamSsoProvider(ctx, team.ID)
+}
+
+// checkSSORequired fails with errSSORequired when email's team has turned
+// off code sign-in for its domain. Invited addresses on other domains are
+// unaffected.
+func checkSSORequired(ctx context.Context, q sqlc.Querier, email string) error {
+	provider, err := domainSSOProvider(ctx, q, email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("check sso: %w", err)
+	}
+	if provider.Required {
+		return errSSORequired
+	}
+	return nil
+}
+
+// handleStartSSO begins a sign-in at the identity provider of the team
+// owning the address. The app opens the returned URL in a browser; the
+// login is bound to the device that started it.
+func (a *API) handleStartSSO(w http.ResponseWriter, r *http.Request) {
+	var req startSSORequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "email is required")
+		return
+	}
+	deviceID := strings.TrimSpace(r.Header.Get("X-Device-Id"))
+	if deviceID == "" {
+		writeError(w, http.StatusBadRequest, "X-Device-Id is required")
+		return
+	}
+
+	ctx := r.Context()
+	q := a.store.Querier()
+	provider, err := domainSSOProvider(ctx, q, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "single sign-on is not set up for this address")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to start sign-in")
+		return
+	}
+
+	state, stateHash, err := generateToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start sign-in")
+		return
+	}
+	nonce, _, err := generateToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start sign-in")
+		return
+	}
+
+	var authURL, verifier string
+	switch provider.Protocol {
+	case ssoProtocolOIDC:
+		verifier = oidcrp.NewVerifier()
+		authURL, err = a.oidc.AuthCodeURL(ctx, a.oidcConfig(provider), state, nonce, verifier)
+	case ssoProtocolSAML:
+		// The request ID stands in for the nonce: the response has to
+		// answer it.
+		authURL, nonce, err = samlsp.AuthnRequestURL(a.samlConfig(provider), state)
+	default:
+		err = fmt.Errorf("unknown sso protocol %q", provider.Protocol)
+	}
+	if err != nil {
+		a.logger.Warn("failed to reach identity provider", slog.String("team_id", uuidString(provider.TeamID)), slog.Any("err", err))
+		writeError(w, http.StatusBadGateway, "identity provider unavailable")
+		return
+	}
+
+	now := a.clock()
+	if _, err := q.CreateSsoLogin(ctx, sqlc.CreateSsoLoginParams{
+		TeamID:       provider.TeamID,
+		StateHash:    stateHash,
+		DeviceIDHash: hashString(deviceID),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    toTimestamptz(now.Add(ssoLoginTTL)),
+		CreatedAt:    toTimestamptz(now),
+	}); err != nil {
+		a.logger.Error("failed to create sso login", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to start sign-in")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, startSSOResponse{Protocol: provider.Protocol, AuthorizationURL: authURL})
+}
+
+// claimSSOLogin marks the login behind state as answered and loads its
+// team's provider, which must still speak protocol. The claim commits on
+// its own so the provider round trip that follows holds no row lock, and a
+// replayed answer finds the login already used.
+func (a *API) claimSSOLogin(ctx context.Context, w http.ResponseWriter, state, protocol string, now time.Time) (sqlc.SsoLogin, sqlc.TeamSsoProvider, bool) {
+	q := a.store.Querier()
+	login, err := q.ClaimSsoLogin(ctx, sqlc.ClaimSsoLoginParams{
+		StateHash: hashString(state),
+		ExpiresAt: toTimestamptz(now),
+		ClaimedAt: toTimestamptz(now),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeSSOPage(w, http.StatusBadRequest, "This sign-in has expired or was already used. Start again from TimeSync.")
+			return sqlc.SsoLogin{}, sqlc.TeamSsoProvider{}, false
+		}
+		a.logger.Error("failed to claim sso login", slog.Any("err", err))
+		writeSSOPage(w, http.StatusInternalServerError, "Something went wrong. Start again from TimeSync.")
+		return sqlc.SsoLogin{}, sqlc.TeamSsoProvider{}, false
+	}
+	provider, err := q.GetTeamSsoProvider(ctx, login.TeamID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		writeSSOPage(w, http.StatusInternalServerError, "Something went wrong. Start again from TimeSync.")
+		return sqlc.SsoLogin{}, sqlc.TeamSsoProvider{}, false
+	}
+	if err != nil || provider.Protocol != protocol {
+		writeSSOPage(w, http.StatusBadRequest, "Single sign-on is no longer set up for your team.")
+		return sqlc.SsoLogin{}, sqlc.TeamSsoProvider{}, false
+	}
+	return login, provider, true
+}
+
+// finishSSOLogin records the address an identity provider vouched for and
+// hands the login back to the app. The browser only sees a one-time token;
+// the session itself goes to the device that started the login. A provider
+// can only vouch for addresses on its own team's domains.
+func (a *API) finishSSOLogin(ctx context.Context, w http.ResponseWriter, login sqlc.SsoLogin, assertedEmail string, now time.Time) {
+	email, ok := normalizeEmail(assertedEmail)
+	domain, domainOK := emailDomain(email)
+	if !ok || !domainOK {
+		writeSSOPage(w, http.StatusForbidden, "Your identity provider didn't share a usable email address.")
+		return
+	}
+
+	tx, err := a.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		writeSSOPage(w, http.StatusInternalServerError, "Something went wrong. Start again from TimeSync.")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.store.WithTx(tx)
+	team, _, err := findDomainTeam(ctx, q, domain)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		a.logger.Error("failed to resolve sso team", slog.Any("err", err))
+		writeSSOPage(w, http.StatusInternalServerError, "Something went wrong. Start again from TimeSync.")
+		return
+	}
+	if err != nil || team.ID != login.TeamID {
+		a.logger.Warn("sso email outside team domains", slog.String("team_id", uuidString(login.TeamID)), slog.String("domain", domain))
+		writeSSOPage(w, http.StatusForbidden, "Your identity provider signed you in with an address that isn't on your team's domain.")
+		return
+	}
+	if !team.DomainVerifiedAt.Valid {
+		a.logger.Warn("sso refused for unverified domain", slog.String("team_id", uuidString(login.TeamID)))
+		writeSSOPage(w, http.StatusForbidden, "Single sign-on is paused until your team's domain is verified again. Sign in with an email code instead.")
+		return
+	}
+
+	handoff, handoffHash, err := generateToken()
+	if err != nil {
+		writeSSOPage(w, http.StatusInternalServerError, "Something went wrong. Start again from TimeSync.")
+		return
+	}
+	if err := q.AuthenticateSsoLogin(ctx, sqlc.AuthenticateSsoLoginParams{
+		ID:               login.ID,
+		Email:            pgtype.Text{String: email, Valid: true},
+		HandoffTokenHash: handoffHash,
+		AuthenticatedAt:  toTimestamptz(now),
+	}); err != nil {
+		a.logger.Error("failed to record sso login", slog.Any("err", err))
+		writeSSOPage(w, http.StatusInternalServerError, "Something went wrong. Start again from TimeSync.")
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		writeSSOPage(w, http.StatusInternalServerError, "Something went wrong. Start again from TimeSync.")
+		return
+	}
+
+	writeSignInPage(w, http.StatusOK, signInLinkPage{
+		Message: "Signed in. Opening TimeSync…",
+		AppURL:  template.URL(appSSOURL + "?token=" + url.QueryEscape(handoff)),
+	})
+}
+
+// handleCompleteSSO redeems the hand-off token on the device that started
+// the login and then follows the same path as handleVerifyCode.
+func (a *API) handleCompleteSSO(w http.ResponseWriter, r *http.Request) {
+	var req completeSSORequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	token := strings.TrimSpace(req.Token)
+	if token == "" {
+		writeError(w, http.StatusBadRequest, "token is required")
+		return
+	}
+	inviteCode := normalizeCode(req.InviteCode)
+	if inviteCode != "" && !isValidCode(inviteCode) {
+		writeError(w, http.StatusBadRequest, "invalid invite code format")
+		return
+	}
+	deviceID := strings.TrimSpace(r.Header.Get("X-Device-Id"))
+	if deviceID == "" {
+		writeError(w, http.StatusBadRequest, "X-Device-Id is required")
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := a.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	now := a.clock()
+	q := a.store.WithTx(tx)
+	login, err := q.GetSsoLoginByHandoffForUpdate(ctx, sqlc.GetSsoLoginByHandoffForUpdateParams{
+		HandoffTokenHash: hashString(token),
+		ExpiresAt:        toTimestamptz(now),
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusUnauthorized, "invalid or expired sign-in")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to complete sign-in")
+		return
+	}
+	if !hashEqual(login.DeviceIDHash, hashString(deviceID)) {
+		writeError(w, http.StatusUnauthorized, "sign-in was started on another device")
+		return
+	}
+	if err := q.CompleteSsoLogin(ctx, sqlc.CompleteSsoLoginParams{
+		ID:          login.ID,
+		CompletedAt: toTimestamptz(now),
+	}); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to complete sign-in")
+		return
+	}
+
+	a.completeSignIn(ctx, w, tx, q, login.Email.String, inviteCode, signInBySSO, signInDevice{
+		ID:         deviceID,
+		Name:       req.DeviceName,
+		Platform:   req.Platform,
+		AppVersion: req.AppVersion,
+	}, now)
+}
+
+func writeSSOPage(w http.ResponseWriter, status int, message string) {
+	writeSignInPage(w, status, signInLinkPage{Message: message})
+}
@@ -0,0 +1,349 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/oidcrp"
+	"timesync/backend/internal/oidcrp/oidctest"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestHandleRequestCodeSSORequired(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+
+	tests := []struct {
+		name       string
+		email      string
+		required   bool
+		unverified bool
+		wantStatus int
+	}{
+		{name: "required for domain", email: "ana@acme.com", required: true, wantStatus: http.StatusForbidden},
+		{name: "optional for domain", email: "ana@acme.com", wantStatus: http.StatusNoContent},
+		{name: "invited outsider", email: "contractor@globex.com", required: true, wantStatus: http.StatusNoContent},
+		{name: "domain verification lapsed", email: "ana@acme.com", required: true, unverified: true, wantStatus: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newQuerierBuilder().
+				onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+					if domain != "acme.com" {
+						return sqlc.Team{}, pgx.ErrNoRows
+					}
+					team := sqlc.Team{ID: teamID, Domain: domain}
+					if !tt.unverified {
+						team.DomainVerifiedAt = toTimestamptz(time.Now())
+					}
+					return team, nil
+				}).
+				onGetTeamSsoProvider(func(_ context.Context, id pgtype.UUID) (sqlc.TeamSsoProvider, error) {
+					return sqlc.TeamSsoProvider{TeamID: id, Protocol: ssoProtocolOIDC, Required: tt.required}, nil
+				}).
+				onCreateEmailVerificationCode(func(_ context.Context, arg sqlc.CreateEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+					return sqlc.EmailVerificationCode{Email: arg.Email, Code: arg.Code}, nil
+				}).
+				build()
+			m := &stubMailer{}
+			api := New(&stubStore{querier: q}, m, Settings{
+				CodeTTL:                10 * time.Minute,
+				RequestCodeEmailLimit:  3,
+				RequestCodeEmailWindow: time.Minute,
+			}, nil)
+
+			body, _ := json.Marshal(requestCodeRequest{Email: tt.email})
+			rec := httptest.NewRecorder()
+			api.handleRequestCode(rec, httptest.NewRequest(http.MethodPost, "/auth/request-code", bytes.NewReader(body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if wantMail := tt.wantStatus == http.StatusNoContent; (m.calls == 1) != wantMail {
+				t.Fatalf("expected mail=%v, got %d codes", wantMail, m.calls)
+			}
+		})
+	}
+}
+
+// Codes mailed for an email change or before the team turned on the
+// requirement must not sign anyone in either.
+func TestEmailSignInSSORequired(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	codeID := pgtype.UUID{Bytes: [16]byte{8}, Valid: true}
+	now := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		link       bool
+		required   bool
+		wantStatus int
+	}{
+		{name: "code when required", required: true, wantStatus: http.StatusForbidden},
+		{name: "link when required", link: true, required: true, wantStatus: http.StatusForbidden},
+		{name: "code when optional", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var session bool
+			q := newQuerierBuilder().
+				onGetEmailVerificationCode(func(_ context.Context, arg sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+					return sqlc.EmailVerificationCode{ID: codeID, Email: arg.Email}, nil
+				}).
+				onGetEmailVerificationCodeByIDForUpdate(func(context.Context, sqlc.GetEmailVerificationCodeByIDForUpdateParams) (sqlc.EmailVerificationCode, error) {
+					return sqlc.EmailVerificationCode{ID: codeID, Email: "ana@acme.com"}, nil
+				}).
+				onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+					return sqlc.Team{ID: teamID, Domain: domain, JoinPolicy: joinPolicyOpen, DomainVerifiedAt: toTimestamptz(now)}, nil
+				}).
+				onGetTeamSsoProvider(func(_ context.Context, id pgtype.UUID) (sqlc.TeamSsoProvider, error) {
+					return sqlc.TeamSsoProvider{TeamID: id, Protocol: ssoProtocolOIDC, Required: tt.required}, nil
+				}).
+				onGetUserByEmail(func(_ context.Context, email string) (sqlc.User, error) {
+					return sqlc.User{ID: pgtype.UUID{Bytes: [16]byte{2}, Valid: true}, Email: email, EmailDomain: "acme.com"}, nil
+				}).
+				onGetTeamMembershipByUserID(func(_ context.Context, userID pgtype.UUID) (sqlc.TeamMembership, error) {
+					return sqlc.TeamMembership{TeamID: teamID, UserID: userID, Role: "member"}, nil
+				}).
+				onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+					return sqlc.Team{ID: id, Domain: "acme.com"}, nil
+				}).
+				onCreateAuthSession(func(context.Context, sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
+					session = true
+					return sqlc.AuthSession{}, nil
+				}).
+				build()
+			tx := &testTx{}
+			api := New(&stubStore{
+				querier: q,
+				beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+					return tx, nil
+				},
+			}, &mailer.LogMailer{}, Settings{
+				AccessTTL:      15 * time.Minute,
+				RefreshTTL:     24 * time.Hour,
+				LinkSigningKey: "secret",
+			}, nil)
+			api.clock = func() time.Time { return now }
+
+			rec := httptest.NewRecorder()
+			if tt.link {
+				link, _ := api.signInURL(sqlc.EmailVerificationCode{ID: codeID, ExpiresAt: toTimestamptz(now.Add(10 * time.Minute))}, "device-123")
+				token, _ := url.QueryUnescape(link[strings.Index(link, "token=")+len("token="):])
+				body, _ := json.Marshal(verifyLinkRequest{Token: token})
+				req := httptest.NewRequest(http.MethodPost, "/auth/verify-link", bytes.NewReader(body))
+				req.Header.Set("X-Device-Id", "device-123")
+				api.handleVerifyLink(rec, req)
+			} else {
+				body, _ := json.Marshal(verifyCodeRequest{Email: "ana@acme.com", Code: "ABCD2345"})
+				req := httptest.NewRequest(http.MethodPost, "/auth/verify-code", bytes.NewReader(body))
+				req.Header.Set("X-Device-Id", "device-123")
+				api.handleVerifyCode(rec, req)
+			}
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if wantSession := tt.wantStatus == http.StatusOK; session != wantSession || tx.committed != wantSession {
+				t.Fatalf("expected session=%v, got session=%v committed=%v", wantSession, session, tx.committed)
+			}
+		})
+	}
+}
+
+func TestHandleStartSSO(t *testing.T) {
+	provider := oidctest.NewProvider("timesync")
+	defer provider.Close()
+
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		email      string
+		deviceID   string
+		issuer     string
+		unverified bool
+		wantStatus int
+	}{
+		{name: "team with provider", email: "Ana@Acme.com", deviceID: "device-123", issuer: provider.Issuer, wantStatus: http.StatusOK},
+		{name: "domain without team", email: "ana@globex.com", deviceID: "device-123", issuer: provider.Issuer, wantStatus: http.StatusNotFound},
+		{name: "domain verification lapsed", email: "ana@acme.com", deviceID: "device-123", issuer: provider.Issuer, unverified: true, wantStatus: http.StatusNotFound},
+		{name: "provider unreachable", email: "ana@acme.com", deviceID: "device-123", issuer: "https://127.0.0.1:1", wantStatus: http.StatusBadGateway},
+		{name: "missing device", email: "ana@acme.com", issuer: provider.Issuer, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created sqlc.CreateSsoLoginParams
+			q := newQuerierBuilder().
+				onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+					if domain != "acme.com" {
+						return sqlc.Team{}, pgx.ErrNoRows
+					}
+					team := sqlc.Team{ID: teamID, Domain: domain}
+					if !tt.unverified {
+						team.DomainVerifiedAt = toTimestamptz(now)
+					}
+					return team, nil
+				}).
+				onGetTeamSsoProvider(func(_ context.Context, id pgtype.UUID) (sqlc.TeamSsoProvider, error) {
+					return sqlc.TeamSsoProvider{TeamID: id, Protocol: ssoProtocolOIDC, OidcIssuer: tt.issuer, OidcClientID: "timesync"}, nil
+				}).
+				onCreateSsoLogin(func(_ context.Context, arg sqlc.CreateSsoLoginParams) (sqlc.SsoLogin, error) {
+					created = arg
+					return sqlc.SsoLogin{TeamID: arg.TeamID}, nil
+				}).
+				build()
+			api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{PublicBaseURL: "https://timesync.example"}, nil)
+			api.clock = func() time.Time { return now }
+			api.oidc = oidcrp.New(provider.Client())
+
+			body, _ := json.Marshal(startSSORequest{Email: tt.email})
+			req := httptest.NewRequest(http.MethodPost, "/auth/sso/start", bytes.NewReader(body))
+			if tt.deviceID != "" {
+				req.Header.Set("X-Device-Id", tt.deviceID)
+			}
+			rec := httptest.NewRecorder()
+			api.handleStartSSO(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				if created.TeamID.Valid {
+					t.Fatalf("expected no login, got %+v", created)
+				}
+				return
+			}
+
+			var resp startSSOResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			authURL, err := url.Parse(resp.AuthorizationURL)
+			if err != nil || resp.Protocol != ssoProtocolOIDC || authURL.Scheme+"://"+authURL.Host != provider.Issuer {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+			query := authURL.Query()
+			if !hashEqual(created.StateHash, hashString(query.Get("state"))) || query.Get("nonce") != created.Nonce {
+				t.Fatalf("authorization url doesn't match the stored login: %s", resp.AuthorizationURL)
+			}
+			if query.Get("code_challenge") == "" || created.CodeVerifier == "" || query.Get("redirect_uri") != "https://timesync.example/auth/sso/oidc/callback" {
+				t.Fatalf("expected a PKCE request to the callback, got %s", resp.AuthorizationURL)
+			}
+			if created.TeamID != teamID || !hashEqual(created.DeviceIDHash, hashString("device-123")) || !created.ExpiresAt.Time.Equal(now.Add(ssoLoginTTL)) {
+				t.Fatalf("unexpected login: %+v", created)
+			}
+		})
+	}
+}
+
+func TestHandleCompleteSSO(t *testing.T) {
+	loginID := pgtype.UUID{Bytes: [16]byte{7}, Valid: true}
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	newQuerier := func(completed *bool, session *sqlc.CreateAuthSessionParams) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetSsoLoginByHandoffForUpdate(func(_ context.Context, arg sqlc.GetSsoLoginByHandoffForUpdateParams) (sqlc.SsoLogin, error) {
+				if !hashEqual(arg.HandoffTokenHash, hashString("handoff-token")) || !arg.ExpiresAt.Time.Equal(now) {
+					return sqlc.SsoLogin{}, pgx.ErrNoRows
+				}
+				return sqlc.SsoLogin{
+					ID:           loginID,
+					TeamID:       teamID,
+					DeviceIDHash: hashString("device-123"),
+					Email:        pgtype.Text{String: "ana@acme.com", Valid: true},
+				}, nil
+			}).
+			onCompleteSsoLogin(func(_ context.Context, arg sqlc.CompleteSsoLoginParams) error {
+				*completed = arg.ID == loginID && arg.CompletedAt.Time.Equal(now)
+				return nil
+			}).
+			onGetUserByEmail(func(_ context.Context, email string) (sqlc.User, error) {
+				return sqlc.User{ID: userID, Email: email, EmailDomain: "acme.com", EmailVerifiedAt: toTimestamptz(now)}, nil
+			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{TeamID: teamID, UserID: userID, Role: "member"}, nil
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "acme.com"}, nil
+			}).
+			onCreateAuthSession(func(_ context.Context, arg sqlc.CreateAuthSessionParams) (sqlc.AuthSession, error) {
+				*session = arg
+				return sqlc.AuthSession{}, nil
+			}).
+			build()
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		deviceID   string
+		wantStatus int
+	}{
+		{name: "same device", token: "handoff-token", deviceID: "device-123", wantStatus: http.StatusOK},
+		{name: "another device", token: "handoff-token", deviceID: "device-999", wantStatus: http.StatusUnauthorized},
+		{name: "unknown token", token: "forged", deviceID: "device-123", wantStatus: http.StatusUnauthorized},
+		{name: "missing device", token: "handoff-token", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var completed bool
+			var session sqlc.CreateAuthSessionParams
+			tx := &testTx{}
+			api := New(&stubStore{
+				querier: newQuerier(&completed, &session),
+				beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+					return tx, nil
+				},
+			}, &mailer.LogMailer{}, Settings{
+				AccessTTL:  15 * time.Minute,
+				RefreshTTL: 24 * time.Hour,
+			}, nil)
+			api.clock = func() time.Time { return now }
+
+			body, _ := json.Marshal(completeSSORequest{Token: tt.token, DeviceName: "Work Mac"})
+			req := httptest.NewRequest(http.MethodPost, "/auth/sso/complete", bytes.NewReader(body))
+			if tt.deviceID != "" {
+				req.Header.Set("X-Device-Id", tt.deviceID)
+			}
+			rec := httptest.NewRecorder()
+			api.handleCompleteSSO(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				if completed || tx.committed {
+					t.Fatal("expected the login to stay open")
+				}
+				return
+			}
+			if !completed || !tx.committed {
+				t.Fatalf("expected login completed and committed, got completed=%v committed=%v", completed, tx.committed)
+			}
+			if session.UserID != userID || !hashEqual(session.DeviceIDHash, hashString("device-123")) || session.DeviceName != "Work Mac" {
+				t.Fatalf("unexpected session: %+v", session)
+			}
+			var resp authResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.AccessToken == "" || resp.Status != joinStatusMember || resp.Team == nil {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
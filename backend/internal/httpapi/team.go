@@ -0,0 +1,426 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const maxTeamNameLength = 64
+
+type updateTeamRequest struct {
+	Name              *string `json:"name"`
+	IncludeSubdomains *bool   `json:"include_subdomains"`
+	JoinPolicy        *string `json:"join_policy"`
+}
+
+type joinTeamRequest struct {
+	InviteCode string `json:"invite_code"`
+}
+
+type rosterResponse struct {
+	Members []rosterMemberResponse `json:"members"`
+}
+
+type rosterMemberResponse struct {
+	UserID                  string     `json:"user_id"`
+	Name                    string     `json:"name"`
+	Email                   string     `json:"email"`
+	Role                    string     `json:"role"`
+	Timezone                string     `json:"timezone"`
+	ZoneLabel               string     `json:"zone_label"`
+	CountryCode             string     `json:"country_code"`
+	LocalTime               time.Time  `json:"local_time"`
+	UTCOffsetMinutes        int32      `json:"utc_offset_minutes"`
+	OffsetFromViewerMinutes int32      `json:"offset_from_viewer_minutes"`
+	OutsideWorkingHours     bool       `json:"outside_working_hours"`
+	IsSelf                  bool       `json:"is_self"`
+	LastReportedAt          time.Time  `json:"last_reported_at"`
+	Hidden                  bool       `json:"hidden"`
+	HiddenUntil             *time.Time `json:"hidden_until,omitempty"`
+	HiddenIndefinitely      bool       `json:"hidden_indefinitely"`
+}
+
+func (a *API) handleListTeamMembers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	// Hidden members are filtered out in the query itself; only admins get
+	// them back, greyed out with their last known zone.
+	role, _ := roleFromContext(ctx)
+	now := a.clock()
+	q := a.store.Querier()
+	rows, err := q.ListTeamRoster(ctx, sqlc.ListTeamRosterParams{
+		TeamID:        teamID,
+		IncludeHidden: role == "admin",
+		ViewerID:      userID,
+		Now:           toTimestamptz(now),
+	})
+	if err != nil {
+		a.logger.Error("failed to list team roster", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to list team members")
+		return
+	}
+
+	// Offsets come from the zone rules at read time so DST transitions show
+	// up without waiting for the next report.
+	var viewerOffset int32
+	viewer, err := q.GetTimezoneState(ctx, userID)
+	if err == nil {
+		viewerOffset = utcOffsetMinutes(locationForState(viewer.Timezone, viewer.UtcOffsetMinutes), now)
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusInternalServerError, "failed to list team members")
+		return
+	}
+
+	members := make([]rosterMemberResponse, 0, len(rows))
+	for _, row := range rows {
+		loc := locationForState(row.Timezone, row.UtcOffsetMinutes)
+		local := now.In(loc)
+		offset := utcOffsetMinutes(loc, now)
+		hidden := isHidden(row.HiddenUntil, row.HiddenIndefinitely.Bool, now)
+		member := rosterMemberResponse{
+			UserID:                  uuidString(row.UserID),
+			Name:                    displayName(row.Email),
+			Email:                   row.Email,
+			Role:                    row.Role,
+			Timezone:                row.Timezone,
+			ZoneLabel:               zoneLabel(local),
+			CountryCode:             row.CountryCode,
+			LocalTime:               local,
+			UTCOffsetMinutes:        offset,
+			OffsetFromViewerMinutes: offset - viewerOffset,
+			OutsideWorkingHours:     rosterOutsideWorkingHours(row, local),
+			IsSelf:                  row.UserID == userID,
+			LastReportedAt:          row.ReportedAt.Time,
+			Hidden:                  hidden,
+			HiddenIndefinitely:      hidden && row.HiddenIndefinitely.Bool,
+		}
+		if hidden && !row.HiddenIndefinitely.Bool {
+			hiddenUntil := row.HiddenUntil.Time
+			member.HiddenUntil = &hiddenUntil
+		}
+		members = append(members, member)
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		if members[i].Hidden != members[j].Hidden {
+			return !members[i].Hidden
+		}
+		if members[i].OffsetFromViewerMinutes != members[j].OffsetFromViewerMinutes {
+			return members[i].OffsetFromViewerMinutes < members[j].OffsetFromViewerMinutes
+		}
+		return members[i].Email < members[j].Email
+	})
+
+	writeJSON(w, http.StatusOK, rosterResponse{Members: members})
+}
+
+// handleUpdateTeam lets admins rename the team, choose whether addresses at
+// subdomains (eng.acme.com) join the acme.com team or get their own, and set
+// how domain users get in. Omitted fields keep their current value.
+func (a *API) handleUpdateTeam(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	var req updateTeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxTeamNameLength {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("name must be 1-%d characters", maxTeamNameLength))
+			return
+		}
+		req.Name = &name
+	}
+	if req.JoinPolicy != nil && !isJoinPolicy(*req.JoinPolicy) {
+		writeError(w, http.StatusBadRequest, "join_policy must be open, approval or invite_only")
+		return
+	}
+
+	var updated sqlc.Team
+	err := a.withTeamLock(ctx, teamID, func(q sqlc.Querier) error {
+		team, err := q.GetTeamByID(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		params := sqlc.UpdateTeamSettingsParams{
+			ID:                teamID,
+			Name:              team.Name,
+			IncludeSubdomains: team.IncludeSubdomains,
+			JoinPolicy:        team.JoinPolicy,
+		}
+		if req.Name != nil {
+			params.Name = *req.Name
+		}
+		if req.IncludeSubdomains != nil {
+			params.IncludeSubdomains = *req.IncludeSubdomains
+		}
+		if req.JoinPolicy != nil {
+			params.JoinPolicy = *req.JoinPolicy
+		}
+		updated, err = q.UpdateTeamSettings(ctx, params)
+		return err
+	})
+	if err != nil {
+		a.logger.Error("failed to update team", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to update team")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTeamResponse(updated))
+}
+
+// handleCreateTeam claims the caller's email domain for a new team when
+// verify-code reported choose_team. The creator becomes its admin.
+func (a *API) handleCreateTeam(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if _, ok := teamIDFromContext(ctx); ok {
+		writeError(w, http.StatusConflict, "already a member of a team")
+		return
+	}
+
+	tx, err := a.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	now := a.clock()
+	q := a.store.WithTx(tx)
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create team")
+		return
+	}
+	if a.freeMail.contains(user.EmailDomain) {
+		a.writeJoinError(w, errInviteRequired)
+		return
+	}
+	_, domain, err := findDomainTeam(ctx, q, user.EmailDomain)
+	if err == nil {
+		writeError(w, http.StatusConflict, "a team already exists for this domain")
+		return
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusInternalServerError, "failed to create team")
+		return
+	}
+	// A team that verified acme.com keeps its subdomains even when they
+	// don't share its roster.
+	if registrable := registrableDomain(domain); registrable != domain {
+		parent, err := q.GetTeamByDomain(ctx, registrable)
+		if err == nil && parent.DomainVerifiedAt.Valid {
+			writeError(w, http.StatusConflict, "domain is verified by another team")
+			return
+		}
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusInternalServerError, "failed to create team")
+			return
+		}
+	}
+
+	team, err := q.CreateTeam(ctx, sqlc.CreateTeamParams{
+		Domain: domain,
+		Name:   friendlyTeamName(domain),
+	})
+	if err != nil {
+		// Another team verified this domain as an alias in the meantime.
+		if isUniqueViolation(err) {
+			writeError(w, http.StatusConflict, "a team already exists for this domain")
+			return
+		}
+		a.logger.Error("failed to create team", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to create team")
+		return
+	}
+	if err := q.CreateTeamMembership(ctx, sqlc.CreateTeamMembershipParams{
+		TeamID:   team.ID,
+		UserID:   user.ID,
+		Role:     "admin",
+		JoinedAt: toTimestamptz(now),
+	}); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to create team")
+		return
+	}
+
+	a.commitBootstrap(ctx, w, tx, q, user, team, "admin", now, http.StatusCreated)
+}
+
+// handleJoinTeam redeems an invite for a signed-in user without a team, which
+// also bypasses a pending join request.
+func (a *API) handleJoinTeam(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := userIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req joinTeamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	inviteCode := normalizeCode(req.InviteCode)
+	if !isValidCode(inviteCode) {
+		writeError(w, http.StatusBadRequest, "invalid invite code format")
+		return
+	}
+
+	tx, err := a.store.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to start transaction")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	now := a.clock()
+	q := a.store.WithTx(tx)
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to join team")
+		return
+	}
+	team, role, err := redeemInvite(ctx, q, user, inviteCode, now, a.settings.TeamSizeLimit)
+	if err != nil {
+		a.writeJoinError(w, err)
+		return
+	}
+
+	a.commitBootstrap(ctx, w, tx, q, user, team, role, now, http.StatusOK)
+}
+
+// commitBootstrap loads the new member's bootstrap state inside tx, commits
+// and writes it. It reports whether tx was committed; on failure the error
+// response has already been written.
+func (a *API) commitBootstrap(ctx context.Context, w http.ResponseWriter, tx pgx.Tx, q sqlc.Querier, user sqlc.User, team sqlc.Team, role string, now time.Time, status int) bool {
+	resp, err := loadBootstrap(ctx, q, user, team, role, now)
+	if err != nil {
+		a.logger.Error("failed to load bootstrap state", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to load account")
+		return false
+	}
+	if err := tx.Commit(ctx); err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save membership")
+		return false
+	}
+	writeJSON(w, status, resp)
+	return true
+}
+
+func newTeamResponse(team sqlc.Team) teamResponse {
+	return teamResponse{
+		ID:                uuidString(team.ID),
+		Domain:            team.Domain,
+		Name:              team.Name,
+		IncludeSubdomains: team.IncludeSubdomains,
+		JoinPolicy:        team.JoinPolicy,
+		DomainVerified:    team.DomainVerifiedAt.Valid,
+	}
+}
+
+// locationForState resolves a stored zone, falling back to the offset captured
+// at report time if the server's tzdata no longer knows the name.
+func locationForState(name string, storedOffset int32) *time.Location {
+	if loc, ok := loadTimezone(name); ok {
+		return loc
+	}
+	return time.FixedZone(name, int(storedOffset)*60)
+}
+
+// zoneLabel returns the short zone abbreviation, rendering tzdb's numeric
+// abbreviations such as "+0530" as "UTC+5:30".
+func zoneLabel(local time.Time) string {
+	abbr, offset := local.Zone()
+	if abbr != "" && !strings.HasPrefix(abbr, "+") && !strings.HasPrefix(abbr, "-") {
+		return abbr
+	}
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	hours, minutes := offset/3600, (offset%3600)/60
+	if minutes == 0 {
+		return fmt.Sprintf("UTC%s%d", sign, hours)
+	}
+	return fmt.Sprintf("UTC%s%d:%02d", sign, hours, minutes)
+}
+
+func displayName(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return email
+	}
+	return email[:at]
+}
+
+// rosterOutsideWorkingHours applies the default schedule to members who never
+// saved one, as loadWorkingHours reports it to them.
+func rosterOutsideWorkingHours(row sqlc.ListTeamRosterRow, local time.Time) bool {
+	if !row.StartMinute.Valid || !row.EndMinute.Valid {
+		return outsideWorkingHours(local, defaultStartMinute, defaultEndMinute, defaultWorkingDays)
+	}
+	return outsideWorkingHours(local, row.StartMinute.Int32, row.EndMinute.Int32, row.WorkingDays)
+}
+
+// outsideWorkingHours reports whether local falls outside the schedule. A
+// window whose end is before its start crosses midnight and belongs to the
+// weekday it starts on, so a Friday 22:00-06:00 shift covers early Saturday.
+func outsideWorkingHours(local time.Time, startMinute, endMinute int32, workingDays []int32) bool {
+	minute := int32(local.Hour()*60 + local.Minute())
+	day := local.Weekday()
+	if startMinute < endMinute {
+		return !isWorkingDay(workingDays, day) || minute < startMinute || minute >= endMinute
+	}
+	if minute >= startMinute {
+		return !isWorkingDay(workingDays, day)
+	}
+	if minute < endMinute {
+		return !isWorkingDay(workingDays, (day+6)%7)
+	}
+	return true
+}
+
+func isWorkingDay(workingDays []int32, day time.Weekday) bool {
+	for _, d := range workingDays {
+		if d == int32(day) {
+			return true
+		}
+	}
+	return false
+}
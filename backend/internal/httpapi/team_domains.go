@@ -0,0 +1,409 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"timesync/backend/internal/domainverify"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// pgUniqueViolation is the SQLSTATE Postgres reports for a unique index
+// conflict.
+const pgUniqueViolation = "23505"
+
+var (
+	errTeamDomainUnverified    = errors.New("team domain not verified")
+	errPrimaryDomainUnverified = errors.New("primary domain not verified")
+)
+
+type addTeamDomainRequest struct {
+	Domain string `json:"domain"`
+}
+
+type teamDomainResponse struct {
+	Domain      string     `json:"domain"`
+	Primary     bool       `json:"primary"`
+	Verified    bool       `json:"verified"`
+	VerifiedAt  *time.Time `json:"verified_at,omitempty"`
+	RecordType  string     `json:"record_type,omitempty"`
+	RecordName  string     `json:"record_name,omitempty"`
+	RecordValue string     `json:"record_value,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type teamDomainListResponse struct {
+	Domains []teamDomainResponse `json:"domains"`
+}
+
+func (a *API) handleListTeamDomains(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	q := a.store.Querier()
+	team, err := q.GetTeamByID(ctx, teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to list domains")
+		return
+	}
+	rows, err := q.ListTeamDomains(ctx, teamID)
+	if err != nil {
+		a.logger.Error("failed to list team domains", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to list domains")
+		return
+	}
+
+	domains := make([]teamDomainResponse, 0, len(rows))
+	for _, row := range rows {
+		domains = append(domains, newTeamDomainResponse(row, team))
+	}
+	writeJSON(w, http.StatusOK, teamDomainListResponse{Domains: domains})
+}
+
+// handleAddTeamDomain starts adding an alias such as acme.io to the team.
+// Unlike the primary domain, which the creator's own address vouches for, an
+// alias only routes signups once its TXT record is published, so an admin
+// can't pull in the users of a domain they don't control.
+func (a *API) handleAddTeamDomain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	var req addTeamDomainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	host, ok := teamDomainHost(req.Domain)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid domain")
+		return
+	}
+	if a.freeMail.contains(host) {
+		writeError(w, http.StatusForbidden, "free mail domains can't belong to a team")
+		return
+	}
+
+	q := a.store.Querier()
+	if _, err := q.GetTeamDomain(ctx, sqlc.GetTeamDomainParams{TeamID: teamID, Domain: host}); err == nil {
+		writeError(w, http.StatusConflict, "domain already added")
+		return
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusInternalServerError, "failed to add domain")
+		return
+	}
+	if _, err := q.GetTeamByDomain(ctx, host); err == nil {
+		writeError(w, http.StatusConflict, "domain belongs to another team")
+		return
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		writeError(w, http.StatusInternalServerError, "failed to add domain")
+		return
+	}
+
+	team, err := q.GetTeamByID(ctx, teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to add domain")
+		return
+	}
+	token, err := domainverify.NewToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to add domain")
+		return
+	}
+	domain, err := q.CreateTeamDomain(ctx, sqlc.CreateTeamDomainParams{
+		TeamID:    teamID,
+		Domain:    host,
+		Token:     pgtype.Text{String: token, Valid: true},
+		CreatedAt: toTimestamptz(a.clock()),
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			writeError(w, http.StatusConflict, "domain already added")
+			return
+		}
+		a.logger.Error("failed to add team domain", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to add domain")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newTeamDomainResponse(domain, team))
+}
+
+// handleVerifyTeamDomain looks up an alias's TXT record and, once it is
+// published, starts routing the alias's signups to the team.
+func (a *API) handleVerifyTeamDomain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	q := a.store.Querier()
+	domain, team, ok := a.loadTeamDomain(w, r, q, teamID)
+	if !ok {
+		return
+	}
+	if domain.IsPrimary || domain.VerifiedAt.Valid {
+		writeJSON(w, http.StatusOK, newTeamDomainResponse(domain, team))
+		return
+	}
+
+	published, err := domainverify.Check(ctx, a.resolver, domain.Domain, domain.Token.String)
+	if err != nil {
+		a.logger.Warn("team domain lookup failed", slog.String("domain", domain.Domain), slog.Any("err", err))
+		writeError(w, http.StatusBadGateway, "failed to look up TXT record")
+		return
+	}
+	if published {
+		domain, err = q.VerifyTeamDomain(ctx, sqlc.VerifyTeamDomainParams{
+			ID:         domain.ID,
+			VerifiedAt: toTimestamptz(a.clock()),
+		})
+		if err != nil {
+			if isUniqueViolation(err) {
+				writeError(w, http.StatusConflict, "domain belongs to another team")
+				return
+			}
+			a.logger.Error("failed to verify team domain", slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to verify domain")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, newTeamDomainResponse(domain, team))
+}
+
+// handleSetPrimaryTeamDomain makes a verified alias the team's primary
+// domain; the old primary stays on as an alias. The team's verification moves
+// to the new primary's TXT record, and the old primary keeps routing signups
+// on the record that verified it. An unverified primary only routes signups
+// because it is primary, so it has to be verified before it can step down.
+func (a *API) handleSetPrimaryTeamDomain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+	host, ok := teamDomainHost(chi.URLParam(r, "domain"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid domain")
+		return
+	}
+
+	var updated sqlc.Team
+	err := a.withTeamLock(ctx, teamID, func(q sqlc.Querier) error {
+		team, err := q.GetTeamByID(ctx, teamID)
+		if err != nil {
+			return err
+		}
+		domain, err := q.GetTeamDomain(ctx, sqlc.GetTeamDomainParams{TeamID: teamID, Domain: host})
+		if err != nil {
+			return err
+		}
+		if domain.IsPrimary {
+			updated = team
+			return nil
+		}
+		if !domain.VerifiedAt.Valid {
+			return errTeamDomainUnverified
+		}
+		if !team.DomainVerifiedAt.Valid {
+			return errPrimaryDomainUnverified
+		}
+		demoted, err := demotedPrimaryDomain(ctx, q, team)
+		if err != nil {
+			return err
+		}
+
+		// The trigger turns the old primary row into an alias without a
+		// record of its own; give it the one that verified it.
+		updated, err = q.SetTeamPrimaryDomain(ctx, sqlc.SetTeamPrimaryDomainParams{
+			ID:     teamID,
+			Domain: domain.Domain,
+		})
+		if err != nil {
+			return err
+		}
+		if err := q.SetTeamDomainVerification(ctx, demoted); err != nil {
+			return err
+		}
+		if err := q.SetTeamDomainVerified(ctx, sqlc.SetTeamDomainVerifiedParams{
+			ID:               teamID,
+			DomainVerifiedAt: domain.VerifiedAt,
+		}); err != nil {
+			return err
+		}
+		if _, err := q.DeleteTeamDomainVerifications(ctx, sqlc.DeleteTeamDomainVerificationsParams{
+			TeamID: teamID,
+			Domain: team.Domain,
+		}); err != nil {
+			return err
+		}
+		updated.DomainVerifiedAt = domain.VerifiedAt
+		return nil
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			writeError(w, http.StatusNotFound, "domain not found")
+		case errors.Is(err, errTeamDomainUnverified):
+			writeError(w, http.StatusConflict, "domain must be verified first")
+		case errors.Is(err, errPrimaryDomainUnverified):
+			writeError(w, http.StatusConflict, "verify the current primary domain first")
+		default:
+			a.logger.Error("failed to set primary domain", slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to update domain")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTeamResponse(updated))
+}
+
+func (a *API) handleRemoveTeamDomain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+	host, ok := teamDomainHost(chi.URLParam(r, "domain"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid domain")
+		return
+	}
+
+	q := a.store.Querier()
+	deleted, err := q.DeleteTeamDomain(ctx, sqlc.DeleteTeamDomainParams{TeamID: teamID, Domain: host})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to remove domain")
+		return
+	}
+	if deleted == 0 {
+		// The delete skips the primary domain; tell that apart from a typo.
+		if _, err := q.GetTeamDomain(ctx, sqlc.GetTeamDomainParams{TeamID: teamID, Domain: host}); err == nil {
+			writeError(w, http.StatusConflict, "the primary domain can't be removed")
+			return
+		}
+		writeError(w, http.StatusNotFound, "domain not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadTeamDomain resolves the {domain} URL parameter to one of the team's
+// domains, writing the error response itself when it can't.
+func (a *API) loadTeamDomain(w http.ResponseWriter, r *http.Request, q sqlc.Querier, teamID pgtype.UUID) (sqlc.TeamDomain, sqlc.Team, bool) {
+	ctx := r.Context()
+	host, ok := teamDomainHost(chi.URLParam(r, "domain"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid domain")
+		return sqlc.TeamDomain{}, sqlc.Team{}, false
+	}
+	domain, err := q.GetTeamDomain(ctx, sqlc.GetTeamDomainParams{TeamID: teamID, Domain: host})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "domain not found")
+			return sqlc.TeamDomain{}, sqlc.Team{}, false
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load domain")
+		return sqlc.TeamDomain{}, sqlc.Team{}, false
+	}
+	team, err := q.GetTeamByID(ctx, teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load domain")
+		return sqlc.TeamDomain{}, sqlc.Team{}, false
+	}
+	return domain, team, true
+}
+
+// newTeamDomainResponse reports the primary domain as verified when the team
+// proved control of it; aliases carry their own verification and, until it
+// succeeds, the record to publish.
+func newTeamDomainResponse(domain sqlc.TeamDomain, team sqlc.Team) teamDomainResponse {
+	resp := teamDomainResponse{
+		Domain:    domain.Domain,
+		Primary:   domain.IsPrimary,
+		CreatedAt: domain.CreatedAt.Time,
+	}
+	verifiedAt := domain.VerifiedAt
+	if domain.IsPrimary {
+		verifiedAt = team.DomainVerifiedAt
+	}
+	if verifiedAt.Valid {
+		resp.Verified = true
+		at := verifiedAt.Time
+		resp.VerifiedAt = &at
+	} else if domain.Token.Valid {
+		resp.RecordType = "TXT"
+		resp.RecordName = domainverify.RecordName(domain.Domain)
+		resp.RecordValue = domainverify.RecordValue(domain.Token.String)
+	}
+	return resp
+}
+
+// demotedPrimaryDomain is the alias state the team's verified primary domain
+// keeps once another domain takes its place. A primary verified through
+// domain_verifications brings that claim's token along, so the recheck job
+// keeps checking the same record. Without a claim on file the domain keeps
+// routing under a fresh token until the job looks that record up.
+func demotedPrimaryDomain(ctx context.Context, q sqlc.Querier, team sqlc.Team) (sqlc.SetTeamDomainVerificationParams, error) {
+	primary, err := q.GetTeamDomain(ctx, sqlc.GetTeamDomainParams{TeamID: team.ID, Domain: team.Domain})
+	if err != nil {
+		return sqlc.SetTeamDomainVerificationParams{}, err
+	}
+	demoted := sqlc.SetTeamDomainVerificationParams{
+		ID:         primary.ID,
+		Token:      primary.Token,
+		VerifiedAt: primary.VerifiedAt,
+		CheckedAt:  primary.CheckedAt,
+	}
+	// A primary that was an alias before still has its own record.
+	if primary.Token.Valid {
+		return demoted, nil
+	}
+
+	demoted.VerifiedAt = team.DomainVerifiedAt
+	claim, err := q.GetVerifiedDomainVerification(ctx, sqlc.GetVerifiedDomainVerificationParams{
+		TeamID: team.ID,
+		Domain: team.Domain,
+	})
+	switch {
+	case err == nil:
+		demoted.Token = pgtype.Text{String: claim.Token, Valid: true}
+		demoted.CheckedAt = claim.CheckedAt
+	case errors.Is(err, pgx.ErrNoRows):
+		token, err := domainverify.NewToken()
+		if err != nil {
+			return sqlc.SetTeamDomainVerificationParams{}, err
+		}
+		demoted.Token = pgtype.Text{String: token, Valid: true}
+	default:
+		return sqlc.SetTeamDomainVerificationParams{}, err
+	}
+	return demoted, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation
+}
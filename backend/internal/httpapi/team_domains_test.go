@@ -0,0 +1,443 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestHandleAddTeamDomain(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	newQuerier := func(created *sqlc.CreateTeamDomainParams) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetTeamDomain(func(_ context.Context, arg sqlc.GetTeamDomainParams) (sqlc.TeamDomain, error) {
+				if arg.Domain == "acme.com" {
+					return sqlc.TeamDomain{TeamID: teamID, Domain: arg.Domain, IsPrimary: true}, nil
+				}
+				return sqlc.TeamDomain{}, pgx.ErrNoRows
+			}).
+			onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+				if domain == "globex.com" {
+					return sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{9}, Valid: true}, Domain: domain}, nil
+				}
+				return sqlc.Team{}, pgx.ErrNoRows
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "acme.com"}, nil
+			}).
+			onCreateTeamDomain(func(_ context.Context, arg sqlc.CreateTeamDomainParams) (sqlc.TeamDomain, error) {
+				*created = arg
+				return sqlc.TeamDomain{TeamID: arg.TeamID, Domain: arg.Domain, Token: arg.Token, CreatedAt: arg.CreatedAt}, nil
+			}).
+			build()
+	}
+
+	tests := []struct {
+		name       string
+		domain     string
+		wantStatus int
+	}{
+		{name: "adds alias", domain: "Acme.IO", wantStatus: http.StatusCreated},
+		{name: "invalid", domain: "co.uk", wantStatus: http.StatusBadRequest},
+		{name: "free mail", domain: "gmail.com", wantStatus: http.StatusForbidden},
+		{name: "already added", domain: "acme.com", wantStatus: http.StatusConflict},
+		{name: "other team", domain: "globex.com", wantStatus: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created sqlc.CreateTeamDomainParams
+			api := New(&stubStore{querier: newQuerier(&created)}, &mailer.LogMailer{}, Settings{}, nil)
+			api.clock = func() time.Time { return now }
+
+			body, _ := json.Marshal(addTeamDomainRequest{Domain: tt.domain})
+			req := authedRequest(http.MethodPost, "/team/domains", body, adminID, teamID, "admin")
+			rec := httptest.NewRecorder()
+			api.handleAddTeamDomain(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusCreated {
+				if created.Domain != "" {
+					t.Fatalf("unexpected domain: %+v", created)
+				}
+				return
+			}
+			if created.TeamID != teamID || created.Domain != "acme.io" || !created.Token.Valid || !created.CreatedAt.Time.Equal(now) {
+				t.Fatalf("unexpected domain: %+v", created)
+			}
+			var resp teamDomainResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Domain != "acme.io" || resp.Primary || resp.Verified || resp.RecordName != "_timesync.acme.io" || resp.RecordValue != "timesync-domain-verification="+created.Token.String {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestHandleVerifyTeamDomain(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	newQuerier := func(verifyErr error, verified *bool) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetTeamDomain(func(_ context.Context, arg sqlc.GetTeamDomainParams) (sqlc.TeamDomain, error) {
+				if arg.Domain != "acme.io" {
+					return sqlc.TeamDomain{}, pgx.ErrNoRows
+				}
+				return sqlc.TeamDomain{ID: pgtype.UUID{Bytes: [16]byte{5}, Valid: true}, TeamID: teamID, Domain: arg.Domain, Token: pgtype.Text{String: "tok", Valid: true}}, nil
+			}).
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: id, Domain: "acme.com"}, nil
+			}).
+			onVerifyTeamDomain(func(_ context.Context, arg sqlc.VerifyTeamDomainParams) (sqlc.TeamDomain, error) {
+				if verifyErr != nil {
+					return sqlc.TeamDomain{}, verifyErr
+				}
+				*verified = arg.VerifiedAt.Time.Equal(now)
+				return sqlc.TeamDomain{ID: arg.ID, TeamID: teamID, Domain: "acme.io", Token: pgtype.Text{String: "tok", Valid: true}, VerifiedAt: arg.VerifiedAt}, nil
+			}).
+			build()
+	}
+	published := map[string][]string{"_timesync.acme.io": {"timesync-domain-verification=tok"}}
+
+	tests := []struct {
+		name         string
+		domain       string
+		resolver     *fakeResolver
+		verifyErr    error
+		wantStatus   int
+		wantVerified bool
+	}{
+		{name: "published", domain: "acme.io", resolver: &fakeResolver{records: published}, wantStatus: http.StatusOK, wantVerified: true},
+		{name: "not published", domain: "acme.io", resolver: &fakeResolver{}, wantStatus: http.StatusOK},
+		{name: "lookup failure", domain: "acme.io", resolver: &fakeResolver{err: &net.DNSError{Err: "timeout", IsTimeout: true}}, wantStatus: http.StatusBadGateway},
+		{name: "claimed meanwhile", domain: "acme.io", resolver: &fakeResolver{records: published}, verifyErr: &pgconn.PgError{Code: pgUniqueViolation}, wantStatus: http.StatusConflict},
+		{name: "unknown domain", domain: "acme.dev", resolver: &fakeResolver{}, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verified bool
+			api := New(&stubStore{querier: newQuerier(tt.verifyErr, &verified)}, &mailer.LogMailer{}, Settings{}, nil)
+			api.clock = func() time.Time { return now }
+			api.resolver = tt.resolver
+
+			req := authedRequest(http.MethodPost, "/team/domains/"+tt.domain+"/verify", nil, adminID, teamID, "admin")
+			req = withURLParam(req, "domain", tt.domain)
+			rec := httptest.NewRecorder()
+			api.handleVerifyTeamDomain(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if verified != tt.wantVerified {
+				t.Fatalf("expected verified=%v", tt.wantVerified)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+			var resp teamDomainResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Verified != tt.wantVerified || (resp.RecordValue == "") != tt.wantVerified {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+// primaryDomainFake holds a team's domains the way Postgres does, with
+// SetTeamPrimaryDomain applying the sync_team_primary_domain trigger: it only
+// flips is_primary, leaving token and verified_at as they were.
+type primaryDomainFake struct {
+	team    sqlc.Team
+	domains map[string]*sqlc.TeamDomain
+	claims  []sqlc.DomainVerification
+}
+
+// routes mirrors GetTeamByDomain's filter.
+func (f *primaryDomainFake) routes(domain string) bool {
+	row, ok := f.domains[domain]
+	return ok && (row.IsPrimary || row.VerifiedAt.Valid)
+}
+
+func (f *primaryDomainFake) querier() sqlc.Querier {
+	return f.builder().build()
+}
+
+func (f *primaryDomainFake) builder() *querierBuilder {
+	return newQuerierBuilder().
+		onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+			if !f.routes(domain) {
+				return sqlc.Team{}, pgx.ErrNoRows
+			}
+			return f.team, nil
+		}).
+		onGetTeamByIDForUpdate(func(context.Context, pgtype.UUID) (sqlc.Team, error) {
+			return f.team, nil
+		}).
+		onGetTeamByID(func(context.Context, pgtype.UUID) (sqlc.Team, error) {
+			return f.team, nil
+		}).
+		onGetTeamDomain(func(_ context.Context, arg sqlc.GetTeamDomainParams) (sqlc.TeamDomain, error) {
+			row, ok := f.domains[arg.Domain]
+			if !ok {
+				return sqlc.TeamDomain{}, pgx.ErrNoRows
+			}
+			return *row, nil
+		}).
+		onGetVerifiedDomainVerification(func(_ context.Context, arg sqlc.GetVerifiedDomainVerificationParams) (sqlc.DomainVerification, error) {
+			for _, claim := range f.claims {
+				if claim.Domain == arg.Domain && claim.VerifiedAt.Valid {
+					return claim, nil
+				}
+			}
+			return sqlc.DomainVerification{}, pgx.ErrNoRows
+		}).
+		onSetTeamPrimaryDomain(func(_ context.Context, arg sqlc.SetTeamPrimaryDomainParams) (sqlc.Team, error) {
+			f.team.Domain = arg.Domain
+			for domain, row := range f.domains {
+				row.IsPrimary = domain == arg.Domain
+			}
+			return f.team, nil
+		}).
+		onSetTeamDomainVerification(func(_ context.Context, arg sqlc.SetTeamDomainVerificationParams) error {
+			for _, row := range f.domains {
+				if row.ID == arg.ID {
+					row.Token, row.VerifiedAt, row.CheckedAt = arg.Token, arg.VerifiedAt, arg.CheckedAt
+				}
+			}
+			return nil
+		}).
+		onSetTeamDomainVerified(func(_ context.Context, arg sqlc.SetTeamDomainVerifiedParams) error {
+			f.team.DomainVerifiedAt = arg.DomainVerifiedAt
+			return nil
+		}).
+		onDeleteTeamDomainVerifications(func(_ context.Context, arg sqlc.DeleteTeamDomainVerificationsParams) (int64, error) {
+			kept := f.claims[:0]
+			for _, claim := range f.claims {
+				if claim.Domain != arg.Domain {
+					kept = append(kept, claim)
+				}
+			}
+			deleted := int64(len(f.claims) - len(kept))
+			f.claims = kept
+			return deleted, nil
+		})
+}
+
+func TestHandleSetPrimaryTeamDomain(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamVerifiedAt := toTimestamptz(time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC))
+	aliasVerifiedAt := toTimestamptz(time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+
+	newFake := func(verified bool) *primaryDomainFake {
+		f := &primaryDomainFake{
+			team: sqlc.Team{ID: teamID, Domain: "acme.com"},
+			domains: map[string]*sqlc.TeamDomain{
+				"acme.com": {ID: pgtype.UUID{Bytes: [16]byte{10}, Valid: true}, TeamID: teamID, Domain: "acme.com", IsPrimary: true},
+				"acme.io":  {ID: pgtype.UUID{Bytes: [16]byte{11}, Valid: true}, TeamID: teamID, Domain: "acme.io", Token: pgtype.Text{String: "alias-token", Valid: true}, VerifiedAt: aliasVerifiedAt},
+				"acme.dev": {ID: pgtype.UUID{Bytes: [16]byte{12}, Valid: true}, TeamID: teamID, Domain: "acme.dev", Token: pgtype.Text{String: "pending-token", Valid: true}},
+			},
+		}
+		if verified {
+			f.team.DomainVerifiedAt = teamVerifiedAt
+			f.claims = []sqlc.DomainVerification{{TeamID: teamID, Domain: "acme.com", Token: "claim-token", VerifiedAt: teamVerifiedAt}}
+		}
+		return f
+	}
+
+	tests := []struct {
+		name         string
+		domain       string
+		verified     bool
+		wantStatus   int
+		wantPrimary  string
+		wantOldToken string
+	}{
+		{name: "verified primary keeps routing", domain: "acme.io", verified: true, wantStatus: http.StatusOK, wantPrimary: "acme.io", wantOldToken: "claim-token"},
+		{name: "unverified primary", domain: "acme.io", wantStatus: http.StatusConflict, wantPrimary: "acme.com"},
+		{name: "already primary", domain: "acme.com", verified: true, wantStatus: http.StatusOK, wantPrimary: "acme.com"},
+		{name: "unverified alias", domain: "acme.dev", verified: true, wantStatus: http.StatusConflict, wantPrimary: "acme.com"},
+		{name: "unknown domain", domain: "globex.com", verified: true, wantStatus: http.StatusNotFound, wantPrimary: "acme.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFake(tt.verified)
+			verifiedBefore, claimsBefore := f.team.DomainVerifiedAt, len(f.claims)
+			api := New(newTxStore(f.querier()), &mailer.LogMailer{}, Settings{}, nil)
+
+			req := authedRequest(http.MethodPost, "/team/domains/"+tt.domain+"/primary", nil, adminID, teamID, "admin")
+			req = withURLParam(req, "domain", tt.domain)
+			rec := httptest.NewRecorder()
+			api.handleSetPrimaryTeamDomain(rec, req)
+
+			if rec.Code != tt.wantStatus || f.team.Domain != tt.wantPrimary {
+				t.Fatalf("expected status %d and primary %q, got %d and %q", tt.wantStatus, tt.wantPrimary, rec.Code, f.team.Domain)
+			}
+			if tt.wantPrimary == "acme.com" {
+				if f.team.DomainVerifiedAt != verifiedBefore || len(f.claims) != claimsBefore {
+					t.Fatalf("expected verification untouched, got %+v and %d claims", f.team, len(f.claims))
+				}
+				return
+			}
+
+			old := f.domains["acme.com"]
+			if old.IsPrimary || !f.domains["acme.io"].IsPrimary || !f.routes("acme.io") {
+				t.Fatalf("expected acme.io to be the routing primary, got %+v", f.domains)
+			}
+			if f.team.DomainVerifiedAt != aliasVerifiedAt {
+				t.Fatalf("expected the team verified by the new primary's record, got %v", f.team.DomainVerifiedAt)
+			}
+			if len(f.claims) != 0 {
+				t.Fatalf("expected claims on the old primary to be dropped, got %+v", f.claims)
+			}
+			if !old.Token.Valid || old.Token.String != tt.wantOldToken {
+				t.Fatalf("expected the old primary to keep a record to check, got %+v", old)
+			}
+			if !f.routes("acme.com") || old.VerifiedAt != teamVerifiedAt {
+				t.Fatalf("expected the old primary to keep routing, got %+v", old)
+			}
+			var resp teamResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Domain != "acme.io" || !resp.DomainVerified {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+// TestHandleSetPrimaryTeamDomainOldDomainSignIn switches a verified team to
+// its alias and signs a new hire in at the original domain.
+func TestHandleSetPrimaryTeamDomainOldDomainSignIn(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	verifiedAt := toTimestamptz(time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC))
+	f := &primaryDomainFake{
+		team: sqlc.Team{ID: teamID, Domain: "acme.com", JoinPolicy: joinPolicyOpen, DomainVerifiedAt: verifiedAt},
+		domains: map[string]*sqlc.TeamDomain{
+			"acme.com": {ID: pgtype.UUID{Bytes: [16]byte{10}, Valid: true}, TeamID: teamID, Domain: "acme.com", IsPrimary: true},
+			"acme.io":  {ID: pgtype.UUID{Bytes: [16]byte{11}, Valid: true}, TeamID: teamID, Domain: "acme.io", Token: pgtype.Text{String: "alias-token", Valid: true}, VerifiedAt: verifiedAt},
+		},
+		claims: []sqlc.DomainVerification{{TeamID: teamID, Domain: "acme.com", Token: "claim-token", VerifiedAt: verifiedAt}},
+	}
+
+	api := New(newTxStore(f.querier()), &mailer.LogMailer{}, Settings{}, nil)
+	req := authedRequest(http.MethodPost, "/team/domains/acme.io/primary", nil, adminID, teamID, "admin")
+	req = withURLParam(req, "domain", "acme.io")
+	rec := httptest.NewRecorder()
+	api.handleSetPrimaryTeamDomain(rec, req)
+	if rec.Code != http.StatusOK || f.team.Domain != "acme.io" {
+		t.Fatalf("expected acme.io to become primary, got %d and %q", rec.Code, f.team.Domain)
+	}
+
+	var joined sqlc.CreateTeamMembershipParams
+	q := f.builder().
+		onGetEmailVerificationCode(func(context.Context, sqlc.GetEmailVerificationCodeParams) (sqlc.EmailVerificationCode, error) {
+			return sqlc.EmailVerificationCode{ID: pgtype.UUID{Bytes: [16]byte{20}, Valid: true}}, nil
+		}).
+		onGetUserByEmail(func(context.Context, string) (sqlc.User, error) {
+			return sqlc.User{}, pgx.ErrNoRows
+		}).
+		onCreateUser(func(_ context.Context, arg sqlc.CreateUserParams) (sqlc.User, error) {
+			return sqlc.User{ID: pgtype.UUID{Bytes: [16]byte{21}, Valid: true}, Email: arg.Email}, nil
+		}).
+		onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+			return sqlc.TeamMembership{}, pgx.ErrNoRows
+		}).
+		onGetTeamMembership(func(context.Context, sqlc.GetTeamMembershipParams) (sqlc.TeamMembership, error) {
+			return sqlc.TeamMembership{}, pgx.ErrNoRows
+		}).
+		onCountTeamMembers(func(context.Context, pgtype.UUID) (int64, error) {
+			return 1, nil
+		}).
+		onCreateTeamMembership(func(_ context.Context, arg sqlc.CreateTeamMembershipParams) error {
+			joined = arg
+			return nil
+		}).
+		build()
+	api = New(newTxStore(q), &mailer.LogMailer{}, Settings{
+		AccessTTL:             15 * time.Minute,
+		RefreshTTL:            24 * time.Hour,
+		VerifyCodeEmailLimit:  5,
+		VerifyCodeEmailWindow: 15 * time.Minute,
+		VerifyCodeLock:        15 * time.Minute,
+		TeamSizeLimit:         30,
+	}, nil)
+
+	body, _ := json.Marshal(verifyCodeRequest{Email: "new.hire@acme.com", Code: "ABCD2345"})
+	req = httptest.NewRequest(http.MethodPost, "/auth/verify-code", bytes.NewReader(body))
+	req.Header.Set("X-Device-Id", "device-123")
+	rec = httptest.NewRecorder()
+	api.handleVerifyCode(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp authResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Status != joinStatusMember || joined.TeamID != teamID {
+		t.Fatalf("expected the new hire to join the team, got status %q and %+v", resp.Status, joined)
+	}
+}
+
+func TestHandleRemoveTeamDomain(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+
+	q := newQuerierBuilder().
+		onDeleteTeamDomain(func(_ context.Context, arg sqlc.DeleteTeamDomainParams) (int64, error) {
+			if arg.TeamID == teamID && arg.Domain == "acme.io" {
+				return 1, nil
+			}
+			return 0, nil
+		}).
+		onGetTeamDomain(func(_ context.Context, arg sqlc.GetTeamDomainParams) (sqlc.TeamDomain, error) {
+			if arg.Domain == "acme.com" {
+				return sqlc.TeamDomain{TeamID: teamID, Domain: arg.Domain, IsPrimary: true}, nil
+			}
+			return sqlc.TeamDomain{}, pgx.ErrNoRows
+		}).
+		build()
+	api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+	tests := map[string]int{
+		"acme.io":    http.StatusNoContent,
+		"acme.com":   http.StatusConflict,
+		"globex.com": http.StatusNotFound,
+	}
+	for domain, want := range tests {
+		req := authedRequest(http.MethodDelete, "/team/domains/"+domain, nil, adminID, teamID, "admin")
+		req = withURLParam(req, "domain", domain)
+		rec := httptest.NewRecorder()
+		api.handleRemoveTeamDomain(rec, req)
+
+		if rec.Code != want {
+			t.Fatalf("%s: expected status %d, got %d", domain, want, rec.Code)
+		}
+	}
+}
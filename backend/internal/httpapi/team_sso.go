@@ -0,0 +1,198 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"timesync/backend/internal/samlsp"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type teamSSORequest struct {
+	Protocol string `json:"protocol"`
+	Issuer   string `json:"issuer,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	// ClientSecret keeps the stored secret when omitted; an empty string
+	// clears it for public clients.
+	ClientSecret *string `json:"client_secret,omitempty"`
+	// IDPMetadata is the SAML identity provider's metadata XML.
+	IDPMetadata string `json:"idp_metadata,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+type teamSSOResponse struct {
+	Protocol        string    `json:"protocol"`
+	Issuer          string    `json:"issuer,omitempty"`
+	ClientID        string    `json:"client_id,omitempty"`
+	HasClientSecret bool      `json:"has_client_secret"`
+	RedirectURL     string    `json:"redirect_url,omitempty"`
+	IDPEntityID     string    `json:"idp_entity_id,omitempty"`
+	SPEntityID      string    `json:"sp_entity_id,omitempty"`
+	ACSURL          string    `json:"acs_url,omitempty"`
+	Required        bool      `json:"required"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (a *API) handleGetTeamSSO(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	provider, err := a.store.Querier().GetTeamSsoProvider(ctx, teamID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusNotFound, "single sign-on not set up")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "failed to load single sign-on")
+		return
+	}
+	writeJSON(w, http.StatusOK, a.newTeamSSOResponse(provider))
+}
+
+// handlePutTeamSSO sets the team's identity provider. An OIDC issuer has to
+// serve a matching discovery document before it is saved; SAML metadata has
+// to name a signing certificate and an https sign-on service. Only teams
+// that proved control of their domain may vouch for its addresses this way.
+func (a *API) handlePutTeamSSO(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	var req teamSSORequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	params := sqlc.UpsertTeamSsoProviderParams{
+		TeamID:    teamID,
+		Protocol:  req.Protocol,
+		Required:  req.Required,
+		CreatedAt: toTimestamptz(a.clock()),
+	}
+	switch req.Protocol {
+	case ssoProtocolOIDC:
+		params.OidcIssuer = strings.TrimSpace(req.Issuer)
+		if !isHTTPSURL(params.OidcIssuer) {
+			writeError(w, http.StatusBadRequest, "issuer must be an https URL")
+			return
+		}
+		params.OidcClientID = strings.TrimSpace(req.ClientID)
+		if params.OidcClientID == "" {
+			writeError(w, http.StatusBadRequest, "client_id is required")
+			return
+		}
+	case ssoProtocolSAML:
+		metadata, err := samlsp.ParseMetadata([]byte(req.IDPMetadata))
+		if err != nil {
+			a.logger.Warn("saml metadata rejected", slog.String("team_id", uuidString(teamID)), slog.Any("err", err))
+			writeError(w, http.StatusBadRequest, "idp_metadata must be identity provider metadata with a signing certificate and an https sign-on service")
+			return
+		}
+		params.SamlIdpEntityID = metadata.EntityID
+		params.SamlIdpMetadata = req.IDPMetadata
+	default:
+		writeError(w, http.StatusBadRequest, "unsupported protocol")
+		return
+	}
+
+	q := a.store.Querier()
+	team, err := q.GetTeamByID(ctx, teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to save single sign-on")
+		return
+	}
+	if !team.DomainVerifiedAt.Valid {
+		writeError(w, http.StatusConflict, "verify the team domain first")
+		return
+	}
+
+	if req.Protocol == ssoProtocolOIDC {
+		if req.ClientSecret != nil {
+			params.OidcClientSecret = *req.ClientSecret
+		} else if existing, err := q.GetTeamSsoProvider(ctx, teamID); err == nil {
+			if existing.Protocol == ssoProtocolOIDC {
+				params.OidcClientSecret = existing.OidcClientSecret
+			}
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			writeError(w, http.StatusInternalServerError, "failed to save single sign-on")
+			return
+		}
+
+		if err := a.oidc.Discover(ctx, params.OidcIssuer); err != nil {
+			a.logger.Warn("oidc discovery failed", slog.String("issuer", params.OidcIssuer), slog.Any("err", err))
+			writeError(w, http.StatusBadRequest, "could not load the issuer's OpenID configuration")
+			return
+		}
+	}
+
+	provider, err := q.UpsertTeamSsoProvider(ctx, params)
+	if err != nil {
+		a.logger.Error("failed to save team sso", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to save single sign-on")
+		return
+	}
+	writeJSON(w, http.StatusOK, a.newTeamSSOResponse(provider))
+}
+
+// handleDeleteTeamSSO removes the identity provider, which turns code
+// sign-in back on for the domain.
+func (a *API) handleDeleteTeamSSO(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	teamID, ok := teamIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusForbidden, "team membership required")
+		return
+	}
+
+	rows, err := a.store.Querier().DeleteTeamSsoProvider(ctx, teamID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to remove single sign-on")
+		return
+	}
+	if rows == 0 {
+		writeError(w, http.StatusNotFound, "single sign-on not set up")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) newTeamSSOResponse(provider sqlc.TeamSsoProvider) teamSSOResponse {
+	resp := teamSSOResponse{
+		Protocol:  provider.Protocol,
+		Required:  provider.Required,
+		CreatedAt: provider.CreatedAt.Time,
+		UpdatedAt: provider.UpdatedAt.Time,
+	}
+	switch provider.Protocol {
+	case ssoProtocolOIDC:
+		resp.Issuer = provider.OidcIssuer
+		resp.ClientID = provider.OidcClientID
+		resp.HasClientSecret = provider.OidcClientSecret != ""
+		resp.RedirectURL = a.oidcRedirectURL()
+	case ssoProtocolSAML:
+		resp.IDPEntityID = provider.SamlIdpEntityID
+		resp.SPEntityID = a.samlEntityID()
+		resp.ACSURL = a.samlACSURL()
+	}
+	return resp
+}
+
+func isHTTPSURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme == "https" && u.Host != "" && u.User == nil && u.RawQuery == "" && u.Fragment == ""
+}
@@ -0,0 +1,244 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/oidcrp"
+	"timesync/backend/internal/oidcrp/oidctest"
+	"timesync/backend/internal/samlsp/samltest"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestHandlePutTeamSSO(t *testing.T) {
+	provider := oidctest.NewProvider("timesync")
+	defer provider.Close()
+
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+	secret := func(s string) *string { return &s }
+
+	newQuerier := func(verified, existing bool, saved *sqlc.UpsertTeamSsoProviderParams) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+				team := sqlc.Team{ID: id, Domain: "acme.com"}
+				if verified {
+					team.DomainVerifiedAt = toTimestamptz(now)
+				}
+				return team, nil
+			}).
+			onGetTeamSsoProvider(func(_ context.Context, id pgtype.UUID) (sqlc.TeamSsoProvider, error) {
+				if !existing {
+					return sqlc.TeamSsoProvider{}, pgx.ErrNoRows
+				}
+				return sqlc.TeamSsoProvider{TeamID: id, Protocol: ssoProtocolOIDC, OidcClientSecret: "stored"}, nil
+			}).
+			onUpsertTeamSsoProvider(func(_ context.Context, arg sqlc.UpsertTeamSsoProviderParams) (sqlc.TeamSsoProvider, error) {
+				*saved = arg
+				return sqlc.TeamSsoProvider{
+					TeamID:           arg.TeamID,
+					Protocol:         arg.Protocol,
+					OidcIssuer:       arg.OidcIssuer,
+					OidcClientID:     arg.OidcClientID,
+					OidcClientSecret: arg.OidcClientSecret,
+					Required:         arg.Required,
+				}, nil
+			}).
+			build()
+	}
+
+	tests := []struct {
+		name       string
+		req        teamSSORequest
+		unverified bool
+		existing   bool
+		wantStatus int
+		wantSecret string
+	}{
+		{name: "public client", req: teamSSORequest{Protocol: "oidc", Issuer: provider.Issuer, ClientID: "timesync", Required: true}, wantStatus: http.StatusOK},
+		{name: "keeps stored secret", req: teamSSORequest{Protocol: "oidc", Issuer: provider.Issuer, ClientID: "timesync"}, existing: true, wantStatus: http.StatusOK, wantSecret: "stored"},
+		{name: "replaces secret", req: teamSSORequest{Protocol: "oidc", Issuer: provider.Issuer, ClientID: "timesync", ClientSecret: secret("fresh")}, existing: true, wantStatus: http.StatusOK, wantSecret: "fresh"},
+		{name: "unverified domain", req: teamSSORequest{Protocol: "oidc", Issuer: provider.Issuer, ClientID: "timesync"}, unverified: true, wantStatus: http.StatusConflict},
+		{name: "plain http issuer", req: teamSSORequest{Protocol: "oidc", Issuer: "http://idp.acme.com", ClientID: "timesync"}, wantStatus: http.StatusBadRequest},
+		{name: "missing client id", req: teamSSORequest{Protocol: "oidc", Issuer: provider.Issuer}, wantStatus: http.StatusBadRequest},
+		{name: "unknown protocol", req: teamSSORequest{Protocol: "cas", Issuer: provider.Issuer, ClientID: "timesync"}, wantStatus: http.StatusBadRequest},
+		{name: "issuer without discovery", req: teamSSORequest{Protocol: "oidc", Issuer: provider.Issuer + "/tenant", ClientID: "timesync"}, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var saved sqlc.UpsertTeamSsoProviderParams
+			api := New(&stubStore{querier: newQuerier(!tt.unverified, tt.existing, &saved)}, &mailer.LogMailer{}, Settings{
+				PublicBaseURL: "https://timesync.example/",
+			}, nil)
+			api.clock = func() time.Time { return now }
+			api.oidc = oidcrp.New(provider.Client())
+
+			body, _ := json.Marshal(tt.req)
+			req := authedRequest(http.MethodPut, "/team/sso", body, adminID, teamID, "admin")
+			rec := httptest.NewRecorder()
+			api.handlePutTeamSSO(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				if saved.TeamID.Valid {
+					t.Fatalf("expected nothing saved, got %+v", saved)
+				}
+				return
+			}
+			if saved.TeamID != teamID || saved.OidcIssuer != provider.Issuer || saved.OidcClientSecret != tt.wantSecret || saved.Required != tt.req.Required {
+				t.Fatalf("unexpected saved provider: %+v", saved)
+			}
+			var resp teamSSOResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.RedirectURL != "https://timesync.example/auth/sso/oidc/callback" || resp.HasClientSecret != (tt.wantSecret != "") {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestHandlePutTeamSSOSAML(t *testing.T) {
+	idp := samltest.NewProvider()
+	defer idp.Close()
+	metadata := string(idp.Metadata())
+
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		metadata   string
+		wantStatus int
+	}{
+		{name: "provider metadata", metadata: metadata, wantStatus: http.StatusOK},
+		{name: "without signing certificate", metadata: strings.ReplaceAll(metadata, "X509Certificate", "X509Subject"), wantStatus: http.StatusBadRequest},
+		{name: "not metadata", metadata: "<html></html>", wantStatus: http.StatusBadRequest},
+		{name: "missing", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var saved sqlc.UpsertTeamSsoProviderParams
+			q := newQuerierBuilder().
+				onGetTeamByID(func(_ context.Context, id pgtype.UUID) (sqlc.Team, error) {
+					return sqlc.Team{ID: id, Domain: "acme.com", DomainVerifiedAt: toTimestamptz(now)}, nil
+				}).
+				onUpsertTeamSsoProvider(func(_ context.Context, arg sqlc.UpsertTeamSsoProviderParams) (sqlc.TeamSsoProvider, error) {
+					saved = arg
+					return sqlc.TeamSsoProvider{
+						TeamID:          arg.TeamID,
+						Protocol:        arg.Protocol,
+						SamlIdpEntityID: arg.SamlIdpEntityID,
+						SamlIdpMetadata: arg.SamlIdpMetadata,
+						Required:        arg.Required,
+					}, nil
+				}).
+				build()
+			api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{
+				PublicBaseURL: "https://timesync.example",
+			}, nil)
+			api.clock = func() time.Time { return now }
+
+			body, _ := json.Marshal(teamSSORequest{Protocol: "saml", IDPMetadata: tt.metadata, Required: true})
+			rec := httptest.NewRecorder()
+			api.handlePutTeamSSO(rec, authedRequest(http.MethodPut, "/team/sso", body, adminID, teamID, "admin"))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if rec.Code != http.StatusOK {
+				if saved.TeamID.Valid {
+					t.Fatalf("expected nothing saved, got %+v", saved)
+				}
+				return
+			}
+			if saved.Protocol != ssoProtocolSAML || saved.SamlIdpEntityID != idp.EntityID || saved.SamlIdpMetadata != metadata || saved.OidcIssuer != "" || !saved.Required {
+				t.Fatalf("unexpected saved provider: %+v", saved)
+			}
+			var resp teamSSOResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.IDPEntityID != idp.EntityID || resp.SPEntityID != "https://timesync.example/auth/sso/saml/metadata" ||
+				resp.ACSURL != "https://timesync.example/auth/sso/saml/acs" || resp.RedirectURL != "" {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+}
+
+func TestHandleGetTeamSSO(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+
+	for _, configured := range []bool{true, false} {
+		q := newQuerierBuilder().
+			onGetTeamSsoProvider(func(_ context.Context, id pgtype.UUID) (sqlc.TeamSsoProvider, error) {
+				if !configured {
+					return sqlc.TeamSsoProvider{}, pgx.ErrNoRows
+				}
+				return sqlc.TeamSsoProvider{TeamID: id, Protocol: ssoProtocolOIDC, OidcIssuer: "https://idp.acme.com", OidcClientID: "timesync", OidcClientSecret: "s3cret"}, nil
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := httptest.NewRecorder()
+		api.handleGetTeamSSO(rec, authedRequest(http.MethodGet, "/team/sso", nil, adminID, teamID, "admin"))
+
+		if !configured {
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("expected status 404, got %d", rec.Code)
+			}
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "s3cret") || !strings.Contains(rec.Body.String(), `"has_client_secret":true`) {
+			t.Fatalf("expected the secret to stay hidden, got %s", rec.Body.String())
+		}
+	}
+}
+
+func TestHandleDeleteTeamSSO(t *testing.T) {
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+
+	for _, tt := range []struct {
+		rows       int64
+		wantStatus int
+	}{
+		{rows: 1, wantStatus: http.StatusNoContent},
+		{rows: 0, wantStatus: http.StatusNotFound},
+	} {
+		var deleted pgtype.UUID
+		q := newQuerierBuilder().
+			onDeleteTeamSsoProvider(func(_ context.Context, id pgtype.UUID) (int64, error) {
+				deleted = id
+				return tt.rows, nil
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		rec := httptest.NewRecorder()
+		api.handleDeleteTeamSSO(rec, authedRequest(http.MethodDelete, "/team/sso", nil, adminID, teamID, "admin"))
+
+		if rec.Code != tt.wantStatus || deleted != teamID {
+			t.Fatalf("expected status %d for team, got %d for %v", tt.wantStatus, rec.Code, deleted)
+		}
+	}
+}
@@ -0,0 +1,698 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestZoneLabel(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]string{
+		"Europe/Berlin":  "CEST",
+		"Asia/Kolkata":   "IST",
+		"Asia/Dubai":     "UTC+4",
+		"Asia/Kathmandu": "UTC+5:45",
+		"America/Bogota": "UTC-5",
+	}
+	for zone, want := range tests {
+		loc, _ := loadTimezone(zone)
+		if got := zoneLabel(now.In(loc)); got != want {
+			t.Fatalf("expected %q for %s, got %q", want, zone, got)
+		}
+	}
+}
+
+func TestLocationForStateFallback(t *testing.T) {
+	loc := locationForState("Gone/Zone", 90)
+	if got := utcOffsetMinutes(loc, time.Now()); got != 90 {
+		t.Fatalf("expected fallback offset 90, got %d", got)
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	if got := displayName("jane.doe@example.com"); got != "jane.doe" {
+		t.Fatalf("unexpected display name: %q", got)
+	}
+	if got := displayName("no-at"); got != "no-at" {
+		t.Fatalf("unexpected display name: %q", got)
+	}
+}
+
+func TestOutsideWorkingHours(t *testing.T) {
+	weekdays := []int32{1, 2, 3, 4, 5}
+	// Friday-Saturday weekend.
+	sundayToThursday := []int32{0, 1, 2, 3, 4}
+	monday := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	friday := time.Date(2024, 7, 5, 10, 0, 0, 0, time.UTC)
+	saturday := time.Date(2024, 7, 6, 10, 0, 0, 0, time.UTC)
+	sunday := time.Date(2024, 7, 7, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		local time.Time
+		start int32
+		end   int32
+		days  []int32
+		want  bool
+	}{
+		{"inside day window", monday, 9 * 60, 17 * 60, weekdays, false},
+		{"after day window", monday.Add(8 * time.Hour), 9 * 60, 17 * 60, weekdays, true},
+		{"end is exclusive", monday.Add(7 * time.Hour), 9 * 60, 17 * 60, weekdays, true},
+		{"weekend day", saturday, 9 * 60, 17 * 60, weekdays, true},
+		{"friday off", friday, 9 * 60, 17 * 60, sundayToThursday, true},
+		{"sunday on", sunday, 9 * 60, 17 * 60, sundayToThursday, false},
+		{"no working days", monday, 9 * 60, 17 * 60, nil, true},
+		{"overnight evening", monday.Add(13 * time.Hour), 22 * 60, 6 * 60, weekdays, false},
+		{"overnight morning after working day", monday.Add(-5 * time.Hour), 22 * 60, 6 * 60, weekdays, true},
+		{"overnight morning belongs to previous day", saturday.Add(-5 * time.Hour), 22 * 60, 6 * 60, weekdays, false},
+		{"overnight gap", monday, 22 * 60, 6 * 60, weekdays, true},
+		{"overnight starting on day off", saturday.Add(13 * time.Hour), 22 * 60, 6 * 60, weekdays, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := outsideWorkingHours(tt.local, tt.start, tt.end, tt.days); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandleListTeamMembers(t *testing.T) {
+	viewerID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	tokyoID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	nyID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	// 08:00 UTC on a Monday: 10:00 in Berlin, 17:00 in Tokyo, 04:00 in New York.
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	roster := []sqlc.ListTeamRosterRow{
+		{
+			UserID:      tokyoID,
+			Email:       "kenji@example.com",
+			Role:        "member",
+			Timezone:    "Asia/Tokyo",
+			CountryCode: "JP",
+			StartMinute: pgtype.Int4{Int32: 9 * 60, Valid: true},
+			EndMinute:   pgtype.Int4{Int32: 18 * 60, Valid: true},
+			WorkingDays: []int32{1, 2, 3, 4, 5},
+		},
+		{
+			UserID:      viewerID,
+			Email:       "anna@example.com",
+			Role:        "admin",
+			Timezone:    "Europe/Berlin",
+			CountryCode: "DE",
+		},
+		{
+			UserID: nyID,
+			Email:  "sam@example.com",
+			Role:   "member",
+			// Stored offset is stale winter time; the response must use EDT.
+			// No saved schedule, so the default 09:00-17:00 applies.
+			Timezone:         "America/New_York",
+			UtcOffsetMinutes: -300,
+			CountryCode:      "US",
+		},
+	}
+
+	t.Run("sorted by offset from viewer", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onListTeamRoster(func(_ context.Context, arg sqlc.ListTeamRosterParams) ([]sqlc.ListTeamRosterRow, error) {
+				if arg.TeamID != teamID {
+					t.Fatalf("unexpected team id: %v", arg.TeamID)
+				}
+				if !arg.Now.Time.Equal(now) || arg.ViewerID != viewerID || !arg.IncludeHidden {
+					t.Fatalf("unexpected params: %+v", arg)
+				}
+				return roster, nil
+			}).
+			onGetTimezoneState(func(_ context.Context, id pgtype.UUID) (sqlc.TimezoneState, error) {
+				if id != viewerID {
+					t.Fatalf("unexpected viewer id: %v", id)
+				}
+				return sqlc.TimezoneState{Timezone: "Europe/Berlin"}, nil
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+
+		req := authedRequest(http.MethodGet, "/team/members", nil, viewerID, teamID, "admin")
+		rec := httptest.NewRecorder()
+
+		api.handleListTeamMembers(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var resp rosterResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if len(resp.Members) != 3 {
+			t.Fatalf("expected 3 members, got %d", len(resp.Members))
+		}
+
+		ny, viewer, tokyo := resp.Members[0], resp.Members[1], resp.Members[2]
+		if ny.Email != "sam@example.com" || viewer.Email != "anna@example.com" || tokyo.Email != "kenji@example.com" {
+			t.Fatalf("unexpected order: %q, %q, %q", ny.Email, viewer.Email, tokyo.Email)
+		}
+		if ny.UTCOffsetMinutes != -240 || ny.OffsetFromViewerMinutes != -360 {
+			t.Fatalf("unexpected new york offsets: %d/%d", ny.UTCOffsetMinutes, ny.OffsetFromViewerMinutes)
+		}
+		if ny.ZoneLabel != "EDT" || !ny.OutsideWorkingHours {
+			t.Fatalf("unexpected new york entry: %+v", ny)
+		}
+		if !viewer.IsSelf || viewer.OffsetFromViewerMinutes != 0 || viewer.OutsideWorkingHours {
+			t.Fatalf("unexpected viewer entry: %+v", viewer)
+		}
+		if tokyo.OffsetFromViewerMinutes != 420 || tokyo.Name != "kenji" || tokyo.OutsideWorkingHours {
+			t.Fatalf("unexpected tokyo entry: %+v", tokyo)
+		}
+		if tokyo.LocalTime.Hour() != 17 {
+			t.Fatalf("expected tokyo local hour 17, got %d", tokyo.LocalTime.Hour())
+		}
+	})
+
+	t.Run("members do not ask for hidden teammates", func(t *testing.T) {
+		var params sqlc.ListTeamRosterParams
+		q := newQuerierBuilder().
+			onListTeamRoster(func(_ context.Context, arg sqlc.ListTeamRosterParams) ([]sqlc.ListTeamRosterRow, error) {
+				params = arg
+				return nil, nil
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+
+		req := authedRequest(http.MethodGet, "/team/members", nil, viewerID, teamID, "member")
+		rec := httptest.NewRecorder()
+
+		api.handleListTeamMembers(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if params.IncludeHidden {
+			t.Fatal("expected hidden members to be excluded for members")
+		}
+	})
+
+	t.Run("admins see hidden members", func(t *testing.T) {
+		hiddenUntil := now.Add(7 * 24 * time.Hour)
+		rows := []sqlc.ListTeamRosterRow{
+			{
+				UserID:      tokyoID,
+				Email:       "kenji@example.com",
+				Timezone:    "Asia/Tokyo",
+				ReportedAt:  pgtype.Timestamptz{Time: now.Add(-time.Hour), Valid: true},
+				HiddenUntil: pgtype.Timestamptz{Time: hiddenUntil, Valid: true},
+			},
+			{
+				UserID:             nyID,
+				Email:              "sam@example.com",
+				Timezone:           "America/New_York",
+				HiddenIndefinitely: pgtype.Bool{Bool: true, Valid: true},
+			},
+			{
+				// An expired hide no longer counts.
+				UserID:      viewerID,
+				Email:       "anna@example.com",
+				Timezone:    "Europe/Berlin",
+				HiddenUntil: pgtype.Timestamptz{Time: now.Add(-time.Minute), Valid: true},
+			},
+		}
+		q := newQuerierBuilder().
+			onListTeamRoster(func(context.Context, sqlc.ListTeamRosterParams) ([]sqlc.ListTeamRosterRow, error) {
+				return rows, nil
+			}).
+			onGetTimezoneState(func(context.Context, pgtype.UUID) (sqlc.TimezoneState, error) {
+				return sqlc.TimezoneState{}, pgx.ErrNoRows
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+
+		req := authedRequest(http.MethodGet, "/team/members", nil, viewerID, teamID, "admin")
+		rec := httptest.NewRecorder()
+
+		api.handleListTeamMembers(rec, req)
+
+		var resp rosterResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		// Hidden members sink to the bottom, still ordered by offset.
+		viewer, ny, tokyo := resp.Members[0], resp.Members[1], resp.Members[2]
+		if viewer.Email != "anna@example.com" || ny.Email != "sam@example.com" || tokyo.Email != "kenji@example.com" {
+			t.Fatalf("unexpected order: %q, %q, %q", viewer.Email, ny.Email, tokyo.Email)
+		}
+		if !tokyo.Hidden || tokyo.HiddenUntil == nil || !tokyo.HiddenUntil.Equal(hiddenUntil) || tokyo.HiddenIndefinitely {
+			t.Fatalf("unexpected tokyo entry: %+v", tokyo)
+		}
+		if !tokyo.LastReportedAt.Equal(now.Add(-time.Hour)) || tokyo.LocalTime.Hour() != 17 {
+			t.Fatalf("expected last known time for hidden member: %+v", tokyo)
+		}
+		if !ny.Hidden || !ny.HiddenIndefinitely || ny.HiddenUntil != nil {
+			t.Fatalf("unexpected new york entry: %+v", ny)
+		}
+		if viewer.Hidden || viewer.HiddenUntil != nil {
+			t.Fatalf("expected expired hide to be visible: %+v", viewer)
+		}
+	})
+
+	t.Run("viewer without timezone sorts by utc offset", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onListTeamRoster(func(context.Context, sqlc.ListTeamRosterParams) ([]sqlc.ListTeamRosterRow, error) {
+				return roster[:1], nil
+			}).
+			onGetTimezoneState(func(context.Context, pgtype.UUID) (sqlc.TimezoneState, error) {
+				return sqlc.TimezoneState{}, pgx.ErrNoRows
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+
+		req := authedRequest(http.MethodGet, "/team/members", nil, viewerID, teamID, "member")
+		rec := httptest.NewRecorder()
+
+		api.handleListTeamMembers(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var resp rosterResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if len(resp.Members) != 1 || resp.Members[0].OffsetFromViewerMinutes != 540 {
+			t.Fatalf("unexpected members: %+v", resp.Members)
+		}
+	})
+
+	t.Run("empty roster", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetTimezoneState(func(context.Context, pgtype.UUID) (sqlc.TimezoneState, error) {
+				return sqlc.TimezoneState{}, pgx.ErrNoRows
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodGet, "/team/members", nil, viewerID, teamID, "member")
+		rec := httptest.NewRecorder()
+
+		api.handleListTeamMembers(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if body := rec.Body.String(); body != "{\"members\":[]}\n" {
+			t.Fatalf("unexpected body: %q", body)
+		}
+	})
+
+	t.Run("roster failure", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onListTeamRoster(func(context.Context, sqlc.ListTeamRosterParams) ([]sqlc.ListTeamRosterRow, error) {
+				return nil, errors.New("query failed")
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodGet, "/team/members", nil, viewerID, teamID, "member")
+		rec := httptest.NewRecorder()
+
+		api.handleListTeamMembers(rec, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", rec.Code)
+		}
+	})
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		api := New(&stubStore{}, &mailer.LogMailer{}, Settings{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/team/members", nil)
+		rec := httptest.NewRecorder()
+
+		api.handleListTeamMembers(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status 401, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleUpdateTeam(t *testing.T) {
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	current := sqlc.Team{ID: teamID, Domain: "acme.com", Name: "Acme", IncludeSubdomains: true}
+
+	newQuerier := func(got *sqlc.UpdateTeamSettingsParams) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetTeamByIDForUpdate(func(context.Context, pgtype.UUID) (sqlc.Team, error) {
+				return current, nil
+			}).
+			onGetTeamByID(func(context.Context, pgtype.UUID) (sqlc.Team, error) {
+				return current, nil
+			}).
+			onUpdateTeamSettings(func(_ context.Context, arg sqlc.UpdateTeamSettingsParams) (sqlc.Team, error) {
+				*got = arg
+				return sqlc.Team{ID: arg.ID, Domain: current.Domain, Name: arg.Name, IncludeSubdomains: arg.IncludeSubdomains}, nil
+			}).
+			build()
+	}
+
+	t.Run("keeps omitted fields", func(t *testing.T) {
+		var got sqlc.UpdateTeamSettingsParams
+		api := New(newTxStore(newQuerier(&got)), &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodPatch, "/team", []byte(`{"include_subdomains":false}`), adminID, teamID, "admin")
+		rec := httptest.NewRecorder()
+		api.handleUpdateTeam(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if got.ID != teamID || got.Name != "Acme" || got.IncludeSubdomains {
+			t.Fatalf("unexpected update params: %+v", got)
+		}
+		var resp teamResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Name != "Acme" || resp.Domain != "acme.com" || resp.IncludeSubdomains {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("renames", func(t *testing.T) {
+		var got sqlc.UpdateTeamSettingsParams
+		api := New(newTxStore(newQuerier(&got)), &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodPatch, "/team", []byte(`{"name":"  Acme Corp  "}`), adminID, teamID, "admin")
+		rec := httptest.NewRecorder()
+		api.handleUpdateTeam(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if got.Name != "Acme Corp" || !got.IncludeSubdomains {
+			t.Fatalf("unexpected update params: %+v", got)
+		}
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		api := New(newTxStore(newQuerierBuilder().build()), &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodPatch, "/team", []byte(`{"name":"   "}`), adminID, teamID, "admin")
+		rec := httptest.NewRecorder()
+		api.handleUpdateTeam(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("db failure", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetTeamByIDForUpdate(func(context.Context, pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{}, errors.New("db down")
+			}).
+			build()
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodPatch, "/team", []byte(`{"include_subdomains":true}`), adminID, teamID, "admin")
+		rec := httptest.NewRecorder()
+		api.handleUpdateTeam(rec, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleUpdateTeamJoinPolicy(t *testing.T) {
+	adminID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	current := sqlc.Team{ID: teamID, Domain: "acme.com", Name: "Acme", IncludeSubdomains: true, JoinPolicy: joinPolicyOpen}
+
+	var got sqlc.UpdateTeamSettingsParams
+	q := newQuerierBuilder().
+		onGetTeamByIDForUpdate(func(context.Context, pgtype.UUID) (sqlc.Team, error) {
+			return current, nil
+		}).
+		onGetTeamByID(func(context.Context, pgtype.UUID) (sqlc.Team, error) {
+			return current, nil
+		}).
+		onUpdateTeamSettings(func(_ context.Context, arg sqlc.UpdateTeamSettingsParams) (sqlc.Team, error) {
+			got = arg
+			return sqlc.Team{ID: arg.ID, Name: arg.Name, IncludeSubdomains: arg.IncludeSubdomains, JoinPolicy: arg.JoinPolicy}, nil
+		}).
+		build()
+	api := New(newTxStore(q), &mailer.LogMailer{}, Settings{}, nil)
+
+	req := authedRequest(http.MethodPatch, "/team", []byte(`{"join_policy":"approval"}`), adminID, teamID, "admin")
+	rec := httptest.NewRecorder()
+	api.handleUpdateTeam(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if got.JoinPolicy != joinPolicyApproval || got.Name != "Acme" || !got.IncludeSubdomains {
+		t.Fatalf("unexpected update params: %+v", got)
+	}
+
+	req = authedRequest(http.MethodPatch, "/team", []byte(`{"join_policy":"anyone"}`), adminID, teamID, "admin")
+	rec = httptest.NewRecorder()
+	api.handleUpdateTeam(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestHandleCreateTeam(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	now := time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC)
+
+	newQuerier := func(emailDomain string, existing bool, created *sqlc.CreateTeamParams, membership *sqlc.CreateTeamMembershipParams) sqlc.Querier {
+		return newQuerierBuilder().
+			onGetUserByID(func(context.Context, pgtype.UUID) (sqlc.User, error) {
+				return sqlc.User{ID: userID, Email: "sam@" + emailDomain, EmailDomain: emailDomain}, nil
+			}).
+			onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+				if existing {
+					return sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, Domain: domain, IncludeSubdomains: true}, nil
+				}
+				return sqlc.Team{}, pgx.ErrNoRows
+			}).
+			onCreateTeam(func(_ context.Context, arg sqlc.CreateTeamParams) (sqlc.Team, error) {
+				*created = arg
+				return sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, Domain: arg.Domain, Name: arg.Name, JoinPolicy: joinPolicyOpen}, nil
+			}).
+			onCreateTeamMembership(func(_ context.Context, arg sqlc.CreateTeamMembershipParams) error {
+				*membership = arg
+				return nil
+			}).
+			build()
+	}
+
+	t.Run("claims registrable domain", func(t *testing.T) {
+		var created sqlc.CreateTeamParams
+		var membership sqlc.CreateTeamMembershipParams
+		tx := &testTx{}
+		store := &stubStore{
+			querier: newQuerier("eng.acme.co.uk", false, &created, &membership),
+			beginTxFn: func(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+				return tx, nil
+			},
+		}
+		api := New(store, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+
+		req := authedRequest(http.MethodPost, "/team", nil, userID, pgtype.UUID{}, "")
+		rec := httptest.NewRecorder()
+		api.handleCreateTeam(rec, req)
+
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if created.Domain != "acme.co.uk" || created.Name != "Acme" {
+			t.Fatalf("unexpected team: %+v", created)
+		}
+		if membership.UserID != userID || membership.Role != "admin" || !membership.JoinedAt.Time.Equal(now) {
+			t.Fatalf("unexpected membership: %+v", membership)
+		}
+		if !tx.committed {
+			t.Fatal("expected tx to be committed")
+		}
+		var resp bootstrapResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Status != joinStatusMember || resp.Role != "admin" || resp.Team == nil || resp.Team.Domain != "acme.co.uk" {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("domain already claimed", func(t *testing.T) {
+		var created sqlc.CreateTeamParams
+		var membership sqlc.CreateTeamMembershipParams
+		api := New(newTxStore(newQuerier("acme.com", true, &created, &membership)), &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodPost, "/team", nil, userID, pgtype.UUID{}, "")
+		rec := httptest.NewRecorder()
+		api.handleCreateTeam(rec, req)
+
+		if rec.Code != http.StatusConflict || created.Domain != "" {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+	})
+
+	t.Run("free mail", func(t *testing.T) {
+		var created sqlc.CreateTeamParams
+		var membership sqlc.CreateTeamMembershipParams
+		api := New(newTxStore(newQuerier("gmail.com", false, &created, &membership)), &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodPost, "/team", nil, userID, pgtype.UUID{}, "")
+		rec := httptest.NewRecorder()
+		api.handleCreateTeam(rec, req)
+
+		if rec.Code != http.StatusForbidden || created.Domain != "" {
+			t.Fatalf("expected status 403, got %d", rec.Code)
+		}
+	})
+
+	t.Run("subdomain of verified domain", func(t *testing.T) {
+		var created bool
+		q := newQuerierBuilder().
+			onGetUserByID(func(context.Context, pgtype.UUID) (sqlc.User, error) {
+				return sqlc.User{ID: userID, Email: "sam@eng.acme.com", EmailDomain: "eng.acme.com"}, nil
+			}).
+			onGetTeamByDomain(func(_ context.Context, domain string) (sqlc.Team, error) {
+				if domain != "acme.com" {
+					return sqlc.Team{}, pgx.ErrNoRows
+				}
+				return sqlc.Team{ID: pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, Domain: domain, DomainVerifiedAt: toTimestamptz(now)}, nil
+			}).
+			onCreateTeam(func(context.Context, sqlc.CreateTeamParams) (sqlc.Team, error) {
+				created = true
+				return sqlc.Team{}, nil
+			}).
+			build()
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodPost, "/team", nil, userID, pgtype.UUID{}, "")
+		rec := httptest.NewRecorder()
+		api.handleCreateTeam(rec, req)
+
+		if rec.Code != http.StatusConflict || created {
+			t.Fatalf("expected status 409 without a team, got %d", rec.Code)
+		}
+	})
+
+	t.Run("already a member", func(t *testing.T) {
+		api := New(newTxStore(newQuerierBuilder().build()), &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodPost, "/team", nil, userID, pgtype.UUID{Bytes: [16]byte{3}, Valid: true}, "member")
+		rec := httptest.NewRecorder()
+		api.handleCreateTeam(rec, req)
+
+		if rec.Code != http.StatusConflict {
+			t.Fatalf("expected status 409, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleJoinTeam(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+
+	t.Run("redeems invite", func(t *testing.T) {
+		var redeemed sqlc.RedeemInviteCodeParams
+		q := newQuerierBuilder().
+			onGetUserByID(func(context.Context, pgtype.UUID) (sqlc.User, error) {
+				return sqlc.User{ID: userID, Email: "sam@gmail.com"}, nil
+			}).
+			onRedeemInviteCode(func(_ context.Context, arg sqlc.RedeemInviteCodeParams) (sqlc.InviteCode, error) {
+				redeemed = arg
+				return sqlc.InviteCode{TeamID: teamID}, nil
+			}).
+			onGetTeamByIDForUpdate(func(context.Context, pgtype.UUID) (sqlc.Team, error) {
+				return sqlc.Team{ID: teamID, Name: "Acme"}, nil
+			}).
+			onGetTeamMembershipByUserID(func(context.Context, pgtype.UUID) (sqlc.TeamMembership, error) {
+				return sqlc.TeamMembership{}, pgx.ErrNoRows
+			}).
+			build()
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{TeamSizeLimit: 30}, nil)
+
+		req := authedRequest(http.MethodPost, "/team/join", []byte(`{"invite_code":"abcd2345"}`), userID, pgtype.UUID{}, "")
+		rec := httptest.NewRecorder()
+		api.handleJoinTeam(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if redeemed.Code != "ABCD2345" || redeemed.Email != "sam@gmail.com" {
+			t.Fatalf("unexpected redeem params: %+v", redeemed)
+		}
+		var resp bootstrapResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Status != joinStatusMember || resp.Role != "member" || resp.Team == nil || resp.Team.Name != "Acme" {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("invalid invite", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetUserByID(func(context.Context, pgtype.UUID) (sqlc.User, error) {
+				return sqlc.User{ID: userID, Email: "sam@gmail.com"}, nil
+			}).
+			onRedeemInviteCode(func(context.Context, sqlc.RedeemInviteCodeParams) (sqlc.InviteCode, error) {
+				return sqlc.InviteCode{}, pgx.ErrNoRows
+			}).
+			build()
+		api := New(newTxStore(q), &mailer.LogMailer{}, Settings{TeamSizeLimit: 30}, nil)
+
+		req := authedRequest(http.MethodPost, "/team/join", []byte(`{"invite_code":"ABCD2345"}`), userID, pgtype.UUID{}, "")
+		rec := httptest.NewRecorder()
+		api.handleJoinTeam(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("malformed code", func(t *testing.T) {
+		api := New(newTxStore(newQuerierBuilder().build()), &mailer.LogMailer{}, Settings{}, nil)
+
+		req := authedRequest(http.MethodPost, "/team/join", []byte(`{"invite_code":"nope"}`), userID, pgtype.UUID{}, "")
+		rec := httptest.NewRecorder()
+		api.handleJoinTeam(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+}
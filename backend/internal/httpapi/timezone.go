@@ -0,0 +1,172 @@
+package httpapi
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed data/zone.tab
+var zoneTab string
+
+//go:embed data/backward
+var zoneLinks string
+
+var (
+	zoneCountriesOnce sync.Once
+	zoneCountries     map[string]string
+)
+
+type reportTimezoneRequest struct {
+	Timezone   string     `json:"timezone"`
+	ReportedAt *time.Time `json:"reported_at,omitempty"`
+}
+
+type timezoneStateResponse struct {
+	Timezone         string    `json:"timezone"`
+	UTCOffsetMinutes int32     `json:"utc_offset_minutes"`
+	CountryCode      string    `json:"country_code"`
+	ReportedAt       time.Time `json:"reported_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+func (a *API) handleReportTimezone(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req reportTimezoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	name := strings.TrimSpace(req.Timezone)
+	loc, ok := loadTimezone(name)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid timezone")
+		return
+	}
+
+	// Reports stamped in the future would block every later report, so the
+	// client clock is never trusted beyond the server's own.
+	now := a.clock()
+	reportedAt := now
+	if req.ReportedAt != nil && !req.ReportedAt.IsZero() && req.ReportedAt.Before(now) {
+		reportedAt = *req.ReportedAt
+	}
+
+	ctx := r.Context()
+	q := a.store.Querier()
+	state, err := q.UpsertTimezoneState(ctx, sqlc.UpsertTimezoneStateParams{
+		UserID:           userID,
+		Timezone:         loc.String(),
+		UtcOffsetMinutes: utcOffsetMinutes(loc, reportedAt),
+		CountryCode:      timezoneCountry(loc.String()),
+		ReportedAt:       toTimestamptz(reportedAt),
+	})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			a.logger.Error("failed to save timezone", slog.Any("err", err))
+			writeError(w, http.StatusInternalServerError, "failed to save timezone")
+			return
+		}
+		// A newer report already won; hand back the authoritative state.
+		state, err = q.GetTimezoneState(ctx, userID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to load timezone")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, newTimezoneStateResponse(state))
+}
+
+func newTimezoneStateResponse(state sqlc.TimezoneState) timezoneStateResponse {
+	return timezoneStateResponse{
+		Timezone:         state.Timezone,
+		UTCOffsetMinutes: state.UtcOffsetMinutes,
+		CountryCode:      state.CountryCode,
+		ReportedAt:       state.ReportedAt.Time,
+		UpdatedAt:        state.UpdatedAt.Time,
+	}
+}
+
+// loadTimezone accepts only explicit IANA names; time.LoadLocation would
+// otherwise map "" to UTC and "Local" to the server's own zone.
+func loadTimezone(name string) (*time.Location, bool) {
+	if name == "" || name == "Local" {
+		return nil, false
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, false
+	}
+	return loc, true
+}
+
+func utcOffsetMinutes(loc *time.Location, at time.Time) int32 {
+	_, offset := at.In(loc).Zone()
+	return int32(offset / 60)
+}
+
+// timezoneCountry returns the ISO 3166 country code tzdb assigns to the zone,
+// or "" for zones that are not tied to a country such as Etc/UTC. Link names
+// such as Asia/Calcutta, which macOS still reports, take their target's code.
+func timezoneCountry(name string) string {
+	zoneCountriesOnce.Do(func() {
+		zoneCountries = parseZoneTab(zoneTab)
+		for link, target := range parseZoneLinks(zoneLinks) {
+			if _, ok := zoneCountries[link]; ok {
+				continue
+			}
+			if country, ok := zoneCountries[target]; ok {
+				zoneCountries[link] = country
+			}
+		}
+	})
+	return zoneCountries[name]
+}
+
+func parseZoneTab(data string) map[string]string {
+	out := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 {
+			continue
+		}
+		out[fields[2]] = fields[0]
+	}
+	return out
+}
+
+// parseZoneLinks reads tzdb Link lines into a map from link name to target.
+func parseZoneLinks(data string) map[string]string {
+	out := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] != "Link" {
+			continue
+		}
+		out[fields[2]] = fields[1]
+	}
+	return out
+}
@@ -0,0 +1,248 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func authedRequest(method, target string, body []byte, userID, teamID pgtype.UUID, role string) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	ctx := contextWithAuth(req.Context(), pgtype.UUID{Bytes: [16]byte{0xff}, Valid: true}, userID, teamID, role)
+	return req.WithContext(ctx)
+}
+
+func TestLoadTimezone(t *testing.T) {
+	if _, ok := loadTimezone("Europe/Berlin"); !ok {
+		t.Fatal("expected Europe/Berlin to load")
+	}
+	for _, name := range []string{"", "Local", "Mars/Olympus", "../etc/passwd"} {
+		if _, ok := loadTimezone(name); ok {
+			t.Fatalf("expected %q to be rejected", name)
+		}
+	}
+}
+
+func TestUTCOffsetMinutes(t *testing.T) {
+	loc, _ := loadTimezone("America/New_York")
+	winter := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	summer := time.Date(2024, 7, 15, 12, 0, 0, 0, time.UTC)
+	if got := utcOffsetMinutes(loc, winter); got != -300 {
+		t.Fatalf("expected -300 in winter, got %d", got)
+	}
+	if got := utcOffsetMinutes(loc, summer); got != -240 {
+		t.Fatalf("expected -240 in summer, got %d", got)
+	}
+}
+
+func TestTimezoneCountry(t *testing.T) {
+	tests := map[string]string{
+		"Europe/Berlin":        "DE",
+		"Asia/Kolkata":         "IN",
+		"Asia/Calcutta":        "IN",
+		"Asia/Saigon":          "VN",
+		"Europe/Kiev":          "UA",
+		"America/Buenos_Aires": "AR",
+		"America/New_York":     "US",
+		"Etc/UTC":              "",
+		"Etc/UCT":              "",
+	}
+	for zone, want := range tests {
+		if got := timezoneCountry(zone); got != want {
+			t.Fatalf("expected %q for %s, got %q", want, zone, got)
+		}
+	}
+}
+
+func TestHandleReportTimezone(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("success", func(t *testing.T) {
+		reportedAt := now.Add(-time.Minute)
+		q := newQuerierBuilder().
+			onUpsertTimezoneState(func(_ context.Context, arg sqlc.UpsertTimezoneStateParams) (sqlc.TimezoneState, error) {
+				if arg.UserID != userID {
+					t.Fatalf("unexpected user id: %v", arg.UserID)
+				}
+				if arg.Timezone != "Europe/Berlin" || arg.UtcOffsetMinutes != 120 || arg.CountryCode != "DE" {
+					t.Fatalf("unexpected params: %+v", arg)
+				}
+				if !arg.ReportedAt.Time.Equal(reportedAt) {
+					t.Fatalf("unexpected reported at: %v", arg.ReportedAt.Time)
+				}
+				return sqlc.TimezoneState{
+					UserID:           arg.UserID,
+					Timezone:         arg.Timezone,
+					UtcOffsetMinutes: arg.UtcOffsetMinutes,
+					CountryCode:      arg.CountryCode,
+					ReportedAt:       arg.ReportedAt,
+					UpdatedAt:        toTimestamptz(now),
+				}, nil
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+
+		body, _ := json.Marshal(reportTimezoneRequest{Timezone: "Europe/Berlin", ReportedAt: &reportedAt})
+		req := authedRequest(http.MethodPut, "/me/timezone", body, userID, teamID, "member")
+		rec := httptest.NewRecorder()
+
+		api.handleReportTimezone(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var resp timezoneStateResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if resp.Timezone != "Europe/Berlin" || resp.CountryCode != "DE" || resp.UTCOffsetMinutes != 120 {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("future reported_at is clamped", func(t *testing.T) {
+		future := now.Add(time.Hour)
+		q := newQuerierBuilder().
+			onUpsertTimezoneState(func(_ context.Context, arg sqlc.UpsertTimezoneStateParams) (sqlc.TimezoneState, error) {
+				if !arg.ReportedAt.Time.Equal(now) {
+					t.Fatalf("expected reported at to be clamped to now, got %v", arg.ReportedAt.Time)
+				}
+				return sqlc.TimezoneState{}, nil
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+
+		body, _ := json.Marshal(reportTimezoneRequest{Timezone: "Asia/Tokyo", ReportedAt: &future})
+		req := authedRequest(http.MethodPut, "/me/timezone", body, userID, teamID, "member")
+		rec := httptest.NewRecorder()
+
+		api.handleReportTimezone(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+	})
+
+	t.Run("stale report returns current state", func(t *testing.T) {
+		var loaded bool
+		q := newQuerierBuilder().
+			onUpsertTimezoneState(func(context.Context, sqlc.UpsertTimezoneStateParams) (sqlc.TimezoneState, error) {
+				return sqlc.TimezoneState{}, pgx.ErrNoRows
+			}).
+			onGetTimezoneState(func(_ context.Context, id pgtype.UUID) (sqlc.TimezoneState, error) {
+				loaded = true
+				return sqlc.TimezoneState{
+					UserID:           id,
+					Timezone:         "Asia/Tokyo",
+					UtcOffsetMinutes: 540,
+					CountryCode:      "JP",
+					ReportedAt:       toTimestamptz(now),
+					UpdatedAt:        toTimestamptz(now),
+				}, nil
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+
+		older := now.Add(-time.Hour)
+		body, _ := json.Marshal(reportTimezoneRequest{Timezone: "Europe/Berlin", ReportedAt: &older})
+		req := authedRequest(http.MethodPut, "/me/timezone", body, userID, teamID, "member")
+		rec := httptest.NewRecorder()
+
+		api.handleReportTimezone(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if !loaded {
+			t.Fatal("expected current state to be loaded")
+		}
+		var resp timezoneStateResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if resp.Timezone != "Asia/Tokyo" {
+			t.Fatalf("expected authoritative timezone, got %q", resp.Timezone)
+		}
+	})
+
+	t.Run("db failure", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onUpsertTimezoneState(func(context.Context, sqlc.UpsertTimezoneStateParams) (sqlc.TimezoneState, error) {
+				return sqlc.TimezoneState{}, errors.New("insert failed")
+			}).
+			build()
+
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+
+		body, _ := json.Marshal(reportTimezoneRequest{Timezone: "Europe/Berlin"})
+		req := authedRequest(http.MethodPut, "/me/timezone", body, userID, teamID, "member")
+		rec := httptest.NewRecorder()
+
+		api.handleReportTimezone(rec, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleReportTimezoneInputValidation(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{2}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{3}, Valid: true}
+
+	tests := []struct {
+		name string
+		body []byte
+		code int
+	}{
+		{"invalid JSON", []byte("{bad json"), http.StatusBadRequest},
+		{"missing timezone", []byte(`{}`), http.StatusBadRequest},
+		{"unknown timezone", []byte(`{"timezone":"Mars/Olympus"}`), http.StatusBadRequest},
+		{"local timezone", []byte(`{"timezone":"Local"}`), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := New(&stubStore{}, &mailer.LogMailer{}, Settings{}, nil)
+			req := authedRequest(http.MethodPut, "/me/timezone", tt.body, userID, teamID, "member")
+			rec := httptest.NewRecorder()
+
+			api.handleReportTimezone(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, rec.Code)
+			}
+		})
+	}
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		api := New(&stubStore{}, &mailer.LogMailer{}, Settings{}, nil)
+		req := httptest.NewRequest(http.MethodPut, "/me/timezone", bytes.NewReader([]byte(`{"timezone":"UTC"}`)))
+		rec := httptest.NewRecorder()
+
+		api.handleReportTimezone(rec, req)
+
+		if rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status 401, got %d", rec.Code)
+		}
+	})
+}
@@ -10,6 +10,9 @@ import (
 const (
 	tokenBytes = 32
 	codeLength = 8
+	// codeAttempts is how many codes withFreshCode draws before giving up
+	// on finding one that isn't taken.
+	codeAttempts = 3
 )
 
 var codeAlphabet = []rune("ABCDEFGHJKLMNPQRSTUVWXYZ23456789")
@@ -36,6 +39,23 @@ func generateCode() (string, error) {
 	return string(out), nil
 }
 
+// withFreshCode calls store with newly generated codes until it stops failing
+// on a unique index, so that a code still open elsewhere is redrawn instead
+// of failing the request.
+func withFreshCode(store func(code string) error) error {
+	var err error
+	for range codeAttempts {
+		var code string
+		if code, err = generateCode(); err != nil {
+			return err
+		}
+		if err = store(code); !isUniqueViolation(err) {
+			return err
+		}
+	}
+	return err
+}
+
 func hashString(value string) []byte {
 	sum := sha256.Sum256([]byte(value))
 	return sum[:]
@@ -2,7 +2,10 @@ package httpapi
 
 import (
 	"encoding/base64"
+	"errors"
 	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func TestGenerateToken(t *testing.T) {
@@ -40,6 +43,38 @@ func TestGenerateCode(t *testing.T) {
 	}
 }
 
+func TestWithFreshCode(t *testing.T) {
+	taken := &pgconn.PgError{Code: pgUniqueViolation}
+	other := errors.New("db down")
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "first code free", errs: []error{nil}, wantCalls: 1},
+		{name: "taken then free", errs: []error{taken, nil}, wantCalls: 2},
+		{name: "always taken", errs: []error{taken, taken, taken}, wantErr: taken, wantCalls: codeAttempts},
+		{name: "other failure", errs: []error{other}, wantErr: other, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := map[string]bool{}
+			err := withFreshCode(func(code string) error {
+				if seen[code] {
+					t.Fatalf("code %q drawn twice", code)
+				}
+				seen[code] = true
+				return tt.errs[len(seen)-1]
+			})
+			if !errors.Is(err, tt.wantErr) || len(seen) != tt.wantCalls {
+				t.Fatalf("expected %v after %d calls, got %v after %d", tt.wantErr, tt.wantCalls, err, len(seen))
+			}
+		})
+	}
+}
+
 func stringsContainsRune(list []rune, r rune) bool {
 	for _, item := range list {
 		if item == r {
@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const hideIndefinitely = "indefinite"
+
+// hideDurations are the only hide periods clients may choose.
+var hideDurations = map[string]time.Duration{
+	"7d":  7 * 24 * time.Hour,
+	"15d": 15 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+type hideTimezoneRequest struct {
+	Duration string `json:"duration"`
+}
+
+type visibilityResponse struct {
+	Hidden             bool       `json:"hidden"`
+	HiddenUntil        *time.Time `json:"hidden_until,omitempty"`
+	HiddenIndefinitely bool       `json:"hidden_indefinitely"`
+}
+
+func (a *API) handleGetVisibility(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	resp, err := loadVisibility(r.Context(), a.store.Querier(), userID, a.clock())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load visibility")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleHideTimezone hides the caller's own timezone. Visibility routes never
+// take a user id, so admins cannot hide or reveal anyone else.
+func (a *API) handleHideTimezone(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req hideTimezoneRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	now := a.clock()
+	params := sqlc.UpsertTimezoneVisibilityParams{UserID: userID}
+	if req.Duration == hideIndefinitely {
+		params.HiddenIndefinitely = true
+	} else {
+		duration, ok := hideDurations[req.Duration]
+		if !ok {
+			writeError(w, http.StatusBadRequest, "duration must be 7d, 15d, 30d or indefinite")
+			return
+		}
+		params.HiddenUntil = toTimestamptz(now.Add(duration))
+	}
+
+	a.saveVisibility(w, r, params, now)
+}
+
+func (a *API) handleUnhideTimezone(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	a.saveVisibility(w, r, sqlc.UpsertTimezoneVisibilityParams{UserID: userID}, a.clock())
+}
+
+func (a *API) saveVisibility(w http.ResponseWriter, r *http.Request, params sqlc.UpsertTimezoneVisibilityParams, now time.Time) {
+	visibility, err := a.store.Querier().UpsertTimezoneVisibility(r.Context(), params)
+	if err != nil {
+		a.logger.Error("failed to save visibility", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to save visibility")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newVisibilityResponse(visibility, now))
+}
+
+// loadVisibility treats a user without a visibility row as sharing.
+func loadVisibility(ctx context.Context, q sqlc.Querier, userID pgtype.UUID, now time.Time) (visibilityResponse, error) {
+	visibility, err := q.GetTimezoneVisibility(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return visibilityResponse{}, nil
+		}
+		return visibilityResponse{}, err
+	}
+	return newVisibilityResponse(visibility, now), nil
+}
+
+func newVisibilityResponse(visibility sqlc.TimezoneVisibility, now time.Time) visibilityResponse {
+	if !isHidden(visibility.HiddenUntil, visibility.HiddenIndefinitely, now) {
+		return visibilityResponse{}
+	}
+	resp := visibilityResponse{Hidden: true, HiddenIndefinitely: visibility.HiddenIndefinitely}
+	if !visibility.HiddenIndefinitely {
+		hiddenUntil := visibility.HiddenUntil.Time
+		resp.HiddenUntil = &hiddenUntil
+	}
+	return resp
+}
+
+// isHidden mirrors the roster query: a timed hide lapses on its own once
+// hidden_until passes, so nothing has to clear the row.
+func isHidden(hiddenUntil pgtype.Timestamptz, indefinitely bool, now time.Time) bool {
+	if indefinitely {
+		return true
+	}
+	return hiddenUntil.Valid && hiddenUntil.Time.After(now)
+}
@@ -0,0 +1,189 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestIsHidden(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	if isHidden(pgtype.Timestamptz{}, false, now) {
+		t.Fatal("expected no hide to be visible")
+	}
+	if !isHidden(pgtype.Timestamptz{}, true, now) {
+		t.Fatal("expected indefinite hide to be hidden")
+	}
+	if !isHidden(pgtype.Timestamptz{Time: now.Add(time.Second), Valid: true}, false, now) {
+		t.Fatal("expected future hidden_until to be hidden")
+	}
+	if isHidden(pgtype.Timestamptz{Time: now, Valid: true}, false, now) {
+		t.Fatal("expected hide to lapse at hidden_until")
+	}
+}
+
+func TestHandleHideTimezone(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	newAPI := func(saved *sqlc.UpsertTimezoneVisibilityParams) *API {
+		q := newQuerierBuilder().
+			onUpsertTimezoneVisibility(func(_ context.Context, arg sqlc.UpsertTimezoneVisibilityParams) (sqlc.TimezoneVisibility, error) {
+				*saved = arg
+				return sqlc.TimezoneVisibility{
+					UserID:             arg.UserID,
+					HiddenUntil:        arg.HiddenUntil,
+					HiddenIndefinitely: arg.HiddenIndefinitely,
+				}, nil
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		api.clock = func() time.Time { return now }
+		return api
+	}
+
+	for duration, days := range map[string]int{"7d": 7, "15d": 15, "30d": 30} {
+		t.Run(duration, func(t *testing.T) {
+			var saved sqlc.UpsertTimezoneVisibilityParams
+			api := newAPI(&saved)
+			body := []byte(`{"duration":"` + duration + `"}`)
+			rec := httptest.NewRecorder()
+
+			api.handleHideTimezone(rec, authedRequest(http.MethodPut, "/me/visibility", body, userID, teamID, "member"))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+			want := now.Add(time.Duration(days) * 24 * time.Hour)
+			if saved.UserID != userID || saved.HiddenIndefinitely || !saved.HiddenUntil.Time.Equal(want) {
+				t.Fatalf("unexpected params: %+v", saved)
+			}
+			var resp visibilityResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if !resp.Hidden || resp.HiddenUntil == nil || !resp.HiddenUntil.Equal(want) {
+				t.Fatalf("unexpected response: %+v", resp)
+			}
+		})
+	}
+
+	t.Run("indefinitely", func(t *testing.T) {
+		var saved sqlc.UpsertTimezoneVisibilityParams
+		api := newAPI(&saved)
+		rec := httptest.NewRecorder()
+
+		api.handleHideTimezone(rec, authedRequest(http.MethodPut, "/me/visibility", []byte(`{"duration":"indefinite"}`), userID, teamID, "member"))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if !saved.HiddenIndefinitely || saved.HiddenUntil.Valid {
+			t.Fatalf("unexpected params: %+v", saved)
+		}
+	})
+
+	t.Run("unsupported duration", func(t *testing.T) {
+		var saved sqlc.UpsertTimezoneVisibilityParams
+		api := newAPI(&saved)
+		rec := httptest.NewRecorder()
+
+		api.handleHideTimezone(rec, authedRequest(http.MethodPut, "/me/visibility", []byte(`{"duration":"90d"}`), userID, teamID, "member"))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status 400, got %d", rec.Code)
+		}
+	})
+
+	t.Run("unhide", func(t *testing.T) {
+		var saved sqlc.UpsertTimezoneVisibilityParams
+		api := newAPI(&saved)
+		rec := httptest.NewRecorder()
+
+		api.handleUnhideTimezone(rec, authedRequest(http.MethodDelete, "/me/visibility", nil, userID, teamID, "member"))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if saved.UserID != userID || saved.HiddenIndefinitely || saved.HiddenUntil.Valid {
+			t.Fatalf("unexpected params: %+v", saved)
+		}
+		if body := rec.Body.String(); body != "{\"hidden\":false,\"hidden_indefinitely\":false}\n" {
+			t.Fatalf("unexpected body: %q", body)
+		}
+	})
+
+	t.Run("save failure", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onUpsertTimezoneVisibility(func(context.Context, sqlc.UpsertTimezoneVisibilityParams) (sqlc.TimezoneVisibility, error) {
+				return sqlc.TimezoneVisibility{}, errors.New("db down")
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		rec := httptest.NewRecorder()
+
+		api.handleHideTimezone(rec, authedRequest(http.MethodPut, "/me/visibility", []byte(`{"duration":"7d"}`), userID, teamID, "member"))
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleGetVisibility(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		visibility sqlc.TimezoneVisibility
+		err        error
+		wantHidden bool
+	}{
+		{"no row", sqlc.TimezoneVisibility{}, pgx.ErrNoRows, false},
+		{"hidden", sqlc.TimezoneVisibility{HiddenUntil: pgtype.Timestamptz{Time: now.Add(time.Hour), Valid: true}}, nil, true},
+		{"expired", sqlc.TimezoneVisibility{HiddenUntil: pgtype.Timestamptz{Time: now.Add(-time.Hour), Valid: true}}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newQuerierBuilder().
+				onGetTimezoneVisibility(func(_ context.Context, id pgtype.UUID) (sqlc.TimezoneVisibility, error) {
+					if id != userID {
+						t.Fatalf("unexpected user id: %v", id)
+					}
+					return tt.visibility, tt.err
+				}).
+				build()
+			api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+			api.clock = func() time.Time { return now }
+			rec := httptest.NewRecorder()
+
+			api.handleGetVisibility(rec, authedRequest(http.MethodGet, "/me/visibility", nil, userID, teamID, "member"))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", rec.Code)
+			}
+			var resp visibilityResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Hidden != tt.wantHidden {
+				t.Fatalf("expected hidden=%v, got %+v", tt.wantHidden, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	defaultStartMinute = 9 * 60
+	defaultEndMinute   = 17 * 60
+	lastMinuteOfDay    = 24*60 - 1
+)
+
+// Weekdays use time.Weekday numbering: 0 is Sunday, 6 is Saturday.
+var defaultWorkingDays = []int32{1, 2, 3, 4, 5}
+
+type workingHoursRequest struct {
+	StartMinute *int32  `json:"start_minute"`
+	EndMinute   *int32  `json:"end_minute"`
+	WorkingDays []int32 `json:"working_days"`
+}
+
+type workingHoursResponse struct {
+	StartMinute int32      `json:"start_minute"`
+	EndMinute   int32      `json:"end_minute"`
+	WorkingDays []int32    `json:"working_days"`
+	Overnight   bool       `json:"overnight"`
+	Configured  bool       `json:"configured"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+func (a *API) handleGetWorkingHours(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	resp, err := loadWorkingHours(r.Context(), a.store.Querier(), userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to load working hours")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (a *API) handleUpdateWorkingHours(w http.ResponseWriter, r *http.Request) {
+	userID, ok := userIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req workingHoursRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.StartMinute == nil || req.EndMinute == nil {
+		writeError(w, http.StatusBadRequest, "start_minute and end_minute are required")
+		return
+	}
+	if !isValidMinute(*req.StartMinute) || !isValidMinute(*req.EndMinute) {
+		writeError(w, http.StatusBadRequest, "minutes must be between 0 and 1439")
+		return
+	}
+	if *req.StartMinute == *req.EndMinute {
+		writeError(w, http.StatusBadRequest, "start_minute and end_minute must differ")
+		return
+	}
+	days, ok := normalizeWorkingDays(req.WorkingDays)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "working_days must list weekdays between 0 (Sunday) and 6 (Saturday)")
+		return
+	}
+
+	hours, err := a.store.Querier().UpsertWorkingHours(r.Context(), sqlc.UpsertWorkingHoursParams{
+		UserID:      userID,
+		StartMinute: *req.StartMinute,
+		EndMinute:   *req.EndMinute,
+		WorkingDays: days,
+	})
+	if err != nil {
+		a.logger.Error("failed to save working hours", slog.Any("err", err))
+		writeError(w, http.StatusInternalServerError, "failed to save working hours")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newWorkingHoursResponse(hours))
+}
+
+// loadWorkingHours falls back to the default schedule, marked unconfigured,
+// when the user has never saved one.
+func loadWorkingHours(ctx context.Context, q sqlc.Querier, userID pgtype.UUID) (workingHoursResponse, error) {
+	hours, err := q.GetWorkingHours(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return workingHoursResponse{
+				StartMinute: defaultStartMinute,
+				EndMinute:   defaultEndMinute,
+				WorkingDays: defaultWorkingDays,
+			}, nil
+		}
+		return workingHoursResponse{}, err
+	}
+	return newWorkingHoursResponse(hours), nil
+}
+
+func newWorkingHoursResponse(hours sqlc.WorkingHour) workingHoursResponse {
+	updatedAt := hours.UpdatedAt.Time
+	return workingHoursResponse{
+		StartMinute: hours.StartMinute,
+		EndMinute:   hours.EndMinute,
+		WorkingDays: hours.WorkingDays,
+		Overnight:   hours.EndMinute < hours.StartMinute,
+		Configured:  true,
+		UpdatedAt:   &updatedAt,
+	}
+}
+
+func isValidMinute(minute int32) bool {
+	return minute >= 0 && minute <= lastMinuteOfDay
+}
+
+// normalizeWorkingDays sorts and de-duplicates the weekdays, rejecting an
+// empty set or anything outside 0-6.
+func normalizeWorkingDays(days []int32) ([]int32, bool) {
+	seen := make(map[int32]bool, len(days))
+	out := make([]int32, 0, len(days))
+	for _, day := range days {
+		if day < 0 || day > 6 {
+			return nil, false
+		}
+		if seen[day] {
+			continue
+		}
+		seen[day] = true
+		out = append(out, day)
+	}
+	if len(out) == 0 {
+		return nil, false
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out, true
+}
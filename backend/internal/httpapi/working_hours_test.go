@@ -0,0 +1,160 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestNormalizeWorkingDays(t *testing.T) {
+	got, ok := normalizeWorkingDays([]int32{6, 0, 5, 0})
+	if !ok || !reflect.DeepEqual(got, []int32{0, 5, 6}) {
+		t.Fatalf("unexpected days: %v %v", got, ok)
+	}
+	for _, days := range [][]int32{nil, {}, {7}, {-1, 2}} {
+		if _, ok := normalizeWorkingDays(days); ok {
+			t.Fatalf("expected %v to be rejected", days)
+		}
+	}
+}
+
+func TestHandleGetWorkingHours(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+
+	t.Run("defaults when unset", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetWorkingHours(func(context.Context, pgtype.UUID) (sqlc.WorkingHour, error) {
+				return sqlc.WorkingHour{}, pgx.ErrNoRows
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		rec := httptest.NewRecorder()
+
+		api.handleGetWorkingHours(rec, authedRequest(http.MethodGet, "/me/working-hours", nil, userID, teamID, "member"))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		var resp workingHoursResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if resp.Configured || resp.StartMinute != defaultStartMinute || !reflect.DeepEqual(resp.WorkingDays, defaultWorkingDays) {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("stored schedule", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetWorkingHours(func(_ context.Context, id pgtype.UUID) (sqlc.WorkingHour, error) {
+				if id != userID {
+					t.Fatalf("unexpected user id: %v", id)
+				}
+				return sqlc.WorkingHour{StartMinute: 22 * 60, EndMinute: 6 * 60, WorkingDays: []int32{0, 1, 2, 3, 4}}, nil
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		rec := httptest.NewRecorder()
+
+		api.handleGetWorkingHours(rec, authedRequest(http.MethodGet, "/me/working-hours", nil, userID, teamID, "member"))
+
+		var resp workingHoursResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if !resp.Configured || !resp.Overnight || resp.StartMinute != 22*60 {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+	})
+
+	t.Run("query failure", func(t *testing.T) {
+		q := newQuerierBuilder().
+			onGetWorkingHours(func(context.Context, pgtype.UUID) (sqlc.WorkingHour, error) {
+				return sqlc.WorkingHour{}, errors.New("db down")
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		rec := httptest.NewRecorder()
+
+		api.handleGetWorkingHours(rec, authedRequest(http.MethodGet, "/me/working-hours", nil, userID, teamID, "member"))
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status 500, got %d", rec.Code)
+		}
+	})
+}
+
+func TestHandleUpdateWorkingHours(t *testing.T) {
+	userID := pgtype.UUID{Bytes: [16]byte{1}, Valid: true}
+	teamID := pgtype.UUID{Bytes: [16]byte{9}, Valid: true}
+
+	t.Run("saves overnight window", func(t *testing.T) {
+		var saved sqlc.UpsertWorkingHoursParams
+		q := newQuerierBuilder().
+			onUpsertWorkingHours(func(_ context.Context, arg sqlc.UpsertWorkingHoursParams) (sqlc.WorkingHour, error) {
+				saved = arg
+				return sqlc.WorkingHour{
+					UserID:      arg.UserID,
+					StartMinute: arg.StartMinute,
+					EndMinute:   arg.EndMinute,
+					WorkingDays: arg.WorkingDays,
+				}, nil
+			}).
+			build()
+		api := New(&stubStore{querier: q}, &mailer.LogMailer{}, Settings{}, nil)
+		body := []byte(`{"start_minute":1320,"end_minute":360,"working_days":[4,0,1,2,3]}`)
+		rec := httptest.NewRecorder()
+
+		api.handleUpdateWorkingHours(rec, authedRequest(http.MethodPut, "/me/working-hours", body, userID, teamID, "member"))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if saved.UserID != userID || saved.StartMinute != 1320 || saved.EndMinute != 360 {
+			t.Fatalf("unexpected params: %+v", saved)
+		}
+		if !reflect.DeepEqual(saved.WorkingDays, []int32{0, 1, 2, 3, 4}) {
+			t.Fatalf("expected sorted days, got %v", saved.WorkingDays)
+		}
+		var resp workingHoursResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if !resp.Overnight {
+			t.Fatal("expected overnight window")
+		}
+	})
+
+	invalid := map[string]string{
+		"missing end":    `{"start_minute":540,"working_days":[1]}`,
+		"minute too big": `{"start_minute":540,"end_minute":1440,"working_days":[1]}`,
+		"negative":       `{"start_minute":-1,"end_minute":600,"working_days":[1]}`,
+		"empty window":   `{"start_minute":540,"end_minute":540,"working_days":[1]}`,
+		"no days":        `{"start_minute":540,"end_minute":1020,"working_days":[]}`,
+		"bad day":        `{"start_minute":540,"end_minute":1020,"working_days":[7]}`,
+		"bad json":       `{`,
+	}
+	for name, body := range invalid {
+		t.Run(name, func(t *testing.T) {
+			api := New(&stubStore{}, &mailer.LogMailer{}, Settings{}, nil)
+			rec := httptest.NewRecorder()
+
+			api.handleUpdateWorkingHours(rec, authedRequest(http.MethodPut, "/me/working-hours", []byte(body), userID, teamID, "member"))
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expected status 400, got %d", rec.Code)
+			}
+		})
+	}
+}
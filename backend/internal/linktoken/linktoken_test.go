@@ -0,0 +1,57 @@
+package linktoken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	signer := NewSigner([]byte("secret"))
+
+	token := signer.Sign("resume", "user|123", now.Add(time.Hour))
+	if strings.ContainsAny(token, "+/=") {
+		t.Fatalf("expected url-safe token, got %q", token)
+	}
+
+	subject, err := signer.Verify("resume", token, now)
+	if err != nil {
+		t.Fatalf("verify error: %v", err)
+	}
+	if subject != "user|123" {
+		t.Fatalf("unexpected subject: %q", subject)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	signer := NewSigner([]byte("secret"))
+	token := signer.Sign("resume", "user", now.Add(time.Hour))
+	payload, sig, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		purpose string
+		token   string
+		now     time.Time
+		want    error
+	}{
+		{"expired", signer, "resume", token, now.Add(time.Hour), ErrExpired},
+		{"other purpose", signer, "magic", token, now, ErrInvalid},
+		{"other key", NewSigner([]byte("other")), "resume", token, now, ErrInvalid},
+		{"tampered payload", signer, "resume", payload + "A." + sig, now, ErrInvalid},
+		{"missing signature", signer, "resume", payload, now, ErrInvalid},
+		{"garbage", signer, "resume", "not a token", now, ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.signer.Verify(tt.purpose, tt.token, tt.now); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
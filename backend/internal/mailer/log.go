@@ -11,3 +11,23 @@ func (m *LogMailer) SendVerificationCode(_ context.Context, email, code string)
 	slog.Info("verification code issued", slog.String("email", email), slog.String("code", code))
 	return nil
 }
+
+func (m *LogMailer) SendSignInLink(_ context.Context, email, code, link string) error {
+	slog.Info("sign-in link issued", slog.String("email", email), slog.String("code", code), slog.String("link", link))
+	return nil
+}
+
+func (m *LogMailer) SendInviteCode(_ context.Context, email, teamName, code string) error {
+	slog.Info("invite code issued", slog.String("email", email), slog.String("team", teamName), slog.String("code", code))
+	return nil
+}
+
+func (m *LogMailer) SendSharingReminder(_ context.Context, email, resumeURL string) error {
+	slog.Info("sharing reminder issued", slog.String("email", email), slog.String("resume_url", resumeURL))
+	return nil
+}
+
+func (m *LogMailer) SendEmailChanged(_ context.Context, oldEmail, newEmail string) error {
+	slog.Info("email change notice issued", slog.String("email", oldEmail), slog.String("new_email", newEmail))
+	return nil
+}
@@ -11,3 +11,31 @@ func TestLogMailerSendVerificationCode(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestLogMailerSendSignInLink(t *testing.T) {
+	m := &LogMailer{}
+	if err := m.SendSignInLink(context.Background(), "user@example.com", "ABC12345", "https://example.com/links/sign-in?token=abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLogMailerSendInviteCode(t *testing.T) {
+	m := &LogMailer{}
+	if err := m.SendInviteCode(context.Background(), "user@example.com", "example.com", "ABC12345"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLogMailerSendSharingReminder(t *testing.T) {
+	m := &LogMailer{}
+	if err := m.SendSharingReminder(context.Background(), "user@example.com", "https://example.com/links/resume-sharing?token=abc"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestLogMailerSendEmailChanged(t *testing.T) {
+	m := &LogMailer{}
+	if err := m.SendEmailChanged(context.Background(), "old@example.com", "new@example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -4,4 +4,8 @@ import "context"
 
 type Mailer interface {
 	SendVerificationCode(ctx context.Context, email, code string) error
+	SendSignInLink(ctx context.Context, email, code, link string) error
+	SendInviteCode(ctx context.Context, email, teamName, code string) error
+	SendSharingReminder(ctx context.Context, email, resumeURL string) error
+	SendEmailChanged(ctx context.Context, oldEmail, newEmail string) error
 }
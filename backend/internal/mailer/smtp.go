@@ -51,3 +51,59 @@ func (m *SMTPMailer) SendVerificationCode(ctx context.Context, email, code strin
 	msg.SetBodyString(mail.TypeTextPlain, body)
 	return m.client.DialAndSendWithContext(ctx, msg)
 }
+
+func (m *SMTPMailer) SendSignInLink(ctx context.Context, email, code, link string) error {
+	msg := mail.NewMsg()
+	if err := msg.From(m.from); err != nil {
+		return err
+	}
+	if err := msg.To(email); err != nil {
+		return err
+	}
+	msg.Subject("Sign in to TimeSync")
+	body := fmt.Sprintf("Open this link on the Mac you're signing in from: %s\n\nOr enter code %s in TimeSync. Both expire in 10 minutes and work once.", link, code)
+	msg.SetBodyString(mail.TypeTextPlain, body)
+	return m.client.DialAndSendWithContext(ctx, msg)
+}
+
+func (m *SMTPMailer) SendInviteCode(ctx context.Context, email, teamName, code string) error {
+	msg := mail.NewMsg()
+	if err := msg.From(m.from); err != nil {
+		return err
+	}
+	if err := msg.To(email); err != nil {
+		return err
+	}
+	msg.Subject(fmt.Sprintf("You're invited to %s on TimeSync", teamName))
+	body := fmt.Sprintf("You've been invited to join %s on TimeSync. Sign in with this email address and enter invite code %s to join.", teamName, code)
+	msg.SetBodyString(mail.TypeTextPlain, body)
+	return m.client.DialAndSendWithContext(ctx, msg)
+}
+
+func (m *SMTPMailer) SendSharingReminder(ctx context.Context, email, resumeURL string) error {
+	msg := mail.NewMsg()
+	if err := msg.From(m.from); err != nil {
+		return err
+	}
+	if err := msg.To(email); err != nil {
+		return err
+	}
+	msg.Subject("Your TimeSync timezone is still hidden")
+	body := fmt.Sprintf("You paused timezone sharing in TimeSync a while ago, so your teammates can't see your local time. That's fine if you meant it and we'll check in again in 30 days. To start sharing again, open this link: %s", resumeURL)
+	msg.SetBodyString(mail.TypeTextPlain, body)
+	return m.client.DialAndSendWithContext(ctx, msg)
+}
+
+func (m *SMTPMailer) SendEmailChanged(ctx context.Context, oldEmail, newEmail string) error {
+	msg := mail.NewMsg()
+	if err := msg.From(m.from); err != nil {
+		return err
+	}
+	if err := msg.To(oldEmail); err != nil {
+		return err
+	}
+	msg.Subject("Your TimeSync email address was changed")
+	body := fmt.Sprintf("The email address on your TimeSync account was changed from %s to %s. If you made this change, no action is needed. If you didn't, contact your team admin right away: whoever made it can now sign in to your account.", oldEmail, newEmail)
+	msg.SetBodyString(mail.TypeTextPlain, body)
+	return m.client.DialAndSendWithContext(ctx, msg)
+}
@@ -45,3 +45,83 @@ func TestSMTPMailerSendVerificationCodeInvalidTo(t *testing.T) {
 		t.Fatal("expected error for invalid recipient")
 	}
 }
+
+func TestSMTPMailerSendSignInLinkInvalidFrom(t *testing.T) {
+	m := &SMTPMailer{
+		from: "invalid address",
+	}
+
+	if err := m.SendSignInLink(context.Background(), "user@example.com", "ABC12345", "https://example.com"); err == nil {
+		t.Fatal("expected error for invalid from address")
+	}
+}
+
+func TestSMTPMailerSendSignInLinkInvalidTo(t *testing.T) {
+	m := &SMTPMailer{
+		from: "no-reply@example.com",
+	}
+
+	if err := m.SendSignInLink(context.Background(), "bad address", "ABC12345", "https://example.com"); err == nil {
+		t.Fatal("expected error for invalid recipient")
+	}
+}
+
+func TestSMTPMailerSendInviteCodeInvalidFrom(t *testing.T) {
+	m := &SMTPMailer{
+		from: "invalid address",
+	}
+
+	if err := m.SendInviteCode(context.Background(), "user@example.com", "example.com", "ABC12345"); err == nil {
+		t.Fatal("expected error for invalid from address")
+	}
+}
+
+func TestSMTPMailerSendInviteCodeInvalidTo(t *testing.T) {
+	m := &SMTPMailer{
+		from: "no-reply@example.com",
+	}
+
+	if err := m.SendInviteCode(context.Background(), "bad address", "example.com", "ABC12345"); err == nil {
+		t.Fatal("expected error for invalid recipient")
+	}
+}
+
+func TestSMTPMailerSendSharingReminderInvalidFrom(t *testing.T) {
+	m := &SMTPMailer{
+		from: "invalid address",
+	}
+
+	if err := m.SendSharingReminder(context.Background(), "user@example.com", "https://example.com"); err == nil {
+		t.Fatal("expected error for invalid from address")
+	}
+}
+
+func TestSMTPMailerSendSharingReminderInvalidTo(t *testing.T) {
+	m := &SMTPMailer{
+		from: "no-reply@example.com",
+	}
+
+	if err := m.SendSharingReminder(context.Background(), "bad address", "https://example.com"); err == nil {
+		t.Fatal("expected error for invalid recipient")
+	}
+}
+
+func TestSMTPMailerSendEmailChangedInvalidFrom(t *testing.T) {
+	m := &SMTPMailer{
+		from: "invalid address",
+	}
+
+	if err := m.SendEmailChanged(context.Background(), "old@example.com", "new@example.com"); err == nil {
+		t.Fatal("expected error for invalid from address")
+	}
+}
+
+func TestSMTPMailerSendEmailChangedInvalidTo(t *testing.T) {
+	m := &SMTPMailer{
+		from: "no-reply@example.com",
+	}
+
+	if err := m.SendEmailChanged(context.Background(), "bad address", "new@example.com"); err == nil {
+		t.Fatal("expected error for invalid recipient")
+	}
+}
@@ -0,0 +1,214 @@
+// Package oidcrp signs users in through a team's OpenID Connect provider. It
+// builds PKCE authorization requests and validates the ID token returned by
+// the code exchange against the issuer's published signing keys.
+package oidcrp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrNoIDToken       = errors.New("oidcrp: token response has no id_token")
+	ErrNonceMismatch   = errors.New("oidcrp: id token nonce mismatch")
+	ErrEmailUnverified = errors.New("oidcrp: provider has not verified the email")
+	ErrInternalAddress = errors.New("oidcrp: provider resolves to an internal address")
+)
+
+// providerTTL is how long a discovery document is reused before it is fetched
+// again, so endpoint changes at the provider are picked up.
+const providerTTL = time.Hour
+
+// Config is one team's relying-party registration at its provider.
+// ClientSecret is empty for public clients, which PKCE alone protects.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// Identity is what a validated ID token says about the user.
+type Identity struct {
+	Subject string
+	Email   string
+}
+
+// Client talks to OpenID providers over httpClient. Discovery documents of
+// issuers used to sign in are cached for providerTTL; the provider's key set
+// refetches keys on rotation.
+type Client struct {
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	providers map[string]cachedProvider
+}
+
+type cachedProvider struct {
+	provider  *oidc.Provider
+	fetchedAt time.Time
+}
+
+// New returns a Client using httpClient. A nil httpClient gets one that
+// refuses to connect to loopback, private and link-local addresses, since
+// issuers are URLs team admins type in.
+func New(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = newPublicHTTPClient()
+	}
+	return &Client{httpClient: httpClient, providers: make(map[string]cachedProvider)}
+}
+
+func newPublicHTTPClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: rejectInternalAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the connection on our behalf, out of the dialer's sight.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+// rejectInternalAddress runs after DNS resolution for every address dialed,
+// so a public name that resolves to an internal address is refused too.
+func rejectInternalAddress(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	addr := addrPort.Addr().Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsUnspecified() {
+		return ErrInternalAddress
+	}
+	return nil
+}
+
+// NewVerifier returns a random PKCE code verifier.
+func NewVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// Discover loads issuer's discovery document, which must name the same
+// issuer. Admins' settings are checked with it before they are saved; the
+// result is not cached, so issuers that are tried but never saved don't
+// linger.
+func (c *Client) Discover(ctx context.Context, issuer string) error {
+	_, err := c.discover(ctx, issuer)
+	return err
+}
+
+// AuthCodeURL is where the browser signs in. The provider sends it back to
+// cfg.RedirectURL with state and a code only redeemable with verifier.
+func (c *Client) AuthCodeURL(ctx context.Context, cfg Config, state, nonce, verifier string) (string, error) {
+	provider, err := c.provider(ctx, cfg.Issuer)
+	if err != nil {
+		return "", err
+	}
+	return oauth2Config(cfg, provider).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange redeems code and validates the ID token that comes back: its
+// signature against the issuer's keys, issuer, audience, expiry at now, and
+// nonce. Only an email the provider marks as verified is returned.
+func (c *Client) Exchange(ctx context.Context, cfg Config, code, verifier, nonce string, now time.Time) (Identity, error) {
+	provider, err := c.provider(ctx, cfg.Issuer)
+	if err != nil {
+		return Identity{}, err
+	}
+	token, err := oauth2Config(cfg, provider).Exchange(oidc.ClientContext(ctx, c.httpClient), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return Identity{}, fmt.Errorf("oidcrp: exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return Identity{}, ErrNoIDToken
+	}
+
+	idVerifier := provider.Verifier(&oidc.Config{
+		ClientID: cfg.ClientID,
+		Now:      func() time.Time { return now },
+	})
+	idToken, err := idVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, fmt.Errorf("oidcrp: verify id token: %w", err)
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(nonce)) != 1 {
+		return Identity{}, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email         string    `json:"email"`
+		EmailVerified claimBool `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, fmt.Errorf("oidcrp: decode claims: %w", err)
+	}
+	if claims.Email == "" || !claims.EmailVerified {
+		return Identity{}, ErrEmailUnverified
+	}
+	return Identity{Subject: idToken.Subject, Email: claims.Email}, nil
+}
+
+func (c *Client) provider(ctx context.Context, issuer string) (*oidc.Provider, error) {
+	c.mu.Lock()
+	cached, ok := c.providers[issuer]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < providerTTL {
+		return cached.provider, nil
+	}
+
+	provider, err := c.discover(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	c.mu.Lock()
+	for key, entry := range c.providers {
+		if now.Sub(entry.fetchedAt) >= providerTTL {
+			delete(c.providers, key)
+		}
+	}
+	c.providers[issuer] = cachedProvider{provider: provider, fetchedAt: now}
+	c.mu.Unlock()
+	return provider, nil
+}
+
+func (c *Client) discover(ctx context.Context, issuer string) (*oidc.Provider, error) {
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, c.httpClient), issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidcrp: discover %s: %w", issuer, err)
+	}
+	return provider, nil
+}
+
+func oauth2Config(cfg Config, provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  cfg.RedirectURL,
+		Scopes:       []string{oidc.ScopeOpenID, "email"},
+	}
+}
+
+// claimBool accepts email_verified as a JSON boolean or, as some providers
+// send it, the string "true".
+type claimBool bool
+
+func (b *claimBool) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	*b = claimBool(value == "true")
+	return nil
+}
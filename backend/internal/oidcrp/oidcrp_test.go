@@ -0,0 +1,165 @@
+package oidcrp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/oidcrp/oidctest"
+)
+
+// authorize follows authURL at the provider and returns the code and state
+// it redirects back with.
+func authorize(t *testing.T, provider *oidctest.Provider, authURL string) (string, string) {
+	t.Helper()
+	client := provider.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected redirect, got %d", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse redirect: %v", err)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestExchange(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		setup    func(p *oidctest.Provider)
+		verifier string
+		now      time.Time
+		wantErr  error
+		wantFail bool
+	}{
+		{name: "verified email"},
+		{name: "unverified email", setup: func(p *oidctest.Provider) { p.EmailVerified = false }, wantErr: ErrEmailUnverified},
+		{name: "replayed nonce", setup: func(p *oidctest.Provider) { p.Nonce = "other" }, wantErr: ErrNonceMismatch},
+		{name: "key not in jwks", setup: func(p *oidctest.Provider) { p.SigningKey = otherKey }, wantFail: true},
+		{name: "wrong pkce verifier", verifier: NewVerifier(), wantFail: true},
+		{name: "expired id token", now: time.Now().Add(time.Hour), wantFail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := oidctest.NewProvider("timesync")
+			defer provider.Close()
+			provider.Email = "ana@acme.com"
+			if tt.setup != nil {
+				tt.setup(provider)
+			}
+
+			ctx := context.Background()
+			client := New(provider.Client())
+			cfg := Config{
+				Issuer:      provider.Issuer,
+				ClientID:    "timesync",
+				RedirectURL: "https://timesync.example/auth/sso/oidc/callback",
+			}
+			verifier := NewVerifier()
+			authURL, err := client.AuthCodeURL(ctx, cfg, "state-1", "nonce-1", verifier)
+			if err != nil {
+				t.Fatalf("auth url: %v", err)
+			}
+			if !strings.Contains(authURL, "code_challenge_method=S256") {
+				t.Fatalf("expected a PKCE challenge in %q", authURL)
+			}
+			code, state := authorize(t, provider, authURL)
+			if state != "state-1" {
+				t.Fatalf("unexpected state %q", state)
+			}
+
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+			now := tt.now
+			if now.IsZero() {
+				now = time.Now()
+			}
+			identity, err := client.Exchange(ctx, cfg, code, verifier, "nonce-1", now)
+			if tt.wantErr != nil || tt.wantFail {
+				if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("exchange: %v", err)
+			}
+			if identity.Email != "ana@acme.com" || identity.Subject == "" {
+				t.Fatalf("unexpected identity: %+v", identity)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	provider := oidctest.NewProvider("timesync")
+	defer provider.Close()
+
+	client := New(provider.Client())
+	if err := client.Discover(context.Background(), provider.Issuer); err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	// The discovery document must name the issuer it was fetched for.
+	if err := client.Discover(context.Background(), provider.Issuer+"/tenant"); err == nil {
+		t.Fatal("expected an unknown issuer to fail discovery")
+	}
+}
+
+func TestDiscoverRefusesInternalAddresses(t *testing.T) {
+	provider := oidctest.NewProvider("timesync")
+	defer provider.Close()
+
+	// The test provider listens on loopback, like an internal service would.
+	err := New(nil).Discover(context.Background(), provider.Issuer)
+	if !errors.Is(err, ErrInternalAddress) {
+		t.Fatalf("expected %v, got %v", ErrInternalAddress, err)
+	}
+
+	for _, address := range []string{"10.0.0.5:443", "192.168.1.1:443", "169.254.169.254:80", "[::1]:443", "[::ffff:127.0.0.1]:443", "0.0.0.0:443"} {
+		if err := rejectInternalAddress("tcp", address, nil); !errors.Is(err, ErrInternalAddress) {
+			t.Fatalf("expected %s to be refused, got %v", address, err)
+		}
+	}
+	if err := rejectInternalAddress("tcp", "93.184.215.14:443", nil); err != nil {
+		t.Fatalf("expected a public address to be allowed, got %v", err)
+	}
+}
+
+func TestDiscoverDoesNotCache(t *testing.T) {
+	provider := oidctest.NewProvider("timesync")
+	defer provider.Close()
+
+	client := New(provider.Client())
+	if err := client.Discover(context.Background(), provider.Issuer); err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if len(client.providers) != 0 {
+		t.Fatalf("expected discovery alone not to cache the issuer, got %d entries", len(client.providers))
+	}
+	if _, err := client.AuthCodeURL(context.Background(), Config{Issuer: provider.Issuer, ClientID: "timesync"}, "state", "nonce", NewVerifier()); err != nil {
+		t.Fatalf("auth code url: %v", err)
+	}
+	if len(client.providers) != 1 {
+		t.Fatalf("expected the issuer used to sign in to be cached, got %d entries", len(client.providers))
+	}
+}
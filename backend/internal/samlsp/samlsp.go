@@ -0,0 +1,249 @@
+// Package samlsp signs users in through a team's SAML 2.0 identity provider.
+// It sends authentication requests with the HTTP-Redirect binding and
+// validates the signed responses the provider posts back to the assertion
+// consumer service against the certificates in the provider's metadata.
+package samlsp
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/crewjam/saml"
+	xrv "github.com/mattermost/xml-roundtrip-validator"
+)
+
+var (
+	ErrNoIDPDescriptor = errors.New("samlsp: metadata describes no identity provider")
+	ErrNoSignOnService = errors.New("samlsp: metadata has no https HTTP-Redirect sign-on service")
+	ErrNoSigningCert   = errors.New("samlsp: metadata has no signing certificate")
+	ErrNoEmail         = errors.New("samlsp: assertion has no email address")
+)
+
+// emailAttributes are the attribute names providers commonly put the
+// user's address under, compared case-insensitively.
+var emailAttributes = []string{
+	"email",
+	"emailaddress",
+	"mail",
+	"urn:oid:0.9.2342.19200300.100.1.3",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+}
+
+// Config is the service provider as one team's identity provider knows it.
+// EntityID and ACSURL are shared by every team; IDPMetadata is the team's
+// uploaded provider metadata.
+type Config struct {
+	EntityID    string
+	ACSURL      string
+	IDPMetadata []byte
+}
+
+// Identity is what a validated assertion says about the user.
+type Identity struct {
+	NameID string
+	Email  string
+}
+
+// ParseMetadata reads an identity provider's metadata, either a single
+// EntityDescriptor or an EntitiesDescriptor holding one. The provider must
+// offer an https HTTP-Redirect sign-on service and publish a usable signing
+// certificate.
+func ParseMetadata(data []byte) (*saml.EntityDescriptor, error) {
+	if err := xrv.Validate(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("samlsp: invalid metadata xml: %w", err)
+	}
+
+	var descriptor *saml.EntityDescriptor
+	var entities saml.EntitiesDescriptor
+	if err := xml.Unmarshal(data, &entities); err == nil {
+		for i := range entities.EntityDescriptors {
+			if len(entities.EntityDescriptors[i].IDPSSODescriptors) > 0 {
+				descriptor = &entities.EntityDescriptors[i]
+				break
+			}
+		}
+	} else {
+		descriptor = &saml.EntityDescriptor{}
+		if err := xml.Unmarshal(data, descriptor); err != nil {
+			return nil, fmt.Errorf("samlsp: parse metadata: %w", err)
+		}
+	}
+	if descriptor == nil || descriptor.EntityID == "" || len(descriptor.IDPSSODescriptors) == 0 {
+		return nil, ErrNoIDPDescriptor
+	}
+
+	location, err := url.Parse(signOnLocation(descriptor))
+	if err != nil || location.Scheme != "https" || location.Host == "" {
+		return nil, ErrNoSignOnService
+	}
+	if !hasSigningCert(descriptor) {
+		return nil, ErrNoSigningCert
+	}
+	return descriptor, nil
+}
+
+// AuthnRequestURL is where the browser signs in. The provider posts its
+// response to cfg.ACSURL along with relayState; the returned request ID is
+// the only InResponseTo that ParseResponse will accept for it.
+func AuthnRequestURL(cfg Config, relayState string) (string, string, error) {
+	sp, err := serviceProvider(cfg)
+	if err != nil {
+		return "", "", err
+	}
+	req, err := sp.MakeAuthenticationRequest(signOnLocation(sp.IDPMetadata), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", "", fmt.Errorf("samlsp: build request: %w", err)
+	}
+	authURL, err := req.Redirect(relayState, sp)
+	if err != nil {
+		return "", "", fmt.Errorf("samlsp: encode request: %w", err)
+	}
+	return authURL.String(), req.ID, nil
+}
+
+// ParseResponse validates the base64 SAMLResponse form value: its signature
+// against the provider's metadata certificates, issuer, destination,
+// audience, validity window, and that it answers requestID. The email comes
+// from a well-known attribute or, failing that, an emailAddress NameID.
+func ParseResponse(cfg Config, samlResponse, requestID string) (Identity, error) {
+	sp, err := serviceProvider(cfg)
+	if err != nil {
+		return Identity{}, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(samlResponse))
+	if err != nil {
+		return Identity{}, fmt.Errorf("samlsp: decode response: %w", err)
+	}
+
+	assertion, err := sp.ParseXMLResponse(raw, []string{requestID}, sp.AcsURL)
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) && invalid.PrivateErr != nil {
+			err = invalid.PrivateErr
+		}
+		return Identity{}, fmt.Errorf("samlsp: invalid response: %w", err)
+	}
+	return assertionIdentity(assertion)
+}
+
+// Metadata is the service provider's own metadata, which admins upload to
+// their identity provider. Responses must be signed and posted back.
+func Metadata(entityID, acsURL string) ([]byte, error) {
+	acs, err := url.Parse(acsURL)
+	if err != nil {
+		return nil, fmt.Errorf("samlsp: parse acs url: %w", err)
+	}
+	sp := &saml.ServiceProvider{
+		EntityID:          entityID,
+		AcsURL:            *acs,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+	}
+	descriptor := sp.Metadata()
+	for i := range descriptor.SPSSODescriptors {
+		sso := &descriptor.SPSSODescriptors[i]
+		var services []saml.IndexedEndpoint
+		for _, service := range sso.AssertionConsumerServices {
+			if service.Binding == saml.HTTPPostBinding {
+				services = append(services, service)
+			}
+		}
+		sso.AssertionConsumerServices = services
+	}
+	out, err := xml.MarshalIndent(descriptor, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("samlsp: encode metadata: %w", err)
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+func serviceProvider(cfg Config) (*saml.ServiceProvider, error) {
+	idp, err := ParseMetadata(cfg.IDPMetadata)
+	if err != nil {
+		return nil, err
+	}
+	acs, err := url.Parse(cfg.ACSURL)
+	if err != nil {
+		return nil, fmt.Errorf("samlsp: parse acs url: %w", err)
+	}
+	return &saml.ServiceProvider{
+		EntityID:          cfg.EntityID,
+		AcsURL:            *acs,
+		IDPMetadata:       idp,
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+	}, nil
+}
+
+func signOnLocation(descriptor *saml.EntityDescriptor) string {
+	for _, idp := range descriptor.IDPSSODescriptors {
+		for _, service := range idp.SingleSignOnServices {
+			if service.Binding == saml.HTTPRedirectBinding {
+				return service.Location
+			}
+		}
+	}
+	return ""
+}
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+func hasSigningCert(descriptor *saml.EntityDescriptor) bool {
+	for _, idp := range descriptor.IDPSSODescriptors {
+		for _, key := range idp.KeyDescriptors {
+			if key.Use != "" && key.Use != "signing" {
+				continue
+			}
+			for _, cert := range key.KeyInfo.X509Data.X509Certificates {
+				der, err := base64.StdEncoding.DecodeString(whitespace.ReplaceAllString(cert.Data, ""))
+				if err != nil {
+					continue
+				}
+				if _, err := x509.ParseCertificate(der); err == nil {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func assertionIdentity(assertion *saml.Assertion) (Identity, error) {
+	var identity Identity
+	if assertion.Subject != nil && assertion.Subject.NameID != nil {
+		identity.NameID = assertion.Subject.NameID.Value
+	}
+	for _, statement := range assertion.AttributeStatements {
+		for _, attr := range statement.Attributes {
+			if !isEmailAttribute(attr) {
+				continue
+			}
+			for _, value := range attr.Values {
+				if email := strings.TrimSpace(value.Value); email != "" {
+					identity.Email = email
+					return identity, nil
+				}
+			}
+		}
+	}
+	if assertion.Subject != nil && assertion.Subject.NameID != nil &&
+		assertion.Subject.NameID.Format == string(saml.EmailAddressNameIDFormat) && identity.NameID != "" {
+		identity.Email = strings.TrimSpace(identity.NameID)
+		return identity, nil
+	}
+	return Identity{}, ErrNoEmail
+}
+
+func isEmailAttribute(attr saml.Attribute) bool {
+	for _, name := range emailAttributes {
+		if strings.EqualFold(attr.Name, name) || strings.EqualFold(attr.FriendlyName, name) {
+			return true
+		}
+	}
+	return false
+}
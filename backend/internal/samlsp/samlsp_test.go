@@ -0,0 +1,212 @@
+package samlsp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/samlsp/samltest"
+
+	"github.com/crewjam/saml"
+)
+
+const (
+	testEntityID = "https://timesync.example/auth/sso/saml/metadata"
+	testACSURL   = "https://timesync.example/auth/sso/saml/acs"
+)
+
+func TestParseResponse(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		setup     func(p *samltest.Provider)
+		requestID string
+		acsURL    string
+		wantErr   error
+		wantFail  bool
+	}{
+		{name: "signed by the provider"},
+		{name: "key not in metadata", setup: func(p *samltest.Provider) { p.SigningKey = otherKey }, wantFail: true},
+		{name: "answers another request", requestID: "id-other", wantFail: true},
+		{name: "meant for another service", acsURL: "https://evil.example/acs", wantFail: true},
+		{name: "stale response", setup: func(p *samltest.Provider) { p.Now = func() time.Time { return time.Now().Add(-time.Hour) } }, wantFail: true},
+		{name: "no email", setup: func(p *samltest.Provider) { p.Email = "" }, wantErr: ErrNoEmail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := samltest.NewProvider()
+			defer provider.Close()
+			provider.Email = "ana@acme.com"
+
+			spMetadata, err := Metadata(testEntityID, testACSURL)
+			if err != nil {
+				t.Fatalf("sp metadata: %v", err)
+			}
+			if err := provider.Register(spMetadata); err != nil {
+				t.Fatalf("register: %v", err)
+			}
+			cfg := Config{EntityID: testEntityID, ACSURL: testACSURL, IDPMetadata: provider.Metadata()}
+			if tt.setup != nil {
+				tt.setup(provider)
+			}
+
+			authURL, requestID, err := AuthnRequestURL(cfg, "state-1")
+			if err != nil {
+				t.Fatalf("auth url: %v", err)
+			}
+			if !strings.HasPrefix(authURL, provider.Server.URL+"/sso?") {
+				t.Fatalf("unexpected auth url %s", authURL)
+			}
+			post, err := provider.SignIn(authURL)
+			if err != nil {
+				t.Fatalf("sign in: %v", err)
+			}
+			if post.URL != testACSURL || post.RelayState != "state-1" {
+				t.Fatalf("unexpected post: %+v", post)
+			}
+
+			if tt.requestID != "" {
+				requestID = tt.requestID
+			}
+			if tt.acsURL != "" {
+				cfg.ACSURL = tt.acsURL
+			}
+			identity, err := ParseResponse(cfg, post.SAMLResponse, requestID)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+			case tt.wantFail:
+				if err == nil {
+					t.Fatalf("expected failure, got %+v", identity)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("parse response: %v", err)
+				}
+				if identity.Email != "ana@acme.com" {
+					t.Fatalf("unexpected identity: %+v", identity)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	provider := samltest.NewProvider()
+	defer provider.Close()
+	metadata := string(provider.Metadata())
+
+	tests := []struct {
+		name     string
+		metadata string
+		wantErr  error
+		wantFail bool
+	}{
+		{name: "provider metadata", metadata: metadata},
+		{name: "inside entities descriptor", metadata: `<EntitiesDescriptor xmlns="urn:oasis:names:tc:SAML:2.0:metadata">` + metadata + `</EntitiesDescriptor>`},
+		{name: "plain http sign-on", metadata: strings.ReplaceAll(metadata, provider.Server.URL+"/sso", "http://idp.acme.com/sso"), wantErr: ErrNoSignOnService},
+		{name: "no certificate", metadata: stripCertificates(metadata), wantErr: ErrNoSigningCert},
+		{name: "service provider metadata", metadata: spMetadata(t), wantErr: ErrNoIDPDescriptor},
+		{name: "not xml", metadata: "{}", wantFail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			descriptor, err := ParseMetadata([]byte(tt.metadata))
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+			case tt.wantFail:
+				if err == nil {
+					t.Fatal("expected failure")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("parse metadata: %v", err)
+				}
+				if descriptor.EntityID != provider.EntityID {
+					t.Fatalf("unexpected entity id %q", descriptor.EntityID)
+				}
+			}
+		})
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	out, err := Metadata(testEntityID, testACSURL)
+	if err != nil {
+		t.Fatalf("metadata: %v", err)
+	}
+	body := string(out)
+	if !strings.Contains(body, `entityID="`+testEntityID+`"`) || !strings.Contains(body, `WantAssertionsSigned="true"`) {
+		t.Fatalf("unexpected metadata: %s", body)
+	}
+	if !strings.Contains(body, `Location="`+testACSURL+`"`) || strings.Contains(body, saml.HTTPArtifactBinding) {
+		t.Fatalf("expected only the POST consumer service: %s", body)
+	}
+}
+
+func TestAssertionIdentity(t *testing.T) {
+	attribute := func(name, value string) []saml.AttributeStatement {
+		return []saml.AttributeStatement{{Attributes: []saml.Attribute{{Name: name, Values: []saml.AttributeValue{{Value: value}}}}}}
+	}
+	subject := func(format, value string) *saml.Subject {
+		return &saml.Subject{NameID: &saml.NameID{Format: format, Value: value}}
+	}
+
+	tests := []struct {
+		name      string
+		assertion saml.Assertion
+		wantEmail string
+	}{
+		{name: "claims uri", assertion: saml.Assertion{AttributeStatements: attribute("http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress", "ana@acme.com")}, wantEmail: "ana@acme.com"},
+		{name: "attribute wins over name id", assertion: saml.Assertion{Subject: subject(string(saml.EmailAddressNameIDFormat), "old@acme.com"), AttributeStatements: attribute("Email", "ana@acme.com")}, wantEmail: "ana@acme.com"},
+		{name: "email name id", assertion: saml.Assertion{Subject: subject(string(saml.EmailAddressNameIDFormat), "ana@acme.com")}, wantEmail: "ana@acme.com"},
+		{name: "opaque name id", assertion: saml.Assertion{Subject: subject(string(saml.PersistentNameIDFormat), "ana@acme.com")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := assertionIdentity(&tt.assertion)
+			if tt.wantEmail == "" {
+				if !errors.Is(err, ErrNoEmail) {
+					t.Fatalf("expected ErrNoEmail, got %+v, %v", identity, err)
+				}
+				return
+			}
+			if err != nil || identity.Email != tt.wantEmail {
+				t.Fatalf("unexpected identity: %+v, %v", identity, err)
+			}
+		})
+	}
+}
+
+func spMetadata(t *testing.T) string {
+	t.Helper()
+	out, err := Metadata(testEntityID, testACSURL)
+	if err != nil {
+		t.Fatalf("metadata: %v", err)
+	}
+	return string(out)
+}
+
+// stripCertificates drops every KeyDescriptor from metadata.
+func stripCertificates(metadata string) string {
+	for {
+		start := strings.Index(metadata, "<KeyDescriptor")
+		if start < 0 {
+			return metadata
+		}
+		end := strings.Index(metadata[start:], "</KeyDescriptor>")
+		metadata = metadata[:start] + metadata[start+end+len("</KeyDescriptor>"):]
+	}
+}
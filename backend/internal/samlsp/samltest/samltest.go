@@ -0,0 +1,211 @@
+// Package samltest runs an in-process SAML 2.0 identity provider for tests
+// of the service provider: metadata carrying a self-signed test certificate,
+// and a sign-on endpoint that signs the preset user straight in and answers
+// with the usual auto-posting form.
+package samltest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/crewjam/saml"
+)
+
+// Provider is a TLS test server acting as the identity provider EntityID.
+// Its fields may be changed between requests to shape the next sign-in.
+type Provider struct {
+	Server   *httptest.Server
+	EntityID string
+
+	// Email is the address of whoever signs in next. It is sent both as the
+	// mail attribute and as an emailAddress NameID.
+	Email string
+	// SigningKey signs responses and assertions. Replace it with a key the
+	// metadata's certificate doesn't match to mint forged responses.
+	SigningKey *rsa.PrivateKey
+	// Now stamps responses; it defaults to time.Now.
+	Now func() time.Time
+
+	cert *x509.Certificate
+	mu   sync.Mutex
+	sp   *saml.EntityDescriptor
+}
+
+// NewProvider starts a provider with a fresh key and certificate. Callers
+// Close it.
+func NewProvider() *Provider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic("samltest: generate key: " + err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "samltest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic("samltest: create certificate: " + err.Error())
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic("samltest: parse certificate: " + err.Error())
+	}
+
+	p := &Provider{
+		SigningKey: key,
+		Now:        time.Now,
+		cert:       cert,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metadata", p.handleMetadata)
+	mux.HandleFunc("GET /sso", p.handleSSO)
+	p.Server = httptest.NewTLSServer(mux)
+	p.EntityID = p.Server.URL + "/metadata"
+	return p
+}
+
+// Client trusts the provider's TLS certificate.
+func (p *Provider) Client() *http.Client {
+	return p.Server.Client()
+}
+
+func (p *Provider) Close() {
+	p.Server.Close()
+}
+
+// Metadata is the provider's metadata as an admin would download it.
+func (p *Provider) Metadata() []byte {
+	out, err := xml.MarshalIndent(p.identityProvider().Metadata(), "", "  ")
+	if err != nil {
+		panic("samltest: encode metadata: " + err.Error())
+	}
+	return out
+}
+
+// Register makes the provider answer requests from the service provider
+// described by metadata. Requests from any other entity are refused.
+func (p *Provider) Register(metadata []byte) error {
+	var descriptor saml.EntityDescriptor
+	if err := xml.Unmarshal(metadata, &descriptor); err != nil {
+		return fmt.Errorf("samltest: parse service provider metadata: %w", err)
+	}
+	p.mu.Lock()
+	p.sp = &descriptor
+	p.mu.Unlock()
+	return nil
+}
+
+// Post is the form the provider's answer page submits to the service
+// provider.
+type Post struct {
+	URL          string
+	SAMLResponse string
+	RelayState   string
+}
+
+var formPattern = regexp.MustCompile(`action="([^"]*)"|name="SAMLResponse" value="([^"]*)"|name="RelayState" value="([^"]*)"`)
+
+// SignIn follows authURL to the sign-on endpoint and returns the form its
+// answer page would post.
+func (p *Provider) SignIn(authURL string) (Post, error) {
+	resp, err := p.Client().Get(authURL)
+	if err != nil {
+		return Post{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Post{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Post{}, fmt.Errorf("samltest: sign-on answered %d: %s", resp.StatusCode, body)
+	}
+
+	var post Post
+	for _, match := range formPattern.FindAllStringSubmatch(string(body), -1) {
+		switch {
+		case match[1] != "":
+			post.URL = html.UnescapeString(match[1])
+		case match[2] != "":
+			post.SAMLResponse = html.UnescapeString(match[2])
+		case match[3] != "":
+			post.RelayState = html.UnescapeString(match[3])
+		}
+	}
+	if post.URL == "" || post.SAMLResponse == "" {
+		return Post{}, errors.New("samltest: answer page has no response form")
+	}
+	return post, nil
+}
+
+// GetServiceProvider implements saml.ServiceProviderProvider.
+func (p *Provider) GetServiceProvider(_ *http.Request, serviceProviderID string) (*saml.EntityDescriptor, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sp == nil || p.sp.EntityID != serviceProviderID {
+		return nil, os.ErrNotExist
+	}
+	return p.sp, nil
+}
+
+func (p *Provider) identityProvider() *saml.IdentityProvider {
+	metadataURL, _ := url.Parse(p.EntityID)
+	ssoURL, _ := url.Parse(p.Server.URL + "/sso")
+	return &saml.IdentityProvider{
+		Key:                     p.SigningKey,
+		Certificate:             p.cert,
+		MetadataURL:             *metadataURL,
+		SSOURL:                  *ssoURL,
+		ServiceProviderProvider: p,
+	}
+}
+
+func (p *Provider) handleMetadata(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.Write(p.Metadata())
+}
+
+func (p *Provider) handleSSO(w http.ResponseWriter, r *http.Request) {
+	req, err := saml.NewIdpAuthnRequest(p.identityProvider(), r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req.Now = p.Now()
+	session := &saml.Session{
+		CreateTime:   req.Now,
+		NameID:       p.Email,
+		NameIDFormat: string(saml.EmailAddressNameIDFormat),
+		UserEmail:    p.Email,
+	}
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(req, session); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := req.WriteResponse(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"timesync/backend/internal/domainverify"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// DomainRecheckAge is how long a verified domain goes before its TXT
+	// record is looked up again.
+	DomainRecheckAge = 24 * time.Hour
+
+	domainRecheckBatchSize = 100
+)
+
+// DomainVerificationJob re-checks the TXT record behind every verified team
+// domain: the primary domain claims in domain_verifications and the aliases
+// in team_domains. A record that is gone revokes the verification, which
+// unlocks the domain for a new claim; a failed lookup leaves it verified and
+// is retried on the next run, so a DNS outage never costs a team its domain.
+func DomainVerificationJob(q sqlc.Querier, resolver domainverify.Resolver, interval time.Duration, logger *slog.Logger) Job {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return Job{
+		Name:     "domain_verification_recheck",
+		Interval: interval,
+		Run: func(ctx context.Context, now time.Time) error {
+			checkedBefore := toTimestamptz(now.Add(-DomainRecheckAge))
+			sources := []func(context.Context) ([]domainCheck, error){
+				func(ctx context.Context) ([]domainCheck, error) {
+					due, err := q.ListDueDomainVerifications(ctx, sqlc.ListDueDomainVerificationsParams{
+						CheckedBefore: checkedBefore,
+						BatchSize:     domainRecheckBatchSize,
+					})
+					checks := make([]domainCheck, 0, len(due))
+					for _, v := range due {
+						checks = append(checks, verificationCheck(q, v))
+					}
+					return checks, err
+				},
+				func(ctx context.Context) ([]domainCheck, error) {
+					due, err := q.ListDueTeamDomains(ctx, sqlc.ListDueTeamDomainsParams{
+						CheckedBefore: checkedBefore,
+						BatchSize:     domainRecheckBatchSize,
+					})
+					checks := make([]domainCheck, 0, len(due))
+					for _, d := range due {
+						checks = append(checks, teamDomainCheck(q, d))
+					}
+					return checks, err
+				},
+			}
+
+			failed := 0
+			for _, list := range sources {
+				for {
+					due, err := list(ctx)
+					if err != nil {
+						return err
+					}
+
+					batchFailed := 0
+					for _, check := range due {
+						if err := recheckDomain(ctx, resolver, check, now, logger); err != nil {
+							batchFailed++
+							logger.Error("failed to recheck domain", slog.String("domain", check.domain), slog.Any("err", err))
+						}
+					}
+					failed += batchFailed
+
+					// Failed rows are still due, so stop rather than fetching
+					// them straight back.
+					if len(due) < domainRecheckBatchSize || batchFailed > 0 || ctx.Err() != nil {
+						break
+					}
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d domain rechecks failed", failed)
+			}
+			return nil
+		},
+	}
+}
+
+// domainCheck is one verified domain whose TXT record is due for a lookup,
+// along with how to record the outcome in the table it came from.
+type domainCheck struct {
+	domain string
+	token  string
+	touch  func(ctx context.Context, checkedAt pgtype.Timestamptz) error
+	revoke func(ctx context.Context, checkedAt pgtype.Timestamptz) error
+}
+
+func verificationCheck(q sqlc.Querier, v sqlc.DomainVerification) domainCheck {
+	return domainCheck{
+		domain: v.Domain,
+		token:  v.Token,
+		touch: func(ctx context.Context, checkedAt pgtype.Timestamptz) error {
+			return q.TouchDomainVerification(ctx, sqlc.TouchDomainVerificationParams{ID: v.ID, CheckedAt: checkedAt})
+		},
+		revoke: func(ctx context.Context, checkedAt pgtype.Timestamptz) error {
+			return q.RevokeDomainVerification(ctx, sqlc.RevokeDomainVerificationParams{ID: v.ID, CheckedAt: checkedAt})
+		},
+	}
+}
+
+// teamDomainCheck covers an alias, or an alias that has since become the
+// primary domain; revoking the latter also clears the team's verification.
+func teamDomainCheck(q sqlc.Querier, d sqlc.TeamDomain) domainCheck {
+	return domainCheck{
+		domain: d.Domain,
+		token:  d.Token.String,
+		touch: func(ctx context.Context, checkedAt pgtype.Timestamptz) error {
+			return q.TouchTeamDomain(ctx, sqlc.TouchTeamDomainParams{ID: d.ID, CheckedAt: checkedAt})
+		},
+		revoke: func(ctx context.Context, checkedAt pgtype.Timestamptz) error {
+			return q.RevokeTeamDomain(ctx, sqlc.RevokeTeamDomainParams{ID: d.ID, CheckedAt: checkedAt})
+		},
+	}
+}
+
+func recheckDomain(ctx context.Context, resolver domainverify.Resolver, check domainCheck, now time.Time, logger *slog.Logger) error {
+	published, err := domainverify.Check(ctx, resolver, check.domain, check.token)
+	if err != nil {
+		return err
+	}
+	if published {
+		return check.touch(ctx, toTimestamptz(now))
+	}
+
+	logger.Warn("domain verification record removed", slog.String("domain", check.domain))
+	return check.revoke(ctx, toTimestamptz(now))
+}
@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// domainQuerier keeps verifications and team domains in memory; due rows
+// are the verified ones not checked since CheckedBefore.
+type domainQuerier struct {
+	sqlc.Querier
+	verifications map[pgtype.UUID]*sqlc.DomainVerification
+	domains       map[pgtype.UUID]*sqlc.TeamDomain
+	revoked       []string
+}
+
+func (q *domainQuerier) ListDueDomainVerifications(_ context.Context, arg sqlc.ListDueDomainVerificationsParams) ([]sqlc.DomainVerification, error) {
+	var rows []sqlc.DomainVerification
+	for _, v := range q.verifications {
+		if !v.VerifiedAt.Valid || (v.CheckedAt.Valid && !v.CheckedAt.Time.Before(arg.CheckedBefore.Time)) {
+			continue
+		}
+		if len(rows) == int(arg.BatchSize) {
+			break
+		}
+		rows = append(rows, *v)
+	}
+	return rows, nil
+}
+
+func (q *domainQuerier) TouchDomainVerification(_ context.Context, arg sqlc.TouchDomainVerificationParams) error {
+	q.verifications[arg.ID].CheckedAt = arg.CheckedAt
+	return nil
+}
+
+func (q *domainQuerier) RevokeDomainVerification(_ context.Context, arg sqlc.RevokeDomainVerificationParams) error {
+	v := q.verifications[arg.ID]
+	v.VerifiedAt = pgtype.Timestamptz{}
+	v.CheckedAt = arg.CheckedAt
+	q.revoked = append(q.revoked, v.Domain)
+	return nil
+}
+
+func (q *domainQuerier) ListDueTeamDomains(_ context.Context, arg sqlc.ListDueTeamDomainsParams) ([]sqlc.TeamDomain, error) {
+	var rows []sqlc.TeamDomain
+	for _, d := range q.domains {
+		if !d.VerifiedAt.Valid || !d.Token.Valid || (d.CheckedAt.Valid && !d.CheckedAt.Time.Before(arg.CheckedBefore.Time)) {
+			continue
+		}
+		if len(rows) == int(arg.BatchSize) {
+			break
+		}
+		rows = append(rows, *d)
+	}
+	return rows, nil
+}
+
+func (q *domainQuerier) TouchTeamDomain(_ context.Context, arg sqlc.TouchTeamDomainParams) error {
+	q.domains[arg.ID].CheckedAt = arg.CheckedAt
+	return nil
+}
+
+func (q *domainQuerier) RevokeTeamDomain(_ context.Context, arg sqlc.RevokeTeamDomainParams) error {
+	d := q.domains[arg.ID]
+	d.VerifiedAt = pgtype.Timestamptz{}
+	d.CheckedAt = arg.CheckedAt
+	q.revoked = append(q.revoked, d.Domain)
+	return nil
+}
+
+type txtResolver struct {
+	records map[string][]string
+	down    map[string]bool
+}
+
+func (r txtResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if r.down[name] {
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+	}
+	records, ok := r.records[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func TestDomainVerificationJob(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	stale := toTimestamptz(now.Add(-2 * DomainRecheckAge))
+	q := &domainQuerier{verifications: map[pgtype.UUID]*sqlc.DomainVerification{}, domains: map[pgtype.UUID]*sqlc.TeamDomain{}}
+	add := func(b byte, domain string, checkedAt pgtype.Timestamptz) *sqlc.DomainVerification {
+		id := pgtype.UUID{Bytes: [16]byte{b}, Valid: true}
+		v := &sqlc.DomainVerification{ID: id, Domain: domain, Token: "tok", VerifiedAt: stale, CheckedAt: checkedAt}
+		q.verifications[id] = v
+		return v
+	}
+	kept := add(1, "acme.com", stale)
+	removed := add(2, "gone.com", stale)
+	fresh := add(3, "fresh.com", toTimestamptz(now.Add(-time.Hour)))
+	outage := add(4, "flaky.com", stale)
+
+	resolver := txtResolver{
+		records: map[string][]string{"_timesync.acme.com": {"timesync-domain-verification=tok"}},
+		down:    map[string]bool{"_timesync.flaky.com": true},
+	}
+	job := DomainVerificationJob(q, resolver, time.Hour, nil)
+	if err := job.Run(context.Background(), now); err == nil {
+		t.Fatalf("expected the failed lookup to be reported")
+	}
+
+	if !kept.VerifiedAt.Valid || !kept.CheckedAt.Time.Equal(now) {
+		t.Fatalf("expected acme.com to stay verified and be touched, got %+v", kept)
+	}
+	if removed.VerifiedAt.Valid || len(q.revoked) != 1 || q.revoked[0] != "gone.com" {
+		t.Fatalf("expected gone.com to be revoked, got %v", q.revoked)
+	}
+	if !fresh.CheckedAt.Time.Equal(now.Add(-time.Hour)) {
+		t.Fatalf("expected fresh.com to be skipped")
+	}
+	if !outage.VerifiedAt.Valid || outage.CheckedAt != stale {
+		t.Fatalf("expected flaky.com to be left for the next run, got %+v", outage)
+	}
+}
+
+func TestDomainVerificationJobRechecksAliases(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	stale := toTimestamptz(now.Add(-2 * DomainRecheckAge))
+	q := &domainQuerier{verifications: map[pgtype.UUID]*sqlc.DomainVerification{}, domains: map[pgtype.UUID]*sqlc.TeamDomain{}}
+	add := func(b byte, domain string, token pgtype.Text, verifiedAt pgtype.Timestamptz) *sqlc.TeamDomain {
+		id := pgtype.UUID{Bytes: [16]byte{b}, Valid: true}
+		d := &sqlc.TeamDomain{ID: id, Domain: domain, Token: token, VerifiedAt: verifiedAt, CheckedAt: verifiedAt}
+		q.domains[id] = d
+		return d
+	}
+	tok := pgtype.Text{String: "tok", Valid: true}
+	kept := add(1, "acme.io", tok, stale)
+	removed := add(2, "acme.dev", tok, stale)
+	pending := add(3, "acme.net", tok, pgtype.Timestamptz{})
+	primary := add(4, "acme.com", pgtype.Text{}, stale)
+
+	resolver := txtResolver{records: map[string][]string{"_timesync.acme.io": {"timesync-domain-verification=tok"}}}
+	job := DomainVerificationJob(q, resolver, time.Hour, nil)
+	if err := job.Run(context.Background(), now); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if !kept.VerifiedAt.Valid || !kept.CheckedAt.Time.Equal(now) {
+		t.Fatalf("expected acme.io to stay verified and be touched, got %+v", kept)
+	}
+	if removed.VerifiedAt.Valid || len(q.revoked) != 1 || q.revoked[0] != "acme.dev" {
+		t.Fatalf("expected acme.dev to be revoked, got %v", q.revoked)
+	}
+	if pending.CheckedAt.Valid || primary.CheckedAt != stale {
+		t.Fatalf("expected unverified aliases and tokenless primaries to be skipped")
+	}
+}
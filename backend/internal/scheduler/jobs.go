@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// rateLimitCounterRetention bounds how long IP rate-limit windows are kept;
+// it only needs to exceed the longest configured limiter window.
+const rateLimitCounterRetention = 24 * time.Hour
+
+// CleanupJobs purges rows that no request can use any more. Revoked
+// sessions, and expired or redeemed invites, are kept for retention so
+// recent history stays visible to admins. Rotated sessions live until their
+// refresh token expires: a replayed token must still find its row for the
+// refresh handler to detect the reuse and revoke the family.
+func CleanupJobs(q sqlc.Querier, interval, retention time.Duration, logger *slog.Logger) []Job {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return []Job{
+		cleanupJob("cleanup_email_verification_codes", interval, logger, func(ctx context.Context, now time.Time) (int64, error) {
+			return q.DeleteExpiredEmailVerificationCodes(ctx, toTimestamptz(now))
+		}),
+		cleanupJob("cleanup_auth_sessions", interval, logger, func(ctx context.Context, now time.Time) (int64, error) {
+			return q.DeleteStaleAuthSessions(ctx, sqlc.DeleteStaleAuthSessionsParams{
+				RefreshExpiresAt: toTimestamptz(now),
+				RevokedAt:        toTimestamptz(now.Add(-retention)),
+			})
+		}),
+		cleanupJob("cleanup_device_pairings", interval, logger, func(ctx context.Context, now time.Time) (int64, error) {
+			return q.DeleteExpiredDevicePairings(ctx, toTimestamptz(now))
+		}),
+		cleanupJob("cleanup_sso_logins", interval, logger, func(ctx context.Context, now time.Time) (int64, error) {
+			return q.DeleteExpiredSsoLogins(ctx, toTimestamptz(now))
+		}),
+		cleanupJob("cleanup_invite_codes", interval, logger, func(ctx context.Context, now time.Time) (int64, error) {
+			return q.DeleteStaleInviteCodes(ctx, toTimestamptz(now.Add(-retention)))
+		}),
+		cleanupJob("cleanup_attempt_limits", interval, logger, func(ctx context.Context, now time.Time) (int64, error) {
+			return q.DeleteExpiredAttemptLimits(ctx, toTimestamptz(now))
+		}),
+		cleanupJob("cleanup_rate_limit_counters", interval, logger, func(ctx context.Context, now time.Time) (int64, error) {
+			return q.DeleteExpiredRateLimitCounters(ctx, toTimestamptz(now.Add(-rateLimitCounterRetention)))
+		}),
+	}
+}
+
+func cleanupJob(name string, interval time.Duration, logger *slog.Logger, purge func(ctx context.Context, now time.Time) (int64, error)) Job {
+	return Job{
+		Name:     name,
+		Interval: interval,
+		Run: func(ctx context.Context, now time.Time) error {
+			deleted, err := purge(ctx, now)
+			if err != nil {
+				return err
+			}
+			if deleted > 0 {
+				logger.Info("cleanup removed rows", slog.String("job", name), slog.Int64("rows", deleted))
+			}
+			return nil
+		},
+	}
+}
+
+func toTimestamptz(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{Time: t, Valid: true}
+}
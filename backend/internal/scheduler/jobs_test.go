@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// cleanupQuerier implements only the queries the cleanup jobs use.
+type cleanupQuerier struct {
+	sqlc.Querier
+	codesBefore    pgtype.Timestamptz
+	sessionsParams sqlc.DeleteStaleAuthSessionsParams
+	invitesBefore  pgtype.Timestamptz
+	pairingsBefore pgtype.Timestamptz
+	ssoBefore      pgtype.Timestamptz
+	limitsBefore   pgtype.Timestamptz
+	countersBefore pgtype.Timestamptz
+	err            error
+}
+
+func (q *cleanupQuerier) DeleteExpiredAttemptLimits(_ context.Context, now pgtype.Timestamptz) (int64, error) {
+	q.limitsBefore = now
+	return 0, q.err
+}
+
+func (q *cleanupQuerier) DeleteExpiredRateLimitCounters(_ context.Context, windowStart pgtype.Timestamptz) (int64, error) {
+	q.countersBefore = windowStart
+	return 4, q.err
+}
+
+func (q *cleanupQuerier) DeleteExpiredEmailVerificationCodes(_ context.Context, expiresAt pgtype.Timestamptz) (int64, error) {
+	q.codesBefore = expiresAt
+	return 2, q.err
+}
+
+func (q *cleanupQuerier) DeleteStaleAuthSessions(_ context.Context, arg sqlc.DeleteStaleAuthSessionsParams) (int64, error) {
+	q.sessionsParams = arg
+	return 0, q.err
+}
+
+func (q *cleanupQuerier) DeleteExpiredDevicePairings(_ context.Context, expiresAt pgtype.Timestamptz) (int64, error) {
+	q.pairingsBefore = expiresAt
+	return 3, q.err
+}
+
+func (q *cleanupQuerier) DeleteExpiredSsoLogins(_ context.Context, expiresAt pgtype.Timestamptz) (int64, error) {
+	q.ssoBefore = expiresAt
+	return 0, q.err
+}
+
+func (q *cleanupQuerier) DeleteStaleInviteCodes(_ context.Context, expiresAt pgtype.Timestamptz) (int64, error) {
+	q.invitesBefore = expiresAt
+	return 1, q.err
+}
+
+func TestCleanupJobs(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	retention := 7 * 24 * time.Hour
+	q := &cleanupQuerier{}
+
+	jobs := CleanupJobs(q, time.Hour, retention, nil)
+	if len(jobs) != 7 {
+		t.Fatalf("expected 7 jobs, got %d", len(jobs))
+	}
+	for _, job := range jobs {
+		if job.Interval != time.Hour {
+			t.Fatalf("unexpected interval for %s: %v", job.Name, job.Interval)
+		}
+		if err := job.Run(context.Background(), now); err != nil {
+			t.Fatalf("job %s failed: %v", job.Name, err)
+		}
+	}
+
+	if !q.codesBefore.Time.Equal(now) {
+		t.Fatalf("unexpected code cutoff: %v", q.codesBefore.Time)
+	}
+	if !q.sessionsParams.RefreshExpiresAt.Time.Equal(now) || !q.sessionsParams.RevokedAt.Time.Equal(now.Add(-retention)) {
+		t.Fatalf("unexpected session cutoffs: %+v", q.sessionsParams)
+	}
+	if !q.invitesBefore.Time.Equal(now.Add(-retention)) {
+		t.Fatalf("unexpected invite cutoff: %v", q.invitesBefore.Time)
+	}
+	if !q.pairingsBefore.Time.Equal(now) {
+		t.Fatalf("unexpected pairing cutoff: %v", q.pairingsBefore.Time)
+	}
+	if !q.ssoBefore.Time.Equal(now) {
+		t.Fatalf("unexpected sso login cutoff: %v", q.ssoBefore.Time)
+	}
+	if !q.limitsBefore.Time.Equal(now) {
+		t.Fatalf("unexpected attempt limit cutoff: %v", q.limitsBefore.Time)
+	}
+	if !q.countersBefore.Time.Equal(now.Add(-rateLimitCounterRetention)) {
+		t.Fatalf("unexpected rate limit counter cutoff: %v", q.countersBefore.Time)
+	}
+}
+
+func TestCleanupJobsReturnErrors(t *testing.T) {
+	q := &cleanupQuerier{err: errors.New("db down")}
+
+	for _, job := range CleanupJobs(q, time.Hour, time.Hour, nil) {
+		if err := job.Run(context.Background(), time.Now()); err == nil {
+			t.Fatalf("expected %s to fail", job.Name)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LeaderLockKey is the advisory lock key shared by every replica ("timesync").
+const LeaderLockKey int64 = 0x74696d6573796e63
+
+// PostgresLeader elects a single leader across replicas with a session-level
+// advisory lock. The lock lives as long as the connection holding it, so that
+// connection is kept out of the pool while this replica leads.
+type PostgresLeader struct {
+	pool *pgxpool.Pool
+	key  int64
+	conn *pgxpool.Conn
+}
+
+func NewPostgresLeader(pool *pgxpool.Pool, key int64) *PostgresLeader {
+	return &PostgresLeader{pool: pool, key: key}
+}
+
+func (l *PostgresLeader) TryAcquire(ctx context.Context) (bool, error) {
+	if l.conn != nil {
+		if err := l.conn.Ping(ctx); err == nil {
+			return true, nil
+		}
+		// The session, and the lock with it, is gone.
+		l.conn.Release()
+		l.conn = nil
+	}
+
+	conn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	acquired, err := sqlc.New(conn).TryAdvisoryLock(ctx, l.key)
+	if err != nil || !acquired {
+		conn.Release()
+		return false, err
+	}
+	l.conn = conn
+	return true, nil
+}
+
+func (l *PostgresLeader) Release(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+	err := sqlc.New(l.conn).AdvisoryUnlock(ctx, l.key)
+	l.conn.Release()
+	l.conn = nil
+	return err
+}
@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"timesync/backend/internal/mailer"
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	// ReminderCadence is how long someone stays hidden indefinitely before
+	// each reminder.
+	ReminderCadence = 30 * 24 * time.Hour
+
+	reminderBatchSize = 100
+)
+
+// ResumeURLFunc builds the signed one-click link for a claimed reminder.
+type ResumeURLFunc func(userID pgtype.UUID, reminderAt time.Time) (string, error)
+
+// ReminderJob emails users who have been hidden indefinitely for a full
+// cadence. Each due row is claimed by stamping last_reminder_at in a single
+// SKIP LOCKED update, so concurrent workers never pick the same user; a failed
+// send puts the previous stamp back so the next run retries it.
+func ReminderJob(q sqlc.Querier, m mailer.Mailer, resumeURL ResumeURLFunc, interval time.Duration, logger *slog.Logger) Job {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return Job{
+		Name:     "hidden_sharing_reminders",
+		Interval: interval,
+		Run: func(ctx context.Context, now time.Time) error {
+			// Postgres keeps microseconds; the resume link must match the
+			// stored value exactly.
+			claimedAt := now.Truncate(time.Microsecond)
+			failed := 0
+			for {
+				due, err := q.ClaimDueHiddenReminders(ctx, sqlc.ClaimDueHiddenRemindersParams{
+					DueBefore: toTimestamptz(now.Add(-ReminderCadence)),
+					BatchSize: reminderBatchSize,
+					ClaimedAt: toTimestamptz(claimedAt),
+				})
+				if err != nil {
+					return err
+				}
+
+				for _, row := range due {
+					if err := sendReminder(ctx, m, resumeURL, row, claimedAt); err != nil {
+						failed++
+						logger.Error("failed to send sharing reminder", slog.String("email", row.Email), slog.Any("err", err))
+						if err := q.RestoreHiddenReminder(ctx, sqlc.RestoreHiddenReminderParams{
+							PreviousReminderAt: row.PreviousReminderAt,
+							UserID:             row.UserID,
+							ClaimedAt:          toTimestamptz(claimedAt),
+						}); err != nil {
+							logger.Error("failed to restore sharing reminder", slog.String("email", row.Email), slog.Any("err", err))
+						}
+					}
+				}
+
+				// Restored rows are due again, so stop after a failure rather
+				// than claiming them straight back.
+				if len(due) < reminderBatchSize || failed > 0 || ctx.Err() != nil {
+					break
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d sharing reminders failed", failed)
+			}
+			return nil
+		},
+	}
+}
+
+func sendReminder(ctx context.Context, m mailer.Mailer, resumeURL ResumeURLFunc, row sqlc.ClaimDueHiddenRemindersRow, claimedAt time.Time) error {
+	link, err := resumeURL(row.UserID, claimedAt)
+	if err != nil {
+		return err
+	}
+	return m.SendSharingReminder(ctx, row.Email, link)
+}
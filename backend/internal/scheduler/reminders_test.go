@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"timesync/backend/internal/sqlc"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type reminderState struct {
+	email          string
+	lastReminderAt pgtype.Timestamptz
+	hiddenSince    time.Time
+}
+
+// reminderQuerier keeps visibility rows in memory and claims them atomically,
+// the way the SKIP LOCKED update does.
+type reminderQuerier struct {
+	sqlc.Querier
+	mu       sync.Mutex
+	users    map[pgtype.UUID]*reminderState
+	restored int
+}
+
+func (q *reminderQuerier) ClaimDueHiddenReminders(_ context.Context, arg sqlc.ClaimDueHiddenRemindersParams) ([]sqlc.ClaimDueHiddenRemindersRow, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var rows []sqlc.ClaimDueHiddenRemindersRow
+	for id, state := range q.users {
+		since := state.hiddenSince
+		if state.lastReminderAt.Valid {
+			since = state.lastReminderAt.Time
+		}
+		if since.After(arg.DueBefore.Time) || len(rows) == int(arg.BatchSize) {
+			continue
+		}
+		rows = append(rows, sqlc.ClaimDueHiddenRemindersRow{UserID: id, Email: state.email, PreviousReminderAt: state.lastReminderAt})
+		state.lastReminderAt = arg.ClaimedAt
+	}
+	return rows, nil
+}
+
+func (q *reminderQuerier) RestoreHiddenReminder(_ context.Context, arg sqlc.RestoreHiddenReminderParams) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	state := q.users[arg.UserID]
+	if state.lastReminderAt == arg.ClaimedAt {
+		state.lastReminderAt = arg.PreviousReminderAt
+		q.restored++
+	}
+	return nil
+}
+
+type reminderMailer struct {
+	mu    sync.Mutex
+	sent  map[string]int
+	links map[string]string
+	fail  string
+}
+
+func (m *reminderMailer) SendVerificationCode(context.Context, string, string) error { return nil }
+
+func (m *reminderMailer) SendSignInLink(context.Context, string, string, string) error { return nil }
+
+func (m *reminderMailer) SendInviteCode(context.Context, string, string, string) error { return nil }
+
+func (m *reminderMailer) SendEmailChanged(context.Context, string, string) error { return nil }
+
+func (m *reminderMailer) SendSharingReminder(_ context.Context, email, resumeURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if email == m.fail {
+		return errors.New("smtp down")
+	}
+	m.sent[email]++
+	m.links[email] = resumeURL
+	return nil
+}
+
+func newReminderFixture(now time.Time) (*reminderQuerier, *reminderMailer) {
+	q := &reminderQuerier{users: map[pgtype.UUID]*reminderState{
+		{Bytes: [16]byte{1}, Valid: true}: {email: "due@example.com", hiddenSince: now.Add(-31 * 24 * time.Hour)},
+		{Bytes: [16]byte{2}, Valid: true}: {email: "recent@example.com", hiddenSince: now.Add(-time.Hour)},
+		{Bytes: [16]byte{3}, Valid: true}: {
+			email:          "again@example.com",
+			hiddenSince:    now.Add(-90 * 24 * time.Hour),
+			lastReminderAt: pgtype.Timestamptz{Time: now.Add(-30 * 24 * time.Hour), Valid: true},
+		},
+	}}
+	m := &reminderMailer{sent: make(map[string]int), links: make(map[string]string)}
+	return q, m
+}
+
+func resumeURLFor(userID pgtype.UUID, reminderAt time.Time) (string, error) {
+	return fmt.Sprintf("https://example.com/resume/%d/%s", userID.Bytes[0], reminderAt.Format(time.RFC3339Nano)), nil
+}
+
+func TestReminderJobSendsDueReminders(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 123456789, time.UTC)
+	q, m := newReminderFixture(now)
+
+	job := ReminderJob(q, m, resumeURLFor, time.Hour, nil)
+	if err := job.Run(context.Background(), now); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+
+	if m.sent["due@example.com"] != 1 || m.sent["again@example.com"] != 1 || m.sent["recent@example.com"] != 0 {
+		t.Fatalf("unexpected sends: %v", m.sent)
+	}
+	claimedAt := now.Truncate(time.Microsecond)
+	if want := "https://example.com/resume/1/" + claimedAt.Format(time.RFC3339Nano); m.links["due@example.com"] != want {
+		t.Fatalf("unexpected link: %q", m.links["due@example.com"])
+	}
+	if !q.users[pgtype.UUID{Bytes: [16]byte{1}, Valid: true}].lastReminderAt.Time.Equal(claimedAt) {
+		t.Fatal("expected last_reminder_at to be stamped")
+	}
+
+	// A second pass the same day finds nothing due.
+	if err := job.Run(context.Background(), now.Add(time.Hour)); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	if m.sent["due@example.com"] != 1 {
+		t.Fatalf("expected reminder to go out once, got %d", m.sent["due@example.com"])
+	}
+}
+
+func TestReminderJobConcurrentWorkersSendOnce(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	q, m := newReminderFixture(now)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job := ReminderJob(q, m, resumeURLFor, time.Hour, nil)
+			if err := job.Run(context.Background(), now); err != nil {
+				t.Errorf("run error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if m.sent["due@example.com"] != 1 || m.sent["again@example.com"] != 1 {
+		t.Fatalf("expected one reminder each, got %v", m.sent)
+	}
+}
+
+func TestReminderJobRestoresFailedSends(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	q, m := newReminderFixture(now)
+	m.fail = "again@example.com"
+	previous := q.users[pgtype.UUID{Bytes: [16]byte{3}, Valid: true}].lastReminderAt
+
+	job := ReminderJob(q, m, resumeURLFor, time.Hour, nil)
+	if err := job.Run(context.Background(), now); err == nil {
+		t.Fatal("expected error for failed send")
+	}
+
+	if q.restored != 1 {
+		t.Fatalf("expected one restore, got %d", q.restored)
+	}
+	if got := q.users[pgtype.UUID{Bytes: [16]byte{3}, Valid: true}].lastReminderAt; got != previous {
+		t.Fatalf("expected previous reminder time to be restored, got %v", got)
+	}
+	if m.sent["due@example.com"] != 1 {
+		t.Fatalf("expected other reminders to still go out, got %v", m.sent)
+	}
+}
+
+func TestReminderJobLinkFailure(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	q, m := newReminderFixture(now)
+	failingURL := func(pgtype.UUID, time.Time) (string, error) {
+		return "", errors.New("no signing key")
+	}
+
+	job := ReminderJob(q, m, failingURL, time.Hour, nil)
+	if err := job.Run(context.Background(), now); err == nil {
+		t.Fatal("expected error when links cannot be built")
+	}
+	if len(m.sent) != 0 || q.restored != 2 {
+		t.Fatalf("expected nothing sent and both claims restored, got %v/%d", m.sent, q.restored)
+	}
+}
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job is a periodic task. Run receives the scheduler's clock reading so jobs
+// can be tested without sleeping.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context, now time.Time) error
+}
+
+// Leader decides which replica runs jobs. TryAcquire is called on every poll
+// and must report false once leadership has been lost.
+type Leader interface {
+	TryAcquire(ctx context.Context) (bool, error)
+	Release(ctx context.Context) error
+}
+
+type Scheduler struct {
+	leader  Leader
+	logger  *slog.Logger
+	clock   func() time.Time
+	poll    time.Duration
+	jobs    []Job
+	lastRun map[string]time.Time
+	leading bool
+}
+
+func New(leader Leader, poll time.Duration, logger *slog.Logger, jobs ...Job) *Scheduler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Scheduler{
+		leader:  leader,
+		logger:  logger,
+		clock:   time.Now,
+		poll:    poll,
+		jobs:    jobs,
+		lastRun: make(map[string]time.Time),
+	}
+}
+
+// Run polls for leadership and runs due jobs until ctx is cancelled, then
+// gives up leadership so another replica can take over straight away.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.poll)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			s.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context) {
+	leading, err := s.leader.TryAcquire(ctx)
+	if err != nil && ctx.Err() == nil {
+		s.logger.Error("failed to acquire scheduler leadership", slog.Any("err", err))
+	}
+	if leading != s.leading {
+		s.logger.Info("scheduler leadership changed", slog.Bool("leader", leading))
+		s.leading = leading
+		// A new leader cannot know when the previous one last ran each job,
+		// so it starts every job afresh.
+		clear(s.lastRun)
+	}
+	if !leading {
+		return
+	}
+
+	for _, job := range s.jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		now := s.clock()
+		if last, ok := s.lastRun[job.Name]; ok && now.Sub(last) < job.Interval {
+			continue
+		}
+		s.lastRun[job.Name] = now
+		if err := job.Run(ctx, now); err != nil && ctx.Err() == nil {
+			s.logger.Error("scheduled job failed", slog.String("job", job.Name), slog.Any("err", err))
+		}
+	}
+}
+
+func (s *Scheduler) release() {
+	if !s.leading {
+		return
+	}
+	s.leading = false
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.leader.Release(ctx); err != nil {
+		s.logger.Error("failed to release scheduler leadership", slog.Any("err", err))
+	}
+}
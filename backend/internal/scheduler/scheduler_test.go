@@ -0,0 +1,183 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeLeader struct {
+	mu       sync.Mutex
+	leading  bool
+	err      error
+	acquires int
+	released int
+}
+
+func (l *fakeLeader) TryAcquire(context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.acquires++
+	return l.leading, l.err
+}
+
+func (l *fakeLeader) Release(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.released++
+	return nil
+}
+
+func (l *fakeLeader) set(leading bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.leading = leading
+}
+
+func countingJob(name string, interval time.Duration, runs *[]time.Time) Job {
+	return Job{
+		Name:     name,
+		Interval: interval,
+		Run: func(_ context.Context, now time.Time) error {
+			*runs = append(*runs, now)
+			return nil
+		},
+	}
+}
+
+func TestTickRunsDueJobsOnlyWhenLeading(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	leader := &fakeLeader{}
+	var runs []time.Time
+	s := New(leader, time.Second, nil, countingJob("cleanup", time.Hour, &runs))
+	s.clock = func() time.Time { return now }
+	ctx := context.Background()
+
+	s.tick(ctx)
+	if len(runs) != 0 {
+		t.Fatalf("expected follower not to run jobs, got %d runs", len(runs))
+	}
+
+	leader.set(true)
+	s.tick(ctx)
+	if len(runs) != 1 || !runs[0].Equal(now) {
+		t.Fatalf("expected new leader to run job immediately, got %v", runs)
+	}
+
+	now = now.Add(30 * time.Minute)
+	s.tick(ctx)
+	if len(runs) != 1 {
+		t.Fatalf("expected job not to run before its interval, got %d runs", len(runs))
+	}
+
+	now = now.Add(30 * time.Minute)
+	s.tick(ctx)
+	if len(runs) != 2 {
+		t.Fatalf("expected job to run after its interval, got %d runs", len(runs))
+	}
+}
+
+func TestTickRestartsJobsAfterRegainingLeadership(t *testing.T) {
+	now := time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)
+	leader := &fakeLeader{leading: true}
+	var runs []time.Time
+	s := New(leader, time.Second, nil, countingJob("cleanup", time.Hour, &runs))
+	s.clock = func() time.Time { return now }
+	ctx := context.Background()
+
+	s.tick(ctx)
+	leader.set(false)
+	now = now.Add(time.Minute)
+	s.tick(ctx)
+	leader.set(true)
+	now = now.Add(time.Minute)
+	s.tick(ctx)
+
+	if len(runs) != 2 {
+		t.Fatalf("expected job to run again after regaining leadership, got %d runs", len(runs))
+	}
+}
+
+func TestTickContinuesAfterJobFailure(t *testing.T) {
+	leader := &fakeLeader{leading: true}
+	var runs []time.Time
+	failing := Job{
+		Name:     "failing",
+		Interval: time.Hour,
+		Run: func(context.Context, time.Time) error {
+			return errors.New("boom")
+		},
+	}
+	s := New(leader, time.Second, nil, failing, countingJob("cleanup", time.Hour, &runs))
+
+	s.tick(context.Background())
+
+	if len(runs) != 1 {
+		t.Fatalf("expected later job to run, got %d runs", len(runs))
+	}
+}
+
+func TestTickAcquireError(t *testing.T) {
+	leader := &fakeLeader{err: errors.New("db down")}
+	var runs []time.Time
+	s := New(leader, time.Second, nil, countingJob("cleanup", time.Hour, &runs))
+
+	s.tick(context.Background())
+
+	if len(runs) != 0 {
+		t.Fatalf("expected no runs without leadership, got %d", len(runs))
+	}
+}
+
+func TestRunStopsOnCancelAndReleasesLeadership(t *testing.T) {
+	leader := &fakeLeader{leading: true}
+	ran := make(chan struct{}, 1)
+	job := Job{
+		Name:     "cleanup",
+		Interval: time.Hour,
+		Run: func(context.Context, time.Time) error {
+			select {
+			case ran <- struct{}{}:
+			default:
+			}
+			return nil
+		},
+	}
+	s := New(leader, time.Millisecond, nil, job)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+
+	<-ran
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Run to return after cancel")
+	}
+
+	leader.mu.Lock()
+	defer leader.mu.Unlock()
+	if leader.released != 1 {
+		t.Fatalf("expected leadership to be released once, got %d", leader.released)
+	}
+}
+
+func TestRunFollowerDoesNotRelease(t *testing.T) {
+	leader := &fakeLeader{}
+	s := New(leader, time.Millisecond, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Run(ctx)
+
+	if leader.released != 0 {
+		t.Fatalf("expected follower not to release, got %d", leader.released)
+	}
+}
@@ -19,12 +19,31 @@ INSERT INTO auth_sessions (
     access_expires_at,
     refresh_token_hash,
     refresh_expires_at,
+    device_name,
+    platform,
+    app_version,
+    family_id,
+    parent_id,
     created_at
 )
-VALUES ($1, $2, $3, $4, $5, $6, now())
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    COALESCE($10::uuid, gen_random_uuid()),
+    $11,
+    now()
+)
 RETURNING id, user_id, device_id_hash, access_token_hash, access_expires_at,
           refresh_token_hash, refresh_expires_at, rotated_at, revoked_at,
-          last_used_at, created_at
+          last_used_at, created_at, family_id, parent_id,
+          device_name, platform, app_version
 `
 
 type CreateAuthSessionParams struct {
@@ -34,6 +53,11 @@ type CreateAuthSessionParams struct {
 	AccessExpiresAt  pgtype.Timestamptz
 	RefreshTokenHash []byte
 	RefreshExpiresAt pgtype.Timestamptz
+	DeviceName       string
+	Platform         string
+	AppVersion       string
+	FamilyID         pgtype.UUID
+	ParentID         pgtype.UUID
 }
 
 func (q *Queries) CreateAuthSession(ctx context.Context, arg CreateAuthSessionParams) (AuthSession, error) {
@@ -44,6 +68,11 @@ func (q *Queries) CreateAuthSession(ctx context.Context, arg CreateAuthSessionPa
 		arg.AccessExpiresAt,
 		arg.RefreshTokenHash,
 		arg.RefreshExpiresAt,
+		arg.DeviceName,
+		arg.Platform,
+		arg.AppVersion,
+		arg.FamilyID,
+		arg.ParentID,
 	)
 	var i AuthSession
 	err := row.Scan(
@@ -58,14 +87,39 @@ func (q *Queries) CreateAuthSession(ctx context.Context, arg CreateAuthSessionPa
 		&i.RevokedAt,
 		&i.LastUsedAt,
 		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ParentID,
+		&i.DeviceName,
+		&i.Platform,
+		&i.AppVersion,
 	)
 	return i, err
 }
 
+const deleteStaleAuthSessions = `-- name: DeleteStaleAuthSessions :execrows
+DELETE FROM auth_sessions
+WHERE refresh_expires_at < $1
+   OR revoked_at < $2
+`
+
+type DeleteStaleAuthSessionsParams struct {
+	RefreshExpiresAt pgtype.Timestamptz
+	RevokedAt        pgtype.Timestamptz
+}
+
+func (q *Queries) DeleteStaleAuthSessions(ctx context.Context, arg DeleteStaleAuthSessionsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteStaleAuthSessions, arg.RefreshExpiresAt, arg.RevokedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getAuthSessionByAccessHash = `-- name: GetAuthSessionByAccessHash :one
 SELECT id, user_id, device_id_hash, access_token_hash, access_expires_at,
        refresh_token_hash, refresh_expires_at, rotated_at, revoked_at,
-       last_used_at, created_at
+       last_used_at, created_at, family_id, parent_id,
+       device_name, platform, app_version
 FROM auth_sessions
 WHERE access_token_hash = $1
   AND access_expires_at > $2
@@ -92,6 +146,11 @@ func (q *Queries) GetAuthSessionByAccessHash(ctx context.Context, arg GetAuthSes
 		&i.RevokedAt,
 		&i.LastUsedAt,
 		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ParentID,
+		&i.DeviceName,
+		&i.Platform,
+		&i.AppVersion,
 	)
 	return i, err
 }
@@ -99,7 +158,8 @@ func (q *Queries) GetAuthSessionByAccessHash(ctx context.Context, arg GetAuthSes
 const getAuthSessionByRefreshHash = `-- name: GetAuthSessionByRefreshHash :one
 SELECT id, user_id, device_id_hash, access_token_hash, access_expires_at,
        refresh_token_hash, refresh_expires_at, rotated_at, revoked_at,
-       last_used_at, created_at
+       last_used_at, created_at, family_id, parent_id,
+       device_name, platform, app_version
 FROM auth_sessions
 WHERE refresh_token_hash = $1
   AND refresh_expires_at > $2
@@ -126,10 +186,108 @@ func (q *Queries) GetAuthSessionByRefreshHash(ctx context.Context, arg GetAuthSe
 		&i.RevokedAt,
 		&i.LastUsedAt,
 		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ParentID,
+		&i.DeviceName,
+		&i.Platform,
+		&i.AppVersion,
+	)
+	return i, err
+}
+
+const getAuthSessionByRefreshHashForUpdate = `-- name: GetAuthSessionByRefreshHashForUpdate :one
+SELECT id, user_id, device_id_hash, access_token_hash, access_expires_at,
+       refresh_token_hash, refresh_expires_at, rotated_at, revoked_at,
+       last_used_at, created_at, family_id, parent_id,
+       device_name, platform, app_version
+FROM auth_sessions
+WHERE refresh_token_hash = $1
+  AND refresh_expires_at > $2
+  AND revoked_at IS NULL
+FOR UPDATE
+`
+
+type GetAuthSessionByRefreshHashForUpdateParams struct {
+	RefreshTokenHash []byte
+	RefreshExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) GetAuthSessionByRefreshHashForUpdate(ctx context.Context, arg GetAuthSessionByRefreshHashForUpdateParams) (AuthSession, error) {
+	row := q.db.QueryRow(ctx, getAuthSessionByRefreshHashForUpdate, arg.RefreshTokenHash, arg.RefreshExpiresAt)
+	var i AuthSession
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.DeviceIDHash,
+		&i.AccessTokenHash,
+		&i.AccessExpiresAt,
+		&i.RefreshTokenHash,
+		&i.RefreshExpiresAt,
+		&i.RotatedAt,
+		&i.RevokedAt,
+		&i.LastUsedAt,
+		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ParentID,
+		&i.DeviceName,
+		&i.Platform,
+		&i.AppVersion,
 	)
 	return i, err
 }
 
+const listActiveAuthSessions = `-- name: ListActiveAuthSessions :many
+SELECT DISTINCT ON (family_id)
+    family_id, device_name, platform, app_version, created_at, last_used_at
+FROM auth_sessions
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND rotated_at IS NULL
+  AND refresh_expires_at > $2
+ORDER BY family_id, created_at DESC
+`
+
+type ListActiveAuthSessionsParams struct {
+	UserID           pgtype.UUID
+	RefreshExpiresAt pgtype.Timestamptz
+}
+
+type ListActiveAuthSessionsRow struct {
+	FamilyID   pgtype.UUID
+	DeviceName string
+	Platform   string
+	AppVersion string
+	CreatedAt  pgtype.Timestamptz
+	LastUsedAt pgtype.Timestamptz
+}
+
+func (q *Queries) ListActiveAuthSessions(ctx context.Context, arg ListActiveAuthSessionsParams) ([]ListActiveAuthSessionsRow, error) {
+	rows, err := q.db.Query(ctx, listActiveAuthSessions, arg.UserID, arg.RefreshExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListActiveAuthSessionsRow
+	for rows.Next() {
+		var i ListActiveAuthSessionsRow
+		if err := rows.Scan(
+			&i.FamilyID,
+			&i.DeviceName,
+			&i.Platform,
+			&i.AppVersion,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const markAuthSessionUsed = `-- name: MarkAuthSessionUsed :exec
 UPDATE auth_sessions
 SET last_used_at = $2
@@ -162,7 +320,108 @@ func (q *Queries) RevokeAuthSession(ctx context.Context, arg RevokeAuthSessionPa
 	return err
 }
 
-const rotateAuthSession = `-- name: RotateAuthSession :exec
+const revokeAuthSessionFamily = `-- name: RevokeAuthSessionFamily :execrows
+UPDATE auth_sessions
+SET revoked_at = $2
+WHERE family_id = $1
+  AND revoked_at IS NULL
+`
+
+type RevokeAuthSessionFamilyParams struct {
+	FamilyID  pgtype.UUID
+	RevokedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeAuthSessionFamily(ctx context.Context, arg RevokeAuthSessionFamilyParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeAuthSessionFamily, arg.FamilyID, arg.RevokedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeOtherUserAuthSessions = `-- name: RevokeOtherUserAuthSessions :execrows
+UPDATE auth_sessions
+SET revoked_at = $3
+WHERE user_id = $1
+  AND family_id <> $2
+  AND revoked_at IS NULL
+`
+
+type RevokeOtherUserAuthSessionsParams struct {
+	UserID    pgtype.UUID
+	FamilyID  pgtype.UUID
+	RevokedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeOtherUserAuthSessions(ctx context.Context, arg RevokeOtherUserAuthSessionsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeOtherUserAuthSessions, arg.UserID, arg.FamilyID, arg.RevokedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeSiblingAuthSessions = `-- name: RevokeSiblingAuthSessions :exec
+UPDATE auth_sessions
+SET revoked_at = $3
+WHERE parent_id = $1
+  AND id <> $2
+  AND rotated_at IS NULL
+  AND revoked_at IS NULL
+`
+
+type RevokeSiblingAuthSessionsParams struct {
+	ParentID  pgtype.UUID
+	ID        pgtype.UUID
+	RevokedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeSiblingAuthSessions(ctx context.Context, arg RevokeSiblingAuthSessionsParams) error {
+	_, err := q.db.Exec(ctx, revokeSiblingAuthSessions, arg.ParentID, arg.ID, arg.RevokedAt)
+	return err
+}
+
+const revokeUserAuthSessionFamily = `-- name: RevokeUserAuthSessionFamily :execrows
+UPDATE auth_sessions
+SET revoked_at = $3
+WHERE user_id = $1
+  AND family_id = $2
+  AND revoked_at IS NULL
+`
+
+type RevokeUserAuthSessionFamilyParams struct {
+	UserID    pgtype.UUID
+	FamilyID  pgtype.UUID
+	RevokedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeUserAuthSessionFamily(ctx context.Context, arg RevokeUserAuthSessionFamilyParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeUserAuthSessionFamily, arg.UserID, arg.FamilyID, arg.RevokedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeUserAuthSessions = `-- name: RevokeUserAuthSessions :exec
+UPDATE auth_sessions
+SET revoked_at = $2
+WHERE user_id = $1
+  AND revoked_at IS NULL
+`
+
+type RevokeUserAuthSessionsParams struct {
+	UserID    pgtype.UUID
+	RevokedAt pgtype.Timestamptz
+}
+
+func (q *Queries) RevokeUserAuthSessions(ctx context.Context, arg RevokeUserAuthSessionsParams) error {
+	_, err := q.db.Exec(ctx, revokeUserAuthSessions, arg.UserID, arg.RevokedAt)
+	return err
+}
+
+const rotateAuthSession = `-- name: RotateAuthSession :execrows
 UPDATE auth_sessions
 SET rotated_at = $2
 WHERE id = $1
@@ -175,7 +434,10 @@ type RotateAuthSessionParams struct {
 	RotatedAt pgtype.Timestamptz
 }
 
-func (q *Queries) RotateAuthSession(ctx context.Context, arg RotateAuthSessionParams) error {
-	_, err := q.db.Exec(ctx, rotateAuthSession, arg.ID, arg.RotatedAt)
-	return err
+func (q *Queries) RotateAuthSession(ctx context.Context, arg RotateAuthSessionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rotateAuthSession, arg.ID, arg.RotatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
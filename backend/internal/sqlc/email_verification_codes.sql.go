@@ -42,6 +42,19 @@ func (q *Queries) CreateEmailVerificationCode(ctx context.Context, arg CreateEma
 	return i, err
 }
 
+const deleteExpiredEmailVerificationCodes = `-- name: DeleteExpiredEmailVerificationCodes :execrows
+DELETE FROM email_verification_codes
+WHERE expires_at < $1
+`
+
+func (q *Queries) DeleteExpiredEmailVerificationCodes(ctx context.Context, expiresAt pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredEmailVerificationCodes, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getEmailVerificationCode = `-- name: GetEmailVerificationCode :one
 SELECT id, email, code, expires_at, used_at, created_at
 FROM email_verification_codes
@@ -73,6 +86,34 @@ func (q *Queries) GetEmailVerificationCode(ctx context.Context, arg GetEmailVeri
 	return i, err
 }
 
+const getEmailVerificationCodeByIDForUpdate = `-- name: GetEmailVerificationCodeByIDForUpdate :one
+SELECT id, email, code, expires_at, used_at, created_at
+FROM email_verification_codes
+WHERE id = $1
+  AND expires_at > $2
+  AND used_at IS NULL
+FOR UPDATE
+`
+
+type GetEmailVerificationCodeByIDForUpdateParams struct {
+	ID        pgtype.UUID
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) GetEmailVerificationCodeByIDForUpdate(ctx context.Context, arg GetEmailVerificationCodeByIDForUpdateParams) (EmailVerificationCode, error) {
+	row := q.db.QueryRow(ctx, getEmailVerificationCodeByIDForUpdate, arg.ID, arg.ExpiresAt)
+	var i EmailVerificationCode
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.Code,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const markEmailVerificationCodeUsed = `-- name: MarkEmailVerificationCodeUsed :exec
 UPDATE email_verification_codes
 SET used_at = $2
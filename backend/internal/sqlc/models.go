@@ -8,6 +8,13 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AttemptLimit struct {
+	Key       string
+	Count     int32
+	ResetAt   pgtype.Timestamptz
+	LockUntil pgtype.Timestamptz
+}
+
 type AuthSession struct {
 	ID               pgtype.UUID
 	UserID           pgtype.UUID
@@ -20,6 +27,39 @@ type AuthSession struct {
 	RevokedAt        pgtype.Timestamptz
 	LastUsedAt       pgtype.Timestamptz
 	CreatedAt        pgtype.Timestamptz
+	FamilyID         pgtype.UUID
+	ParentID         pgtype.UUID
+	DeviceName       string
+	Platform         string
+	AppVersion       string
+}
+
+type DevicePairing struct {
+	ID             pgtype.UUID
+	UserID         pgtype.UUID
+	SessionID      pgtype.UUID
+	Code           string
+	ExpiresAt      pgtype.Timestamptz
+	CreatedAt      pgtype.Timestamptz
+	ClaimTokenHash []byte
+	DeviceIDHash   []byte
+	DeviceName     string
+	Platform       string
+	AppVersion     string
+	ClaimedAt      pgtype.Timestamptz
+	ApprovedAt     pgtype.Timestamptz
+	CompletedAt    pgtype.Timestamptz
+}
+
+type DomainVerification struct {
+	ID         pgtype.UUID
+	TeamID     pgtype.UUID
+	UserID     pgtype.UUID
+	Domain     string
+	Token      string
+	CreatedAt  pgtype.Timestamptz
+	VerifiedAt pgtype.Timestamptz
+	CheckedAt  pgtype.Timestamptz
 }
 
 type EmailVerificationCode struct {
@@ -42,12 +82,69 @@ type InviteCode struct {
 	CreatedAt       pgtype.Timestamptz
 }
 
+type RateLimitCounter struct {
+	Key         string
+	WindowStart pgtype.Timestamptz
+	Count       int32
+}
+
+type SsoLogin struct {
+	ID               pgtype.UUID
+	TeamID           pgtype.UUID
+	StateHash        []byte
+	DeviceIDHash     []byte
+	Nonce            string
+	CodeVerifier     string
+	ExpiresAt        pgtype.Timestamptz
+	CreatedAt        pgtype.Timestamptz
+	Email            pgtype.Text
+	HandoffTokenHash []byte
+	AuthenticatedAt  pgtype.Timestamptz
+	CompletedAt      pgtype.Timestamptz
+	ClaimedAt        pgtype.Timestamptz
+}
+
 type Team struct {
-	ID        pgtype.UUID
-	Domain    string
-	Name      string
-	CreatedAt pgtype.Timestamptz
-	UpdatedAt pgtype.Timestamptz
+	ID                pgtype.UUID
+	Domain            string
+	Name              string
+	CreatedAt         pgtype.Timestamptz
+	UpdatedAt         pgtype.Timestamptz
+	IncludeSubdomains bool
+	JoinPolicy        string
+	DomainVerifiedAt  pgtype.Timestamptz
+}
+
+type TeamDomain struct {
+	ID         pgtype.UUID
+	TeamID     pgtype.UUID
+	Domain     string
+	IsPrimary  bool
+	Token      pgtype.Text
+	CreatedAt  pgtype.Timestamptz
+	VerifiedAt pgtype.Timestamptz
+	CheckedAt  pgtype.Timestamptz
+}
+
+type TeamDomainMigration struct {
+	ID              pgtype.UUID
+	TeamID          pgtype.UUID
+	FromDomain      string
+	ToDomain        string
+	Status          string
+	CreatedByUserID pgtype.UUID
+	CreatedAt       pgtype.Timestamptz
+	FinishedAt      pgtype.Timestamptz
+}
+
+type TeamJoinRequest struct {
+	ID              pgtype.UUID
+	TeamID          pgtype.UUID
+	UserID          pgtype.UUID
+	Status          string
+	CreatedAt       pgtype.Timestamptz
+	DecidedAt       pgtype.Timestamptz
+	DecidedByUserID pgtype.UUID
 }
 
 type TeamMembership struct {
@@ -59,6 +156,19 @@ type TeamMembership struct {
 	CreatedAt pgtype.Timestamptz
 }
 
+type TeamSsoProvider struct {
+	TeamID           pgtype.UUID
+	Protocol         string
+	OidcIssuer       string
+	OidcClientID     string
+	OidcClientSecret string
+	SamlIdpEntityID  string
+	SamlIdpMetadata  string
+	Required         bool
+	CreatedAt        pgtype.Timestamptz
+	UpdatedAt        pgtype.Timestamptz
+}
+
 type TimezoneState struct {
 	UserID           pgtype.UUID
 	Timezone         string
@@ -86,11 +196,10 @@ type User struct {
 }
 
 type WorkingHour struct {
-	UserID          pgtype.UUID
-	StartMinute     int32
-	EndMinute       int32
-	SaturdayEnabled bool
-	SundayEnabled   bool
-	CreatedAt       pgtype.Timestamptz
-	UpdatedAt       pgtype.Timestamptz
+	UserID      pgtype.UUID
+	StartMinute int32
+	EndMinute   int32
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+	WorkingDays []int32
 }
@@ -11,24 +11,117 @@ import (
 )
 
 type Querier interface {
+	AdvisoryUnlock(ctx context.Context, lockKey int64) error
+	ApproveDevicePairing(ctx context.Context, arg ApproveDevicePairingParams) (DevicePairing, error)
+	AuthenticateSsoLogin(ctx context.Context, arg AuthenticateSsoLoginParams) error
+	ClaimDevicePairing(ctx context.Context, arg ClaimDevicePairingParams) (DevicePairing, error)
+	ClaimDueHiddenReminders(ctx context.Context, arg ClaimDueHiddenRemindersParams) ([]ClaimDueHiddenRemindersRow, error)
+	ClaimSsoLogin(ctx context.Context, arg ClaimSsoLoginParams) (SsoLogin, error)
+	CompleteDevicePairing(ctx context.Context, arg CompleteDevicePairingParams) error
+	CompleteSsoLogin(ctx context.Context, arg CompleteSsoLoginParams) error
+	CountActivePairingSessions(ctx context.Context, arg CountActivePairingSessionsParams) (int64, error)
+	CountClaimedDevicePairings(ctx context.Context, arg CountClaimedDevicePairingsParams) (int64, error)
+	CountTeamAdmins(ctx context.Context, teamID pgtype.UUID) (int64, error)
 	CountTeamMembers(ctx context.Context, teamID pgtype.UUID) (int64, error)
+	CountTeamMembersAtDomain(ctx context.Context, arg CountTeamMembersAtDomainParams) (int64, error)
 	CreateAuthSession(ctx context.Context, arg CreateAuthSessionParams) (AuthSession, error)
+	CreateDevicePairing(ctx context.Context, arg CreateDevicePairingParams) (DevicePairing, error)
 	CreateEmailVerificationCode(ctx context.Context, arg CreateEmailVerificationCodeParams) (EmailVerificationCode, error)
+	CreateInviteCode(ctx context.Context, arg CreateInviteCodeParams) (InviteCode, error)
+	CreateSsoLogin(ctx context.Context, arg CreateSsoLoginParams) (SsoLogin, error)
 	CreateTeam(ctx context.Context, arg CreateTeamParams) (Team, error)
+	CreateTeamDomain(ctx context.Context, arg CreateTeamDomainParams) (TeamDomain, error)
+	CreateTeamDomainMigration(ctx context.Context, arg CreateTeamDomainMigrationParams) (TeamDomainMigration, error)
+	CreateTeamJoinRequest(ctx context.Context, arg CreateTeamJoinRequestParams) (TeamJoinRequest, error)
 	CreateTeamMembership(ctx context.Context, arg CreateTeamMembershipParams) error
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	DecideTeamJoinRequest(ctx context.Context, arg DecideTeamJoinRequestParams) (TeamJoinRequest, error)
+	DeleteDevicePairing(ctx context.Context, arg DeleteDevicePairingParams) (int64, error)
+	DeleteExpiredAttemptLimits(ctx context.Context, now pgtype.Timestamptz) (int64, error)
+	DeleteExpiredDevicePairings(ctx context.Context, expiresAt pgtype.Timestamptz) (int64, error)
+	DeleteExpiredEmailVerificationCodes(ctx context.Context, expiresAt pgtype.Timestamptz) (int64, error)
+	DeleteExpiredRateLimitCounters(ctx context.Context, windowStart pgtype.Timestamptz) (int64, error)
+	DeleteExpiredSsoLogins(ctx context.Context, expiresAt pgtype.Timestamptz) (int64, error)
+	DeleteInviteCode(ctx context.Context, arg DeleteInviteCodeParams) (int64, error)
+	DeleteStaleAuthSessions(ctx context.Context, arg DeleteStaleAuthSessionsParams) (int64, error)
+	DeleteStaleInviteCodes(ctx context.Context, expiresAt pgtype.Timestamptz) (int64, error)
+	DeleteTeam(ctx context.Context, id pgtype.UUID) error
+	DeleteTeamDomain(ctx context.Context, arg DeleteTeamDomainParams) (int64, error)
+	DeleteTeamDomainVerifications(ctx context.Context, arg DeleteTeamDomainVerificationsParams) (int64, error)
+	DeleteTeamMembership(ctx context.Context, arg DeleteTeamMembershipParams) (int64, error)
+	DeleteTeamSsoProvider(ctx context.Context, teamID pgtype.UUID) (int64, error)
+	DemoteOtherTeamAdmins(ctx context.Context, arg DemoteOtherTeamAdminsParams) (int64, error)
+	FinishTeamDomainMigration(ctx context.Context, arg FinishTeamDomainMigrationParams) (TeamDomainMigration, error)
+	GetActiveTeamDomainMigration(ctx context.Context, teamID pgtype.UUID) (TeamDomainMigration, error)
+	GetAttemptLockUntil(ctx context.Context, key string) (pgtype.Timestamptz, error)
 	GetAuthSessionByAccessHash(ctx context.Context, arg GetAuthSessionByAccessHashParams) (AuthSession, error)
 	GetAuthSessionByRefreshHash(ctx context.Context, arg GetAuthSessionByRefreshHashParams) (AuthSession, error)
+	GetAuthSessionByRefreshHashForUpdate(ctx context.Context, arg GetAuthSessionByRefreshHashForUpdateParams) (AuthSession, error)
+	GetDevicePairing(ctx context.Context, arg GetDevicePairingParams) (DevicePairing, error)
+	GetDevicePairingByClaimTokenForUpdate(ctx context.Context, arg GetDevicePairingByClaimTokenForUpdateParams) (DevicePairing, error)
+	GetDomainVerification(ctx context.Context, arg GetDomainVerificationParams) (DomainVerification, error)
 	GetEmailVerificationCode(ctx context.Context, arg GetEmailVerificationCodeParams) (EmailVerificationCode, error)
+	GetEmailVerificationCodeByIDForUpdate(ctx context.Context, arg GetEmailVerificationCodeByIDForUpdateParams) (EmailVerificationCode, error)
+	GetLatestTeamJoinRequestByUserID(ctx context.Context, userID pgtype.UUID) (TeamJoinRequest, error)
+	GetRateLimitCounts(ctx context.Context, arg GetRateLimitCountsParams) (GetRateLimitCountsRow, error)
+	GetSsoLoginByHandoffForUpdate(ctx context.Context, arg GetSsoLoginByHandoffForUpdateParams) (SsoLogin, error)
 	GetTeamByDomain(ctx context.Context, domain string) (Team, error)
+	GetTeamByID(ctx context.Context, id pgtype.UUID) (Team, error)
+	GetTeamByIDForUpdate(ctx context.Context, id pgtype.UUID) (Team, error)
+	GetTeamDomain(ctx context.Context, arg GetTeamDomainParams) (TeamDomain, error)
+	GetTeamJoinRequestForUpdate(ctx context.Context, arg GetTeamJoinRequestForUpdateParams) (TeamJoinRequest, error)
 	GetTeamMembership(ctx context.Context, arg GetTeamMembershipParams) (TeamMembership, error)
+	GetTeamMembershipByUserID(ctx context.Context, userID pgtype.UUID) (TeamMembership, error)
+	GetTeamSsoProvider(ctx context.Context, teamID pgtype.UUID) (TeamSsoProvider, error)
+	GetTimezoneState(ctx context.Context, userID pgtype.UUID) (TimezoneState, error)
+	GetTimezoneVisibility(ctx context.Context, userID pgtype.UUID) (TimezoneVisibility, error)
 	GetUserByEmail(ctx context.Context, email string) (User, error)
 	GetUserByID(ctx context.Context, id pgtype.UUID) (User, error)
+	GetVerifiedDomainVerification(ctx context.Context, arg GetVerifiedDomainVerificationParams) (DomainVerification, error)
+	GetWorkingHours(ctx context.Context, userID pgtype.UUID) (WorkingHour, error)
+	IncrementAttemptLimit(ctx context.Context, arg IncrementAttemptLimitParams) (int32, error)
+	IncrementRateLimitCounter(ctx context.Context, arg IncrementRateLimitCounterParams) error
+	ListActiveAuthSessions(ctx context.Context, arg ListActiveAuthSessionsParams) ([]ListActiveAuthSessionsRow, error)
+	ListDueDomainVerifications(ctx context.Context, arg ListDueDomainVerificationsParams) ([]DomainVerification, error)
+	ListDueTeamDomains(ctx context.Context, arg ListDueTeamDomainsParams) ([]TeamDomain, error)
+	ListInviteCodes(ctx context.Context, teamID pgtype.UUID) ([]InviteCode, error)
+	ListPendingTeamJoinRequests(ctx context.Context, teamID pgtype.UUID) ([]ListPendingTeamJoinRequestsRow, error)
+	ListTeamDomains(ctx context.Context, teamID pgtype.UUID) ([]TeamDomain, error)
+	ListTeamRoster(ctx context.Context, arg ListTeamRosterParams) ([]ListTeamRosterRow, error)
 	MarkAuthSessionUsed(ctx context.Context, arg MarkAuthSessionUsedParams) error
+	MarkDomainVerified(ctx context.Context, arg MarkDomainVerifiedParams) (DomainVerification, error)
 	MarkEmailVerificationCodeUsed(ctx context.Context, arg MarkEmailVerificationCodeUsedParams) error
+	RedeemInviteCode(ctx context.Context, arg RedeemInviteCodeParams) (InviteCode, error)
+	RefreshInviteCode(ctx context.Context, arg RefreshInviteCodeParams) (InviteCode, error)
+	RegisterAttemptFailure(ctx context.Context, arg RegisterAttemptFailureParams) (pgtype.Timestamptz, error)
+	ResetAttemptLimit(ctx context.Context, key string) error
+	RestoreHiddenReminder(ctx context.Context, arg RestoreHiddenReminderParams) error
+	ResumeSharingFromReminder(ctx context.Context, arg ResumeSharingFromReminderParams) (int64, error)
 	RevokeAuthSession(ctx context.Context, arg RevokeAuthSessionParams) error
-	RotateAuthSession(ctx context.Context, arg RotateAuthSessionParams) error
+	RevokeAuthSessionFamily(ctx context.Context, arg RevokeAuthSessionFamilyParams) (int64, error)
+	RevokeDomainVerification(ctx context.Context, arg RevokeDomainVerificationParams) error
+	RevokeOtherUserAuthSessions(ctx context.Context, arg RevokeOtherUserAuthSessionsParams) (int64, error)
+	RevokeSiblingAuthSessions(ctx context.Context, arg RevokeSiblingAuthSessionsParams) error
+	RevokeTeamDomain(ctx context.Context, arg RevokeTeamDomainParams) error
+	RevokeUserAuthSessionFamily(ctx context.Context, arg RevokeUserAuthSessionFamilyParams) (int64, error)
+	RevokeUserAuthSessions(ctx context.Context, arg RevokeUserAuthSessionsParams) error
+	RotateAuthSession(ctx context.Context, arg RotateAuthSessionParams) (int64, error)
+	SetTeamDomainVerification(ctx context.Context, arg SetTeamDomainVerificationParams) error
+	SetTeamDomainVerified(ctx context.Context, arg SetTeamDomainVerifiedParams) error
+	SetTeamPrimaryDomain(ctx context.Context, arg SetTeamPrimaryDomainParams) (Team, error)
+	TouchDomainVerification(ctx context.Context, arg TouchDomainVerificationParams) error
+	TouchTeamDomain(ctx context.Context, arg TouchTeamDomainParams) error
+	TryAdvisoryLock(ctx context.Context, lockKey int64) (bool, error)
+	UpdateTeamMembershipRole(ctx context.Context, arg UpdateTeamMembershipRoleParams) (TeamMembership, error)
+	UpdateTeamSettings(ctx context.Context, arg UpdateTeamSettingsParams) (Team, error)
+	UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (User, error)
 	UpdateUserVerifiedAt(ctx context.Context, arg UpdateUserVerifiedAtParams) (User, error)
+	UpsertDomainVerification(ctx context.Context, arg UpsertDomainVerificationParams) (DomainVerification, error)
+	UpsertTeamSsoProvider(ctx context.Context, arg UpsertTeamSsoProviderParams) (TeamSsoProvider, error)
+	UpsertTimezoneState(ctx context.Context, arg UpsertTimezoneStateParams) (TimezoneState, error)
+	UpsertTimezoneVisibility(ctx context.Context, arg UpsertTimezoneVisibilityParams) (TimezoneVisibility, error)
+	UpsertWorkingHours(ctx context.Context, arg UpsertWorkingHoursParams) (WorkingHour, error)
+	VerifyTeamDomain(ctx context.Context, arg VerifyTeamDomainParams) (TeamDomain, error)
 }
 
 var _ Querier = (*Queries)(nil)
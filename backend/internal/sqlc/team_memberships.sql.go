@@ -11,6 +11,20 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countTeamAdmins = `-- name: CountTeamAdmins :one
+SELECT COUNT(*)
+FROM team_memberships
+WHERE team_id = $1
+  AND role = 'admin'
+`
+
+func (q *Queries) CountTeamAdmins(ctx context.Context, teamID pgtype.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countTeamAdmins, teamID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createTeamMembership = `-- name: CreateTeamMembership :exec
 INSERT INTO team_memberships (
     team_id,
@@ -40,6 +54,25 @@ func (q *Queries) CreateTeamMembership(ctx context.Context, arg CreateTeamMember
 	return err
 }
 
+const deleteTeamMembership = `-- name: DeleteTeamMembership :execrows
+DELETE FROM team_memberships
+WHERE team_id = $1
+  AND user_id = $2
+`
+
+type DeleteTeamMembershipParams struct {
+	TeamID pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) DeleteTeamMembership(ctx context.Context, arg DeleteTeamMembershipParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteTeamMembership, arg.TeamID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getTeamMembership = `-- name: GetTeamMembership :one
 SELECT id, team_id, user_id, role, joined_at, created_at
 FROM team_memberships
@@ -65,3 +98,53 @@ func (q *Queries) GetTeamMembership(ctx context.Context, arg GetTeamMembershipPa
 	)
 	return i, err
 }
+
+const getTeamMembershipByUserID = `-- name: GetTeamMembershipByUserID :one
+SELECT id, team_id, user_id, role, joined_at, created_at
+FROM team_memberships
+WHERE user_id = $1
+ORDER BY joined_at ASC
+LIMIT 1
+`
+
+func (q *Queries) GetTeamMembershipByUserID(ctx context.Context, userID pgtype.UUID) (TeamMembership, error) {
+	row := q.db.QueryRow(ctx, getTeamMembershipByUserID, userID)
+	var i TeamMembership
+	err := row.Scan(
+		&i.ID,
+		&i.TeamID,
+		&i.UserID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateTeamMembershipRole = `-- name: UpdateTeamMembershipRole :one
+UPDATE team_memberships
+SET role = $3
+WHERE team_id = $1
+  AND user_id = $2
+RETURNING id, team_id, user_id, role, joined_at, created_at
+`
+
+type UpdateTeamMembershipRoleParams struct {
+	TeamID pgtype.UUID
+	UserID pgtype.UUID
+	Role   string
+}
+
+func (q *Queries) UpdateTeamMembershipRole(ctx context.Context, arg UpdateTeamMembershipRoleParams) (TeamMembership, error) {
+	row := q.db.QueryRow(ctx, updateTeamMembershipRole, arg.TeamID, arg.UserID, arg.Role)
+	var i TeamMembership
+	err := row.Scan(
+		&i.ID,
+		&i.TeamID,
+		&i.UserID,
+		&i.Role,
+		&i.JoinedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
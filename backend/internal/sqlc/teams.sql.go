@@ -32,7 +32,7 @@ INSERT INTO teams (
     updated_at
 )
 VALUES ($1, $2, now(), now())
-RETURNING id, domain, name, created_at, updated_at
+RETURNING id, domain, name, created_at, updated_at, include_subdomains, join_policy, domain_verified_at
 `
 
 type CreateTeamParams struct {
@@ -49,14 +49,29 @@ func (q *Queries) CreateTeam(ctx context.Context, arg CreateTeamParams) (Team, e
 		&i.Name,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.IncludeSubdomains,
+		&i.JoinPolicy,
+		&i.DomainVerifiedAt,
 	)
 	return i, err
 }
 
+const deleteTeam = `-- name: DeleteTeam :exec
+DELETE FROM teams
+WHERE id = $1
+`
+
+func (q *Queries) DeleteTeam(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteTeam, id)
+	return err
+}
+
 const getTeamByDomain = `-- name: GetTeamByDomain :one
-SELECT id, domain, name, created_at, updated_at
-FROM teams
-WHERE domain = $1
+SELECT t.id, t.domain, t.name, t.created_at, t.updated_at, t.include_subdomains, t.join_policy, t.domain_verified_at
+FROM teams t
+JOIN team_domains d ON d.team_id = t.id
+WHERE d.domain = $1
+  AND (d.is_primary OR d.verified_at IS NOT NULL)
 `
 
 func (q *Queries) GetTeamByDomain(ctx context.Context, domain string) (Team, error) {
@@ -68,6 +83,121 @@ func (q *Queries) GetTeamByDomain(ctx context.Context, domain string) (Team, err
 		&i.Name,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.IncludeSubdomains,
+		&i.JoinPolicy,
+		&i.DomainVerifiedAt,
+	)
+	return i, err
+}
+
+const getTeamByID = `-- name: GetTeamByID :one
+SELECT id, domain, name, created_at, updated_at, include_subdomains, join_policy, domain_verified_at
+FROM teams
+WHERE id = $1
+`
+
+func (q *Queries) GetTeamByID(ctx context.Context, id pgtype.UUID) (Team, error) {
+	row := q.db.QueryRow(ctx, getTeamByID, id)
+	var i Team
+	err := row.Scan(
+		&i.ID,
+		&i.Domain,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IncludeSubdomains,
+		&i.JoinPolicy,
+		&i.DomainVerifiedAt,
+	)
+	return i, err
+}
+
+const getTeamByIDForUpdate = `-- name: GetTeamByIDForUpdate :one
+SELECT id, domain, name, created_at, updated_at, include_subdomains, join_policy, domain_verified_at
+FROM teams
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetTeamByIDForUpdate(ctx context.Context, id pgtype.UUID) (Team, error) {
+	row := q.db.QueryRow(ctx, getTeamByIDForUpdate, id)
+	var i Team
+	err := row.Scan(
+		&i.ID,
+		&i.Domain,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IncludeSubdomains,
+		&i.JoinPolicy,
+		&i.DomainVerifiedAt,
+	)
+	return i, err
+}
+
+const setTeamPrimaryDomain = `-- name: SetTeamPrimaryDomain :one
+UPDATE teams
+SET domain = $2,
+    updated_at = now()
+WHERE id = $1
+RETURNING id, domain, name, created_at, updated_at, include_subdomains, join_policy, domain_verified_at
+`
+
+type SetTeamPrimaryDomainParams struct {
+	ID     pgtype.UUID
+	Domain string
+}
+
+func (q *Queries) SetTeamPrimaryDomain(ctx context.Context, arg SetTeamPrimaryDomainParams) (Team, error) {
+	row := q.db.QueryRow(ctx, setTeamPrimaryDomain, arg.ID, arg.Domain)
+	var i Team
+	err := row.Scan(
+		&i.ID,
+		&i.Domain,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IncludeSubdomains,
+		&i.JoinPolicy,
+		&i.DomainVerifiedAt,
+	)
+	return i, err
+}
+
+const updateTeamSettings = `-- name: UpdateTeamSettings :one
+UPDATE teams
+SET name = $2,
+    include_subdomains = $3,
+    join_policy = $4,
+    updated_at = now()
+WHERE id = $1
+RETURNING id, domain, name, created_at, updated_at, include_subdomains, join_policy, domain_verified_at
+`
+
+type UpdateTeamSettingsParams struct {
+	ID                pgtype.UUID
+	Name              string
+	IncludeSubdomains bool
+	JoinPolicy        string
+}
+
+func (q *Queries) UpdateTeamSettings(ctx context.Context, arg UpdateTeamSettingsParams) (Team, error) {
+	row := q.db.QueryRow(ctx, updateTeamSettings,
+		arg.ID,
+		arg.Name,
+		arg.IncludeSubdomains,
+		arg.JoinPolicy,
+	)
+	var i Team
+	err := row.Scan(
+		&i.ID,
+		&i.Domain,
+		&i.Name,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IncludeSubdomains,
+		&i.JoinPolicy,
+		&i.DomainVerifiedAt,
 	)
 	return i, err
 }
@@ -83,6 +83,35 @@ func (q *Queries) GetUserByID(ctx context.Context, id pgtype.UUID) (User, error)
 	return i, err
 }
 
+const updateUserEmail = `-- name: UpdateUserEmail :one
+UPDATE users
+SET email = $2,
+    email_domain = $3,
+    updated_at = now()
+WHERE id = $1
+RETURNING id, email, email_domain, email_verified_at, created_at, updated_at
+`
+
+type UpdateUserEmailParams struct {
+	ID          pgtype.UUID
+	Email       string
+	EmailDomain string
+}
+
+func (q *Queries) UpdateUserEmail(ctx context.Context, arg UpdateUserEmailParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserEmail, arg.ID, arg.Email, arg.EmailDomain)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.EmailDomain,
+		&i.EmailVerifiedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const updateUserVerifiedAt = `-- name: UpdateUserVerifiedAt :one
 UPDATE users
 SET email_verified_at = $2,
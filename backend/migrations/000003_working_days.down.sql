@@ -0,0 +1,15 @@
+ALTER TABLE working_hours
+    DROP CONSTRAINT IF EXISTS working_hours_working_days_check,
+    DROP CONSTRAINT IF EXISTS working_hours_end_minute_check,
+    DROP CONSTRAINT IF EXISTS working_hours_start_minute_check,
+    ADD COLUMN saturday_enabled boolean NOT NULL DEFAULT false,
+    ADD COLUMN sunday_enabled boolean NOT NULL DEFAULT false;
+
+UPDATE working_hours
+SET saturday_enabled = 6 = ANY (working_days),
+    sunday_enabled = 0 = ANY (working_days);
+
+ALTER TABLE working_hours
+    ALTER COLUMN saturday_enabled DROP DEFAULT,
+    ALTER COLUMN sunday_enabled DROP DEFAULT,
+    DROP COLUMN working_days;
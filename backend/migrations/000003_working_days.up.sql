@@ -0,0 +1,14 @@
+ALTER TABLE working_hours ADD COLUMN working_days integer[] NOT NULL DEFAULT '{1,2,3,4,5}';
+
+UPDATE working_hours
+SET working_days =
+    (CASE WHEN sunday_enabled THEN ARRAY[0] ELSE '{}'::integer[] END)
+    || ARRAY[1, 2, 3, 4, 5]
+    || (CASE WHEN saturday_enabled THEN ARRAY[6] ELSE '{}'::integer[] END);
+
+ALTER TABLE working_hours
+    DROP COLUMN saturday_enabled,
+    DROP COLUMN sunday_enabled,
+    ADD CONSTRAINT working_hours_start_minute_check CHECK (start_minute BETWEEN 0 AND 1439),
+    ADD CONSTRAINT working_hours_end_minute_check CHECK (end_minute BETWEEN 0 AND 1439),
+    ADD CONSTRAINT working_hours_working_days_check CHECK (working_days <@ ARRAY[0, 1, 2, 3, 4, 5, 6]);
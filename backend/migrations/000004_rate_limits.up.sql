@@ -0,0 +1,17 @@
+CREATE TABLE attempt_limits (
+    key text PRIMARY KEY,
+    count integer NOT NULL,
+    reset_at timestamptz NOT NULL,
+    lock_until timestamptz NULL
+);
+
+CREATE INDEX attempt_limits_reset_at_idx ON attempt_limits (reset_at);
+
+CREATE TABLE rate_limit_counters (
+    key text NOT NULL,
+    window_start timestamptz NOT NULL,
+    count integer NOT NULL,
+    PRIMARY KEY (key, window_start)
+);
+
+CREATE INDEX rate_limit_counters_window_start_idx ON rate_limit_counters (window_start);
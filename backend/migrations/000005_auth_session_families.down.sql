@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS auth_sessions_family_id_idx;
+
+ALTER TABLE auth_sessions
+    DROP COLUMN IF EXISTS parent_id,
+    DROP COLUMN IF EXISTS family_id;
@@ -0,0 +1,12 @@
+ALTER TABLE auth_sessions
+    ADD COLUMN family_id uuid NULL,
+    ADD COLUMN parent_id uuid NULL REFERENCES auth_sessions(id) ON DELETE SET NULL;
+
+-- Existing sessions each start their own family.
+UPDATE auth_sessions SET family_id = id;
+
+ALTER TABLE auth_sessions
+    ALTER COLUMN family_id SET DEFAULT gen_random_uuid(),
+    ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX auth_sessions_family_id_idx ON auth_sessions (family_id);
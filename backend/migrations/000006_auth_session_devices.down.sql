@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS auth_sessions_user_id_family_id_idx;
+
+ALTER TABLE auth_sessions
+    DROP COLUMN IF EXISTS app_version,
+    DROP COLUMN IF EXISTS platform,
+    DROP COLUMN IF EXISTS device_name;
@@ -0,0 +1,6 @@
+ALTER TABLE auth_sessions
+    ADD COLUMN device_name text NOT NULL DEFAULT '',
+    ADD COLUMN platform text NOT NULL DEFAULT '',
+    ADD COLUMN app_version text NOT NULL DEFAULT '';
+
+CREATE INDEX auth_sessions_user_id_family_id_idx ON auth_sessions (user_id, family_id);
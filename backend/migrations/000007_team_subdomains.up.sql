@@ -0,0 +1,2 @@
+ALTER TABLE teams
+    ADD COLUMN include_subdomains boolean NOT NULL DEFAULT true;
@@ -0,0 +1,17 @@
+ALTER TABLE teams
+    ADD COLUMN join_policy text NOT NULL DEFAULT 'open'
+        CHECK (join_policy IN ('open', 'approval', 'invite_only'));
+
+CREATE TABLE team_join_requests (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    team_id uuid NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status text NOT NULL CHECK (status IN ('pending', 'approved', 'rejected')),
+    created_at timestamptz NOT NULL,
+    decided_at timestamptz NULL,
+    decided_by_user_id uuid NULL REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE (team_id, user_id)
+);
+
+CREATE INDEX team_join_requests_team_id_status_idx ON team_join_requests (team_id, status);
+CREATE INDEX team_join_requests_user_id_idx ON team_join_requests (user_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS domain_verifications;
+
+ALTER TABLE teams
+    DROP COLUMN IF EXISTS domain_verified_at;
@@ -0,0 +1,17 @@
+ALTER TABLE teams
+    ADD COLUMN domain_verified_at timestamptz NULL;
+
+CREATE TABLE domain_verifications (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    team_id uuid NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    domain text NOT NULL,
+    token text NOT NULL UNIQUE,
+    created_at timestamptz NOT NULL,
+    verified_at timestamptz NULL,
+    checked_at timestamptz NULL,
+    UNIQUE (team_id, user_id)
+);
+
+CREATE INDEX domain_verifications_checked_at_idx ON domain_verifications (checked_at)
+    WHERE verified_at IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS teams_sync_primary_domain ON teams;
+DROP FUNCTION IF EXISTS sync_team_primary_domain();
+DROP TABLE IF EXISTS team_domains;
@@ -0,0 +1,51 @@
+CREATE TABLE team_domains (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    team_id uuid NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    domain text NOT NULL,
+    is_primary boolean NOT NULL DEFAULT false,
+    token text NULL,
+    created_at timestamptz NOT NULL,
+    verified_at timestamptz NULL,
+    UNIQUE (team_id, domain)
+);
+
+-- A domain routes signups to one team at most; aliases still waiting for
+-- their TXT record don't block anyone.
+CREATE UNIQUE INDEX team_domains_active_domain_idx ON team_domains (domain)
+    WHERE is_primary OR verified_at IS NOT NULL;
+CREATE UNIQUE INDEX team_domains_primary_idx ON team_domains (team_id)
+    WHERE is_primary;
+
+-- teams.domain stays the primary domain and the trigger mirrors it, so
+-- servers still running the previous release keep creating teams that the
+-- new lookup finds. The trigger exists before the backfill so no team slips
+-- in between.
+CREATE FUNCTION sync_team_primary_domain() RETURNS trigger AS $$
+BEGIN
+    UPDATE team_domains
+    SET is_primary = false
+    WHERE team_id = NEW.id
+      AND is_primary
+      AND domain <> NEW.domain;
+
+    UPDATE team_domains
+    SET is_primary = true
+    WHERE team_id = NEW.id
+      AND domain = NEW.domain;
+
+    IF NOT FOUND THEN
+        INSERT INTO team_domains (team_id, domain, is_primary, created_at)
+        VALUES (NEW.id, NEW.domain, true, now());
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER teams_sync_primary_domain
+    AFTER INSERT OR UPDATE OF domain ON teams
+    FOR EACH ROW EXECUTE FUNCTION sync_team_primary_domain();
+
+INSERT INTO team_domains (team_id, domain, is_primary, created_at)
+SELECT id, domain, true, created_at
+FROM teams
+ON CONFLICT (team_id, domain) DO NOTHING;
@@ -0,0 +1,13 @@
+CREATE TABLE team_domain_migrations (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    team_id uuid NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    from_domain text NOT NULL,
+    to_domain text NOT NULL,
+    status text NOT NULL CHECK (status IN ('active', 'completed', 'cancelled')),
+    created_by_user_id uuid NULL REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamptz NOT NULL,
+    finished_at timestamptz NULL
+);
+
+CREATE UNIQUE INDEX team_domain_migrations_active_idx ON team_domain_migrations (team_id)
+    WHERE status = 'active';
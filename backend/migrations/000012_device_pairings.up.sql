@@ -0,0 +1,20 @@
+CREATE TABLE device_pairings (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id uuid NOT NULL REFERENCES auth_sessions(id) ON DELETE CASCADE,
+    code text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz NOT NULL,
+    claim_token_hash bytea NULL UNIQUE,
+    device_id_hash bytea NULL,
+    device_name text NOT NULL DEFAULT '',
+    platform text NOT NULL DEFAULT '',
+    app_version text NOT NULL DEFAULT '',
+    claimed_at timestamptz NULL,
+    approved_at timestamptz NULL,
+    completed_at timestamptz NULL
+);
+
+CREATE UNIQUE INDEX device_pairings_open_code_idx ON device_pairings (code)
+    WHERE claimed_at IS NULL;
+CREATE INDEX device_pairings_expires_at_idx ON device_pairings (expires_at);
@@ -0,0 +1,27 @@
+CREATE TABLE team_sso_providers (
+    team_id uuid PRIMARY KEY REFERENCES teams(id) ON DELETE CASCADE,
+    protocol text NOT NULL CHECK (protocol IN ('oidc')),
+    oidc_issuer text NOT NULL DEFAULT '',
+    oidc_client_id text NOT NULL DEFAULT '',
+    oidc_client_secret text NOT NULL DEFAULT '',
+    required boolean NOT NULL DEFAULT false,
+    created_at timestamptz NOT NULL,
+    updated_at timestamptz NOT NULL
+);
+
+CREATE TABLE sso_logins (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    team_id uuid NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    state_hash bytea NOT NULL UNIQUE,
+    device_id_hash bytea NOT NULL,
+    nonce text NOT NULL,
+    code_verifier text NOT NULL DEFAULT '',
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz NOT NULL,
+    email text NULL,
+    handoff_token_hash bytea NULL UNIQUE,
+    authenticated_at timestamptz NULL,
+    completed_at timestamptz NULL
+);
+
+CREATE INDEX sso_logins_expires_at_idx ON sso_logins (expires_at);
@@ -0,0 +1,8 @@
+DELETE FROM team_sso_providers
+WHERE protocol = 'saml';
+
+ALTER TABLE team_sso_providers
+    DROP COLUMN IF EXISTS saml_idp_metadata,
+    DROP COLUMN IF EXISTS saml_idp_entity_id,
+    DROP CONSTRAINT team_sso_providers_protocol_check,
+    ADD CONSTRAINT team_sso_providers_protocol_check CHECK (protocol IN ('oidc'));
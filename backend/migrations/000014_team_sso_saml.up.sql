@@ -0,0 +1,5 @@
+ALTER TABLE team_sso_providers
+    DROP CONSTRAINT team_sso_providers_protocol_check,
+    ADD CONSTRAINT team_sso_providers_protocol_check CHECK (protocol IN ('oidc', 'saml')),
+    ADD COLUMN saml_idp_entity_id text NOT NULL DEFAULT '',
+    ADD COLUMN saml_idp_metadata text NOT NULL DEFAULT '';
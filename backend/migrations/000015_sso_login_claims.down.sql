@@ -0,0 +1,2 @@
+ALTER TABLE sso_logins
+    DROP COLUMN IF EXISTS claimed_at;
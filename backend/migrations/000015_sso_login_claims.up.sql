@@ -0,0 +1,2 @@
+ALTER TABLE sso_logins
+    ADD COLUMN claimed_at timestamptz NULL;
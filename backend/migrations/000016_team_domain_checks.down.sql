@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS team_domains_checked_at_idx;
+
+ALTER TABLE team_domains
+    DROP COLUMN IF EXISTS checked_at;
@@ -0,0 +1,9 @@
+ALTER TABLE team_domains
+    ADD COLUMN checked_at timestamptz NULL;
+
+UPDATE team_domains
+SET checked_at = verified_at
+WHERE verified_at IS NOT NULL;
+
+CREATE INDEX team_domains_checked_at_idx ON team_domains (checked_at)
+    WHERE verified_at IS NOT NULL;
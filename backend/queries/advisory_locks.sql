@@ -0,0 +1,5 @@
+-- name: AdvisoryUnlock :exec
+SELECT pg_advisory_unlock(sqlc.arg(lock_key)::bigint);
+
+-- name: TryAdvisoryLock :one
+SELECT pg_try_advisory_lock(sqlc.arg(lock_key)::bigint);
@@ -6,17 +6,37 @@ INSERT INTO auth_sessions (
     access_expires_at,
     refresh_token_hash,
     refresh_expires_at,
+    device_name,
+    platform,
+    app_version,
+    family_id,
+    parent_id,
     created_at
 )
-VALUES ($1, $2, $3, $4, $5, $6, now())
+VALUES (
+    @user_id,
+    @device_id_hash,
+    @access_token_hash,
+    @access_expires_at,
+    @refresh_token_hash,
+    @refresh_expires_at,
+    @device_name,
+    @platform,
+    @app_version,
+    COALESCE(sqlc.narg(family_id)::uuid, gen_random_uuid()),
+    sqlc.narg(parent_id),
+    now()
+)
 RETURNING id, user_id, device_id_hash, access_token_hash, access_expires_at,
           refresh_token_hash, refresh_expires_at, rotated_at, revoked_at,
-          last_used_at, created_at;
+          last_used_at, created_at, family_id, parent_id,
+          device_name, platform, app_version;
 
 -- name: GetAuthSessionByAccessHash :one
 SELECT id, user_id, device_id_hash, access_token_hash, access_expires_at,
        refresh_token_hash, refresh_expires_at, rotated_at, revoked_at,
-       last_used_at, created_at
+       last_used_at, created_at, family_id, parent_id,
+       device_name, platform, app_version
 FROM auth_sessions
 WHERE access_token_hash = $1
   AND access_expires_at > $2
@@ -25,18 +45,40 @@ WHERE access_token_hash = $1
 -- name: GetAuthSessionByRefreshHash :one
 SELECT id, user_id, device_id_hash, access_token_hash, access_expires_at,
        refresh_token_hash, refresh_expires_at, rotated_at, revoked_at,
-       last_used_at, created_at
+       last_used_at, created_at, family_id, parent_id,
+       device_name, platform, app_version
 FROM auth_sessions
 WHERE refresh_token_hash = $1
   AND refresh_expires_at > $2
   AND revoked_at IS NULL;
 
+-- name: GetAuthSessionByRefreshHashForUpdate :one
+SELECT id, user_id, device_id_hash, access_token_hash, access_expires_at,
+       refresh_token_hash, refresh_expires_at, rotated_at, revoked_at,
+       last_used_at, created_at, family_id, parent_id,
+       device_name, platform, app_version
+FROM auth_sessions
+WHERE refresh_token_hash = $1
+  AND refresh_expires_at > $2
+  AND revoked_at IS NULL
+FOR UPDATE;
+
+-- name: ListActiveAuthSessions :many
+SELECT DISTINCT ON (family_id)
+    family_id, device_name, platform, app_version, created_at, last_used_at
+FROM auth_sessions
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND rotated_at IS NULL
+  AND refresh_expires_at > $2
+ORDER BY family_id, created_at DESC;
+
 -- name: MarkAuthSessionUsed :exec
 UPDATE auth_sessions
 SET last_used_at = $2
 WHERE id = $1;
 
--- name: RotateAuthSession :exec
+-- name: RotateAuthSession :execrows
 UPDATE auth_sessions
 SET rotated_at = $2
 WHERE id = $1
@@ -47,3 +89,42 @@ WHERE id = $1
 UPDATE auth_sessions
 SET revoked_at = $2
 WHERE id = $1;
+
+-- name: RevokeAuthSessionFamily :execrows
+UPDATE auth_sessions
+SET revoked_at = $2
+WHERE family_id = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeOtherUserAuthSessions :execrows
+UPDATE auth_sessions
+SET revoked_at = $3
+WHERE user_id = $1
+  AND family_id <> $2
+  AND revoked_at IS NULL;
+
+-- name: RevokeSiblingAuthSessions :exec
+UPDATE auth_sessions
+SET revoked_at = $3
+WHERE parent_id = $1
+  AND id <> $2
+  AND rotated_at IS NULL
+  AND revoked_at IS NULL;
+
+-- name: RevokeUserAuthSessionFamily :execrows
+UPDATE auth_sessions
+SET revoked_at = $3
+WHERE user_id = $1
+  AND family_id = $2
+  AND revoked_at IS NULL;
+
+-- name: RevokeUserAuthSessions :exec
+UPDATE auth_sessions
+SET revoked_at = $2
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: DeleteStaleAuthSessions :execrows
+DELETE FROM auth_sessions
+WHERE refresh_expires_at < $1
+   OR revoked_at < $2;
@@ -0,0 +1,82 @@
+-- name: CreateDevicePairing :one
+INSERT INTO device_pairings (
+    user_id,
+    session_id,
+    code,
+    expires_at,
+    created_at
+)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING id, user_id, session_id, code, expires_at, created_at, claim_token_hash, device_id_hash, device_name, platform, app_version, claimed_at, approved_at, completed_at;
+
+-- name: GetDevicePairing :one
+SELECT id, user_id, session_id, code, expires_at, created_at, claim_token_hash, device_id_hash, device_name, platform, app_version, claimed_at, approved_at, completed_at
+FROM device_pairings
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: ClaimDevicePairing :one
+UPDATE device_pairings
+SET claim_token_hash = $2,
+    device_id_hash = $3,
+    device_name = $4,
+    platform = $5,
+    app_version = $6,
+    claimed_at = $7
+WHERE code = $1
+  AND claimed_at IS NULL
+  AND expires_at > $7
+RETURNING id, user_id, session_id, code, expires_at, created_at, claim_token_hash, device_id_hash, device_name, platform, app_version, claimed_at, approved_at, completed_at;
+
+-- name: CountClaimedDevicePairings :one
+SELECT COUNT(*)
+FROM device_pairings
+WHERE code = $1
+  AND claimed_at IS NOT NULL
+  AND expires_at > $2;
+
+-- name: ApproveDevicePairing :one
+UPDATE device_pairings
+SET approved_at = $3
+WHERE id = $1
+  AND user_id = $2
+  AND session_id IN (
+      SELECT id
+      FROM auth_sessions
+      WHERE family_id = $4
+  )
+  AND claimed_at IS NOT NULL
+  AND approved_at IS NULL
+  AND expires_at > $3
+RETURNING id, user_id, session_id, code, expires_at, created_at, claim_token_hash, device_id_hash, device_name, platform, app_version, claimed_at, approved_at, completed_at;
+
+-- name: GetDevicePairingByClaimTokenForUpdate :one
+SELECT id, user_id, session_id, code, expires_at, created_at, claim_token_hash, device_id_hash, device_name, platform, app_version, claimed_at, approved_at, completed_at
+FROM device_pairings
+WHERE claim_token_hash = $1
+  AND expires_at > $2
+FOR UPDATE;
+
+-- name: CountActivePairingSessions :one
+SELECT COUNT(*)
+FROM auth_sessions origin
+JOIN auth_sessions s ON s.family_id = origin.family_id
+WHERE origin.id = sqlc.arg(session_id)
+  AND s.revoked_at IS NULL
+  AND s.rotated_at IS NULL
+  AND s.refresh_expires_at > sqlc.arg(now);
+
+-- name: CompleteDevicePairing :exec
+UPDATE device_pairings
+SET completed_at = $2
+WHERE id = $1;
+
+-- name: DeleteDevicePairing :execrows
+DELETE FROM device_pairings
+WHERE id = $1
+  AND user_id = $2
+  AND completed_at IS NULL;
+
+-- name: DeleteExpiredDevicePairings :execrows
+DELETE FROM device_pairings
+WHERE expires_at < $1;
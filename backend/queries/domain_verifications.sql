@@ -0,0 +1,80 @@
+-- name: UpsertDomainVerification :one
+INSERT INTO domain_verifications (
+    team_id,
+    user_id,
+    domain,
+    token,
+    created_at
+)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (team_id, user_id) DO UPDATE
+SET domain = EXCLUDED.domain
+RETURNING id, team_id, user_id, domain, token, created_at, verified_at, checked_at;
+
+-- name: GetDomainVerification :one
+SELECT id, team_id, user_id, domain, token, created_at, verified_at, checked_at
+FROM domain_verifications
+WHERE team_id = $1
+  AND user_id = $2;
+
+-- name: GetVerifiedDomainVerification :one
+SELECT id, team_id, user_id, domain, token, created_at, verified_at, checked_at
+FROM domain_verifications
+WHERE team_id = $1
+  AND domain = $2
+  AND verified_at IS NOT NULL
+ORDER BY verified_at DESC
+LIMIT 1;
+
+-- name: MarkDomainVerified :one
+UPDATE domain_verifications
+SET verified_at = $2,
+    checked_at = $2
+WHERE id = $1
+RETURNING id, team_id, user_id, domain, token, created_at, verified_at, checked_at;
+
+-- name: TouchDomainVerification :exec
+UPDATE domain_verifications
+SET checked_at = $2
+WHERE id = $1;
+
+-- name: SetTeamDomainVerified :exec
+UPDATE teams
+SET domain_verified_at = $2,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: DemoteOtherTeamAdmins :execrows
+UPDATE team_memberships
+SET role = 'member'
+WHERE team_id = $1
+  AND user_id <> $2
+  AND role = 'admin';
+
+-- name: ListDueDomainVerifications :many
+SELECT v.id, v.team_id, v.user_id, v.domain, v.token, v.created_at, v.verified_at, v.checked_at
+FROM domain_verifications v
+JOIN teams t ON t.id = v.team_id
+WHERE v.verified_at IS NOT NULL
+  AND t.domain_verified_at IS NOT NULL
+  AND (v.checked_at IS NULL OR v.checked_at < @checked_before)
+ORDER BY v.checked_at NULLS FIRST
+LIMIT @batch_size;
+
+-- name: RevokeDomainVerification :exec
+WITH revoked AS (
+    UPDATE domain_verifications
+    SET verified_at = NULL,
+        checked_at = $2
+    WHERE id = $1
+    RETURNING team_id
+)
+UPDATE teams
+SET domain_verified_at = NULL,
+    updated_at = now()
+WHERE id IN (SELECT team_id FROM revoked);
+
+-- name: DeleteTeamDomainVerifications :execrows
+DELETE FROM domain_verifications
+WHERE team_id = $1
+  AND domain = $2;
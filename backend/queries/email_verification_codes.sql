@@ -18,7 +18,19 @@ WHERE email = $1
 ORDER BY created_at DESC
 LIMIT 1;
 
+-- name: GetEmailVerificationCodeByIDForUpdate :one
+SELECT id, email, code, expires_at, used_at, created_at
+FROM email_verification_codes
+WHERE id = $1
+  AND expires_at > $2
+  AND used_at IS NULL
+FOR UPDATE;
+
 -- name: MarkEmailVerificationCodeUsed :exec
 UPDATE email_verification_codes
 SET used_at = $2
 WHERE id = $1;
+
+-- name: DeleteExpiredEmailVerificationCodes :execrows
+DELETE FROM email_verification_codes
+WHERE expires_at < $1;
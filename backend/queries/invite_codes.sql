@@ -0,0 +1,47 @@
+-- name: CreateInviteCode :one
+INSERT INTO invite_codes (
+    team_id,
+    email,
+    code,
+    expires_at,
+    created_by_user_id,
+    created_at
+)
+VALUES ($1, $2, $3, $4, $5, now())
+RETURNING id, team_id, email, code, expires_at, redeemed_at, created_by_user_id, created_at;
+
+-- name: ListInviteCodes :many
+SELECT id, team_id, email, code, expires_at, redeemed_at, created_by_user_id, created_at
+FROM invite_codes
+WHERE team_id = $1
+  AND redeemed_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: RefreshInviteCode :one
+UPDATE invite_codes
+SET code = $3,
+    expires_at = $4
+WHERE id = $1
+  AND team_id = $2
+  AND redeemed_at IS NULL
+RETURNING id, team_id, email, code, expires_at, redeemed_at, created_by_user_id, created_at;
+
+-- name: DeleteInviteCode :execrows
+DELETE FROM invite_codes
+WHERE id = $1
+  AND team_id = $2
+  AND redeemed_at IS NULL;
+
+-- name: RedeemInviteCode :one
+UPDATE invite_codes
+SET redeemed_at = $3
+WHERE code = $1
+  AND email = $2
+  AND expires_at > $3
+  AND redeemed_at IS NULL
+RETURNING id, team_id, email, code, expires_at, redeemed_at, created_by_user_id, created_at;
+
+-- name: DeleteStaleInviteCodes :execrows
+DELETE FROM invite_codes
+WHERE expires_at < $1
+   OR redeemed_at < $1;
@@ -0,0 +1,62 @@
+-- name: DeleteExpiredAttemptLimits :execrows
+DELETE FROM attempt_limits
+WHERE reset_at < @now::timestamptz
+  AND (lock_until IS NULL OR lock_until < @now::timestamptz);
+
+-- name: DeleteExpiredRateLimitCounters :execrows
+DELETE FROM rate_limit_counters
+WHERE window_start < $1;
+
+-- name: GetAttemptLockUntil :one
+SELECT lock_until
+FROM attempt_limits
+WHERE key = $1;
+
+-- name: GetRateLimitCounts :one
+SELECT
+    COALESCE(SUM(count) FILTER (WHERE window_start = @current_window::timestamptz), 0)::integer AS current_count,
+    COALESCE(SUM(count) FILTER (WHERE window_start = @previous_window::timestamptz), 0)::integer AS previous_count
+FROM rate_limit_counters
+WHERE key = @key
+  AND window_start IN (@current_window::timestamptz, @previous_window::timestamptz);
+
+-- name: IncrementAttemptLimit :one
+INSERT INTO attempt_limits AS al (key, count, reset_at)
+VALUES (@key, 1, @reset_at)
+ON CONFLICT (key) DO UPDATE
+SET count = CASE WHEN al.reset_at < @now::timestamptz THEN 1 ELSE LEAST(al.count + 1, @max_attempts::integer + 1) END,
+    reset_at = CASE WHEN al.reset_at < @now::timestamptz THEN EXCLUDED.reset_at ELSE al.reset_at END
+RETURNING count;
+
+-- name: IncrementRateLimitCounter :exec
+INSERT INTO rate_limit_counters (key, window_start, count)
+VALUES ($1, $2, 1)
+ON CONFLICT (key, window_start) DO UPDATE
+SET count = rate_limit_counters.count + 1;
+
+-- name: RegisterAttemptFailure :one
+INSERT INTO attempt_limits AS al (key, count, reset_at, lock_until)
+VALUES (
+    @key,
+    1,
+    @reset_at,
+    CASE WHEN @max_attempts::integer <= 1 THEN @lock_until::timestamptz END
+)
+ON CONFLICT (key) DO UPDATE
+SET count = CASE
+        WHEN al.reset_at < @now::timestamptz THEN 1
+        WHEN al.lock_until > @now::timestamptz THEN al.count
+        ELSE al.count + 1
+    END,
+    lock_until = CASE
+        WHEN al.reset_at < @now::timestamptz THEN EXCLUDED.lock_until
+        WHEN al.lock_until > @now::timestamptz THEN al.lock_until
+        WHEN al.count + 1 >= @max_attempts::integer THEN @lock_until::timestamptz
+        ELSE al.lock_until
+    END,
+    reset_at = CASE WHEN al.reset_at < @now::timestamptz THEN EXCLUDED.reset_at ELSE al.reset_at END
+RETURNING lock_until;
+
+-- name: ResetAttemptLimit :exec
+DELETE FROM attempt_limits
+WHERE key = $1;
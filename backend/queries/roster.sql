@@ -0,0 +1,29 @@
+-- name: ListTeamRoster :many
+SELECT
+    u.id AS user_id,
+    u.email,
+    tm.role,
+    ts.timezone,
+    ts.utc_offset_minutes,
+    ts.country_code,
+    ts.reported_at,
+    wh.start_minute,
+    wh.end_minute,
+    wh.working_days,
+    tv.hidden_until,
+    tv.hidden_indefinitely
+FROM team_memberships tm
+JOIN users u ON u.id = tm.user_id
+JOIN timezone_states ts ON ts.user_id = tm.user_id
+LEFT JOIN working_hours wh ON wh.user_id = tm.user_id
+LEFT JOIN timezone_visibility tv ON tv.user_id = tm.user_id
+WHERE tm.team_id = @team_id
+  AND (
+      @include_hidden::boolean
+      OR tm.user_id = @viewer_id
+      OR tv.user_id IS NULL
+      OR (
+          tv.hidden_indefinitely = false
+          AND (tv.hidden_until IS NULL OR tv.hidden_until <= @now::timestamptz)
+      )
+  );
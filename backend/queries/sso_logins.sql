@@ -0,0 +1,44 @@
+-- name: CreateSsoLogin :one
+INSERT INTO sso_logins (
+    team_id,
+    state_hash,
+    device_id_hash,
+    nonce,
+    code_verifier,
+    expires_at,
+    created_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, team_id, state_hash, device_id_hash, nonce, code_verifier, expires_at, created_at, email, handoff_token_hash, authenticated_at, completed_at, claimed_at;
+
+-- name: ClaimSsoLogin :one
+UPDATE sso_logins
+SET claimed_at = $3
+WHERE state_hash = $1
+  AND expires_at > $2
+  AND claimed_at IS NULL
+RETURNING id, team_id, state_hash, device_id_hash, nonce, code_verifier, expires_at, created_at, email, handoff_token_hash, authenticated_at, completed_at, claimed_at;
+
+-- name: AuthenticateSsoLogin :exec
+UPDATE sso_logins
+SET email = $2,
+    handoff_token_hash = $3,
+    authenticated_at = $4
+WHERE id = $1;
+
+-- name: GetSsoLoginByHandoffForUpdate :one
+SELECT id, team_id, state_hash, device_id_hash, nonce, code_verifier, expires_at, created_at, email, handoff_token_hash, authenticated_at, completed_at, claimed_at
+FROM sso_logins
+WHERE handoff_token_hash = $1
+  AND expires_at > $2
+  AND completed_at IS NULL
+FOR UPDATE;
+
+-- name: CompleteSsoLogin :exec
+UPDATE sso_logins
+SET completed_at = $2
+WHERE id = $1;
+
+-- name: DeleteExpiredSsoLogins :execrows
+DELETE FROM sso_logins
+WHERE expires_at < $1;
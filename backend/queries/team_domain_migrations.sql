@@ -0,0 +1,32 @@
+-- name: CreateTeamDomainMigration :one
+INSERT INTO team_domain_migrations (
+    team_id,
+    from_domain,
+    to_domain,
+    status,
+    created_by_user_id,
+    created_at
+)
+VALUES ($1, $2, $3, 'active', $4, $5)
+RETURNING id, team_id, from_domain, to_domain, status, created_by_user_id, created_at, finished_at;
+
+-- name: GetActiveTeamDomainMigration :one
+SELECT id, team_id, from_domain, to_domain, status, created_by_user_id, created_at, finished_at
+FROM team_domain_migrations
+WHERE team_id = $1
+  AND status = 'active';
+
+-- name: FinishTeamDomainMigration :one
+UPDATE team_domain_migrations
+SET status = $2,
+    finished_at = $3
+WHERE id = $1
+  AND status = 'active'
+RETURNING id, team_id, from_domain, to_domain, status, created_by_user_id, created_at, finished_at;
+
+-- name: CountTeamMembersAtDomain :one
+SELECT COUNT(*)
+FROM team_memberships m
+JOIN users u ON u.id = m.user_id
+WHERE m.team_id = sqlc.arg(team_id)
+  AND (u.email_domain = sqlc.arg(domain)::text OR u.email_domain LIKE '%.' || sqlc.arg(domain)::text);
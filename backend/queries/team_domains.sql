@@ -0,0 +1,68 @@
+-- name: ListTeamDomains :many
+SELECT id, team_id, domain, is_primary, token, created_at, verified_at, checked_at
+FROM team_domains
+WHERE team_id = $1
+ORDER BY is_primary DESC, domain;
+
+-- name: GetTeamDomain :one
+SELECT id, team_id, domain, is_primary, token, created_at, verified_at, checked_at
+FROM team_domains
+WHERE team_id = $1
+  AND domain = $2;
+
+-- name: CreateTeamDomain :one
+INSERT INTO team_domains (
+    team_id,
+    domain,
+    token,
+    created_at
+)
+VALUES ($1, $2, $3, $4)
+RETURNING id, team_id, domain, is_primary, token, created_at, verified_at, checked_at;
+
+-- name: VerifyTeamDomain :one
+UPDATE team_domains
+SET verified_at = $2,
+    checked_at = $2
+WHERE id = $1
+RETURNING id, team_id, domain, is_primary, token, created_at, verified_at, checked_at;
+
+-- name: SetTeamDomainVerification :exec
+UPDATE team_domains
+SET token = $2,
+    verified_at = $3,
+    checked_at = $4
+WHERE id = $1;
+
+-- name: DeleteTeamDomain :execrows
+DELETE FROM team_domains
+WHERE team_id = $1
+  AND domain = $2
+  AND NOT is_primary;
+
+-- name: ListDueTeamDomains :many
+SELECT id, team_id, domain, is_primary, token, created_at, verified_at, checked_at
+FROM team_domains
+WHERE verified_at IS NOT NULL
+  AND token IS NOT NULL
+  AND (checked_at IS NULL OR checked_at < @checked_before)
+ORDER BY checked_at NULLS FIRST
+LIMIT @batch_size;
+
+-- name: TouchTeamDomain :exec
+UPDATE team_domains
+SET checked_at = $2
+WHERE id = $1;
+
+-- name: RevokeTeamDomain :exec
+WITH revoked AS (
+    UPDATE team_domains
+    SET verified_at = NULL,
+        checked_at = $2
+    WHERE id = $1
+    RETURNING team_id, is_primary
+)
+UPDATE teams
+SET domain_verified_at = NULL,
+    updated_at = now()
+WHERE id IN (SELECT team_id FROM revoked WHERE is_primary);
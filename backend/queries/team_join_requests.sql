@@ -0,0 +1,49 @@
+-- name: CreateTeamJoinRequest :one
+INSERT INTO team_join_requests (
+    team_id,
+    user_id,
+    status,
+    created_at
+)
+VALUES ($1, $2, 'pending', $3)
+ON CONFLICT (team_id, user_id) DO UPDATE
+SET status = CASE WHEN team_join_requests.status = 'rejected' THEN 'rejected' ELSE 'pending' END,
+    created_at = CASE WHEN team_join_requests.status = 'approved' THEN EXCLUDED.created_at ELSE team_join_requests.created_at END,
+    decided_at = CASE WHEN team_join_requests.status = 'rejected' THEN team_join_requests.decided_at END,
+    decided_by_user_id = CASE WHEN team_join_requests.status = 'rejected' THEN team_join_requests.decided_by_user_id END
+RETURNING id, team_id, user_id, status, created_at, decided_at, decided_by_user_id;
+
+-- name: GetLatestTeamJoinRequestByUserID :one
+SELECT id, team_id, user_id, status, created_at, decided_at, decided_by_user_id
+FROM team_join_requests
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: ListPendingTeamJoinRequests :many
+SELECT r.id, r.user_id, u.email, r.created_at
+FROM team_join_requests r
+JOIN users u ON u.id = r.user_id
+WHERE r.team_id = $1
+  AND r.status = 'pending'
+  AND NOT EXISTS (
+      SELECT 1
+      FROM team_memberships m
+      WHERE m.user_id = r.user_id
+  )
+ORDER BY r.created_at;
+
+-- name: GetTeamJoinRequestForUpdate :one
+SELECT id, team_id, user_id, status, created_at, decided_at, decided_by_user_id
+FROM team_join_requests
+WHERE id = $1
+  AND team_id = $2
+FOR UPDATE;
+
+-- name: DecideTeamJoinRequest :one
+UPDATE team_join_requests
+SET status = $2,
+    decided_at = $3,
+    decided_by_user_id = $4
+WHERE id = $1
+RETURNING id, team_id, user_id, status, created_at, decided_at, decided_by_user_id;
@@ -14,3 +14,28 @@ SELECT id, team_id, user_id, role, joined_at, created_at
 FROM team_memberships
 WHERE team_id = $1
   AND user_id = $2;
+
+-- name: GetTeamMembershipByUserID :one
+SELECT id, team_id, user_id, role, joined_at, created_at
+FROM team_memberships
+WHERE user_id = $1
+ORDER BY joined_at ASC
+LIMIT 1;
+
+-- name: UpdateTeamMembershipRole :one
+UPDATE team_memberships
+SET role = $3
+WHERE team_id = $1
+  AND user_id = $2
+RETURNING id, team_id, user_id, role, joined_at, created_at;
+
+-- name: DeleteTeamMembership :execrows
+DELETE FROM team_memberships
+WHERE team_id = $1
+  AND user_id = $2;
+
+-- name: CountTeamAdmins :one
+SELECT COUNT(*)
+FROM team_memberships
+WHERE team_id = $1
+  AND role = 'admin';
@@ -0,0 +1,33 @@
+-- name: GetTeamSsoProvider :one
+SELECT team_id, protocol, oidc_issuer, oidc_client_id, oidc_client_secret, saml_idp_entity_id, saml_idp_metadata, required, created_at, updated_at
+FROM team_sso_providers
+WHERE team_id = $1;
+
+-- name: UpsertTeamSsoProvider :one
+INSERT INTO team_sso_providers (
+    team_id,
+    protocol,
+    oidc_issuer,
+    oidc_client_id,
+    oidc_client_secret,
+    saml_idp_entity_id,
+    saml_idp_metadata,
+    required,
+    created_at,
+    updated_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+ON CONFLICT (team_id) DO UPDATE
+SET protocol = EXCLUDED.protocol,
+    oidc_issuer = EXCLUDED.oidc_issuer,
+    oidc_client_id = EXCLUDED.oidc_client_id,
+    oidc_client_secret = EXCLUDED.oidc_client_secret,
+    saml_idp_entity_id = EXCLUDED.saml_idp_entity_id,
+    saml_idp_metadata = EXCLUDED.saml_idp_metadata,
+    required = EXCLUDED.required,
+    updated_at = EXCLUDED.updated_at
+RETURNING team_id, protocol, oidc_issuer, oidc_client_id, oidc_client_secret, saml_idp_entity_id, saml_idp_metadata, required, created_at, updated_at;
+
+-- name: DeleteTeamSsoProvider :execrows
+DELETE FROM team_sso_providers
+WHERE team_id = $1;
@@ -1,7 +1,9 @@
 -- name: GetTeamByDomain :one
-SELECT id, domain, name, created_at, updated_at
-FROM teams
-WHERE domain = $1;
+SELECT t.id, t.domain, t.name, t.created_at, t.updated_at, t.include_subdomains, t.join_policy, t.domain_verified_at
+FROM teams t
+JOIN team_domains d ON d.team_id = t.id
+WHERE d.domain = $1
+  AND (d.is_primary OR d.verified_at IS NOT NULL);
 
 -- name: CreateTeam :one
 INSERT INTO teams (
@@ -11,9 +13,40 @@ INSERT INTO teams (
     updated_at
 )
 VALUES ($1, $2, now(), now())
-RETURNING id, domain, name, created_at, updated_at;
+RETURNING id, domain, name, created_at, updated_at, include_subdomains, join_policy, domain_verified_at;
 
 -- name: CountTeamMembers :one
 SELECT COUNT(*)
 FROM team_memberships
 WHERE team_id = $1;
+
+-- name: DeleteTeam :exec
+DELETE FROM teams
+WHERE id = $1;
+
+-- name: GetTeamByID :one
+SELECT id, domain, name, created_at, updated_at, include_subdomains, join_policy, domain_verified_at
+FROM teams
+WHERE id = $1;
+
+-- name: GetTeamByIDForUpdate :one
+SELECT id, domain, name, created_at, updated_at, include_subdomains, join_policy, domain_verified_at
+FROM teams
+WHERE id = $1
+FOR UPDATE;
+
+-- name: UpdateTeamSettings :one
+UPDATE teams
+SET name = $2,
+    include_subdomains = $3,
+    join_policy = $4,
+    updated_at = now()
+WHERE id = $1
+RETURNING id, domain, name, created_at, updated_at, include_subdomains, join_policy, domain_verified_at;
+
+-- name: SetTeamPrimaryDomain :one
+UPDATE teams
+SET domain = $2,
+    updated_at = now()
+WHERE id = $1
+RETURNING id, domain, name, created_at, updated_at, include_subdomains, join_policy, domain_verified_at;
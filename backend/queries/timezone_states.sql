@@ -0,0 +1,29 @@
+-- name: GetTimezoneState :one
+SELECT user_id, timezone, utc_offset_minutes, country_code, reported_at, updated_at
+FROM timezone_states
+WHERE user_id = $1;
+
+-- name: UpsertTimezoneState :one
+INSERT INTO timezone_states (
+    user_id,
+    timezone,
+    utc_offset_minutes,
+    country_code,
+    reported_at,
+    updated_at
+)
+VALUES ($1, $2, $3, $4, $5, now())
+ON CONFLICT (user_id) DO UPDATE
+SET timezone = EXCLUDED.timezone,
+    utc_offset_minutes = EXCLUDED.utc_offset_minutes,
+    country_code = EXCLUDED.country_code,
+    reported_at = EXCLUDED.reported_at,
+    updated_at = CASE
+        WHEN timezone_states.timezone = EXCLUDED.timezone
+         AND timezone_states.utc_offset_minutes = EXCLUDED.utc_offset_minutes
+         AND timezone_states.country_code = EXCLUDED.country_code
+        THEN timezone_states.updated_at
+        ELSE now()
+    END
+WHERE timezone_states.reported_at < EXCLUDED.reported_at
+RETURNING user_id, timezone, utc_offset_minutes, country_code, reported_at, updated_at;
@@ -0,0 +1,53 @@
+-- name: GetTimezoneVisibility :one
+SELECT user_id, hidden_until, hidden_indefinitely, last_reminder_at, updated_at
+FROM timezone_visibility
+WHERE user_id = $1;
+
+-- name: UpsertTimezoneVisibility :one
+INSERT INTO timezone_visibility (
+    user_id,
+    hidden_until,
+    hidden_indefinitely,
+    last_reminder_at,
+    updated_at
+)
+VALUES ($1, $2, $3, NULL, now())
+ON CONFLICT (user_id) DO UPDATE
+SET hidden_until = EXCLUDED.hidden_until,
+    hidden_indefinitely = EXCLUDED.hidden_indefinitely,
+    last_reminder_at = NULL,
+    updated_at = now()
+RETURNING user_id, hidden_until, hidden_indefinitely, last_reminder_at, updated_at;
+
+-- name: ClaimDueHiddenReminders :many
+WITH due AS (
+    SELECT tv.user_id, tv.last_reminder_at AS previous_reminder_at
+    FROM timezone_visibility tv
+    WHERE tv.hidden_indefinitely = true
+      AND COALESCE(tv.last_reminder_at, tv.updated_at) <= @due_before::timestamptz
+    ORDER BY tv.user_id
+    LIMIT @batch_size::integer
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE timezone_visibility
+SET last_reminder_at = @claimed_at::timestamptz
+FROM due
+JOIN users u ON u.id = due.user_id
+WHERE timezone_visibility.user_id = due.user_id
+RETURNING timezone_visibility.user_id, u.email, due.previous_reminder_at;
+
+-- name: RestoreHiddenReminder :exec
+UPDATE timezone_visibility
+SET last_reminder_at = @previous_reminder_at
+WHERE user_id = @user_id
+  AND last_reminder_at = @claimed_at::timestamptz;
+
+-- name: ResumeSharingFromReminder :execrows
+UPDATE timezone_visibility
+SET hidden_until = NULL,
+    hidden_indefinitely = false,
+    last_reminder_at = NULL,
+    updated_at = now()
+WHERE user_id = $1
+  AND hidden_indefinitely = true
+  AND last_reminder_at = $2;
@@ -25,3 +25,11 @@ INSERT INTO users (
 )
 VALUES ($1, $2, $3, now(), now())
 RETURNING id, email, email_domain, email_verified_at, created_at, updated_at;
+
+-- name: UpdateUserEmail :one
+UPDATE users
+SET email = $2,
+    email_domain = $3,
+    updated_at = now()
+WHERE id = $1
+RETURNING id, email, email_domain, email_verified_at, created_at, updated_at;
@@ -0,0 +1,20 @@
+-- name: GetWorkingHours :one
+SELECT user_id, start_minute, end_minute, created_at, updated_at, working_days
+FROM working_hours
+WHERE user_id = $1;
+
+-- name: UpsertWorkingHours :one
+INSERT INTO working_hours (
+    user_id,
+    start_minute,
+    end_minute,
+    working_days,
+    updated_at
+)
+VALUES ($1, $2, $3, $4, now())
+ON CONFLICT (user_id) DO UPDATE
+SET start_minute = EXCLUDED.start_minute,
+    end_minute = EXCLUDED.end_minute,
+    working_days = EXCLUDED.working_days,
+    updated_at = now()
+RETURNING user_id, start_minute, end_minute, created_at, updated_at, working_days;